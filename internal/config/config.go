@@ -18,6 +18,21 @@ type ServerConfig struct {
 	Log             *log    `json:"log,omitempty" yaml:"log,omitempty"`
 	Github          *Github `json:"github,omitempty" yaml:"github,omitempty"`
 	LogDataSaveDays int     `json:"logDataSaveDays,omitempty" yaml:"logDataSaveDays,omitempty"`
+	Node            *Node   `json:"node,omitempty" yaml:"node,omitempty"`
+}
+
+// Node 节点配置
+type Node struct {
+	TLS *NodeTLS `json:"tls,omitempty" yaml:"tls,omitempty"`
+}
+
+// NodeTLS 节点之间的传输加密，开启后所有节点需要使用 TLS，并通过 CA 或证书指纹双向验证
+type NodeTLS struct {
+	Open         bool     `json:"open,omitempty" yaml:"open,omitempty"`
+	Cert         string   `json:"cert,omitempty" yaml:"cert,omitempty"`
+	Key          string   `json:"key,omitempty" yaml:"key,omitempty"`
+	CA           string   `json:"ca,omitempty" yaml:"ca,omitempty"`
+	Fingerprints []string `json:"fingerprints,omitempty" yaml:"fingerprints,omitempty"`
 }
 
 type server struct {
@@ -15,12 +15,15 @@ func NewSetting() (setting *Setting) {
 
 	setting.TerminalLocalEnable = true
 	setting.TerminalNodeEnable = true
+	setting.TerminalReattachSeconds = 300
 
 	setting.FileManagerLocalEnable = true
 	setting.FileManagerNodeEnable = true
 
 	setting.LogRetentionDays = 0
 
+	setting.PortForwardPublicListen = false
+
 	return
 }
 
@@ -31,12 +34,17 @@ type Setting struct {
 
 	TerminalLocalEnable bool `json:"terminalLocalEnable"` // 启用 本地终端  默认启用
 	TerminalNodeEnable  bool `json:"terminalNodeEnable"`  // 启用 节点终端  默认启用
+	TerminalRecordInput bool `json:"terminalRecordInput"` // 终端录像 记录用户输入 默认关闭（输入可能包含密码）
+
+	TerminalReattachSeconds int `json:"terminalReattachSeconds"` // 终端 连接断开后 会话保留秒数 期间可重新连接 默认 300 0 断开即关闭
 
 	FileManagerLocalEnable bool `json:"fileManagerLocalEnable"` // 启用 本地文件管理器 默认启用
 	FileManagerNodeEnable  bool `json:"fileManagerNodeEnable"`  // 启用 节点文件管理器 默认启用
 
 	LogRetentionDays int `json:"logRetentionDays"` // 日志 保留天数 默认 0 一直保留
 
+	PortForwardPublicListen bool `json:"portForwardPublicListen"` // 端口转发 允许监听非本机地址 默认关闭 只能监听 127.0.0.1
+
 	StandAloneUserId int64 `json:"standAloneUserId"` // StandAloneUserId 单机版本 用户 ID
 }
 
@@ -56,6 +64,16 @@ func (this_ *Setting) Set(name string, value interface{}) (find bool, err error)
 	case "terminalNodeEnable":
 		this_.TerminalNodeEnable = util.IsTrue(value)
 		break
+	case "terminalRecordInput":
+		this_.TerminalRecordInput = util.IsTrue(value)
+		break
+	case "terminalReattachSeconds":
+		sv := util.GetStringValue(value)
+		if sv == "" {
+			sv = "0"
+		}
+		this_.TerminalReattachSeconds, err = strconv.Atoi(sv)
+		break
 
 	case "fileManagerLocalEnable":
 		this_.FileManagerLocalEnable = util.IsTrue(value)
@@ -72,6 +90,10 @@ func (this_ *Setting) Set(name string, value interface{}) (find bool, err error)
 		this_.LogRetentionDays, err = strconv.Atoi(sv)
 		break
 
+	case "portForwardPublicListen":
+		this_.PortForwardPublicListen = util.IsTrue(value)
+		break
+
 	case "standAloneUserId":
 		sv := util.GetStringValue(value)
 		if sv == "" {
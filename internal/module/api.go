@@ -14,18 +14,22 @@ import (
 	"teamide/internal/module/module_datamove"
 	"teamide/internal/module/module_elasticsearch"
 	"teamide/internal/module/module_file_manager"
+	"teamide/internal/module/module_forward"
 	"teamide/internal/module/module_http"
 	"teamide/internal/module/module_id"
 	"teamide/internal/module/module_javascript"
 	"teamide/internal/module/module_kafka"
 	"teamide/internal/module/module_log"
 	"teamide/internal/module/module_login"
+	"teamide/internal/module/module_metric"
 	"teamide/internal/module/module_mongodb"
 	"teamide/internal/module/module_net"
 	"teamide/internal/module/module_node"
 	"teamide/internal/module/module_power"
+	"teamide/internal/module/module_process"
 	"teamide/internal/module/module_redis"
 	"teamide/internal/module/module_register"
+	"teamide/internal/module/module_remote_desktop"
 	"teamide/internal/module/module_serial"
 	"teamide/internal/module/module_setting"
 	"teamide/internal/module/module_sync"
@@ -36,11 +40,17 @@ import (
 	"teamide/internal/module/module_user"
 	"teamide/internal/module/module_zookeeper"
 	"teamide/pkg/base"
+	"teamide/pkg/ssh"
 	"time"
 )
 
 func NewApi(ServerContext *context.ServerContext) (api *Api, err error) {
 
+	// SSH 主机密钥存储，所有 SSH 连接（终端、文件管理、隧道等）共用
+	ssh.SetKnownHostsStore(ssh.NewKnownHostsStore(ServerContext.ServerConfig.Server.Data + "ssh/known_hosts"))
+	// 服务模式下多个用户共用服务进程，不能使用服务器上的 ssh-agent
+	ssh.SetAgentEnabled(!ServerContext.IsServer)
+
 	api = &Api{
 		ServerContext:          ServerContext,
 		userService:            module_user.NewUserService(ServerContext),
@@ -59,6 +69,9 @@ func NewApi(ServerContext *context.ServerContext) (api *Api, err error) {
 		logService:             module_log.NewLogService(ServerContext),
 		apiCache:               make(map[string]*base.ApiWorker),
 	}
+	api.forwardService = module_forward.NewForwardService(ServerContext, api.toolboxService)
+	api.metricService = module_metric.NewMetricService(api.toolboxService, api.nodeService)
+	api.remoteDesktopService = module_remote_desktop.NewRemoteDesktopService(api.toolboxService, api.nodeService)
 	var apis []*base.ApiWorker
 	apis, err = api.GetApis()
 	if err != nil {
@@ -102,6 +115,18 @@ func NewApi(ServerContext *context.ServerContext) (api *Api, err error) {
 	if err != nil {
 		return
 	}
+	err = api.forwardService.ServerReady()
+	if err != nil {
+		return
+	}
+	err = api.metricService.ServerReady()
+	if err != nil {
+		return
+	}
+	err = api.remoteDesktopService.ServerReady()
+	if err != nil {
+		return
+	}
 
 	return
 }
@@ -128,6 +153,9 @@ type Api struct {
 	*context.ServerContext
 	toolboxService         *module_toolbox.ToolboxService
 	nodeService            *module_node.NodeService
+	forwardService         *module_forward.ForwardService
+	metricService          *module_metric.MetricService
+	remoteDesktopService   *module_remote_desktop.RemoteDesktopService
 	terminalCommandService *module_terminal.TerminalCommandService
 	userService            *module_user.UserService
 	userSettingService     *module_user.UserSettingService
@@ -192,6 +220,10 @@ func (this_ *Api) GetApis() (apis []*base.ApiWorker, err error) {
 	apis = append(apis, module_sync.NewApi(this_.toolboxService, this_.userService, this_.userSettingService).GetApis()...)
 	apis = append(apis, module_http.NewApi(this_.toolboxService).GetApis()...)
 	apis = append(apis, module_serial.NewApi(this_.toolboxService).GetApis()...)
+	apis = append(apis, module_forward.NewApi(this_.forwardService).GetApis()...)
+	apis = append(apis, module_process.NewApi(this_.toolboxService, this_.nodeService).GetApis()...)
+	apis = append(apis, module_metric.NewApi(this_.metricService).GetApis()...)
+	apis = append(apis, module_remote_desktop.NewApi(this_.remoteDesktopService).GetApis()...)
 
 	return
 }
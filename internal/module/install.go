@@ -10,6 +10,7 @@ import (
 	"teamide/internal/module/module_id"
 	"teamide/internal/module/module_log"
 	"teamide/internal/module/module_login"
+	"teamide/internal/module/module_metric"
 	"teamide/internal/module/module_node"
 	"teamide/internal/module/module_power"
 	"teamide/internal/module/module_register"
@@ -132,6 +133,11 @@ func (this_ *InstallService) Install() (err error) {
 		return
 	}
 
+	err = this_.InstallSteps(module_metric.GetInstallStages())
+	if err != nil {
+		return
+	}
+
 	return
 }
 
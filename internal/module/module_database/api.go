@@ -19,9 +19,7 @@ import (
 	_ "github.com/team-ide/go-tool/db/db_type_sqlite"
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
-	goSSH "golang.org/x/crypto/ssh"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -217,14 +215,13 @@ func getService(config *db.Config, sshConfig *ssh.Config) (res db.IService, err
 		key += "-tls-" + config.TlsClientKey
 	}
 	if sshConfig != nil {
-		key += "-ssh-" + sshConfig.Address
-		key += "-ssh-" + sshConfig.Username
+		key += "-ssh-" + sshConfig.GetChainKey()
 	}
 
 	var serviceInfo *base.ServiceInfo
 	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
 		var s db.IService
-		var sshTunnel *SSHTunnel
+		var sshTunnel *ssh.Tunnel
 
 		if sshConfig != nil {
 			// 对于不支持SSHClient的数据库类型，使用本地端口转发
@@ -232,11 +229,7 @@ func getService(config *db.Config, sshConfig *ssh.Config) (res db.IService, err
 
 			if needTunnel {
 				// 创建SSH隧道
-				sshTunnel, err = NewSSHTunnel(sshConfig, config.Host, config.Port)
-				if err != nil {
-					util.Logger.Error("create ssh tunnel error", zap.Any("key", key), zap.Error(err))
-					return
-				}
+				sshTunnel = ssh.NewTunnel(sshConfig, fmt.Sprintf("%s:%d", config.Host, config.Port))
 
 				err = sshTunnel.Start()
 				if err != nil {
@@ -1236,124 +1229,3 @@ func removeWorkerTasks(workerId string) {
 	delete(workerTasksCache, workerId)
 	return
 }
-
-// SSHTunnel SSH隧道实现，用于不支持SSHClient的数据库类型
-type SSHTunnel struct {
-	sshConfig  *ssh.Config
-	remoteHost string
-	remotePort int
-	LocalPort  int
-	listener   net.Listener
-	sshClient  *goSSH.Client
-	closed     bool
-	mu         sync.Mutex
-}
-
-// NewSSHTunnel 创建SSH隧道
-func NewSSHTunnel(sshConfig *ssh.Config, remoteHost string, remotePort int) (*SSHTunnel, error) {
-	return &SSHTunnel{
-		sshConfig:  sshConfig,
-		remoteHost: remoteHost,
-		remotePort: remotePort,
-	}, nil
-}
-
-// Start 启动SSH隧道
-func (t *SSHTunnel) Start() error {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-
-	if t.closed {
-		return errors.New("tunnel is closed")
-	}
-
-	// 创建SSH客户端
-	client, err := ssh.NewClient(*t.sshConfig)
-	if err != nil {
-		return fmt.Errorf("failed to create ssh client: %w", err)
-	}
-	t.sshClient = client
-
-	// 创建本地监听器，使用随机端口
-	listener, err := net.Listen("tcp", "127.0.0.1:0")
-	if err != nil {
-		t.sshClient.Close()
-		return fmt.Errorf("failed to listen on local port: %w", err)
-	}
-	t.listener = listener
-	t.LocalPort = listener.Addr().(*net.TCPAddr).Port
-
-	// 启动转发goroutine
-	go t.forward()
-
-	return nil
-}
-
-// forward 处理端口转发
-func (t *SSHTunnel) forward() {
-	for {
-		localConn, err := t.listener.Accept()
-		if err != nil {
-			if !t.closed {
-				util.Logger.Error("ssh tunnel accept error", zap.Error(err))
-			}
-			return
-		}
-
-		go t.handleConnection(localConn)
-	}
-}
-
-// handleConnection 处理单个连接
-func (t *SSHTunnel) handleConnection(localConn net.Conn) {
-	defer localConn.Close()
-
-	// 通过SSH连接到远程目标
-	remoteAddr := fmt.Sprintf("%s:%d", t.remoteHost, t.remotePort)
-	remoteConn, err := t.sshClient.Dial("tcp", remoteAddr)
-	if err != nil {
-		util.Logger.Error("ssh tunnel dial remote error",
-			zap.String("remoteAddr", remoteAddr),
-			zap.Error(err),
-		)
-		return
-	}
-	defer remoteConn.Close()
-
-	// 双向数据转发
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	// local -> remote
-	go func() {
-		defer wg.Done()
-		io.Copy(remoteConn, localConn)
-	}()
-
-	// remote -> local
-	go func() {
-		defer wg.Done()
-		io.Copy(localConn, remoteConn)
-	}()
-
-	wg.Wait()
-}
-
-// Close 关闭SSH隧道
-func (t *SSHTunnel) Close() {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-
-	if t.closed {
-		return
-	}
-	t.closed = true
-
-	if t.listener != nil {
-		t.listener.Close()
-	}
-
-	if t.sshClient != nil {
-		t.sshClient.Close()
-	}
-}
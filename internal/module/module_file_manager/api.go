@@ -2,9 +2,11 @@ package module_file_manager
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
 	"io"
@@ -48,6 +50,8 @@ var (
 	callStopPower   = base.AppendPower(&base.PowerAction{Action: "callStop", Text: "文件操作停止", ShouldLogin: true, StandAlone: true, Parent: Power})
 	closePower      = base.AppendPower(&base.PowerAction{Action: "close", Text: "文件管理器关闭", ShouldLogin: true, StandAlone: true, Parent: Power})
 	openPower       = base.AppendPower(&base.PowerAction{Action: "open", Text: "打开文件", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	tailPower = base.AppendPower(&base.PowerAction{Action: "tail", Text: "日志跟踪", ShouldLogin: true, StandAlone: true, Parent: Power})
 )
 
 func (this_ *api) GetApis() (apis []*base.ApiWorker) {
@@ -66,6 +70,7 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: callStopPower, Do: this_.callStop})
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
 	apis = append(apis, &base.ApiWorker{Power: openPower, Do: this_.open, IsGet: true})
+	apis = append(apis, &base.ApiWorker{Power: tailPower, Do: this_.tail, IsWebSocket: true})
 	return
 }
 
@@ -463,3 +468,38 @@ func (this_ *api) open(r *base.RequestBean, c *gin.Context) (res interface{}, er
 	c.Status(http.StatusOK)
 	return
 }
+
+// tail 日志跟踪，连接后客户端发送跟踪配置，服务端定时推送过滤后的新增行
+func (this_ *api) tail(r *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	if r.JWT == nil || r.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	//升级get请求为webSocket协议
+	ws, err := upGrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	res = base.HttpNotResponse
+
+	config := &TailConfig{}
+	_, bs, err := ws.ReadMessage()
+	if err == nil {
+		err = json.Unmarshal(bs, config)
+	}
+	var session *tailSession
+	if err == nil {
+		session, err = this_.startTail(ws, config)
+	}
+	if err != nil {
+		this_.Logger.Error("tail start error", zap.Error(err))
+		msg, _ := json.Marshal(&TailMessage{Error: err.Error()})
+		_ = ws.WriteMessage(websocket.TextMessage, msg)
+		_ = ws.Close()
+		err = nil
+		return
+	}
+	go session.readLoop()
+	go session.run()
+	return
+}
@@ -0,0 +1,278 @@
+package module_file_manager
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/websocket"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	"sync"
+	"teamide/internal/module/module_node"
+	"teamide/pkg/logtail"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+const (
+	// tailMinInterval 最小的轮询间隔
+	tailMinInterval = 500 * time.Millisecond
+	// tailDefaultInterval 默认的轮询间隔
+	tailDefaultInterval = time.Second
+	// tailMaxSources 最多同时跟踪的文件数
+	tailMaxSources = 20
+)
+
+var upGrader = websocket.Upgrader{
+	ReadBufferSize:  32 * 1024,
+	WriteBufferSize: 32 * 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// TailSource 跟踪的文件
+type TailSource struct {
+	// Name 来源名称，为空时使用 place:placeId:path
+	Name    string `json:"name,omitempty"`
+	Place   string `json:"place,omitempty"`
+	PlaceId string `json:"placeId,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// TailConfig 日志跟踪配置，WebSocket 连接后客户端发送的第一条消息
+type TailConfig struct {
+	Sources    []*TailSource        `json:"sources,omitempty"`
+	Include    []string             `json:"include,omitempty"`
+	Exclude    []string             `json:"exclude,omitempty"`
+	Highlights []*logtail.Highlight `json:"highlights,omitempty"`
+	// MaxRate 每秒最多推送的行数，0 不限制
+	MaxRate int `json:"maxRate,omitempty"`
+	// Interval 轮询间隔，单位毫秒
+	Interval int `json:"interval,omitempty"`
+}
+
+// TailMessage 推送给客户端的消息
+type TailMessage struct {
+	Lines   []*logtail.Line   `json:"lines,omitempty"`
+	Dropped int64             `json:"dropped,omitempty"`
+	Rotated []string          `json:"rotated,omitempty"`
+	Errors  map[string]string `json:"errors,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+type tailSource struct {
+	name     string
+	tail     *logtail.Tail
+	lastTime int64
+	lastErr  string
+	close    func()
+}
+
+// tailSession 一个 WebSocket 连接的日志跟踪
+type tailSession struct {
+	*worker
+	ws        *websocket.Conn
+	config    *TailConfig
+	matcher   *logtail.Matcher
+	limiter   *logtail.RateLimiter
+	sources   []*tailSource
+	closed    chan struct{}
+	closeOnce sync.Once
+	writeLock sync.Mutex
+}
+
+// createTailReader 按位置创建读取器，本地、SSH 使用文件服务随机读取，节点通过执行命令读取
+func (this_ *worker) createTailReader(source *TailSource) (reader logtail.Reader, closeFunc func(), err error) {
+	switch source.Place {
+	case "local":
+		service, e := this_.GetService("", &BaseParam{Place: source.Place})
+		if e != nil {
+			err = e
+			return
+		}
+		reader = logtail.NewFileReader(service)
+	case "ssh":
+		// 每个跟踪使用独立的 SFTP 连接，结束时关闭
+		key := "tail-" + util.GetUUID()
+		service, e := this_.GetService(key, &BaseParam{Place: source.Place, PlaceId: source.PlaceId})
+		if e != nil {
+			err = e
+			return
+		}
+		reader = logtail.NewFileReader(service)
+		closeFunc = func() {
+			ssh.CloseFileService(key)
+		}
+	case "node":
+		if source.PlaceId == "" {
+			err = errors.New("node配置不能为空")
+			return
+		}
+		reader = logtail.NewExecReader(module_node.NewTerminalService(source.PlaceId, this_.nodeService).Exec)
+	default:
+		err = errors.New("[" + source.Place + "]不支持日志跟踪")
+	}
+	return
+}
+
+// startTail 根据配置创建所有来源，任意来源创建失败时关闭已创建的来源
+func (this_ *worker) startTail(ws *websocket.Conn, config *TailConfig) (session *tailSession, err error) {
+	if len(config.Sources) == 0 {
+		err = errors.New("日志文件不能为空")
+		return
+	}
+	if len(config.Sources) > tailMaxSources {
+		err = errors.New("最多同时跟踪20个日志文件")
+		return
+	}
+	matcher, err := logtail.NewMatcher(config.Include, config.Exclude, config.Highlights)
+	if err != nil {
+		return
+	}
+	session = &tailSession{
+		worker:  this_,
+		ws:      ws,
+		config:  config,
+		matcher: matcher,
+		limiter: &logtail.RateLimiter{MaxRate: config.MaxRate},
+		closed:  make(chan struct{}),
+	}
+	for _, one := range config.Sources {
+		if strings.TrimSpace(one.Path) == "" {
+			err = errors.New("日志文件路径不能为空")
+			break
+		}
+		name := one.Name
+		if name == "" {
+			name = one.Place + ":" + one.PlaceId + ":" + one.Path
+		}
+		reader, closeFunc, e := this_.createTailReader(one)
+		if e != nil {
+			err = errors.New("[" + name + "]" + e.Error())
+			break
+		}
+		session.sources = append(session.sources, &tailSource{
+			name:  name,
+			tail:  logtail.NewTail(reader, one.Path),
+			close: closeFunc,
+		})
+	}
+	if err != nil {
+		session.close()
+		session = nil
+		return
+	}
+	return
+}
+
+func (this_ *tailSession) close() {
+	this_.closeOnce.Do(func() {
+		close(this_.closed)
+		for _, one := range this_.sources {
+			if one.close != nil {
+				one.close()
+			}
+		}
+	})
+}
+
+func (this_ *tailSession) write(message *TailMessage) (err error) {
+	bs, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	this_.writeLock.Lock()
+	defer this_.writeLock.Unlock()
+	err = this_.ws.WriteMessage(websocket.TextMessage, bs)
+	return
+}
+
+// poll 读取所有来源新增的行，过滤后按时间合并，读取出错的来源只在错误变化时推送
+func (this_ *tailSession) poll() (message *TailMessage) {
+	message = &TailMessage{}
+	var sourceLines [][]*logtail.Line
+	for _, one := range this_.sources {
+		texts, rotated, err := one.tail.Poll()
+		if err != nil {
+			if err.Error() != one.lastErr {
+				one.lastErr = err.Error()
+				if message.Errors == nil {
+					message.Errors = map[string]string{}
+				}
+				message.Errors[one.name] = one.lastErr
+			}
+			continue
+		}
+		one.lastErr = ""
+		if rotated {
+			message.Rotated = append(message.Rotated, one.name)
+		}
+		var lines []*logtail.Line
+		for _, text := range texts {
+			lines = append(lines, &logtail.Line{Source: one.name, Text: text})
+		}
+		// 先按完整的行设置时间，过滤后的行也能沿用上一行的时间
+		one.lastTime = logtail.Stamp(lines, one.lastTime)
+		var matched []*logtail.Line
+		for _, line := range lines {
+			if this_.matcher.Match(line.Text) {
+				matched = append(matched, line)
+			}
+		}
+		sourceLines = append(sourceLines, matched)
+	}
+	now := time.Now()
+	for _, line := range logtail.Merge(sourceLines...) {
+		if !this_.limiter.Allow(now) {
+			continue
+		}
+		line.Highlights = this_.matcher.Highlight(line.Text)
+		message.Lines = append(message.Lines, line)
+	}
+	message.Dropped = this_.limiter.Dropped
+	return
+}
+
+func (this_ *tailSession) run() {
+	defer func() {
+		if e := recover(); e != nil {
+			this_.Logger.Error("tail run error", zap.Any("error", e))
+		}
+		this_.close()
+		_ = this_.ws.Close()
+	}()
+
+	interval := time.Duration(this_.config.Interval) * time.Millisecond
+	if interval <= 0 {
+		interval = tailDefaultInterval
+	} else if interval < tailMinInterval {
+		interval = tailMinInterval
+	}
+	var lastDropped int64
+	for {
+		message := this_.poll()
+		if len(message.Lines) > 0 || len(message.Rotated) > 0 || len(message.Errors) > 0 || message.Dropped != lastDropped {
+			lastDropped = message.Dropped
+			if err := this_.write(message); err != nil {
+				return
+			}
+		}
+		select {
+		case <-this_.closed:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// readLoop 读取客户端消息，连接断开时结束跟踪
+func (this_ *tailSession) readLoop() {
+	defer this_.close()
+	for {
+		if _, _, err := this_.ws.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
@@ -3,9 +3,6 @@ package module_file_manager
 import (
 	"errors"
 	"fmt"
-	"github.com/team-ide/go-tool/util"
-	"go.uber.org/zap"
-	goSSH "golang.org/x/crypto/ssh"
 	"io"
 	"strconv"
 	"teamide/internal/context"
@@ -60,15 +57,11 @@ func (this_ *worker) GetService(fileWorkerKey string, param *BaseParam) (service
 			var config *ssh.Config
 			var sshConfig *ssh.Config
 			config, sshConfig, err = this_.toolboxService.GetSSHConfig(tD.Option)
-			if sshConfig != nil {
-				var sshClient *goSSH.Client
-				sshClient, err = ssh.NewClient(*sshConfig)
-				if err != nil {
-					util.Logger.Error("getSSHService ssh NewClient error", zap.Any("address", sshConfig.Address), zap.Error(err))
-					return
-				}
-				config.SSHClient = sshClient
+			if err != nil {
+				return
 			}
+			// 经由跳板链连接，跳板随 SSH 连接一起关闭
+			config.JumpConfig = sshConfig
 			service = ssh.CreateOrGetClient(fileWorkerKey, config)
 		}
 	case "node":
@@ -0,0 +1,130 @@
+package module_forward
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+)
+
+type api struct {
+	*ForwardService
+}
+
+func NewApi(forwardService_ *ForwardService) *api {
+	return &api{
+		ForwardService: forwardService_,
+	}
+}
+
+var (
+	// Power 端口转发 基本 权限
+	Power       = base.AppendPower(&base.PowerAction{Action: "portForward", Text: "端口转发", ShouldLogin: true, StandAlone: true})
+	listPower   = base.AppendPower(&base.PowerAction{Action: "list", Text: "端口转发列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	statusPower = base.AppendPower(&base.PowerAction{Action: "status", Text: "端口转发状态", ShouldLogin: true, StandAlone: true, Parent: Power})
+	startPower  = base.AppendPower(&base.PowerAction{Action: "start", Text: "端口转发启动", ShouldLogin: true, StandAlone: true, ShouldPower: true, Parent: Power})
+	stopPower   = base.AppendPower(&base.PowerAction{Action: "stop", Text: "端口转发停止", ShouldLogin: true, StandAlone: true, Parent: Power})
+	enablePower = base.AppendPower(&base.PowerAction{Action: "enable", Text: "端口转发启用", ShouldLogin: true, StandAlone: true, ShouldPower: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: listPower, Do: this_.list, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: statusPower, Do: this_.status, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: startPower, Do: this_.start})
+	apis = append(apis, &base.ApiWorker{Power: stopPower, Do: this_.stop})
+	apis = append(apis, &base.ApiWorker{Power: enablePower, Do: this_.enable})
+
+	return
+}
+
+type Request struct {
+	ToolboxId int64 `json:"toolboxId,omitempty"`
+	Enable    bool  `json:"enable,omitempty"`
+}
+
+// checkToolbox 验证当前用户可以操作该端口转发
+func (this_ *api) checkToolbox(requestBean *base.RequestBean, toolboxId int64) (err error) {
+	toolbox, err := this_.toolboxService.Get(toolboxId)
+	if err != nil {
+		return
+	}
+	if toolbox == nil || toolbox.ToolboxType != ToolboxType {
+		err = errors.New(fmt.Sprint("端口转发[", toolboxId, "]不存在"))
+		return
+	}
+	err = this_.toolboxService.CheckToolboxPower(requestBean, toolbox)
+	return
+}
+
+func (this_ *api) list(requestBean *base.RequestBean, _ *gin.Context) (res interface{}, err error) {
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	list, err := this_.toolboxService.Query(&module_toolbox.ToolboxModel{
+		ToolboxType: ToolboxType,
+		UserId:      requestBean.JWT.UserId,
+	})
+	if err != nil {
+		return
+	}
+	var statusList []*Status
+	for _, one := range list {
+		statusList = append(statusList, this_.GetStatus(one))
+	}
+	res = statusList
+	return
+}
+
+func (this_ *api) status(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if err = this_.checkToolbox(requestBean, request.ToolboxId); err != nil {
+		return
+	}
+	toolbox, err := this_.toolboxService.Get(request.ToolboxId)
+	if err != nil {
+		return
+	}
+	res = this_.GetStatus(toolbox)
+	return
+}
+
+func (this_ *api) start(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if err = this_.checkToolbox(requestBean, request.ToolboxId); err != nil {
+		return
+	}
+	err = this_.Start(request.ToolboxId)
+	return
+}
+
+func (this_ *api) stop(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if err = this_.checkToolbox(requestBean, request.ToolboxId); err != nil {
+		return
+	}
+	this_.Stop(request.ToolboxId)
+	return
+}
+
+func (this_ *api) enable(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if err = this_.checkToolbox(requestBean, request.ToolboxId); err != nil {
+		return
+	}
+	err = this_.SetEnable(request.ToolboxId, request.Enable)
+	return
+}
@@ -0,0 +1,489 @@
+package module_forward
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+const (
+	// ToolboxType 端口转发工具类型
+	ToolboxType = "portForward"
+
+	StatusStopped      = "stopped"
+	StatusStarting     = "starting"
+	StatusRunning      = "running"
+	StatusReconnecting = "reconnecting"
+	StatusError        = "error"
+
+	defaultReconnectInterval = 5
+	// maxReconnectInterval 连续重连失败时间隔翻倍，最长等待时间，单位秒
+	maxReconnectInterval = 300
+)
+
+// Config 端口转发配置，SSH 隧道通过 sshToolboxId、sshToolboxChain 配置
+type Config struct {
+	ForwardType       string `json:"forwardType"`
+	ListenAddress     string `json:"listenAddress"`
+	TargetAddress     string `json:"targetAddress"`
+	Enable            bool   `json:"enable"`
+	AutoReconnect     bool   `json:"autoReconnect"`
+	ReconnectInterval int    `json:"reconnectInterval"` // 秒
+}
+
+// Status 端口转发运行状态
+type Status struct {
+	ToolboxId      int64  `json:"toolboxId"`
+	Name           string `json:"name"`
+	UserId         int64  `json:"userId"`
+	ForwardType    string `json:"forwardType"`
+	ListenAddress  string `json:"listenAddress"`
+	TargetAddress  string `json:"targetAddress,omitempty"`
+	Enable         bool   `json:"enable"`
+	Status         string `json:"status"`
+	Error          string `json:"error,omitempty"`
+	StartTime      int64  `json:"startTime,omitempty"`
+	ConnectTime    int64  `json:"connectTime,omitempty"`
+	ReconnectCount int    `json:"reconnectCount,omitempty"`
+	*ssh.ForwardStats
+}
+
+func NewForwardService(ServerContext *context.ServerContext, toolboxService_ *module_toolbox.ToolboxService) (res *ForwardService) {
+	res = &ForwardService{
+		ServerContext:  ServerContext,
+		toolboxService: toolboxService_,
+		workers:        make(map[int64]*forwardWorker),
+	}
+	toolboxService_.AddChangeListener(res.onToolboxChange)
+	return
+}
+
+// ForwardService 端口转发服务，转发在服务端运行，与用户会话无关
+type ForwardService struct {
+	*context.ServerContext
+	toolboxService *module_toolbox.ToolboxService
+	workers        map[int64]*forwardWorker
+	workersLock    sync.Mutex
+}
+
+// ServerReady 启动已启用的端口转发
+func (this_ *ForwardService) ServerReady() (err error) {
+	list, err := this_.toolboxService.Query(&module_toolbox.ToolboxModel{ToolboxType: ToolboxType})
+	if err != nil {
+		return
+	}
+	for _, one := range list {
+		config := &Config{}
+		if e := json.Unmarshal([]byte(one.Option), config); e != nil || !config.Enable {
+			continue
+		}
+		if e := this_.Start(one.ToolboxId); e != nil {
+			this_.Logger.Error("port forward start error", zap.Any("toolboxId", one.ToolboxId), zap.Error(e))
+		}
+	}
+	return
+}
+
+// getToolbox 获取端口转发配置和 SSH 隧道配置
+func (this_ *ForwardService) getToolbox(toolboxId int64) (toolbox *module_toolbox.ToolboxModel, config *Config, sshConfig *ssh.Config, err error) {
+	toolbox, err = this_.toolboxService.Get(toolboxId)
+	if err != nil {
+		return
+	}
+	if toolbox == nil || toolbox.Deleted == 1 || toolbox.ToolboxType != ToolboxType {
+		err = errors.New(fmt.Sprint("端口转发[", toolboxId, "]不存在"))
+		return
+	}
+	config = &Config{}
+	sshConfig, err = this_.toolboxService.BindConfigByOption(toolbox.Option, config)
+	if err != nil {
+		return
+	}
+	if sshConfig == nil {
+		err = errors.New("端口转发[" + toolbox.Name + "]未配置SSH隧道")
+		return
+	}
+	if config.ListenAddress == "" {
+		err = errors.New("端口转发[" + toolbox.Name + "]监听地址不能为空")
+		return
+	}
+	config.ListenAddress, err = this_.formatListenAddress(config.ListenAddress)
+	if err != nil {
+		err = errors.New("端口转发[" + toolbox.Name + "]" + err.Error())
+		return
+	}
+	switch config.ForwardType {
+	case ssh.ForwardTypeLocal, ssh.ForwardTypeRemote:
+		if config.TargetAddress == "" {
+			err = errors.New("端口转发[" + toolbox.Name + "]目标地址不能为空")
+			return
+		}
+	case ssh.ForwardTypeDynamic:
+	default:
+		err = errors.New("端口转发[" + toolbox.Name + "]转发类型[" + config.ForwardType + "]不支持")
+		return
+	}
+	return
+}
+
+// formatListenAddress 只配置端口时监听 127.0.0.1，未开启允许监听非本机地址的设置时只能监听本机地址
+func (this_ *ForwardService) formatListenAddress(address string) (res string, err error) {
+	host, port, e := net.SplitHostPort(address)
+	if e != nil {
+		host = ""
+		port = strings.TrimPrefix(address, ":")
+	}
+	if _, e = strconv.Atoi(port); e != nil {
+		err = errors.New("监听地址[" + address + "]格式错误")
+		return
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if !this_.Setting.PortForwardPublicListen {
+		ip := net.ParseIP(host)
+		if host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			err = errors.New("监听地址[" + address + "]不是本机地址，需要管理员在设置中允许监听非本机地址")
+			return
+		}
+	}
+	res = net.JoinHostPort(host, port)
+	return
+}
+
+// getSSHOption SSH 跳板链的配置，用于判断引用的 SSH 配置是否修改
+func getSSHOption(sshConfig *ssh.Config) string {
+	var option string
+	for one := sshConfig; one != nil; one = one.JumpConfig {
+		bs, _ := json.Marshal(one)
+		option += string(bs)
+	}
+	return option
+}
+
+func (this_ *ForwardService) getWorker(toolboxId int64) *forwardWorker {
+	this_.workersLock.Lock()
+	defer this_.workersLock.Unlock()
+
+	return this_.workers[toolboxId]
+}
+
+// Start 启动端口转发，已在运行时不处理
+func (this_ *ForwardService) Start(toolboxId int64) (err error) {
+	toolbox, config, sshConfig, err := this_.getToolbox(toolboxId)
+	if err != nil {
+		return
+	}
+
+	this_.workersLock.Lock()
+	defer this_.workersLock.Unlock()
+
+	if worker := this_.workers[toolboxId]; worker != nil && !worker.isStopped() {
+		return
+	}
+	worker := &forwardWorker{
+		ForwardService: this_,
+		toolboxId:      toolboxId,
+		option:         getForwardOption(toolbox.Option),
+		sshOption:      getSSHOption(sshConfig),
+		stop:           make(chan struct{}),
+		stats:          &ssh.ForwardStats{},
+		status: &Status{
+			ToolboxId:     toolboxId,
+			Name:          toolbox.Name,
+			UserId:        toolbox.UserId,
+			ForwardType:   config.ForwardType,
+			ListenAddress: config.ListenAddress,
+			TargetAddress: config.TargetAddress,
+			Enable:        config.Enable,
+			Status:        StatusStarting,
+			StartTime:     util.GetNowMilli(),
+		},
+	}
+	this_.workers[toolboxId] = worker
+	go worker.run()
+	return
+}
+
+// Stop 停止端口转发
+func (this_ *ForwardService) Stop(toolboxId int64) {
+	worker := this_.getWorker(toolboxId)
+	if worker != nil {
+		worker.stopWork()
+	}
+}
+
+// onToolboxChange 端口转发删除后停止，运行中的端口转发修改配置或者引用的 SSH 配置修改后重启
+func (this_ *ForwardService) onToolboxChange(toolboxId int64, deleted bool) {
+	if worker := this_.getWorker(toolboxId); worker != nil && deleted {
+		this_.workersLock.Lock()
+		if this_.workers[toolboxId] == worker {
+			delete(this_.workers, toolboxId)
+		}
+		this_.workersLock.Unlock()
+		worker.stopWork()
+		return
+	}
+	var workers []*forwardWorker
+	this_.workersLock.Lock()
+	for _, worker := range this_.workers {
+		if !worker.isStopped() {
+			workers = append(workers, worker)
+		}
+	}
+	this_.workersLock.Unlock()
+
+	for _, worker := range workers {
+		toolbox, _, sshConfig, err := this_.getToolbox(worker.toolboxId)
+		if err == nil && getForwardOption(toolbox.Option) == worker.option && getSSHOption(sshConfig) == worker.sshOption {
+			continue
+		}
+		this_.Logger.Info("port forward option changed, restart", zap.Any("toolboxId", worker.toolboxId), zap.Any("changeToolboxId", toolboxId))
+		worker.stopWork()
+		if err = this_.Start(worker.toolboxId); err != nil {
+			this_.Logger.Error("port forward restart error", zap.Any("toolboxId", worker.toolboxId), zap.Error(err))
+		}
+	}
+}
+
+// getForwardOption 去除是否启用后的配置，用于判断配置是否修改
+func getForwardOption(option string) string {
+	optionMap := map[string]interface{}{}
+	if err := util.JSONDecodeUseNumber([]byte(option), &optionMap); err != nil {
+		return option
+	}
+	delete(optionMap, "enable")
+	bs, _ := json.Marshal(optionMap)
+	return string(bs)
+}
+
+// SetEnable 修改是否启用，启用后立即启动，停用后立即停止
+func (this_ *ForwardService) SetEnable(toolboxId int64, enable bool) (err error) {
+	toolbox, err := this_.toolboxService.Get(toolboxId)
+	if err != nil {
+		return
+	}
+	if toolbox == nil || toolbox.ToolboxType != ToolboxType {
+		err = errors.New(fmt.Sprint("端口转发[", toolboxId, "]不存在"))
+		return
+	}
+	option := map[string]interface{}{}
+	if toolbox.Option != "" {
+		if err = util.JSONDecodeUseNumber([]byte(toolbox.Option), &option); err != nil {
+			return
+		}
+	}
+	option["enable"] = enable
+	bs, err := json.Marshal(option)
+	if err != nil {
+		return
+	}
+	_, err = this_.toolboxService.Update(&module_toolbox.ToolboxModel{
+		ToolboxId:   toolboxId,
+		ToolboxType: toolbox.ToolboxType,
+		Option:      string(bs),
+	})
+	if err != nil {
+		return
+	}
+	if enable {
+		err = this_.Start(toolboxId)
+	} else {
+		this_.Stop(toolboxId)
+	}
+	return
+}
+
+// GetStatus 获取端口转发状态，未启动过时为停止状态
+func (this_ *ForwardService) GetStatus(toolbox *module_toolbox.ToolboxModel) (status *Status) {
+	if worker := this_.getWorker(toolbox.ToolboxId); worker != nil {
+		return worker.getStatus()
+	}
+	config := &Config{}
+	_ = json.Unmarshal([]byte(toolbox.Option), config)
+	status = &Status{
+		ToolboxId:     toolbox.ToolboxId,
+		Name:          toolbox.Name,
+		UserId:        toolbox.UserId,
+		ForwardType:   config.ForwardType,
+		ListenAddress: config.ListenAddress,
+		TargetAddress: config.TargetAddress,
+		Enable:        config.Enable,
+		Status:        StatusStopped,
+		ForwardStats:  &ssh.ForwardStats{},
+	}
+	return
+}
+
+type forwardWorker struct {
+	*ForwardService
+	toolboxId  int64
+	option     string
+	sshOption  string
+	stop       chan struct{}
+	stopOnce   sync.Once
+	stats      *ssh.ForwardStats
+	status     *Status
+	statusLock sync.Mutex
+	forward    *ssh.Forward
+}
+
+func (this_ *forwardWorker) isStopped() bool {
+	select {
+	case <-this_.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (this_ *forwardWorker) stopWork() {
+	this_.stopOnce.Do(func() {
+		close(this_.stop)
+	})
+	this_.statusLock.Lock()
+	forward := this_.forward
+	this_.statusLock.Unlock()
+	if forward != nil {
+		forward.Close()
+	}
+}
+
+func (this_ *forwardWorker) getStatus() (status *Status) {
+	this_.statusLock.Lock()
+	defer this_.statusLock.Unlock()
+
+	one := *this_.status
+	one.ForwardStats = this_.stats.Snapshot()
+	return &one
+}
+
+// setStatus 修改状态并通知转发所有者，已被删除或重启替换的转发不再通知
+func (this_ *forwardWorker) setStatus(status string, err error) {
+	this_.statusLock.Lock()
+	this_.status.Status = status
+	this_.status.Error = ""
+	if err != nil {
+		this_.status.Error = err.Error()
+	}
+	if status == StatusRunning {
+		this_.status.ConnectTime = util.GetNowMilli()
+	}
+	this_.statusLock.Unlock()
+
+	if this_.getWorker(this_.toolboxId) != this_ {
+		return
+	}
+	s := this_.getStatus()
+	if s.UserId != 0 {
+		context.CallUserEvent(s.UserId, context.NewListenEvent("port-forward", s))
+	}
+}
+
+func (this_ *forwardWorker) run() {
+	defer func() {
+		if e := recover(); e != nil {
+			this_.Logger.Error("port forward run error", zap.Any("toolboxId", this_.toolboxId), zap.Any("error", e))
+			this_.setStatus(StatusError, errors.New(fmt.Sprint(e)))
+		}
+	}()
+
+	var retry int
+	for !this_.isStopped() {
+		config, connected, err := this_.runOnce()
+		if this_.isStopped() {
+			break
+		}
+		if connected {
+			retry = 0
+		}
+		if config == nil || !config.AutoReconnect {
+			this_.setStatus(StatusError, err)
+			this_.stopWork()
+			return
+		}
+		interval := config.ReconnectInterval
+		if interval <= 0 {
+			interval = defaultReconnectInterval
+		}
+		for i := 0; i < retry && interval < maxReconnectInterval; i++ {
+			interval *= 2
+		}
+		if interval > maxReconnectInterval {
+			interval = maxReconnectInterval
+		}
+		retry++
+		this_.statusLock.Lock()
+		this_.status.ReconnectCount++
+		this_.statusLock.Unlock()
+		this_.setStatus(StatusReconnecting, err)
+		this_.Logger.Warn("port forward reconnect", zap.Any("toolboxId", this_.toolboxId), zap.Any("interval", interval), zap.Error(err))
+
+		timer := time.NewTimer(time.Duration(interval) * time.Second)
+		select {
+		case <-this_.stop:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+	this_.setStatus(StatusStopped, nil)
+}
+
+// runOnce 每次连接重新读取配置，返回 SSH 连接断开或启动失败的原因，connected 表示是否成功开始转发
+func (this_ *forwardWorker) runOnce() (config *Config, connected bool, err error) {
+	toolbox, config, sshConfig, err := this_.getToolbox(this_.toolboxId)
+	if err != nil {
+		// 配置不存在或错误时不再重连
+		config = nil
+		return
+	}
+	this_.statusLock.Lock()
+	this_.status.Name = toolbox.Name
+	this_.status.ForwardType = config.ForwardType
+	this_.status.ListenAddress = config.ListenAddress
+	this_.status.TargetAddress = config.TargetAddress
+	this_.status.Enable = config.Enable
+	this_.statusLock.Unlock()
+
+	client, err := ssh.NewClient(*sshConfig)
+	if err != nil {
+		return
+	}
+	defer func() { _ = client.Close() }()
+
+	forward := ssh.NewForward(client, config.ForwardType, config.ListenAddress, config.TargetAddress, this_.stats)
+	if err = forward.Listen(); err != nil {
+		return
+	}
+	this_.statusLock.Lock()
+	this_.forward = forward
+	this_.statusLock.Unlock()
+	defer func() {
+		forward.Close()
+		this_.statusLock.Lock()
+		this_.forward = nil
+		this_.statusLock.Unlock()
+	}()
+	if this_.isStopped() {
+		return
+	}
+	connected = true
+	this_.setStatus(StatusRunning, nil)
+	this_.Logger.Info("port forward running", zap.Any("toolboxId", this_.toolboxId), zap.Any("type", config.ForwardType), zap.Any("listen", config.ListenAddress))
+
+	err = forward.Serve()
+	if err == nil {
+		err = errors.New("SSH连接已断开")
+	}
+	return
+}
@@ -63,4 +63,15 @@ const (
 	IDTypeTerminalLog = 8001
 	// IDTypeTerminalCommand 控制台命令
 	IDTypeTerminalCommand = 8002
+	// IDTypeTerminalBatchReport 批量执行报告
+	IDTypeTerminalBatchReport = 8003
+	// IDTypeTerminalPolicy 命令策略
+	IDTypeTerminalPolicy = 8004
+	// IDTypeTerminalTrigger 输出触发器
+	IDTypeTerminalTrigger = 8005
+
+	// IDTypeMetricHost 指标采集主机
+	IDTypeMetricHost = 9001
+	// IDTypeMetricAlertRule 指标告警规则
+	IDTypeMetricAlertRule = 9002
 )
@@ -7,6 +7,7 @@ import (
 	"go.uber.org/zap"
 	"teamide/internal/module/module_toolbox"
 	"teamide/pkg/base"
+	"teamide/pkg/ssh"
 )
 
 type api struct {
@@ -71,16 +72,16 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	return
 }
 
-func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *kafka.Config, err error) {
+func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *kafka.Config, sshConfig *ssh.Config, err error) {
 	config = &kafka.Config{}
-	_, err = this_.toolboxService.BindConfig(requestBean, c, config)
+	sshConfig, err = this_.toolboxService.BindConfig(requestBean, c, config)
 	if err != nil {
 		return
 	}
 	return
 }
 
-func getService(kafkaConfig *kafka.Config) (res kafka.IService, err error) {
+func getService(kafkaConfig *kafka.Config, sshConfig *ssh.Config) (res kafka.IService, err error) {
 	key := "kafka-" + kafkaConfig.Address
 	if kafkaConfig.Username != "" {
 		key += "-" + base.GetMd5String(key+kafkaConfig.Username)
@@ -91,15 +92,33 @@ func getService(kafkaConfig *kafka.Config) (res kafka.IService, err error) {
 	if kafkaConfig.CertPath != "" {
 		key += "-" + base.GetMd5String(key+kafkaConfig.CertPath)
 	}
+	if sshConfig != nil {
+		key += "-ssh-" + sshConfig.GetChainKey()
+	}
 	var serviceInfo *base.ServiceInfo
 	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
 		var s kafka.IService
-		s, err = kafka.New(kafkaConfig)
+		var tunnels []*ssh.Tunnel
+		serviceConfig := kafkaConfig
+		if sshConfig != nil {
+			// 客户端不支持 SSHClient 拨号，经由本地端口转发连接
+			var localAddress string
+			tunnels, localAddress, err = ssh.StartAddressTunnels(sshConfig, kafkaConfig.Address)
+			if err != nil {
+				util.Logger.Error("getKafkaService ssh tunnel error", zap.Any("key", key), zap.Error(err))
+				return
+			}
+			tunnelConfig := *kafkaConfig
+			tunnelConfig.Address = localAddress
+			serviceConfig = &tunnelConfig
+		}
+		s, err = kafka.New(serviceConfig)
 		if err != nil {
 			util.Logger.Error("getKafkaService error", zap.Any("key", key), zap.Error(err))
 			if s != nil {
 				s.Close()
 			}
+			ssh.CloseTunnels(tunnels)
 			return
 		}
 		_, err = s.GetTopic("toolbox-kafka-test-topic", -2)
@@ -108,13 +127,17 @@ func getService(kafkaConfig *kafka.Config) (res kafka.IService, err error) {
 			if s != nil {
 				s.Close()
 			}
+			ssh.CloseTunnels(tunnels)
 			return
 		}
 		res = &base.ServiceInfo{
 			WaitTime:    10 * 60 * 1000,
 			LastUseTime: util.GetNowMilli(),
 			Service:     s,
-			Stop:        s.Close,
+			Stop: func() {
+				s.Close()
+				ssh.CloseTunnels(tunnels)
+			},
 		}
 		return
 	})
@@ -145,11 +168,11 @@ type BaseRequest struct {
 }
 
 func (this_ *api) check(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	_, err = getService(config)
+	_, err = getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -158,11 +181,11 @@ func (this_ *api) check(requestBean *base.RequestBean, c *gin.Context) (res inte
 }
 
 func (this_ *api) info(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -175,11 +198,11 @@ func (this_ *api) info(requestBean *base.RequestBean, c *gin.Context) (res inter
 }
 
 func (this_ *api) topics(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -192,11 +215,11 @@ func (this_ *api) topics(requestBean *base.RequestBean, c *gin.Context) (res int
 }
 
 func (this_ *api) topic(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -214,11 +237,11 @@ func (this_ *api) topic(requestBean *base.RequestBean, c *gin.Context) (res inte
 }
 
 func (this_ *api) topicDescribe(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -241,11 +264,11 @@ func (this_ *api) topicDescribe(requestBean *base.RequestBean, c *gin.Context) (
 }
 
 func (this_ *api) commit(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -263,11 +286,11 @@ func (this_ *api) commit(requestBean *base.RequestBean, c *gin.Context) (res int
 }
 
 func (this_ *api) pull(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -285,11 +308,11 @@ func (this_ *api) pull(requestBean *base.RequestBean, c *gin.Context) (res inter
 }
 
 func (this_ *api) push(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -307,11 +330,11 @@ func (this_ *api) push(requestBean *base.RequestBean, c *gin.Context) (res inter
 }
 
 func (this_ *api) reset(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -329,11 +352,11 @@ func (this_ *api) reset(requestBean *base.RequestBean, c *gin.Context) (res inte
 }
 
 func (this_ *api) deleteTopic(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -351,11 +374,11 @@ func (this_ *api) deleteTopic(requestBean *base.RequestBean, c *gin.Context) (re
 }
 
 func (this_ *api) createTopic(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -373,11 +396,11 @@ func (this_ *api) createTopic(requestBean *base.RequestBean, c *gin.Context) (re
 }
 
 func (this_ *api) createPartitions(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -395,11 +418,11 @@ func (this_ *api) createPartitions(requestBean *base.RequestBean, c *gin.Context
 }
 
 func (this_ *api) deleteRecords(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -419,11 +442,11 @@ func (this_ *api) deleteRecords(requestBean *base.RequestBean, c *gin.Context) (
 }
 
 func (this_ *api) groupList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -441,11 +464,11 @@ func (this_ *api) groupList(requestBean *base.RequestBean, c *gin.Context) (res
 }
 
 func (this_ *api) groupOffsets(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -463,11 +486,11 @@ func (this_ *api) groupOffsets(requestBean *base.RequestBean, c *gin.Context) (r
 }
 
 func (this_ *api) groupDeleteOffsets(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -485,11 +508,11 @@ func (this_ *api) groupDeleteOffsets(requestBean *base.RequestBean, c *gin.Conte
 }
 
 func (this_ *api) groupDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -507,11 +530,11 @@ func (this_ *api) groupDelete(requestBean *base.RequestBean, c *gin.Context) (re
 }
 
 func (this_ *api) groupDescribe(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,209 @@
+package module_metric
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/util"
+	"strconv"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/metric"
+)
+
+type api struct {
+	*MetricService
+}
+
+func NewApi(metricService_ *MetricService) *api {
+	return &api{
+		MetricService: metricService_,
+	}
+}
+
+var (
+	// Power 主机指标 基本 权限
+	Power           = base.AppendPower(&base.PowerAction{Action: "metric", Text: "主机指标", ShouldLogin: true, StandAlone: true})
+	hostPower       = base.AppendPower(&base.PowerAction{Action: "host", Text: "指标采集主机", ShouldLogin: true, StandAlone: true, Parent: Power})
+	hostListPower   = base.AppendPower(&base.PowerAction{Action: "list", Text: "指标采集主机列表", ShouldLogin: true, StandAlone: true, Parent: hostPower})
+	hostSavePower   = base.AppendPower(&base.PowerAction{Action: "save", Text: "指标采集主机保存", ShouldLogin: true, StandAlone: true, Parent: hostPower})
+	hostDeletePower = base.AppendPower(&base.PowerAction{Action: "delete", Text: "指标采集主机删除", ShouldLogin: true, StandAlone: true, Parent: hostPower})
+	samplePower     = base.AppendPower(&base.PowerAction{Action: "sample", Text: "指标采样查询", ShouldLogin: true, StandAlone: true, Parent: Power})
+	rulePower       = base.AppendPower(&base.PowerAction{Action: "rule", Text: "指标告警规则", ShouldLogin: true, StandAlone: true, Parent: Power})
+	ruleListPower   = base.AppendPower(&base.PowerAction{Action: "list", Text: "指标告警规则列表", ShouldLogin: true, StandAlone: true, Parent: rulePower})
+	ruleSavePower   = base.AppendPower(&base.PowerAction{Action: "save", Text: "指标告警规则保存", ShouldLogin: true, StandAlone: true, Parent: rulePower})
+	ruleDeletePower = base.AppendPower(&base.PowerAction{Action: "delete", Text: "指标告警规则删除", ShouldLogin: true, StandAlone: true, Parent: rulePower})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: hostListPower, Do: this_.hostList, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: hostSavePower, Do: this_.hostSave})
+	apis = append(apis, &base.ApiWorker{Power: hostDeletePower, Do: this_.hostDelete})
+	apis = append(apis, &base.ApiWorker{Power: samplePower, Do: this_.sample, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: ruleListPower, Do: this_.ruleList, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: ruleSavePower, Do: this_.ruleSave})
+	apis = append(apis, &base.ApiWorker{Power: ruleDeletePower, Do: this_.ruleDelete})
+
+	return
+}
+
+type Request struct {
+	HostId int64 `json:"hostId,omitempty"`
+	RuleId int64 `json:"ruleId,omitempty"`
+	// Level 聚合级别，不传时根据时间跨度选择
+	Level     *int  `json:"level,omitempty"`
+	StartTime int64 `json:"startTime,omitempty"`
+	EndTime   int64 `json:"endTime,omitempty"`
+}
+
+func getUserId(requestBean *base.RequestBean) int64 {
+	if requestBean.JWT == nil {
+		return 0
+	}
+	return requestBean.JWT.UserId
+}
+
+// checkHost 验证主机属于当前用户
+func (this_ *api) checkHost(requestBean *base.RequestBean, hostId int64) (host *MetricHostModel, err error) {
+	host, err = this_.GetHost(hostId)
+	if err != nil {
+		return
+	}
+	if host == nil || host.UserId != getUserId(requestBean) {
+		err = errors.New(fmt.Sprint("采集主机[", hostId, "]不存在"))
+		host = nil
+		return
+	}
+	return
+}
+
+func (this_ *api) hostList(requestBean *base.RequestBean, _ *gin.Context) (res interface{}, err error) {
+	res, err = this_.QueryHost(getUserId(requestBean))
+	return
+}
+
+func (this_ *api) hostSave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &MetricHostModel{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if request.HostId > 0 {
+		if _, err = this_.checkHost(requestBean, request.HostId); err != nil {
+			return
+		}
+	}
+	// SSH 主机需要验证当前用户可以操作该工具
+	if request.Place == "ssh" {
+		var id int64
+		if id, err = strconv.ParseInt(request.PlaceId, 10, 64); err != nil {
+			return
+		}
+		var tD *module_toolbox.ToolboxModel
+		if tD, err = this_.toolboxService.Get(id); err != nil {
+			return
+		}
+		if tD == nil {
+			err = errors.New("SSH[" + request.PlaceId + "]配置不存在")
+			return
+		}
+		if err = this_.toolboxService.CheckToolboxPower(requestBean, tD); err != nil {
+			return
+		}
+	}
+	request.UserId = getUserId(requestBean)
+	err = this_.SaveHost(request)
+	res = request
+	return
+}
+
+func (this_ *api) hostDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if _, err = this_.checkHost(requestBean, request.HostId); err != nil {
+		return
+	}
+	err = this_.DeleteHost(request.HostId)
+	return
+}
+
+func (this_ *api) sample(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if _, err = this_.checkHost(requestBean, request.HostId); err != nil {
+		return
+	}
+	if request.EndTime <= 0 {
+		request.EndTime = util.GetNowMilli()
+	}
+	if request.StartTime <= 0 {
+		request.StartTime = request.EndTime - 60*60*1000
+	}
+	level := -1
+	if request.Level != nil {
+		level = *request.Level
+	}
+	if level < 0 {
+		level = metric.ChooseLevel(request.StartTime, request.EndTime)
+	}
+	data := map[string]interface{}{}
+	data["level"] = level
+	data["list"], err = this_.QuerySample(request.HostId, level, request.StartTime, request.EndTime)
+	res = data
+	return
+}
+
+func (this_ *api) ruleList(requestBean *base.RequestBean, _ *gin.Context) (res interface{}, err error) {
+	data := map[string]interface{}{}
+	data["metrics"] = metric.Metrics
+	data["list"], err = this_.QueryRule(getUserId(requestBean))
+	res = data
+	return
+}
+
+func (this_ *api) ruleSave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &MetricAlertRuleModel{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId := getUserId(requestBean)
+	if request.RuleId > 0 {
+		var find *MetricAlertRuleModel
+		if find, err = this_.GetRule(request.RuleId); err != nil {
+			return
+		}
+		if find == nil || find.UserId != userId {
+			err = errors.New(fmt.Sprint("告警规则[", request.RuleId, "]不存在"))
+			return
+		}
+	}
+	if request.HostId > 0 {
+		if _, err = this_.checkHost(requestBean, request.HostId); err != nil {
+			return
+		}
+	}
+	request.UserId = userId
+	err = this_.SaveRule(request)
+	res = request
+	return
+}
+
+func (this_ *api) ruleDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	find, err := this_.GetRule(request.RuleId)
+	if err != nil {
+		return
+	}
+	if find == nil || find.UserId != getUserId(requestBean) {
+		err = errors.New(fmt.Sprint("告警规则[", request.RuleId, "]不存在"))
+		return
+	}
+	err = this_.DeleteRule(request.RuleId)
+	return
+}
@@ -0,0 +1,245 @@
+package module_metric
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"teamide/internal/context"
+	"teamide/internal/module/module_node"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/metric"
+	"teamide/pkg/ssh"
+	"teamide/pkg/system"
+	"teamide/pkg/terminal"
+	"time"
+)
+
+// MetricAlertEvent 告警事件，推送给规则所属用户以及 Webhook
+type MetricAlertEvent struct {
+	*metric.AlertEvent
+	HostId   int64  `json:"hostId"`
+	HostName string `json:"hostName"`
+	Place    string `json:"place"`
+	PlaceId  string `json:"placeId"`
+}
+
+// collectDue 采集到达采集间隔的主机，上一次采集未结束的主机跳过
+func (this_ *MetricService) collectDue() {
+	list, err := this_.queryEnableHost()
+	if err != nil {
+		this_.Logger.Error("metric query host error", zap.Error(err))
+		return
+	}
+	now := util.GetNowMilli()
+	this_.collectLock.Lock()
+	var dueList []*MetricHostModel
+	for _, host := range list {
+		if this_.collecting[host.HostId] {
+			continue
+		}
+		interval := int64(host.CollectInterval) * 1000
+		// cron 每 10 秒执行，预留 1 秒误差
+		if now-this_.lastCollect[host.HostId] < interval-1000 {
+			continue
+		}
+		this_.collecting[host.HostId] = true
+		this_.lastCollect[host.HostId] = now
+		dueList = append(dueList, host)
+	}
+	this_.collectLock.Unlock()
+
+	for _, host := range dueList {
+		go this_.collectHost(host)
+	}
+}
+
+func (this_ *MetricService) collectHost(host *MetricHostModel) {
+	defer func() {
+		if e := recover(); e != nil {
+			this_.Logger.Error("metric collect panic", zap.Any("hostId", host.HostId), zap.Any("error", e))
+		}
+		this_.collectLock.Lock()
+		delete(this_.collecting, host.HostId)
+		this_.collectLock.Unlock()
+	}()
+
+	sample, err := this_.getSample(host)
+	if err != nil {
+		this_.Logger.Warn("metric collect error", zap.Any("hostId", host.HostId), zap.Any("place", host.Place), zap.Error(err))
+		return
+	}
+	sample.Time = util.GetNowMilli()
+	if err = this_.saveSamples(host.HostId, metric.LevelRaw, []*metric.Sample{sample}); err != nil {
+		this_.Logger.Error("metric save sample error", zap.Any("hostId", host.HostId), zap.Error(err))
+		return
+	}
+
+	rules, err := this_.queryHostRule(host)
+	if err != nil {
+		this_.Logger.Error("metric query rule error", zap.Any("hostId", host.HostId), zap.Error(err))
+		return
+	}
+	key := strconv.FormatInt(host.HostId, 10)
+	for _, rule := range rules {
+		event := this_.evaluator.Evaluate(key, rule.toAlertRule(), sample)
+		if event == nil {
+			continue
+		}
+		this_.notify(host, rule, &MetricAlertEvent{
+			AlertEvent: event,
+			HostId:     host.HostId,
+			HostName:   host.Name,
+			Place:      host.Place,
+			PlaceId:    host.PlaceId,
+		})
+	}
+}
+
+// getSample 采集主机指标，本地和节点使用监控数据，SSH 通过执行命令读取 /proc
+func (this_ *MetricService) getSample(host *MetricHostModel) (sample *metric.Sample, err error) {
+	switch host.Place {
+	case "local":
+		system.StartCollectMonitorData()
+		var data *system.MonitorData
+		if data, err = system.GetCacheOrNew(); err != nil {
+			return
+		}
+		sample = metric.FromMonitorData(data, system.GetDiskUsages())
+	case "node":
+		service := module_node.NewTerminalService(host.PlaceId, this_.nodeService)
+		var data *system.MonitorData
+		if data, err = service.SystemMonitorData(); err != nil {
+			return
+		}
+		if data == nil {
+			err = errors.New("节点[" + host.PlaceId + "]监控数据获取失败")
+			return
+		}
+		var info *system.Info
+		if info, err = service.SystemInfo(); err != nil {
+			return
+		}
+		var disks []*system.DiskUsageStat
+		if info != nil {
+			disks = info.Disks
+		}
+		sample = metric.FromMonitorData(data, disks)
+	case "ssh":
+		var config *ssh.Config
+		if config, err = this_.getSSHConfig(host.PlaceId); err != nil {
+			return
+		}
+		sample, err = metric.CollectExec(func(command string, timeout time.Duration) (*terminal.ExecResult, error) {
+			return ssh.Exec(*config, command, timeout)
+		})
+	default:
+		err = errors.New("[" + host.Place + "]不支持指标采集")
+	}
+	return
+}
+
+func (this_ *MetricService) getSSHConfig(placeId string) (config *ssh.Config, err error) {
+	id, err := strconv.ParseInt(placeId, 10, 64)
+	if err != nil {
+		return
+	}
+	var tD *module_toolbox.ToolboxModel
+	tD, err = this_.toolboxService.Get(id)
+	if err != nil {
+		return
+	}
+	if tD == nil || tD.Option == "" {
+		err = errors.New("SSH[" + placeId + "]配置不存在")
+		return
+	}
+	config, sshConfig, err := this_.toolboxService.GetSSHConfig(tD.Option)
+	if err != nil {
+		return
+	}
+	config.JumpConfig = sshConfig
+	return
+}
+
+// notify 告警事件推送给规则所属用户，并发送到规则配置的 Webhook
+func (this_ *MetricService) notify(host *MetricHostModel, rule *MetricAlertRuleModel, event *MetricAlertEvent) {
+	this_.Logger.Warn("metric alert", zap.Any("hostId", host.HostId), zap.Any("rule", rule.Name), zap.Any("status", event.Status), zap.Any("value", event.Value))
+
+	context.CallUserEvent(rule.UserId, context.NewListenEvent("metric-alert", event))
+
+	for _, url := range strings.Split(rule.Webhooks, "\n") {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		go this_.sendWebhook(url, event)
+	}
+}
+
+func (this_ *MetricService) sendWebhook(url string, event *MetricAlertEvent) {
+	bs, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	res, err := this_.webhookClient.Post(url, "application/json", bytes.NewReader(bs))
+	if err != nil {
+		this_.Logger.Error("metric webhook error", zap.Any("url", url), zap.Error(err))
+		return
+	}
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		this_.Logger.Error("metric webhook error", zap.Any("url", url), zap.Error(fmt.Errorf("status code %d", res.StatusCode)))
+	}
+}
+
+// downsample 将 fromLevel 的采样按 toLevel 的时间跨度聚合，重新计算最近 count 个完整时间段
+func (this_ *MetricService) downsample(fromLevel int, toLevel int, count int64) {
+	bucket := metric.LevelBucket[toLevel]
+	now := util.GetNowMilli()
+	endTime := now - now%bucket
+	startTime := endTime - bucket*count
+
+	var sqlInfo = "SELECT * FROM " + TableMetricSample + " WHERE level=? AND sampleTime>=? AND sampleTime<? ORDER BY sampleTime ASC "
+
+	var models []*MetricSampleModel
+	err := this_.DatabaseWorker.Query(sqlInfo, []interface{}{fromLevel, startTime, endTime}, &models)
+	if err != nil {
+		this_.Logger.Error("metric downsample query error", zap.Any("level", fromLevel), zap.Error(err))
+		return
+	}
+	hostSamples := map[int64][]*metric.Sample{}
+	for _, one := range models {
+		hostSamples[one.HostId] = append(hostSamples[one.HostId], one.toSample())
+	}
+	for hostId, list := range hostSamples {
+		if err = this_.saveSamples(hostId, toLevel, metric.Downsample(list, bucket)); err != nil {
+			this_.Logger.Error("metric downsample save error", zap.Any("hostId", hostId), zap.Any("level", toLevel), zap.Error(err))
+		}
+	}
+}
+
+// downsampleMinute 聚合最近 5 分钟的原始采样，补全服务重启等原因遗漏的时间段
+func (this_ *MetricService) downsampleMinute() {
+	this_.downsample(metric.LevelRaw, metric.LevelMinute, 5)
+}
+
+// downsampleHour 聚合最近 3 小时的分钟采样
+func (this_ *MetricService) downsampleHour() {
+	this_.downsample(metric.LevelMinute, metric.LevelHour, 3)
+}
+
+// cleanSamples 删除超过保留时间的采样
+func (this_ *MetricService) cleanSamples() {
+	now := time.Now()
+	for level, duration := range retention {
+		var sqlInfo = "DELETE FROM " + TableMetricSample + " WHERE level=? AND sampleTime<? "
+		_, err := this_.DatabaseWorker.Exec(sqlInfo, []interface{}{level, now.Add(-duration).UnixMilli()})
+		if err != nil {
+			this_.Logger.Error("metric clean sample error", zap.Any("level", level), zap.Error(err))
+		}
+	}
+}
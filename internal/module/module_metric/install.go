@@ -0,0 +1,146 @@
+package module_metric
+
+import (
+	"teamide/internal/install"
+)
+
+func GetInstallStages() []*install.StageModel {
+
+	return []*install.StageModel{
+
+		// 创建 指标采集主机 表 开始
+		{
+			Version: "1.0",
+			Module:  ModuleMetricHost,
+			Stage:   `创建表[` + TableMetricHost + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableMetricHost + ` (
+	hostId bigint(20) NOT NULL COMMENT '主机ID',
+	name varchar(200) DEFAULT NULL COMMENT '名称',
+	place varchar(20) NOT NULL COMMENT '位置',
+	placeId varchar(20) DEFAULT NULL COMMENT '位置ID',
+	collectInterval int(10) DEFAULT NULL COMMENT '采集间隔',
+	enable int(2) DEFAULT NULL COMMENT '启用',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	updateTime datetime DEFAULT NULL COMMENT '修改时间',
+	PRIMARY KEY (hostId),
+	KEY index_userId (userId)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableMetricHostComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableMetricHost + ` (
+	hostId bigint(20) NOT NULL,
+	name varchar(200) DEFAULT NULL,
+	place varchar(20) NOT NULL,
+	placeId varchar(20) DEFAULT NULL,
+	collectInterval int(10) DEFAULT NULL,
+	enable int(2) DEFAULT NULL,
+	userId bigint(20) DEFAULT NULL,
+	createTime datetime NOT NULL,
+	updateTime datetime DEFAULT NULL,
+	PRIMARY KEY (hostId)
+);
+`,
+					`CREATE INDEX ` + TableMetricHost + `_index_userId on ` + TableMetricHost + ` (userId);`,
+				},
+			},
+		},
+		// 创建 指标采集主机 表 结束
+
+		// 创建 指标采样 表 开始
+		{
+			Version: "1.0",
+			Module:  ModuleMetricSample,
+			Stage:   `创建表[` + TableMetricSample + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableMetricSample + ` (
+	hostId bigint(20) NOT NULL COMMENT '主机ID',
+	level int(2) NOT NULL COMMENT '聚合级别',
+	sampleTime bigint(20) NOT NULL COMMENT '采样时间',
+	cpu double DEFAULT NULL COMMENT 'CPU使用率',
+	mem double DEFAULT NULL COMMENT '内存使用率',
+	disk double DEFAULT NULL COMMENT '磁盘使用率',
+	netRecv double DEFAULT NULL COMMENT '网络接收速率',
+	netSent double DEFAULT NULL COMMENT '网络发送速率',
+	diskRead double DEFAULT NULL COMMENT '磁盘读取速率',
+	diskWrite double DEFAULT NULL COMMENT '磁盘写入速率',
+	sampleCount int(10) DEFAULT NULL COMMENT '原始采样数',
+	PRIMARY KEY (hostId, level, sampleTime),
+	KEY index_level_sampleTime (level, sampleTime)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableMetricSampleComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableMetricSample + ` (
+	hostId bigint(20) NOT NULL,
+	level int(2) NOT NULL,
+	sampleTime bigint(20) NOT NULL,
+	cpu double DEFAULT NULL,
+	mem double DEFAULT NULL,
+	disk double DEFAULT NULL,
+	netRecv double DEFAULT NULL,
+	netSent double DEFAULT NULL,
+	diskRead double DEFAULT NULL,
+	diskWrite double DEFAULT NULL,
+	sampleCount int(10) DEFAULT NULL,
+	PRIMARY KEY (hostId, level, sampleTime)
+);
+`,
+					`CREATE INDEX ` + TableMetricSample + `_index_level_sampleTime on ` + TableMetricSample + ` (level, sampleTime);`,
+				},
+			},
+		},
+		// 创建 指标采样 表 结束
+
+		// 创建 指标告警规则 表 开始
+		{
+			Version: "1.0",
+			Module:  ModuleMetricAlertRule,
+			Stage:   `创建表[` + TableMetricAlertRule + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableMetricAlertRule + ` (
+	ruleId bigint(20) NOT NULL COMMENT '规则ID',
+	name varchar(200) DEFAULT NULL COMMENT '名称',
+	hostId bigint(20) DEFAULT NULL COMMENT '主机ID',
+	metric varchar(50) NOT NULL COMMENT '指标',
+	operator varchar(10) NOT NULL COMMENT '比较方式',
+	threshold double DEFAULT NULL COMMENT '阈值',
+	duration int(10) DEFAULT NULL COMMENT '持续时间',
+	webhooks text DEFAULT NULL COMMENT 'Webhook地址',
+	enable int(2) DEFAULT NULL COMMENT '启用',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	updateTime datetime DEFAULT NULL COMMENT '修改时间',
+	PRIMARY KEY (ruleId),
+	KEY index_hostId (hostId),
+	KEY index_userId (userId)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableMetricAlertRuleComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableMetricAlertRule + ` (
+	ruleId bigint(20) NOT NULL,
+	name varchar(200) DEFAULT NULL,
+	hostId bigint(20) DEFAULT NULL,
+	metric varchar(50) NOT NULL,
+	operator varchar(10) NOT NULL,
+	threshold double DEFAULT NULL,
+	duration int(10) DEFAULT NULL,
+	webhooks text DEFAULT NULL,
+	enable int(2) DEFAULT NULL,
+	userId bigint(20) DEFAULT NULL,
+	createTime datetime NOT NULL,
+	updateTime datetime DEFAULT NULL,
+	PRIMARY KEY (ruleId)
+);
+`,
+					`CREATE INDEX ` + TableMetricAlertRule + `_index_hostId on ` + TableMetricAlertRule + ` (hostId);`,
+					`CREATE INDEX ` + TableMetricAlertRule + `_index_userId on ` + TableMetricAlertRule + ` (userId);`,
+				},
+			},
+		},
+		// 创建 指标告警规则 表 结束
+	}
+}
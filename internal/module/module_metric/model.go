@@ -0,0 +1,95 @@
+package module_metric
+
+import (
+	"teamide/pkg/metric"
+	"time"
+)
+
+const (
+	// ModuleMetricHost 指标采集主机模块
+	ModuleMetricHost = "metric_host"
+	// TableMetricHost 指标采集主机表
+	TableMetricHost        = "TM_METRIC_HOST"
+	TableMetricHostComment = "指标采集主机"
+
+	// ModuleMetricSample 指标采样模块
+	ModuleMetricSample = "metric_sample"
+	// TableMetricSample 指标采样表
+	TableMetricSample        = "TM_METRIC_SAMPLE"
+	TableMetricSampleComment = "指标采样"
+
+	// ModuleMetricAlertRule 指标告警规则模块
+	ModuleMetricAlertRule = "metric_alert_rule"
+	// TableMetricAlertRule 指标告警规则表
+	TableMetricAlertRule        = "TM_METRIC_ALERT_RULE"
+	TableMetricAlertRuleComment = "指标告警规则"
+)
+
+// MetricHostModel 指标采集主机，Place 为 local、ssh、node
+type MetricHostModel struct {
+	HostId          int64     `json:"hostId,omitempty"`
+	Name            string    `json:"name,omitempty"`
+	Place           string    `json:"place,omitempty"`
+	PlaceId         string    `json:"placeId,omitempty"`
+	CollectInterval int       `json:"collectInterval,omitempty"` // 采集间隔，秒
+	Enable          int       `json:"enable,omitempty"`          // 1 为启用
+	UserId          int64     `json:"userId,omitempty"`
+	CreateTime      time.Time `json:"createTime,omitempty"`
+	UpdateTime      time.Time `json:"updateTime,omitempty"`
+}
+
+// MetricSampleModel 指标采样，Level 为聚合级别：0 原始、1 分钟、2 小时
+type MetricSampleModel struct {
+	HostId      int64   `json:"hostId,omitempty"`
+	Level       int     `json:"level,omitempty"`
+	SampleTime  int64   `json:"sampleTime,omitempty"`
+	Cpu         float64 `json:"cpu,omitempty"`
+	Mem         float64 `json:"mem,omitempty"`
+	Disk        float64 `json:"disk,omitempty"`
+	NetRecv     float64 `json:"netRecv,omitempty"`
+	NetSent     float64 `json:"netSent,omitempty"`
+	DiskRead    float64 `json:"diskRead,omitempty"`
+	DiskWrite   float64 `json:"diskWrite,omitempty"`
+	SampleCount int     `json:"sampleCount,omitempty"`
+}
+
+func (this_ *MetricSampleModel) toSample() *metric.Sample {
+	return &metric.Sample{
+		Time:      this_.SampleTime,
+		Cpu:       this_.Cpu,
+		Mem:       this_.Mem,
+		Disk:      this_.Disk,
+		NetRecv:   this_.NetRecv,
+		NetSent:   this_.NetSent,
+		DiskRead:  this_.DiskRead,
+		DiskWrite: this_.DiskWrite,
+		Count:     this_.SampleCount,
+	}
+}
+
+// MetricAlertRuleModel 指标告警规则，HostId 为 0 时对用户所有主机生效，Webhooks 按行分隔
+type MetricAlertRuleModel struct {
+	RuleId     int64     `json:"ruleId,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	HostId     int64     `json:"hostId,omitempty"`
+	Metric     string    `json:"metric,omitempty"`
+	Operator   string    `json:"operator,omitempty"`
+	Threshold  float64   `json:"threshold,omitempty"`
+	Duration   int       `json:"duration,omitempty"` // 持续时间，秒
+	Webhooks   string    `json:"webhooks,omitempty"`
+	Enable     int       `json:"enable,omitempty"` // 1 为启用
+	UserId     int64     `json:"userId,omitempty"`
+	CreateTime time.Time `json:"createTime,omitempty"`
+	UpdateTime time.Time `json:"updateTime,omitempty"`
+}
+
+func (this_ *MetricAlertRuleModel) toAlertRule() *metric.AlertRule {
+	return &metric.AlertRule{
+		RuleId:    this_.RuleId,
+		Name:      this_.Name,
+		Metric:    this_.Metric,
+		Operator:  this_.Operator,
+		Threshold: this_.Threshold,
+		Duration:  int64(this_.Duration) * 1000,
+	}
+}
@@ -0,0 +1,373 @@
+package module_metric
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_id"
+	"teamide/internal/module/module_node"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/metric"
+	"time"
+)
+
+const (
+	// defaultCollectInterval 默认采集间隔，秒
+	defaultCollectInterval = 60
+	// minCollectInterval 最小采集间隔，秒
+	minCollectInterval = 10
+)
+
+// retention 各聚合级别的保留时间
+var retention = map[int]time.Duration{
+	metric.LevelRaw:    2 * 24 * time.Hour,
+	metric.LevelMinute: 30 * 24 * time.Hour,
+	metric.LevelHour:   365 * 24 * time.Hour,
+}
+
+// NewMetricService 根据库配置创建MetricService
+func NewMetricService(toolboxService_ *module_toolbox.ToolboxService, nodeService_ *module_node.NodeService) (res *MetricService) {
+	res = &MetricService{
+		ServerContext:  toolboxService_.ServerContext,
+		idService:      module_id.NewIDService(toolboxService_.ServerContext),
+		toolboxService: toolboxService_,
+		nodeService:    nodeService_,
+		evaluator:      metric.NewEvaluator(),
+		lastCollect:    map[int64]int64{},
+		collecting:     map[int64]bool{},
+		webhookClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+	return
+}
+
+// MetricService 主机指标采集、存储和告警
+type MetricService struct {
+	*context.ServerContext
+	idService      *module_id.IDService
+	toolboxService *module_toolbox.ToolboxService
+	nodeService    *module_node.NodeService
+	evaluator      *metric.Evaluator
+	lastCollect    map[int64]int64
+	collecting     map[int64]bool
+	collectLock    sync.Mutex
+	webhookClient  *http.Client
+}
+
+func (this_ *MetricService) ServerReady() (err error) {
+	// 每 10 秒检查需要采集的主机
+	if _, err = this_.CronHandler.AddFunc("0/10 * * * * ?", this_.collectDue); err != nil {
+		return
+	}
+	// 每分钟聚合上一分钟的原始采样
+	if _, err = this_.CronHandler.AddFunc("5 * * * * ?", this_.downsampleMinute); err != nil {
+		return
+	}
+	// 每小时聚合上一小时的分钟采样
+	if _, err = this_.CronHandler.AddFunc("10 0 * * * ?", this_.downsampleHour); err != nil {
+		return
+	}
+	// 每天 2 点 40 分清理过期的采样
+	_, err = this_.CronHandler.AddFunc("0 40 2 * * ?", this_.cleanSamples)
+	return
+}
+
+// SaveHost 新增或更新采集主机
+func (this_ *MetricService) SaveHost(host *MetricHostModel) (err error) {
+	switch host.Place {
+	case "local", "ssh", "node":
+	default:
+		err = errors.New("[" + host.Place + "]不支持指标采集")
+		return
+	}
+	if host.Place != "local" && host.PlaceId == "" {
+		err = errors.New("主机配置不能为空")
+		return
+	}
+	if host.CollectInterval <= 0 {
+		host.CollectInterval = defaultCollectInterval
+	} else if host.CollectInterval < minCollectInterval {
+		host.CollectInterval = minCollectInterval
+	}
+	host.UpdateTime = time.Now()
+
+	if host.HostId > 0 {
+		sql := `UPDATE ` + TableMetricHost + ` SET name=?,place=?,placeId=?,collectInterval=?,enable=?,updateTime=? WHERE hostId=? `
+
+		_, err = this_.DatabaseWorker.Exec(sql, []interface{}{
+			host.Name,
+			host.Place,
+			host.PlaceId,
+			host.CollectInterval,
+			host.Enable,
+			host.UpdateTime,
+			host.HostId,
+		})
+		if err != nil {
+			return
+		}
+		return
+	}
+	host.HostId, err = this_.idService.GetNextID(module_id.IDTypeMetricHost)
+	if err != nil {
+		return
+	}
+	if host.CreateTime.IsZero() {
+		host.CreateTime = time.Now()
+	}
+
+	sql := `INSERT INTO ` + TableMetricHost + `(hostId, name, place, placeId, collectInterval, enable, userId, createTime, updateTime) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{
+		host.HostId,
+		host.Name,
+		host.Place,
+		host.PlaceId,
+		host.CollectInterval,
+		host.Enable,
+		host.UserId,
+		host.CreateTime,
+		host.UpdateTime,
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// QueryHost 查询用户的采集主机
+func (this_ *MetricService) QueryHost(userId int64) (list []*MetricHostModel, err error) {
+
+	var sqlInfo = "SELECT * FROM " + TableMetricHost + " WHERE userId=? ORDER BY createTime ASC "
+
+	err = this_.DatabaseWorker.Query(sqlInfo, []interface{}{userId}, &list)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// queryEnableHost 查询所有启用的采集主机
+func (this_ *MetricService) queryEnableHost() (list []*MetricHostModel, err error) {
+
+	var sqlInfo = "SELECT * FROM " + TableMetricHost + " WHERE enable=? "
+
+	err = this_.DatabaseWorker.Query(sqlInfo, []interface{}{1}, &list)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// GetHost 获取采集主机
+func (this_ *MetricService) GetHost(hostId int64) (res *MetricHostModel, err error) {
+
+	var sqlInfo = "SELECT * FROM " + TableMetricHost + " WHERE hostId=? "
+
+	var list []*MetricHostModel
+	err = this_.DatabaseWorker.Query(sqlInfo, []interface{}{hostId}, &list)
+	if err != nil {
+		return
+	}
+	if len(list) > 0 {
+		res = list[0]
+	}
+	return
+}
+
+// DeleteHost 删除采集主机以及主机的采样和告警规则
+func (this_ *MetricService) DeleteHost(hostId int64) (err error) {
+
+	var sqlInfo = "DELETE FROM " + TableMetricHost + " WHERE hostId=? "
+	if _, err = this_.DatabaseWorker.Exec(sqlInfo, []interface{}{hostId}); err != nil {
+		return
+	}
+
+	sqlInfo = "DELETE FROM " + TableMetricSample + " WHERE hostId=? "
+	if _, err = this_.DatabaseWorker.Exec(sqlInfo, []interface{}{hostId}); err != nil {
+		return
+	}
+
+	sqlInfo = "DELETE FROM " + TableMetricAlertRule + " WHERE hostId=? "
+	if _, err = this_.DatabaseWorker.Exec(sqlInfo, []interface{}{hostId}); err != nil {
+		return
+	}
+	return
+}
+
+// SaveRule 新增或更新告警规则，保存前校验规则
+func (this_ *MetricService) SaveRule(rule *MetricAlertRuleModel) (err error) {
+	if strings.TrimSpace(rule.Name) == "" {
+		err = errors.New("规则名称不能为空")
+		return
+	}
+	if err = rule.toAlertRule().Check(); err != nil {
+		return
+	}
+	for _, one := range strings.Split(rule.Webhooks, "\n") {
+		one = strings.TrimSpace(one)
+		if one != "" && !strings.HasPrefix(one, "http://") && !strings.HasPrefix(one, "https://") {
+			err = errors.New("Webhook地址[" + one + "]错误")
+			return
+		}
+	}
+	rule.UpdateTime = time.Now()
+
+	if rule.RuleId > 0 {
+		sql := `UPDATE ` + TableMetricAlertRule + ` SET name=?,hostId=?,metric=?,operator=?,threshold=?,duration=?,webhooks=?,enable=?,updateTime=? WHERE ruleId=? `
+
+		_, err = this_.DatabaseWorker.Exec(sql, []interface{}{
+			rule.Name,
+			rule.HostId,
+			rule.Metric,
+			rule.Operator,
+			rule.Threshold,
+			rule.Duration,
+			rule.Webhooks,
+			rule.Enable,
+			rule.UpdateTime,
+			rule.RuleId,
+		})
+		if err != nil {
+			return
+		}
+		// 规则变更后重新计算
+		this_.evaluator.Remove(rule.RuleId)
+		return
+	}
+	rule.RuleId, err = this_.idService.GetNextID(module_id.IDTypeMetricAlertRule)
+	if err != nil {
+		return
+	}
+	if rule.CreateTime.IsZero() {
+		rule.CreateTime = time.Now()
+	}
+
+	sql := `INSERT INTO ` + TableMetricAlertRule +
+		`(ruleId, name, hostId, metric, operator, threshold, duration, webhooks, enable, userId, createTime, updateTime)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{
+		rule.RuleId,
+		rule.Name,
+		rule.HostId,
+		rule.Metric,
+		rule.Operator,
+		rule.Threshold,
+		rule.Duration,
+		rule.Webhooks,
+		rule.Enable,
+		rule.UserId,
+		rule.CreateTime,
+		rule.UpdateTime,
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// QueryRule 查询用户的告警规则
+func (this_ *MetricService) QueryRule(userId int64) (list []*MetricAlertRuleModel, err error) {
+
+	var sqlInfo = "SELECT * FROM " + TableMetricAlertRule + " WHERE userId=? ORDER BY createTime ASC "
+
+	err = this_.DatabaseWorker.Query(sqlInfo, []interface{}{userId}, &list)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// GetRule 获取告警规则
+func (this_ *MetricService) GetRule(ruleId int64) (res *MetricAlertRuleModel, err error) {
+
+	var sqlInfo = "SELECT * FROM " + TableMetricAlertRule + " WHERE ruleId=? "
+
+	var list []*MetricAlertRuleModel
+	err = this_.DatabaseWorker.Query(sqlInfo, []interface{}{ruleId}, &list)
+	if err != nil {
+		return
+	}
+	if len(list) > 0 {
+		res = list[0]
+	}
+	return
+}
+
+// queryHostRule 查询主机生效的告警规则
+func (this_ *MetricService) queryHostRule(host *MetricHostModel) (list []*MetricAlertRuleModel, err error) {
+
+	var sqlInfo = "SELECT * FROM " + TableMetricAlertRule + " WHERE userId=? AND enable=? AND (hostId=? OR hostId=?) "
+
+	err = this_.DatabaseWorker.Query(sqlInfo, []interface{}{host.UserId, 1, 0, host.HostId}, &list)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// DeleteRule 删除告警规则
+func (this_ *MetricService) DeleteRule(ruleId int64) (err error) {
+
+	var sqlInfo = "DELETE FROM " + TableMetricAlertRule + " WHERE ruleId=? "
+
+	_, err = this_.DatabaseWorker.Exec(sqlInfo, []interface{}{ruleId})
+	if err != nil {
+		return
+	}
+	this_.evaluator.Remove(ruleId)
+	return
+}
+
+// QuerySample 查询主机时间范围内的采样，level 小于 0 时根据时间跨度选择聚合级别
+func (this_ *MetricService) QuerySample(hostId int64, level int, startTime int64, endTime int64) (list []*metric.Sample, err error) {
+	if level < 0 {
+		level = metric.ChooseLevel(startTime, endTime)
+	}
+
+	var sqlInfo = "SELECT * FROM " + TableMetricSample + " WHERE hostId=? AND level=? AND sampleTime>=? AND sampleTime<=? ORDER BY sampleTime ASC "
+
+	var models []*MetricSampleModel
+	err = this_.DatabaseWorker.Query(sqlInfo, []interface{}{hostId, level, startTime, endTime}, &models)
+	if err != nil {
+		return
+	}
+	for _, one := range models {
+		list = append(list, one.toSample())
+	}
+	return
+}
+
+// saveSamples 保存采样，已存在的同一时间的采样先删除
+func (this_ *MetricService) saveSamples(hostId int64, level int, list []*metric.Sample) (err error) {
+	for _, one := range list {
+		var sqlInfo = "DELETE FROM " + TableMetricSample + " WHERE hostId=? AND level=? AND sampleTime=? "
+		if _, err = this_.DatabaseWorker.Exec(sqlInfo, []interface{}{hostId, level, one.Time}); err != nil {
+			return
+		}
+
+		sqlInfo = `INSERT INTO ` + TableMetricSample +
+			`(hostId, level, sampleTime, cpu, mem, disk, netRecv, netSent, diskRead, diskWrite, sampleCount)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) `
+
+		_, err = this_.DatabaseWorker.Exec(sqlInfo, []interface{}{
+			hostId,
+			level,
+			one.Time,
+			one.Cpu,
+			one.Mem,
+			one.Disk,
+			one.NetRecv,
+			one.NetSent,
+			one.DiskRead,
+			one.DiskWrite,
+			one.Count,
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -15,6 +15,7 @@ import (
 	"strings"
 	"teamide/internal/module/module_toolbox"
 	"teamide/pkg/base"
+	"teamide/pkg/ssh"
 )
 
 type api struct {
@@ -85,16 +86,16 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	return
 }
 
-func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *mongodb.Config, err error) {
+func (this_ *api) getConfig(requestBean *base.RequestBean, c *gin.Context) (config *mongodb.Config, sshConfig *ssh.Config, err error) {
 	config = &mongodb.Config{}
-	_, err = this_.toolboxService.BindConfig(requestBean, c, config)
+	sshConfig, err = this_.toolboxService.BindConfig(requestBean, c, config)
 	if err != nil {
 		return
 	}
 	return
 }
 
-func getService(config *mongodb.Config) (res mongodb.IService, err error) {
+func getService(config *mongodb.Config, sshConfig *ssh.Config) (res mongodb.IService, err error) {
 	key := "mongodb-" + config.Address
 	if config.Username != "" {
 		key += "-" + base.GetMd5String(key+config.Username)
@@ -105,16 +106,34 @@ func getService(config *mongodb.Config) (res mongodb.IService, err error) {
 	if config.CertPath != "" {
 		key += "-" + base.GetMd5String(key+config.CertPath)
 	}
+	if sshConfig != nil {
+		key += "-ssh-" + sshConfig.GetChainKey()
+	}
 
 	var serviceInfo *base.ServiceInfo
 	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
 		var s mongodb.IService
-		s, err = mongodb.New(config)
+		var tunnels []*ssh.Tunnel
+		serviceConfig := config
+		if sshConfig != nil {
+			// 客户端不支持 SSHClient 拨号，经由本地端口转发连接
+			var localAddress string
+			tunnels, localAddress, err = ssh.StartAddressTunnels(sshConfig, config.Address)
+			if err != nil {
+				util.Logger.Error("getService ssh tunnel error", zap.Any("key", key), zap.Error(err))
+				return
+			}
+			tunnelConfig := *config
+			tunnelConfig.Address = localAddress
+			serviceConfig = &tunnelConfig
+		}
+		s, err = mongodb.New(serviceConfig)
 		if err != nil {
 			util.Logger.Error("getService error", zap.Any("key", key), zap.Error(err))
 			if s != nil {
 				s.Close()
 			}
+			ssh.CloseTunnels(tunnels)
 			return
 		}
 		_, err = s.Count("_check_for_service_", "_check_for_service_", &map[string]interface{}{})
@@ -123,13 +142,17 @@ func getService(config *mongodb.Config) (res mongodb.IService, err error) {
 			if s != nil {
 				s.Close()
 			}
+			ssh.CloseTunnels(tunnels)
 			return
 		}
 		res = &base.ServiceInfo{
 			WaitTime:    10 * 60 * 1000,
 			LastUseTime: util.GetNowMilli(),
 			Service:     s,
-			Stop:        s.Close,
+			Stop: func() {
+				s.Close()
+				ssh.CloseTunnels(tunnels)
+			},
 		}
 		return
 	})
@@ -179,11 +202,11 @@ type Order struct {
 }
 
 func (this_ *api) check(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	_, err = getService(config)
+	_, err = getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -196,11 +219,11 @@ func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res inte
 	return
 }
 func (this_ *api) info(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	_, err = getService(config)
+	_, err = getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -209,11 +232,11 @@ func (this_ *api) info(requestBean *base.RequestBean, c *gin.Context) (res inter
 }
 
 func (this_ *api) databases(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -227,11 +250,11 @@ func (this_ *api) databases(requestBean *base.RequestBean, c *gin.Context) (res
 }
 
 func (this_ *api) databaseDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -250,11 +273,11 @@ func (this_ *api) databaseDelete(requestBean *base.RequestBean, c *gin.Context)
 }
 
 func (this_ *api) databaseDataTrim(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -280,11 +303,11 @@ func (this_ *api) databaseDataTrim(requestBean *base.RequestBean, c *gin.Context
 }
 
 func (this_ *api) collections(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -303,11 +326,11 @@ func (this_ *api) collections(requestBean *base.RequestBean, c *gin.Context) (re
 }
 
 func (this_ *api) collectionCreate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -325,11 +348,11 @@ func (this_ *api) collectionCreate(requestBean *base.RequestBean, c *gin.Context
 }
 
 func (this_ *api) collectionDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -347,11 +370,11 @@ func (this_ *api) collectionDelete(requestBean *base.RequestBean, c *gin.Context
 }
 
 func (this_ *api) collectionDataTrim(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -370,11 +393,11 @@ func (this_ *api) collectionDataTrim(requestBean *base.RequestBean, c *gin.Conte
 }
 
 func (this_ *api) indexList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -392,11 +415,11 @@ func (this_ *api) indexList(requestBean *base.RequestBean, c *gin.Context) (res
 }
 
 func (this_ *api) indexDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -414,11 +437,11 @@ func (this_ *api) indexDelete(requestBean *base.RequestBean, c *gin.Context) (re
 }
 
 func (this_ *api) indexCreate(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -447,11 +470,11 @@ func (this_ *api) indexCreate(requestBean *base.RequestBean, c *gin.Context) (re
 }
 
 func (this_ *api) insert(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -516,11 +539,11 @@ func (this_ *BaseRequest) getID() interface{} {
 }
 
 func (this_ *api) update(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -555,11 +578,11 @@ func getNumberValue(s string) (interface{}, error) {
 	return n.Int64()
 }
 func (this_ *api) queryPage(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -759,11 +782,11 @@ func (this_ *api) queryPage(requestBean *base.RequestBean, c *gin.Context) (res
 }
 
 func (this_ *api) delete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
@@ -787,11 +810,11 @@ func (this_ *api) delete(requestBean *base.RequestBean, c *gin.Context) (res int
 }
 
 func (this_ *api) deleteById(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
-	config, err := this_.getConfig(requestBean, c)
+	config, sshConfig, err := this_.getConfig(requestBean, c)
 	if err != nil {
 		return
 	}
-	service, err := getService(config)
+	service, err := getService(config, sshConfig)
 	if err != nil {
 		return
 	}
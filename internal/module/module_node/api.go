@@ -35,6 +35,7 @@ var (
 	enablePower       = base.AppendPower(&base.PowerAction{Action: "enable", Text: "节点启用", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
 	disablePower      = base.AppendPower(&base.PowerAction{Action: "disable", Text: "节点停用", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
 	deletePower       = base.AppendPower(&base.PowerAction{Action: "delete", Text: "节点删除", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
+	topologyPower     = base.AppendPower(&base.PowerAction{Action: "topology", Text: "节点拓扑", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
 
 	systemPower                 = base.AppendPower(&base.PowerAction{Action: "system", Text: "节点服务器信息", Parent: PowerNode, ShouldLogin: true, StandAlone: true})
 	systemInfoPower             = base.AppendPower(&base.PowerAction{Action: "info", Text: "节点服务器信息", Parent: systemPower, ShouldLogin: true, StandAlone: true})
@@ -63,6 +64,7 @@ func (this_ *NodeApi) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: enablePower, Do: this_.enable})
 	apis = append(apis, &base.ApiWorker{Power: disablePower, Do: this_.disable})
 	apis = append(apis, &base.ApiWorker{Power: deletePower, Do: this_.delete})
+	apis = append(apis, &base.ApiWorker{Power: topologyPower, Do: this_.topology, NotRecodeLog: true})
 
 	apis = append(apis, &base.ApiWorker{Power: systemInfoPower, Do: this_.nodeSystemInfo})
 	apis = append(apis, &base.ApiWorker{Power: systemMonitorDataPower, Do: this_.nodeSystemQueryMonitorData, NotRecodeLog: true})
@@ -104,6 +106,20 @@ func (this_ *NodeApi) context(requestBean *base.RequestBean, _ *gin.Context) (re
 	return
 }
 
+type TopologyRequest struct {
+}
+
+func (this_ *NodeApi) topology(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &TopologyRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+
+	res = this_.NodeService.nodeContext.getUserTopology(requestBean.JWT.UserId)
+	return
+}
+
 type StartRequest struct {
 }
 
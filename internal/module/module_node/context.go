@@ -122,7 +122,19 @@ func (this_ *NodeContext) GetNodeLineByFromTo(fromNodeId, toNodeId string) (line
 func (this_ *NodeContext) initContext() (err error) {
 
 	if this_.server == nil {
-		this_.server = &node.Server{}
+		server := &node.Server{}
+		if nodeConfig := this_.ServerConfig.Node; nodeConfig != nil && nodeConfig.TLS != nil && nodeConfig.TLS.Open {
+			err = server.SetTLS(&node.TLSConfig{
+				CertFile:     nodeConfig.TLS.Cert,
+				KeyFile:      nodeConfig.TLS.Key,
+				CAFile:       nodeConfig.TLS.CA,
+				Fingerprints: nodeConfig.TLS.Fingerprints,
+			})
+			if err != nil {
+				return
+			}
+		}
+		this_.server = server
 		this_.server.Start()
 	}
 
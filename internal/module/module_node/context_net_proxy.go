@@ -69,7 +69,13 @@ func (this_ *NodeContext) formatNetProxy(netProxyModel *NetProxyModel) (err erro
 		err = errors.New("网络代理输入地址不能为空")
 		return
 	}
-	if netProxyModel.OuterAddress == "" {
+	// SOCKS5、HTTP 代理由客户端指定目标，输出节点连接输入节点传递的目标地址
+	if node.IsDynamicType(netProxyModel.InnerType) {
+		netProxyModel.OuterType = node.NetProxyTypeDynamic
+	} else if node.IsDynamicType(netProxyModel.OuterType) {
+		err = errors.New("网络代理输入类型[" + netProxyModel.InnerType + "]不支持动态输出")
+		return
+	} else if netProxyModel.OuterAddress == "" {
 		err = errors.New("网络代理输出地址不能为空")
 		return
 	}
@@ -127,6 +133,11 @@ func (this_ *NodeContext) toAddNetProxyModel(netProxyModel *NetProxyModel) {
 		this_.Logger.Error("toAddNetProxyModel formatNetProxy error", zap.Error(err))
 		return
 	}
+	option, err := netProxyModel.GetOption()
+	if err != nil {
+		this_.Logger.Error("toAddNetProxyModel GetOption error", zap.Error(err))
+		return
+	}
 	lineNodeIdList := this_.GetNodeLineTo(netProxyModel.InnerServerId)
 	if len(lineNodeIdList) > 0 {
 		err = this_.GetServer().AddNetProxyInnerList(lineNodeIdList, []*node.NetProxyInner{
@@ -137,6 +148,10 @@ func (this_ *NodeContext) toAddNetProxyModel(netProxyModel *NetProxyModel) {
 				Address:        netProxyModel.InnerAddress,
 				Enabled:        netProxyModel.Enabled,
 				LineNodeIdList: netProxyModel.LineNodeIdList,
+				Username:       option.Username,
+				Password:       this_.decryptNetProxyPassword(option.Password),
+				AllowList:      option.AllowList,
+				Limit:          &option.NetProxyLimit,
 			},
 		})
 		if err != nil {
@@ -153,6 +168,7 @@ func (this_ *NodeContext) toAddNetProxyModel(netProxyModel *NetProxyModel) {
 				Address:               netProxyModel.OuterAddress,
 				Enabled:               netProxyModel.Enabled,
 				ReverseLineNodeIdList: netProxyModel.ReverseLineNodeIdList,
+				AllowList:             option.AllowList,
 			},
 		})
 		if err != nil {
@@ -0,0 +1,84 @@
+package module_node
+
+import (
+	"sync"
+	"teamide/pkg/node"
+)
+
+// TopologyNode 拓扑中的节点，Rtt 为本地沿节点线到该节点的往返时间，单位纳秒
+type TopologyNode struct {
+	ServerId         string   `json:"serverId,omitempty"`
+	Name             string   `json:"name,omitempty"`
+	Status           int8     `json:"status"`
+	LineServerIdList []string `json:"lineServerIdList,omitempty"`
+	Rtt              int64    `json:"rtt,omitempty"`
+	Error            string   `json:"error,omitempty"`
+}
+
+// TopologyLink 拓扑中的连接，由 ServerId 节点上报，每条连接两端各上报一次
+type TopologyLink struct {
+	ServerId string `json:"serverId,omitempty"`
+	*node.NodeLink
+	MonitorData *MonitorDataFormat `json:"monitorData,omitempty"`
+}
+
+type Topology struct {
+	NodeList []*TopologyNode `json:"nodeList,omitempty"`
+	LinkList []*TopologyLink `json:"linkList,omitempty"`
+}
+
+// getUserTopology 并发查询每个节点的连接，节点之间互不阻塞
+func (this_ *NodeContext) getUserTopology(userId int64) (topology *Topology) {
+	topology = &Topology{}
+
+	var nodeModelList = this_.getUserNodeModelList(userId)
+	var linkListList = make([][]*TopologyLink, len(nodeModelList))
+
+	var wait sync.WaitGroup
+	for i, nodeModel := range nodeModelList {
+		one := &TopologyNode{
+			ServerId: nodeModel.ServerId,
+			Name:     nodeModel.Name,
+			Status:   nodeModel.Status,
+		}
+		topology.NodeList = append(topology.NodeList, one)
+
+		lineNodeIdList := this_.GetNodeLineTo(nodeModel.ServerId)
+		if len(lineNodeIdList) == 0 {
+			one.Error = "暂无节点线"
+			continue
+		}
+		one.LineServerIdList = lineNodeIdList
+
+		wait.Add(1)
+		go func(index int, one *TopologyNode) {
+			defer wait.Done()
+
+			rtt, err := this_.GetServer().PingNode(one.LineServerIdList)
+			if err != nil {
+				one.Error = err.Error()
+				return
+			}
+			one.Rtt = rtt
+
+			linkList, err := this_.GetServer().GetNodeLinkList(one.LineServerIdList)
+			if err != nil {
+				one.Error = err.Error()
+				return
+			}
+			for _, link := range linkList {
+				linkListList[index] = append(linkListList[index], &TopologyLink{
+					ServerId:    one.ServerId,
+					NodeLink:    link,
+					MonitorData: ToMonitorDataFormat(link.MonitorData),
+				})
+			}
+		}(i, one)
+	}
+	wait.Wait()
+
+	for _, linkList := range linkListList {
+		topology.LinkList = append(topology.LinkList, linkList...)
+	}
+	return
+}
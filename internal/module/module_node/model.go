@@ -2,6 +2,7 @@ package module_node
 
 import (
 	"encoding/json"
+	"teamide/pkg/node"
 	"time"
 )
 
@@ -93,3 +94,21 @@ type NetProxyModel struct {
 	LineNodeIdList        []string `json:"lineNodeIdList,omitempty"`
 	ReverseLineNodeIdList []string `json:"reverseLineNodeIdList,omitempty"`
 }
+
+// NetProxyOption 网络代理扩展配置，保存在 option 中，用于 SOCKS5、HTTP 代理的认证和目标地址允许列表，以及连接数、带宽和客户端地址限制
+type NetProxyOption struct {
+	Username  string   `json:"username,omitempty"`
+	Password  string   `json:"password,omitempty"`
+	AllowList []string `json:"allowList,omitempty"`
+	node.NetProxyLimit
+}
+
+// GetOption 解析扩展配置，option 为空时返回空配置
+func (entity *NetProxyModel) GetOption() (option *NetProxyOption, err error) {
+	option = &NetProxyOption{}
+	if entity.Option == "" {
+		return
+	}
+	err = json.Unmarshal([]byte(entity.Option), option)
+	return
+}
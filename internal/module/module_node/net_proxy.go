@@ -1,11 +1,14 @@
 package module_node
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
 	"strings"
 	"teamide/internal/module/module_id"
+	"teamide/pkg/node"
 	"time"
 )
 
@@ -26,6 +29,48 @@ func (this_ *NodeService) GetNetProxy(netProxyId int64) (res *NetProxyModel, err
 	return
 }
 
+// GetNetProxyDialAddress 获取经由网络代理连接输出地址时需要连接的输入地址
+// 网络代理必须是当前用户创建的，输入节点必须是本服务的节点，连接输入地址即由节点线路连接到输出地址
+func (this_ *NodeService) GetNetProxyDialAddress(userId int64, netProxyId int64) (address string, err error) {
+	netProxy, err := this_.GetNetProxy(netProxyId)
+	if err != nil {
+		return
+	}
+	if netProxy == nil || netProxy.Deleted == 1 {
+		err = errors.New(fmt.Sprint("网络代理[", netProxyId, "]不存在"))
+		return
+	}
+	if netProxy.UserId != userId {
+		err = errors.New("网络代理[" + netProxy.Name + "]不属于当前用户，无法使用")
+		return
+	}
+	if netProxy.Enabled == 2 {
+		err = errors.New("网络代理[" + netProxy.Name + "]已停用")
+		return
+	}
+	if netProxy.InnerType != "" && netProxy.InnerType != "tcp" {
+		err = errors.New("网络代理[" + netProxy.Name + "]输入类型[" + netProxy.InnerType + "]不支持")
+		return
+	}
+	if this_.GetContext() == nil {
+		err = errors.New("node上下文未初始化")
+		return
+	}
+	var isLocal bool
+	for _, id := range this_.GetContext().GetServer().GetLocalNodeIdList() {
+		if id == netProxy.InnerServerId {
+			isLocal = true
+			break
+		}
+	}
+	if !isLocal {
+		err = errors.New("网络代理[" + netProxy.Name + "]输入节点不是本服务节点")
+		return
+	}
+	address = netProxy.InnerAddress
+	return
+}
+
 // QueryNetProxy 查询
 func (this_ *NodeService) QueryNetProxy(netProxy *NetProxyModel) (res []*NetProxyModel, err error) {
 
@@ -104,6 +149,46 @@ func (this_ *NodeService) CheckServerBindAddressExist(serverId string, bindAddre
 	return
 }
 
+// encryptNetProxyOption 加密扩展配置中的代理认证密码，已加密的密码不再加密
+func (this_ *NodeService) encryptNetProxyOption(netProxy *NetProxyModel) (err error) {
+	if netProxy.Option == "" {
+		return
+	}
+	optionMap := map[string]interface{}{}
+	// 使用JSONDecodeUseNumber 防止精度丢失
+	err = util.JSONDecodeUseNumber([]byte(netProxy.Option), &optionMap)
+	if err != nil {
+		return
+	}
+	str, ok := optionMap["password"].(string)
+	if !ok || str == "" || this_.Decryption.IsEncrypt(str) {
+		return
+	}
+	optionMap["password"], err = this_.Decryption.Encrypt(str)
+	if err != nil {
+		return
+	}
+	bs, err := json.Marshal(optionMap)
+	if err != nil {
+		return
+	}
+	netProxy.Option = string(bs)
+	return
+}
+
+// decryptNetProxyPassword 解密代理认证密码，未加密的密码直接返回
+func (this_ *NodeService) decryptNetProxyPassword(str string) (res string) {
+	if str == "" || !this_.Decryption.IsEncrypt(str) {
+		res = str
+		return
+	}
+	res, _ = this_.Decryption.Decrypt(str)
+	if res == "" {
+		res = str
+	}
+	return
+}
+
 // InsertNetProxy 新增
 func (this_ *NodeService) InsertNetProxy(netProxy *NetProxyModel) (rowsAffected int64, err error) {
 	checked, err := this_.CheckNetProxyNameExist(netProxy.Name)
@@ -114,6 +199,22 @@ func (this_ *NodeService) InsertNetProxy(netProxy *NetProxyModel) (rowsAffected
 		err = errors.New(fmt.Sprint("网络代理[", netProxy.Name, "]已存在"))
 		return
 	}
+	// 数据报按消息转发，输入、输出需同为 UDP 或同为 TCP
+	if node.IsPacketType(netProxy.InnerType) != node.IsPacketType(netProxy.OuterType) {
+		err = errors.New("网络代理[" + netProxy.Name + "]输入类型[" + netProxy.InnerType + "]与输出类型[" + netProxy.OuterType + "]不匹配")
+		return
+	}
+	option, err := netProxy.GetOption()
+	if err == nil {
+		err = option.Check()
+	}
+	if err != nil {
+		err = errors.New("网络代理[" + netProxy.Name + "]配置解析失败:" + err.Error())
+		return
+	}
+	if err = this_.encryptNetProxyOption(netProxy); err != nil {
+		return
+	}
 	checked, err = this_.CheckServerBindAddressExist(netProxy.InnerServerId, netProxy.InnerAddress)
 	if err != nil {
 		return
@@ -203,6 +304,17 @@ func (this_ *NodeService) UpdateNetProxy(netProxy *NetProxyModel) (rowsAffected
 
 // UpdateNetProxyOption 更新
 func (this_ *NodeService) UpdateNetProxyOption(netProxy *NetProxyModel) (rowsAffected int64, err error) {
+	option, err := netProxy.GetOption()
+	if err == nil {
+		err = option.Check()
+	}
+	if err != nil {
+		err = errors.New("网络代理配置解析失败:" + err.Error())
+		return
+	}
+	if err = this_.encryptNetProxyOption(netProxy); err != nil {
+		return
+	}
 
 	var values []interface{}
 
@@ -228,8 +340,9 @@ func (this_ *NodeService) UpdateNetProxyOption(netProxy *NetProxyModel) (rowsAff
 	var find = this_.nodeContext.getNetProxyModel(netProxy.NetProxyId)
 	if find != nil {
 		find.Option = netProxy.Option
+		// 认证、允许列表和连接策略保存在 option 中，需要同步到节点
+		this_.nodeContext.onUpdateNetProxyModel(find)
 	}
-	//this_.nodeContext.onUpdateNetProxyModel(node)
 	return
 }
 
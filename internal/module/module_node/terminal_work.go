@@ -6,7 +6,9 @@ import (
 	"go.uber.org/zap"
 	"teamide/pkg/node"
 	"teamide/pkg/system"
+	"teamide/pkg/telnet"
 	"teamide/pkg/terminal"
+	"time"
 )
 
 func NewTerminalService(nodeId string, nodeService *NodeService) (res *terminalService) {
@@ -18,12 +20,22 @@ func NewTerminalService(nodeId string, nodeService *NodeService) (res *terminalS
 	return
 }
 
+// NewTelnetTerminalService 经由节点线路连接 Telnet，连接由节点发起
+func NewTelnetTerminalService(nodeId string, telnetConfig *telnet.Config, nodeService *NodeService) (res *terminalService) {
+	res = NewTerminalService(nodeId, nodeService)
+	res.telnetConfig = telnetConfig
+	return
+}
+
 type terminalService struct {
 	nodeId      string
 	key         string
 	nodeLine    []string
 	nodeService *NodeService
 	bytesChan   chan []byte
+
+	// telnetConfig 不为空时由节点连接 Telnet
+	telnetConfig *telnet.Config
 }
 
 func (this_ *terminalService) getServer() (server *node.Server, err error) {
@@ -42,6 +54,9 @@ func (this_ *terminalService) getServer() (server *node.Server, err error) {
 }
 
 func (this_ *terminalService) IsWindows() (isWindows bool, err error) {
+	if this_.telnetConfig != nil {
+		return
+	}
 	var server *node.Server
 	server, err = this_.getServer()
 	if err != nil {
@@ -94,7 +109,7 @@ func (this_ *terminalService) Start(size *terminal.Size) (err error) {
 		return
 	}
 
-	this_.key, err = server.TerminalStart(this_.nodeLine, size,
+	this_.key, err = server.TerminalStart(this_.nodeLine, size, this_.telnetConfig,
 		func(buf []byte) (err error) {
 			this_.bytesChan <- buf
 			return
@@ -140,6 +155,10 @@ func (this_ *terminalService) Read(buf []byte) (n int, err error) {
 }
 
 func (this_ *terminalService) SystemInfo() (res *system.Info, err error) {
+	if this_.telnetConfig != nil {
+		err = errors.New("telnet 不支持获取系统信息")
+		return
+	}
 	var server *node.Server
 	server, err = this_.getServer()
 	if err != nil {
@@ -150,6 +169,10 @@ func (this_ *terminalService) SystemInfo() (res *system.Info, err error) {
 }
 
 func (this_ *terminalService) SystemMonitorData() (res *system.MonitorData, err error) {
+	if this_.telnetConfig != nil {
+		err = errors.New("telnet 不支持获取监控信息")
+		return
+	}
 	var server *node.Server
 	server, err = this_.getServer()
 	if err != nil {
@@ -158,3 +181,14 @@ func (this_ *terminalService) SystemMonitorData() (res *system.MonitorData, err
 	res = server.SystemMonitorData(this_.nodeLine)
 	return
 }
+
+// Exec 在节点上非交互执行命令，timeout 需小于节点调用的超时时间
+func (this_ *terminalService) Exec(command string, timeout time.Duration) (res *terminal.ExecResult, err error) {
+	var server *node.Server
+	server, err = this_.getServer()
+	if err != nil {
+		return
+	}
+	res, err = server.TerminalExec(this_.nodeLine, command, timeout)
+	return
+}
@@ -0,0 +1,93 @@
+package module_process
+
+import (
+	"github.com/gin-gonic/gin"
+	"teamide/internal/module/module_node"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/process"
+)
+
+type api struct {
+	*ProcessService
+}
+
+func NewApi(toolboxService_ *module_toolbox.ToolboxService, nodeService_ *module_node.NodeService) *api {
+	return &api{
+		ProcessService: NewProcessService(toolboxService_, nodeService_),
+	}
+}
+
+var (
+	// Power 进程管理 基本 权限
+	Power       = base.AppendPower(&base.PowerAction{Action: "process", Text: "进程管理", ShouldLogin: true, StandAlone: true})
+	listPower   = base.AppendPower(&base.PowerAction{Action: "list", Text: "进程列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	portsPower  = base.AppendPower(&base.PowerAction{Action: "ports", Text: "监听端口", ShouldLogin: true, StandAlone: true, Parent: Power})
+	signalPower = base.AppendPower(&base.PowerAction{Action: "signal", Text: "进程发送信号", ShouldLogin: true, StandAlone: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: listPower, Do: this_.list, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: portsPower, Do: this_.ports, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: signalPower, Do: this_.signal})
+
+	return
+}
+
+type Request struct {
+	Place   string `json:"place,omitempty"`
+	PlaceId string `json:"placeId,omitempty"`
+	Pid     int    `json:"pid,omitempty"`
+	Signal  string `json:"signal,omitempty"`
+	*process.Query
+}
+
+func (this_ *api) list(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	exec, err := this_.GetExec(requestBean, request.Place, request.PlaceId)
+	if err != nil {
+		return
+	}
+	list, err := process.List(exec)
+	if err != nil {
+		return
+	}
+	query := request.Query
+	if query == nil {
+		query = &process.Query{}
+	}
+	data := map[string]interface{}{}
+	data["total"] = len(list)
+	data["list"] = query.Apply(list)
+	res = data
+	return
+}
+
+func (this_ *api) ports(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	exec, err := this_.GetExec(requestBean, request.Place, request.PlaceId)
+	if err != nil {
+		return
+	}
+	res, err = process.ListPorts(exec)
+	return
+}
+
+func (this_ *api) signal(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	exec, err := this_.GetExec(requestBean, request.Place, request.PlaceId)
+	if err != nil {
+		return
+	}
+	err = process.Signal(exec, request.Pid, request.Signal)
+	return
+}
@@ -0,0 +1,80 @@
+package module_process
+
+import (
+	"errors"
+	"strconv"
+	"teamide/internal/context"
+	"teamide/internal/module/module_node"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/process"
+	"teamide/pkg/ssh"
+	"teamide/pkg/terminal"
+	"time"
+)
+
+// NewProcessService 创建进程管理服务
+func NewProcessService(toolboxService_ *module_toolbox.ToolboxService, nodeService_ *module_node.NodeService) (res *ProcessService) {
+	res = &ProcessService{
+		ServerContext:  toolboxService_.ServerContext,
+		toolboxService: toolboxService_,
+		nodeService:    nodeService_,
+	}
+	return
+}
+
+// ProcessService 进程管理，本地、SSH、节点统一通过非交互执行命令采集
+type ProcessService struct {
+	*context.ServerContext
+	toolboxService *module_toolbox.ToolboxService
+	nodeService    *module_node.NodeService
+}
+
+// GetExec 按位置获取执行命令的方法，SSH 需要验证当前用户可以操作该工具
+func (this_ *ProcessService) GetExec(requestBean *base.RequestBean, place string, placeId string) (exec process.ExecFunc, err error) {
+	switch place {
+	case "local":
+		exec = terminal.Exec
+	case "ssh":
+		if placeId == "" {
+			err = errors.New("SSH配置不能为空")
+			return
+		}
+		var id int64
+		id, err = strconv.ParseInt(placeId, 10, 64)
+		if err != nil {
+			return
+		}
+		var tD *module_toolbox.ToolboxModel
+		tD, err = this_.toolboxService.Get(id)
+		if err != nil {
+			return
+		}
+		if tD == nil || tD.Option == "" {
+			err = errors.New("SSH[" + placeId + "]配置不存在")
+			return
+		}
+		if err = this_.toolboxService.CheckToolboxPower(requestBean, tD); err != nil {
+			return
+		}
+		var config *ssh.Config
+		var sshConfig *ssh.Config
+		config, sshConfig, err = this_.toolboxService.GetSSHConfig(tD.Option)
+		if err != nil {
+			return
+		}
+		config.JumpConfig = sshConfig
+		exec = func(command string, timeout time.Duration) (res *terminal.ExecResult, err error) {
+			return ssh.Exec(*config, command, timeout)
+		}
+	case "node":
+		if placeId == "" {
+			err = errors.New("node配置不能为空")
+			return
+		}
+		exec = module_node.NewTerminalService(placeId, this_.nodeService).Exec
+	default:
+		err = errors.New("[" + place + "]不支持进程管理")
+	}
+	return
+}
@@ -98,8 +98,7 @@ func getServiceKey(redisConfig *redis.Config, sshConfig *ssh.Config) (key string
 		key += "-skip-verify"
 	}
 	if sshConfig != nil {
-		key += "-ssh-" + sshConfig.Address
-		key += "-ssh-" + sshConfig.Username
+		key += "-ssh-" + sshConfig.GetChainKey()
 	}
 	return
 }
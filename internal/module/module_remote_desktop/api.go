@@ -0,0 +1,247 @@
+package module_remote_desktop
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/base"
+	"teamide/pkg/guac"
+	"time"
+)
+
+type api struct {
+	*RemoteDesktopService
+}
+
+func NewApi(remoteDesktopService_ *RemoteDesktopService) *api {
+	return &api{
+		RemoteDesktopService: remoteDesktopService_,
+	}
+}
+
+var (
+	// Power 远程桌面 基本 权限
+	Power             = base.AppendPower(&base.PowerAction{Action: "remoteDesktop", Text: "远程桌面", ShouldLogin: true, StandAlone: true})
+	keyPower          = base.AppendPower(&base.PowerAction{Action: "key", Text: "远程桌面Key", ShouldLogin: true, StandAlone: true, Parent: Power})
+	websocketPower    = base.AppendPower(&base.PowerAction{Action: "websocket", Text: "远程桌面WebSocket", ShouldLogin: true, StandAlone: true, Parent: Power})
+	recordingList     = base.AppendPower(&base.PowerAction{Action: "recording/list", Text: "远程桌面录像列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	recordingDownload = base.AppendPower(&base.PowerAction{Action: "recording/download", Text: "远程桌面录像下载", ShouldLogin: true, StandAlone: true, Parent: Power})
+	recordingDelete   = base.AppendPower(&base.PowerAction{Action: "recording/delete", Text: "远程桌面录像删除", ShouldLogin: true, StandAlone: true, Parent: Power})
+)
+
+func (this_ *api) GetApis() (apis []*base.ApiWorker) {
+	apis = append(apis, &base.ApiWorker{Power: keyPower, Do: this_.key})
+	apis = append(apis, &base.ApiWorker{Power: websocketPower, Do: this_.websocket, IsWebSocket: true})
+	apis = append(apis, &base.ApiWorker{Power: recordingList, Do: this_.recordingList})
+	apis = append(apis, &base.ApiWorker{Power: recordingDownload, Do: this_.recordingDownload})
+	apis = append(apis, &base.ApiWorker{Power: recordingDelete, Do: this_.recordingDelete})
+
+	return
+}
+
+// key 校验工具权限并解析配置，返回 websocket 连接使用的 key
+func (this_ *api) key(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	config := &Config{}
+	sshConfig, err := this_.toolboxService.BindConfig(requestBean, c, config)
+	if err != nil {
+		return
+	}
+	// 远程桌面配置在本模块定义，密码在此解密，未加密的历史配置原样返回
+	config.Password = this_.toolboxService.DecryptOptionAttr(config.Password)
+	v := requestBean.GetExtend("toolboxModel")
+	if v == nil {
+		err = errors.New("远程桌面配置不存在")
+		return
+	}
+	toolboxModel := v.(*module_toolbox.ToolboxModel)
+	if toolboxModel.ToolboxType != "rdp" && toolboxModel.ToolboxType != "vnc" {
+		err = errors.New("工具类型[" + toolboxModel.ToolboxType + "]不是远程桌面")
+		return
+	}
+	if config.Hostname == "" {
+		err = errors.New("远程桌面主机不能为空")
+		return
+	}
+
+	one := &session{
+		key:        util.GetUUID(),
+		userId:     requestBean.JWT.UserId,
+		toolboxId:  toolboxModel.ToolboxId,
+		protocol:   toolboxModel.ToolboxType,
+		config:     config,
+		sshConfig:  sshConfig,
+		createTime: time.Now(),
+	}
+	this_.addSession(one)
+
+	data := make(map[string]interface{})
+	data["key"] = one.key
+	res = data
+	return
+}
+
+var upGrader = websocket.Upgrader{
+	ReadBufferSize:  32 * 1024,
+	WriteBufferSize: 32 * 1024,
+	// guacamole-common-js 的 WebSocketTunnel 使用 guacamole 子协议
+	Subprotocols: []string{"guacamole"},
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// websocket 参数 key、width、height、dpi，连接 guacd 失败时发送 error 指令后关闭
+func (this_ *api) websocket(request *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	if request.JWT == nil || request.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	key := c.Query("key")
+	if key == "" {
+		err = errors.New("key获取失败")
+		return
+	}
+	width, _ := strconv.Atoi(c.Query("width"))
+	height, _ := strconv.Atoi(c.Query("height"))
+	dpi, _ := strconv.Atoi(c.Query("dpi"))
+
+	//升级get请求为webSocket协议
+	ws, err := upGrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	res = base.HttpNotResponse
+
+	one := this_.bindSession(key, request.JWT.UserId, ws)
+	if one == nil {
+		err = errors.New("会话[" + key + "]不存在")
+	} else {
+		err = this_.start(one, width, height, dpi)
+	}
+	if err != nil {
+		this_.Logger.Error("remote desktop start error", zap.Any("key", key), zap.Error(err))
+		this_.removeSession(key)
+		// 519 为 guacamole 上游连接失败的状态码
+		_ = ws.WriteMessage(websocket.TextMessage, guac.NewInstruction("error", err.Error(), "519").Byte())
+		_ = ws.Close()
+		err = nil
+		return
+	}
+	return
+}
+
+type RecordingRequest struct {
+	ToolboxId int64  `json:"toolboxId,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// checkToolbox 录像按工具保存，录像包含其他用户的操作画面，开放的工具也只有创建者可以操作录像
+func (this_ *api) checkToolbox(requestBean *base.RequestBean, toolboxId int64) (err error) {
+	tD, err := this_.toolboxService.Get(toolboxId)
+	if err != nil {
+		return
+	}
+	if tD == nil {
+		err = errors.New(fmt.Sprint("工具[", toolboxId, "]不存在"))
+		return
+	}
+	if tD.UserId != 0 && (requestBean.JWT == nil || tD.UserId != requestBean.JWT.UserId) {
+		err = errors.New("工具[" + tD.Name + "]不属于当前用户，无法操作录像")
+		return
+	}
+	return
+}
+
+func (this_ *api) recordingList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &RecordingRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if err = this_.checkToolbox(requestBean, request.ToolboxId); err != nil {
+		return
+	}
+	res, err = this_.GetRecordings(request.ToolboxId)
+	return
+}
+
+func (this_ *api) recordingDownload(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	res = base.HttpNotResponse
+	defer func() {
+		if err != nil {
+			_, _ = c.Writer.WriteString(err.Error())
+		}
+	}()
+
+	request := map[string]string{}
+	err = c.Bind(&request)
+	if err != nil {
+		return
+	}
+	toolboxId, err := strconv.ParseInt(request["toolboxId"], 10, 64)
+	if err != nil {
+		return
+	}
+	if err = this_.checkToolbox(requestBean, toolboxId); err != nil {
+		return
+	}
+	path, err := this_.getRecordingPath(toolboxId, request["name"])
+	if err != nil {
+		return
+	}
+
+	fileName := request["name"]
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=utf-8''%s", url.QueryEscape(fileName)))
+	c.Header("download-file-name", fileName)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = errors.New("录像不存在")
+		}
+		return
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(c.Writer, f)
+	c.Status(http.StatusOK)
+	return
+}
+
+func (this_ *api) recordingDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &RecordingRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if err = this_.checkToolbox(requestBean, request.ToolboxId); err != nil {
+		return
+	}
+	path, err := this_.getRecordingPath(request.ToolboxId, request.Name)
+	if err != nil {
+		return
+	}
+	if this_.isRecording(path) {
+		err = errors.New("录像正在录制，无法删除")
+		return
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
@@ -0,0 +1,306 @@
+package module_remote_desktop
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_node"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/guac"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+const (
+	// defaultGuacdAddress guacd 默认地址
+	defaultGuacdAddress = "127.0.0.1:4822"
+	// dialTimeout 连接 guacd 超时时间
+	dialTimeout = 10 * time.Second
+	// keyExpire 会话创建后需要在该时间内建立 websocket
+	keyExpire = time.Minute
+	// recordingExt 录像文件后缀，内容为 guacd 发送到客户端的指令流，可用 guacenc 转换为视频
+	recordingExt = ".guac"
+)
+
+// Config 远程桌面配置，protocol 由工具类型决定
+type Config struct {
+	GuacdAddress string      `json:"guacdAddress,omitempty"`
+	NetProxyId   interface{} `json:"netProxyId,omitempty"`
+
+	Hostname   string `json:"hostname,omitempty"`
+	Port       int    `json:"port,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+	Domain     string `json:"domain,omitempty"`
+	Security   string `json:"security,omitempty"`
+	IgnoreCert bool   `json:"ignoreCert,omitempty"`
+	ColorDepth string `json:"colorDepth,omitempty"`
+
+	DisableCopy     bool `json:"disableCopy,omitempty"`
+	DisablePaste    bool `json:"disablePaste,omitempty"`
+	EnableRecording bool `json:"enableRecording,omitempty"`
+}
+
+// getParameters 转换为 guacd 连接参数
+func (this_ *Config) getParameters(protocol string) (parameters map[string]string) {
+	parameters = map[string]string{
+		"hostname": this_.Hostname,
+		"port":     strconv.Itoa(this_.Port),
+		"username": this_.Username,
+		"password": this_.Password,
+	}
+	if this_.ColorDepth != "" {
+		parameters["color-depth"] = this_.ColorDepth
+	}
+	if this_.DisableCopy {
+		parameters["disable-copy"] = "true"
+	}
+	if this_.DisablePaste {
+		parameters["disable-paste"] = "true"
+	}
+	if protocol == "rdp" {
+		parameters["domain"] = this_.Domain
+		parameters["security"] = this_.Security
+		if this_.IgnoreCert {
+			parameters["ignore-cert"] = "true"
+		}
+		// 客户端窗口变化时通过显示更新通道调整远程桌面分辨率
+		parameters["resize-method"] = "display-update"
+	}
+	return
+}
+
+// NewRemoteDesktopService 创建远程桌面服务
+func NewRemoteDesktopService(toolboxService_ *module_toolbox.ToolboxService, nodeService_ *module_node.NodeService) (res *RemoteDesktopService) {
+	res = &RemoteDesktopService{
+		ServerContext:  toolboxService_.ServerContext,
+		toolboxService: toolboxService_,
+		nodeService:    nodeService_,
+		sessionCache:   map[string]*session{},
+	}
+	return
+}
+
+// RemoteDesktopService RDP、VNC 远程桌面，由 guacd 连接远程桌面，websocket 转发 guacamole 协议
+type RemoteDesktopService struct {
+	*context.ServerContext
+	toolboxService   *module_toolbox.ToolboxService
+	nodeService      *module_node.NodeService
+	sessionCache     map[string]*session
+	sessionCacheLock sync.Mutex
+}
+
+func (this_ *RemoteDesktopService) ServerReady() (err error) {
+	this_.cleanRecording()
+	// 每天 2 点 50 分执行
+	_, err = this_.CronHandler.AddFunc("0 50 2 * * ?", this_.cleanRecording)
+	return
+}
+
+func (this_ *RemoteDesktopService) addSession(one *session) {
+	this_.sessionCacheLock.Lock()
+	defer this_.sessionCacheLock.Unlock()
+
+	// 清理创建后未建立 websocket 的会话
+	for key, find := range this_.sessionCache {
+		if find.ws == nil && time.Since(find.createTime) > keyExpire {
+			delete(this_.sessionCache, key)
+		}
+	}
+	this_.sessionCache[one.key] = one
+}
+
+// bindSession 会话只能由创建的用户连接一次
+func (this_ *RemoteDesktopService) bindSession(key string, userId int64, ws *websocket.Conn) (one *session) {
+	this_.sessionCacheLock.Lock()
+	defer this_.sessionCacheLock.Unlock()
+
+	find := this_.sessionCache[key]
+	if find == nil || find.userId != userId || find.ws != nil {
+		return
+	}
+	find.ws = ws
+	one = find
+	return
+}
+
+func (this_ *RemoteDesktopService) removeSession(key string) {
+	this_.sessionCacheLock.Lock()
+	defer this_.sessionCacheLock.Unlock()
+
+	delete(this_.sessionCache, key)
+}
+
+// dialGuacd 配置了网络代理时连接代理的输入地址经由节点线路连接 guacd，配置了 SSH 隧道时经由 SSH 连接，否则直接连接
+func (this_ *RemoteDesktopService) dialGuacd(userId int64, config *Config, sshConfig *ssh.Config) (conn net.Conn, err error) {
+	address := config.GuacdAddress
+	if address == "" {
+		address = defaultGuacdAddress
+	}
+	netProxyId := util.GetStringValue(config.NetProxyId)
+	if netProxyId != "" {
+		var id int64
+		id, err = strconv.ParseInt(netProxyId, 10, 64)
+		if err != nil {
+			return
+		}
+		address, err = this_.nodeService.GetNetProxyDialAddress(userId, id)
+		if err != nil {
+			return
+		}
+		conn, err = net.DialTimeout("tcp", address, dialTimeout)
+		return
+	}
+	if sshConfig != nil {
+		conn, err = ssh.Dial(sshConfig, "tcp", address)
+		return
+	}
+	conn, err = net.DialTimeout("tcp", address, dialTimeout)
+	return
+}
+
+// connect 连接 guacd 并握手
+func (this_ *RemoteDesktopService) connect(one *session, width int, height int, dpi int) (tunnel *guac.SimpleTunnel, err error) {
+	conn, err := this_.dialGuacd(one.userId, one.config, one.sshConfig)
+	if err != nil {
+		err = errors.New("连接guacd失败:" + err.Error())
+		return
+	}
+	guacConfig := guac.NewGuacamoleConfiguration()
+	guacConfig.Protocol = one.protocol
+	guacConfig.Parameters = one.config.getParameters(one.protocol)
+	if width > 0 && height > 0 {
+		guacConfig.OptimalScreenWidth = width
+		guacConfig.OptimalScreenHeight = height
+	}
+	if dpi > 0 {
+		guacConfig.OptimalResolution = dpi
+	}
+	guacConfig.AudioMimetypes = []string{"audio/L16", "rate=44100", "channels=2"}
+	guacConfig.ImageMimetypes = []string{"image/png", "image/jpeg", "image/webp"}
+
+	tunnel, err = guac.NewTunnel(conn, guacConfig)
+	if err != nil {
+		err = errors.New("guacd握手失败:" + err.Error())
+		return
+	}
+	return
+}
+
+type RecordingInfo struct {
+	ToolboxId int64  `json:"toolboxId"`
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	ModTime   int64  `json:"modTime,omitempty"`
+	// Recording 会话是否仍在录制
+	Recording bool `json:"recording,omitempty"`
+}
+
+func (this_ *RemoteDesktopService) getRecordingDir(toolboxId int64) string {
+	return this_.GetFilesDir() + fmt.Sprint("remote-desktop/toolbox-", toolboxId, "/")
+}
+
+// getRecordingPath 校验录像名称，防止跳出录像目录
+func (this_ *RemoteDesktopService) getRecordingPath(toolboxId int64, name string) (path string, err error) {
+	if name == "" || strings.Contains(name, "..") || strings.ContainsAny(name, "/\\") || !strings.HasSuffix(name, recordingExt) {
+		err = errors.New("录像[" + name + "]不合法")
+		return
+	}
+	path = this_.getRecordingDir(toolboxId) + name
+	return
+}
+
+func (this_ *RemoteDesktopService) isRecording(path string) bool {
+	this_.sessionCacheLock.Lock()
+	defer this_.sessionCacheLock.Unlock()
+
+	for _, one := range this_.sessionCache {
+		if one.recordingPath == path {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRecordings 查询工具的远程桌面录像
+func (this_ *RemoteDesktopService) GetRecordings(toolboxId int64) (recordings []*RecordingInfo, err error) {
+	dir := this_.getRecordingDir(toolboxId)
+	ex, _ := util.PathExists(dir)
+	if !ex {
+		return
+	}
+	fileList, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, f := range fileList {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), recordingExt) {
+			continue
+		}
+		stat, e := f.Info()
+		if e != nil {
+			continue
+		}
+		recordings = append(recordings, &RecordingInfo{
+			ToolboxId: toolboxId,
+			Name:      f.Name(),
+			Size:      stat.Size(),
+			ModTime:   util.GetMilliByTime(stat.ModTime()),
+			Recording: this_.isRecording(dir + f.Name()),
+		})
+	}
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].ModTime > recordings[j].ModTime
+	})
+	return
+}
+
+// cleanRecording 删除超过保留天数的远程桌面录像
+func (this_ *RemoteDesktopService) cleanRecording() {
+	saveDays := this_.ServerConfig.LogDataSaveDays
+	if saveDays <= 0 {
+		return
+	}
+	deleteBeforeTime := time.Now().AddDate(0, 0, -saveDays)
+	var deleteCount int
+	this_.Logger.Info("remote desktop recording clean task start", zap.Any("saveDays", saveDays))
+	defer func() {
+		this_.Logger.Info("remote desktop recording clean task end", zap.Any("saveDays", saveDays), zap.Any("deleteCount", deleteCount))
+	}()
+
+	recordingDir := this_.GetFilesDir() + "remote-desktop/"
+	toolboxDirs, err := os.ReadDir(recordingDir)
+	if err != nil {
+		return
+	}
+	for _, toolboxDir := range toolboxDirs {
+		if !toolboxDir.IsDir() {
+			continue
+		}
+		fileList, e := os.ReadDir(recordingDir + toolboxDir.Name())
+		if e != nil {
+			continue
+		}
+		for _, f := range fileList {
+			path := recordingDir + toolboxDir.Name() + "/" + f.Name()
+			stat, e := f.Info()
+			if e != nil || f.IsDir() || !stat.ModTime().Before(deleteBeforeTime) {
+				continue
+			}
+			if e = os.Remove(path); e != nil {
+				this_.Logger.Error("remote desktop recording remove error", zap.Any("path", path), zap.Error(e))
+				continue
+			}
+			deleteCount++
+		}
+	}
+}
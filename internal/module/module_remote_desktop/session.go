@@ -0,0 +1,166 @@
+package module_remote_desktop
+
+import (
+	"bytes"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"os"
+	"sync"
+	"teamide/pkg/guac"
+	"teamide/pkg/ssh"
+	"time"
+)
+
+// session 远程桌面会话，通过 key 接口创建，websocket 连接后连接 guacd
+type session struct {
+	key        string
+	userId     int64
+	toolboxId  int64
+	protocol   string
+	config     *Config
+	sshConfig  *ssh.Config
+	createTime time.Time
+
+	ws            *websocket.Conn
+	wsLock        sync.Mutex
+	tunnel        *guac.SimpleTunnel
+	recordingPath string
+	recording     *os.File
+	closeOnce     sync.Once
+}
+
+func (this_ *session) writeWS(bs []byte) (err error) {
+	this_.wsLock.Lock()
+	defer this_.wsLock.Unlock()
+
+	err = this_.ws.WriteMessage(websocket.TextMessage, bs)
+	return
+}
+
+func (this_ *session) close() {
+	this_.closeOnce.Do(func() {
+		if this_.tunnel != nil {
+			_ = this_.tunnel.Close()
+		}
+		if this_.ws != nil {
+			_ = this_.ws.Close()
+		}
+	})
+}
+
+// startRecording 录制 guacd 发送到客户端的指令流
+func (this_ *RemoteDesktopService) startRecording(one *session) {
+	if !one.config.EnableRecording || one.toolboxId == 0 {
+		return
+	}
+	dir := this_.getRecordingDir(one.toolboxId)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		this_.Logger.Error("remote desktop recording dir create error", zap.Any("dir", dir), zap.Error(err))
+		return
+	}
+	path := dir + time.Now().Format("20060102150405") + "-" + one.key + recordingExt
+	f, err := os.Create(path)
+	if err != nil {
+		this_.Logger.Error("remote desktop recording create error", zap.Any("path", path), zap.Error(err))
+		return
+	}
+	one.recording = f
+	// isRecording 在锁内读取录像路径
+	this_.sessionCacheLock.Lock()
+	one.recordingPath = path
+	this_.sessionCacheLock.Unlock()
+}
+
+// start 连接 guacd，先发送隧道编号，之后双向转发指令，任一方向结束时关闭会话
+func (this_ *RemoteDesktopService) start(one *session, width int, height int, dpi int) (err error) {
+	tunnel, err := this_.connect(one, width, height, dpi)
+	if err != nil {
+		return
+	}
+	one.tunnel = tunnel
+	if err = one.writeWS(guac.NewInstruction(guac.InternalDataOpcode, one.key).Byte()); err != nil {
+		one.close()
+		return
+	}
+	this_.startRecording(one)
+	this_.Logger.Info("remote desktop start", zap.Any("key", one.key), zap.Any("toolboxId", one.toolboxId), zap.Any("protocol", one.protocol))
+
+	go func() {
+		defer func() {
+			one.close()
+			this_.removeSession(one.key)
+			this_.Logger.Info("remote desktop end", zap.Any("key", one.key))
+		}()
+		go this_.readWS(one)
+		this_.readGuacd(one)
+	}()
+	return
+}
+
+// readGuacd guacd 发送到客户端，缓冲区中还有完整指令时合并发送，录像只在该协程中写入
+func (this_ *RemoteDesktopService) readGuacd(one *session) {
+	defer func() {
+		if one.recording != nil {
+			_ = one.recording.Close()
+		}
+		one.close()
+	}()
+
+	reader := one.tunnel.AcquireReader()
+	defer one.tunnel.ReleaseReader()
+
+	var buf bytes.Buffer
+	for {
+		ins, err := reader.ReadSome()
+		if err != nil {
+			this_.Logger.Debug("remote desktop read guacd end", zap.Any("key", one.key), zap.Error(err))
+			return
+		}
+		if bytes.HasPrefix(ins, guac.InternalOpcodeIns) {
+			continue
+		}
+		buf.Write(ins)
+		if reader.Available() && buf.Len() < guac.MaxGuacMessage {
+			continue
+		}
+		if one.recording != nil {
+			if _, err = one.recording.Write(buf.Bytes()); err != nil {
+				this_.Logger.Error("remote desktop recording write error", zap.Any("key", one.key), zap.Error(err))
+				_ = one.recording.Close()
+				one.recording = nil
+			}
+		}
+		if err = one.writeWS(buf.Bytes()); err != nil {
+			return
+		}
+		buf.Reset()
+	}
+}
+
+// readWS 客户端发送到 guacd，剪贴板、窗口大小变化等均为 guacamole 指令，直接转发
+// 内部指令不转发，ping 原样返回给客户端
+func (this_ *RemoteDesktopService) readWS(one *session) {
+	defer one.close()
+
+	writer := one.tunnel.AcquireWriter()
+	defer one.tunnel.ReleaseWriter()
+
+	for {
+		_, bs, err := one.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		if bytes.HasPrefix(bs, guac.InternalOpcodeIns) {
+			ins, e := guac.Parse(bs)
+			if e == nil && len(ins.Args) > 0 && ins.Args[0] == "ping" {
+				if err = one.writeWS(bs); err != nil {
+					return
+				}
+			}
+			continue
+		}
+		if _, err = writer.Write(bs); err != nil {
+			return
+		}
+	}
+}
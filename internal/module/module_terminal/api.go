@@ -8,14 +8,15 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
-	goSSH "golang.org/x/crypto/ssh"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"teamide/internal/module/module_node"
+	"teamide/internal/module/module_power"
 	"teamide/internal/module/module_toolbox"
+	"teamide/internal/module/module_user"
 	"teamide/pkg/base"
 	"teamide/pkg/ssh"
 	"teamide/pkg/terminal"
@@ -24,13 +25,17 @@ import (
 type api struct {
 	*WorkerFactory
 	terminalCommandService *TerminalCommandService
+	userService            *module_user.UserService
 }
 
 func NewApi(toolboxService_ *module_toolbox.ToolboxService, nodeService_ *module_node.NodeService, res *TerminalCommandService) *api {
-	return &api{
+	api_ := &api{
 		WorkerFactory:          NewWorkerFactory(toolboxService_, nodeService_),
 		terminalCommandService: NewTerminalCommandService(toolboxService_.ServerContext),
+		userService:            module_user.NewUserService(toolboxService_.ServerContext),
 	}
+	api_.WorkerFactory.terminalCommandService = api_.terminalCommandService
+	return api_
 }
 
 var (
@@ -47,9 +52,41 @@ var (
 	deleteLog       = base.AppendPower(&base.PowerAction{Action: "deleteLog", Text: "deleteLog", ShouldLogin: true, StandAlone: true, Parent: Power})
 	cleanLog        = base.AppendPower(&base.PowerAction{Action: "cleanLog", Text: "cleanLog", ShouldLogin: true, StandAlone: true, Parent: Power})
 	downloadLog     = base.AppendPower(&base.PowerAction{Action: "downloadLog", Text: "downloadLog", ShouldLogin: true, StandAlone: true, Parent: Power})
+	searchPower     = base.AppendPower(&base.PowerAction{Action: "search", Text: "搜索日志和历史命令", ShouldLogin: true, StandAlone: true, Parent: Power})
+	readLog         = base.AppendPower(&base.PowerAction{Action: "readLog", Text: "读取日志", ShouldLogin: true, StandAlone: true, Parent: Power})
 	upload          = base.AppendPower(&base.PowerAction{Action: "upload", Text: "upload", ShouldLogin: true, StandAlone: true, Parent: Power})
 	systemInfo      = base.AppendPower(&base.PowerAction{Action: "system/info", Text: "system", ShouldLogin: true, StandAlone: true, Parent: Power})
 	systemMonitor   = base.AppendPower(&base.PowerAction{Action: "system/monitor", Text: "system", ShouldLogin: true, StandAlone: true, Parent: Power})
+	sessionsPower   = base.AppendPower(&base.PowerAction{Action: "sessions", Text: "终端会话列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	recordingList     = base.AppendPower(&base.PowerAction{Action: "recording/list", Text: "录像列表", ShouldLogin: true, StandAlone: true, Parent: Power})
+	recordingStream   = base.AppendPower(&base.PowerAction{Action: "recording/stream", Text: "录像回放", ShouldLogin: true, StandAlone: true, Parent: Power})
+	recordingDownload = base.AppendPower(&base.PowerAction{Action: "recording/download", Text: "录像下载", ShouldLogin: true, StandAlone: true, Parent: Power})
+
+	share          = base.AppendPower(&base.PowerAction{Action: "share", Text: "终端共享", ShouldLogin: true, StandAlone: true, Parent: Power})
+	shareInvite    = base.AppendPower(&base.PowerAction{Action: "invite", Text: "邀请", ShouldLogin: true, StandAlone: true, Parent: share})
+	shareRevoke    = base.AppendPower(&base.PowerAction{Action: "revoke", Text: "取消邀请", ShouldLogin: true, StandAlone: true, Parent: share})
+	shareInfo      = base.AppendPower(&base.PowerAction{Action: "info", Text: "共享信息", ShouldLogin: true, StandAlone: true, Parent: share})
+	shareWebsocket = base.AppendPower(&base.PowerAction{Action: "websocket", Text: "加入共享", ShouldLogin: true, StandAlone: true, Parent: share})
+
+	broadcast         = base.AppendPower(&base.PowerAction{Action: "broadcast", Text: "终端广播", ShouldLogin: true, StandAlone: true, Parent: Power})
+	broadcastList     = base.AppendPower(&base.PowerAction{Action: "list", Text: "广播组列表", ShouldLogin: true, StandAlone: true, Parent: broadcast})
+	broadcastSave     = base.AppendPower(&base.PowerAction{Action: "save", Text: "保存广播组", ShouldLogin: true, StandAlone: true, Parent: broadcast})
+	broadcastMute     = base.AppendPower(&base.PowerAction{Action: "mute", Text: "广播组成员静音", ShouldLogin: true, StandAlone: true, Parent: broadcast})
+	broadcastDissolve = base.AppendPower(&base.PowerAction{Action: "dissolve", Text: "解散广播组", ShouldLogin: true, StandAlone: true, Parent: broadcast})
+
+	batch        = base.AppendPower(&base.PowerAction{Action: "batch", Text: "批量执行", ShouldLogin: true, StandAlone: true, Parent: Power})
+	batchRun     = base.AppendPower(&base.PowerAction{Action: "run", Text: "执行", ShouldLogin: true, StandAlone: true, Parent: batch})
+	batchReports = base.AppendPower(&base.PowerAction{Action: "reports", Text: "报告列表", ShouldLogin: true, StandAlone: true, Parent: batch})
+	batchReport  = base.AppendPower(&base.PowerAction{Action: "report", Text: "报告详情", ShouldLogin: true, StandAlone: true, Parent: batch})
+	batchDelete  = base.AppendPower(&base.PowerAction{Action: "delete", Text: "删除报告", ShouldLogin: true, StandAlone: true, Parent: batch})
+	batchDiff    = base.AppendPower(&base.PowerAction{Action: "diff", Text: "对比主机输出", ShouldLogin: true, StandAlone: true, Parent: batch})
+
+	transferPower    = base.AppendPower(&base.PowerAction{Action: "transfer", Text: "文件传输", ShouldLogin: true, StandAlone: true, Parent: Power})
+	transferUpload   = base.AppendPower(&base.PowerAction{Action: "upload", Text: "上传到远程", ShouldLogin: true, StandAlone: true, Parent: transferPower})
+	transferCancel   = base.AppendPower(&base.PowerAction{Action: "cancel", Text: "取消传输", ShouldLogin: true, StandAlone: true, Parent: transferPower})
+	transferFiles    = base.AppendPower(&base.PowerAction{Action: "files", Text: "已下载文件", ShouldLogin: true, StandAlone: true, Parent: transferPower})
+	transferDownload = base.AppendPower(&base.PowerAction{Action: "download", Text: "下载文件", ShouldLogin: true, StandAlone: true, Parent: transferPower})
 
 	command       = base.AppendPower(&base.PowerAction{Action: "command", Text: "命令行", ShouldLogin: true, StandAlone: true, Parent: Power})
 	commandSave   = base.AppendPower(&base.PowerAction{Action: "save", Text: "插入", ShouldLogin: true, StandAlone: true, Parent: command})
@@ -57,6 +94,18 @@ var (
 	commandCount  = base.AppendPower(&base.PowerAction{Action: "count", Text: "查询", ShouldLogin: true, StandAlone: true, Parent: command})
 	commandDelete = base.AppendPower(&base.PowerAction{Action: "delete", Text: "删除", ShouldLogin: true, StandAlone: true, Parent: command})
 	commandClean  = base.AppendPower(&base.PowerAction{Action: "clean", Text: "清理", ShouldLogin: true, StandAlone: true, Parent: command})
+
+	// 命令策略只在服务端模式下生效，由管理员维护
+	policy       = base.AppendPower(&base.PowerAction{Action: "policy", Text: "命令策略", ShouldLogin: true, ShouldPower: true, Parent: Power})
+	policyList   = base.AppendPower(&base.PowerAction{Action: "list", Text: "策略列表", ShouldLogin: true, ShouldPower: true, Parent: policy})
+	policySave   = base.AppendPower(&base.PowerAction{Action: "save", Text: "保存策略", ShouldLogin: true, ShouldPower: true, Parent: policy})
+	policyDelete = base.AppendPower(&base.PowerAction{Action: "delete", Text: "删除策略", ShouldLogin: true, ShouldPower: true, Parent: policy})
+	policyTest   = base.AppendPower(&base.PowerAction{Action: "test", Text: "测试策略", ShouldLogin: true, ShouldPower: true, Parent: policy})
+
+	trigger       = base.AppendPower(&base.PowerAction{Action: "trigger", Text: "输出触发器", ShouldLogin: true, StandAlone: true, Parent: Power})
+	triggerList   = base.AppendPower(&base.PowerAction{Action: "list", Text: "触发器列表", ShouldLogin: true, StandAlone: true, Parent: trigger})
+	triggerSave   = base.AppendPower(&base.PowerAction{Action: "save", Text: "保存触发器", ShouldLogin: true, StandAlone: true, Parent: trigger})
+	triggerDelete = base.AppendPower(&base.PowerAction{Action: "delete", Text: "删除触发器", ShouldLogin: true, StandAlone: true, Parent: trigger})
 )
 
 func (this_ *api) GetApis() (apis []*base.ApiWorker) {
@@ -65,18 +114,48 @@ func (this_ *api) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: changeSizePower, Do: this_.changeSize})
 	apis = append(apis, &base.ApiWorker{Power: check, Do: this_.check})
 	apis = append(apis, &base.ApiWorker{Power: closePower, Do: this_.close})
+	apis = append(apis, &base.ApiWorker{Power: sessionsPower, Do: this_.sessions, NotRecodeLog: true})
 	apis = append(apis, &base.ApiWorker{Power: getLogs, Do: this_.getLogs})
 	apis = append(apis, &base.ApiWorker{Power: deleteLog, Do: this_.deleteLog})
 	apis = append(apis, &base.ApiWorker{Power: cleanLog, Do: this_.cleanLog})
 	apis = append(apis, &base.ApiWorker{Power: downloadLog, Do: this_.downloadLog})
+	apis = append(apis, &base.ApiWorker{Power: searchPower, Do: this_.search, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: readLog, Do: this_.readLog, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: recordingList, Do: this_.recordingList})
+	apis = append(apis, &base.ApiWorker{Power: recordingStream, Do: this_.recordingStream, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: recordingDownload, Do: this_.recordingDownload})
 	apis = append(apis, &base.ApiWorker{Power: upload, Do: this_.upload, IsUpload: true, NotRecodeLog: true})
 	apis = append(apis, &base.ApiWorker{Power: systemInfo, Do: this_.systemInfo})
 	apis = append(apis, &base.ApiWorker{Power: systemMonitor, Do: this_.systemMonitor, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: shareInvite, Do: this_.shareInvite})
+	apis = append(apis, &base.ApiWorker{Power: shareRevoke, Do: this_.shareRevoke})
+	apis = append(apis, &base.ApiWorker{Power: shareInfo, Do: this_.shareInfo, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: shareWebsocket, Do: this_.shareWebsocket, IsWebSocket: true})
+	apis = append(apis, &base.ApiWorker{Power: broadcastList, Do: this_.broadcastList, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: broadcastSave, Do: this_.broadcastSave})
+	apis = append(apis, &base.ApiWorker{Power: broadcastMute, Do: this_.broadcastMute})
+	apis = append(apis, &base.ApiWorker{Power: broadcastDissolve, Do: this_.broadcastDissolve})
+	apis = append(apis, &base.ApiWorker{Power: batchRun, Do: this_.batchRun})
+	apis = append(apis, &base.ApiWorker{Power: batchReports, Do: this_.batchReports, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: batchReport, Do: this_.batchReport, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: batchDelete, Do: this_.batchDelete})
+	apis = append(apis, &base.ApiWorker{Power: batchDiff, Do: this_.batchDiff, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: transferUpload, Do: this_.transferUpload})
+	apis = append(apis, &base.ApiWorker{Power: transferCancel, Do: this_.transferCancel})
+	apis = append(apis, &base.ApiWorker{Power: transferFiles, Do: this_.transferFiles, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: transferDownload, Do: this_.transferDownload})
 	apis = append(apis, &base.ApiWorker{Power: commandSave, Do: this_.commandSave, NotRecodeLog: true})
 	apis = append(apis, &base.ApiWorker{Power: commandQuery, Do: this_.commandQuery, NotRecodeLog: true})
 	apis = append(apis, &base.ApiWorker{Power: commandCount, Do: this_.commandCount, NotRecodeLog: true})
 	apis = append(apis, &base.ApiWorker{Power: commandClean, Do: this_.commandClean, NotRecodeLog: true})
 	apis = append(apis, &base.ApiWorker{Power: commandDelete, Do: this_.commandDelete, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: policyList, Do: this_.policyList, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: policySave, Do: this_.policySave})
+	apis = append(apis, &base.ApiWorker{Power: policyDelete, Do: this_.policyDelete})
+	apis = append(apis, &base.ApiWorker{Power: policyTest, Do: this_.policyTest, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: triggerList, Do: this_.triggerList, NotRecodeLog: true})
+	apis = append(apis, &base.ApiWorker{Power: triggerSave, Do: this_.triggerSave})
+	apis = append(apis, &base.ApiWorker{Power: triggerDelete, Do: this_.triggerDelete})
 
 	return
 }
@@ -150,16 +229,32 @@ func (this_ *api) websocket(request *base.RequestBean, c *gin.Context) (res inte
 
 	service := this_.GetService(key)
 	if service != nil {
-		err = errors.New("会话[" + key + "]已存在")
-
-		_ = ws.WriteMessage(websocket.BinaryMessage, []byte("service create error:"+err.Error()))
-		this_.Logger.Error("websocket start error", zap.Error(err))
-		_ = ws.Close()
+		// 会话已存在，重新连接
+		if service.userId != request.JWT.UserId {
+			err = errors.New("会话[" + key + "]已存在")
+		} else {
+			err = service.attach(ws)
+		}
+		if err != nil {
+			_ = ws.WriteMessage(websocket.BinaryMessage, []byte("service attach error:"+err.Error()))
+			this_.Logger.Error("websocket attach error", zap.Error(err))
+			_ = ws.Close()
+			return
+		}
+		if cols > 0 && rows > 0 {
+			size := &terminal.Size{Cols: cols, Rows: rows}
+			if e := service.service.ChangeSize(size); e == nil {
+				service.recordResize(size)
+			}
+		}
+		res = base.HttpNotResponse
 		return
 	}
 
 	err = this_.Start(key,
 		&CreateParam{
+			userId:   request.JWT.UserId,
+			userName: request.JWT.Name,
 			place:    place,
 			placeId:  placeId,
 			workerId: workerId,
@@ -181,6 +276,25 @@ func (this_ *api) websocket(request *base.RequestBean, c *gin.Context) (res inte
 	return
 }
 
+// getWriteWorker 通过 key 获取会话，只有会话所有者或者有读写权限的被邀请用户可以操作，会话不存在时返回 nil
+func (this_ *api) getWriteWorker(requestBean *base.RequestBean, key string) (worker *Worker, err error) {
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	worker = this_.GetService(key)
+	if worker == nil || worker.service == nil {
+		worker = nil
+		return
+	}
+	if !worker.canWrite(requestBean.JWT.UserId) {
+		worker = nil
+		err = errors.New("没有操作会话[" + key + "]的权限")
+		return
+	}
+	return
+}
+
 func (this_ *api) upload(r *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 
 	key := c.PostForm("key")
@@ -197,23 +311,30 @@ func (this_ *api) upload(r *base.RequestBean, c *gin.Context) (res interface{},
 		}
 	}
 	//fmt.Println("bs:", bs)
-	service := this_.GetService(key)
-	if service == nil || service.service == nil {
+	service, err := this_.getWriteWorker(r, key)
+	if err != nil {
+		return
+	}
+	if service == nil {
 		err = errors.New("会话[" + key + "]不存在")
 		return
 	}
+	bs = service.checkInput(bs, false)
+	if len(bs) == 0 {
+		return
+	}
 	_, err = service.service.Write(bs)
 	return
 }
 
-func (this_ *api) systemInfo(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+func (this_ *api) systemInfo(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	request := &Request{}
 	if !base.RequestJSON(request, c) {
 		return
 	}
 
-	service := this_.GetService(request.Key)
-	if service == nil || service.service == nil {
+	service, err := this_.getWriteWorker(requestBean, request.Key)
+	if err != nil || service == nil {
 		return
 	}
 
@@ -222,14 +343,14 @@ func (this_ *api) systemInfo(_ *base.RequestBean, c *gin.Context) (res interface
 	return
 }
 
-func (this_ *api) systemMonitor(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+func (this_ *api) systemMonitor(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	request := &Request{}
 	if !base.RequestJSON(request, c) {
 		return
 	}
 
-	service := this_.GetService(request.Key)
-	if service == nil || service.service == nil {
+	service, err := this_.getWriteWorker(requestBean, request.Key)
+	if err != nil || service == nil {
 		return
 	}
 
@@ -330,40 +451,51 @@ func (this_ *api) check(_ *base.RequestBean, c *gin.Context) (res interface{}, e
 	if err != nil {
 		return
 	}
-	if sshConfig != nil {
-		var sshClient *goSSH.Client
-		sshClient, err = ssh.NewClient(*sshConfig)
-		if err != nil {
-			util.Logger.Error("getSSHService ssh NewClient error", zap.Any("address", sshConfig.Address), zap.Error(err))
-			return
-		}
-		config.SSHClient = sshClient
-	}
+	// 经由跳板链连接，跳板随 SSH 连接一起关闭
+	config.JumpConfig = sshConfig
 	service := ssh.NewTerminalService(config, "", "")
 
 	err = service.TestClient()
 	return
 }
 
-func (this_ *api) close(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+// sessions 当前用户的终端会话，页面刷新后可通过 key 重新连接
+func (this_ *api) sessions(requestBean *base.RequestBean, _ *gin.Context) (res interface{}, err error) {
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	res = this_.WorkerFactory.getSessions(requestBean.JWT.UserId)
+	return
+}
+
+func (this_ *api) close(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	request := &Request{}
 	if !base.RequestJSON(request, c) {
 		return
 	}
+	service, err := this_.getWriteWorker(requestBean, request.Key)
+	if err != nil || service == nil {
+		return
+	}
 	this_.stopService(request.Key)
 	return
 }
 
-func (this_ *api) changeSize(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+func (this_ *api) changeSize(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
 	request := &Request{}
 	if !base.RequestJSON(request, c) {
 		return
 	}
-	service := this_.GetService(request.Key)
-	if service == nil || service.service == nil {
+	service, err := this_.getWriteWorker(requestBean, request.Key)
+	if err != nil || service == nil {
 		return
 	}
 	err = service.service.ChangeSize(request.Size)
+	if err != nil {
+		return
+	}
+	service.recordResize(request.Size)
 	return
 }
 
@@ -387,6 +519,7 @@ func (this_ *api) deleteLog(_ *base.RequestBean, c *gin.Context) (res interface{
 	if ex, _ := util.PathExists(path); ex {
 		err = os.Remove(path)
 	}
+	this_.WorkerFactory.removeCommandIndex(request.Place, request.PlaceId, request.WorkerId)
 	return
 }
 
@@ -403,6 +536,7 @@ func (this_ *api) cleanLog(_ *base.RequestBean, c *gin.Context) (res interface{}
 	f, err := os.Create(path)
 	defer func() { _ = f.Close() }()
 	_, err = f.WriteString("")
+	this_.WorkerFactory.removeCommandIndex(request.Place, request.PlaceId, request.WorkerId)
 	return
 }
 
@@ -448,3 +582,209 @@ func (this_ *api) downloadLog(_ *base.RequestBean, c *gin.Context) (res interfac
 	c.Status(http.StatusOK)
 	return
 }
+
+// checkRecordingPlace 验证当前用户可以查看该位置的录像，SSH、Telnet 按工具权限验证
+// 其它位置（本地、节点）只能查看自己的会话，ownerOnly 为 true 时需要按会话所有者过滤，超管可以查看所有会话
+func (this_ *api) checkRecordingPlace(requestBean *base.RequestBean, place string, placeId string) (ownerOnly bool, err error) {
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	if err = checkPathName(place, placeId); err != nil {
+		return
+	}
+	switch place {
+	case "ssh", "telnet":
+		var toolboxId int64
+		toolboxId, err = strconv.ParseInt(placeId, 10, 64)
+		if err != nil {
+			return
+		}
+		var toolbox *module_toolbox.ToolboxModel
+		toolbox, err = this_.toolboxService.Get(toolboxId)
+		if err != nil {
+			return
+		}
+		if toolbox == nil {
+			err = errors.New("工具[" + placeId + "]不存在")
+			return
+		}
+		err = this_.toolboxService.CheckToolboxPower(requestBean, toolbox)
+		return
+	}
+	isSuper, err := this_.isSuperUser(requestBean.JWT.UserId)
+	if err != nil {
+		return
+	}
+	ownerOnly = !isSuper
+	return
+}
+
+// isSuperUser 单机模式或者超管角色的用户
+func (this_ *api) isSuperUser(userId int64) (isSuper bool, err error) {
+	if !this_.IsServer {
+		isSuper = true
+		return
+	}
+	roles, err := module_power.NewPowerUserService(this_.ServerContext).QueryPowerRolesByUserId(userId)
+	if err != nil {
+		return
+	}
+	for _, role := range roles {
+		if role.RoleType == base.SuperRoleType {
+			isSuper = true
+			return
+		}
+	}
+	return
+}
+
+// checkRecording 验证当前用户可以查看该会话的录像
+func (this_ *api) checkRecording(requestBean *base.RequestBean, place string, placeId string, workerId string) (err error) {
+	ownerOnly, err := this_.checkRecordingPlace(requestBean, place, placeId)
+	if err != nil || !ownerOnly {
+		return
+	}
+	if err = checkPathName(workerId); err != nil {
+		return
+	}
+	if this_.readSessionMeta(place, placeId, workerId).UserId != requestBean.JWT.UserId {
+		err = errors.New("会话[" + workerId + "]不属于当前用户，无法查看录像")
+		return
+	}
+	return
+}
+
+func (this_ *api) recordingList(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &Request{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	ownerOnly, err := this_.checkRecordingPlace(requestBean, request.Place, request.PlaceId)
+	if err != nil {
+		return
+	}
+
+	recordings, err := this_.WorkerFactory.getRecordings(request.Place, request.PlaceId)
+	if err != nil {
+		return
+	}
+	if ownerOnly {
+		var list []*RecordingInfo
+		for _, one := range recordings {
+			if this_.readSessionMeta(request.Place, request.PlaceId, one.WorkerId).UserId == requestBean.JWT.UserId {
+				list = append(list, one)
+			}
+		}
+		recordings = list
+	}
+	res = recordings
+	return
+}
+
+// recordingStream 输出 asciicast 录像内容，offset 为已读取的字节数，录制中的会话可按 offset 继续读取新增内容
+func (this_ *api) recordingStream(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	res = base.HttpNotResponse
+	defer func() {
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			_, _ = c.Writer.WriteString(err.Error())
+		}
+	}()
+
+	request := map[string]string{}
+	err = c.Bind(&request)
+	if err != nil {
+		return
+	}
+
+	err = this_.checkRecording(requestBean, request["place"], request["placeId"], request["workerId"])
+	if err != nil {
+		return
+	}
+	path, err := this_.WorkerFactory.getRecordingPath(request["place"], request["placeId"], request["workerId"])
+	if err != nil {
+		return
+	}
+	var offset int64
+	if request["offset"] != "" {
+		offset, err = strconv.ParseInt(request["offset"], 10, 64)
+		if err != nil {
+			return
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = errors.New("录像不存在")
+		}
+		return
+	}
+	defer func() { _ = f.Close() }()
+	stat, err := f.Stat()
+	if err != nil {
+		return
+	}
+	if offset < 0 || offset > stat.Size() {
+		offset = stat.Size()
+	}
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return
+	}
+
+	c.Header("Content-Type", "application/x-asciicast")
+	c.Header("recording-size", fmt.Sprint(stat.Size()))
+	c.Header("recording-offset", fmt.Sprint(offset))
+	c.Header("recording-recording", fmt.Sprint(this_.WorkerFactory.isRecording(request["place"], request["placeId"], request["workerId"])))
+	c.Status(http.StatusOK)
+	_, err = io.CopyN(c.Writer, f, stat.Size()-offset)
+	if err != nil {
+		this_.Logger.Error("recording stream error", zap.Any("path", path), zap.Error(err))
+		err = nil
+	}
+	return
+}
+
+func (this_ *api) recordingDownload(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	res = base.HttpNotResponse
+	defer func() {
+		if err != nil {
+			_, _ = c.Writer.WriteString(err.Error())
+		}
+	}()
+
+	request := map[string]string{}
+	err = c.Bind(&request)
+	if err != nil {
+		return
+	}
+
+	err = this_.checkRecording(requestBean, request["place"], request["placeId"], request["workerId"])
+	if err != nil {
+		return
+	}
+	path, err := this_.WorkerFactory.getRecordingPath(request["place"], request["placeId"], request["workerId"])
+	if err != nil {
+		return
+	}
+
+	fileName := "" + request["fileName"] + ".cast"
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=utf-8''%s", url.QueryEscape(fileName)))
+	c.Header("download-file-name", fileName)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = errors.New("录像不存在")
+		}
+		return
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(c.Writer, f)
+	c.Status(http.StatusOK)
+	return
+}
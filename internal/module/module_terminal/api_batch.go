@@ -0,0 +1,165 @@
+package module_terminal
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"teamide/pkg/base"
+)
+
+type BatchReportRequest struct {
+	ReportId       int64 `json:"reportId,omitempty"`
+	QuickCommandId int64 `json:"quickCommandId,omitempty"`
+	// Base、Target 为对比的主机在结果中的序号，Field 为 stdout 或 stderr，默认 stdout
+	Base   int    `json:"base,omitempty"`
+	Target int    `json:"target,omitempty"`
+	Field  string `json:"field,omitempty"`
+}
+
+type BatchReportResponse struct {
+	*TerminalBatchReportModel
+	ResultList []*BatchHostResult `json:"resultList"`
+}
+
+type BatchDiffResponse struct {
+	Base   *BatchHostResult `json:"base"`
+	Target *BatchHostResult `json:"target"`
+	Lines  []*BatchDiffLine `json:"lines"`
+}
+
+// getUserBatchReport 查询当前用户的报告
+func (this_ *api) getUserBatchReport(userId int64, reportId int64) (report *TerminalBatchReportModel, err error) {
+	report, err = this_.terminalCommandService.GetBatchReport(reportId)
+	if err != nil {
+		return
+	}
+	if report == nil || report.UserId != userId {
+		report = nil
+		err = errors.New("报告[" + strconv.FormatInt(reportId, 10) + "]不存在")
+		return
+	}
+	return
+}
+
+func (this_ *api) batchRun(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BatchRunRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	res, err = this_.startBatch(userId, request)
+	return
+}
+
+func (this_ *api) batchReports(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BatchReportRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	res, err = this_.terminalCommandService.QueryBatchReport(&TerminalBatchReportModel{
+		UserId:         userId,
+		QuickCommandId: request.QuickCommandId,
+	})
+	return
+}
+
+func (this_ *api) batchReport(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BatchReportRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	report, err := this_.getUserBatchReport(userId, request.ReportId)
+	if err != nil {
+		return
+	}
+	response := &BatchReportResponse{
+		TerminalBatchReportModel: report,
+		ResultList:               []*BatchHostResult{},
+	}
+	if report.Result != "" {
+		err = json.Unmarshal([]byte(report.Result), &response.ResultList)
+		if err != nil {
+			return
+		}
+	}
+	report.Result = ""
+	res = response
+	return
+}
+
+func (this_ *api) batchDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BatchReportRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	_, err = this_.getUserBatchReport(userId, request.ReportId)
+	if err != nil {
+		return
+	}
+	err = this_.terminalCommandService.DeleteBatchReport(request.ReportId)
+	return
+}
+
+// batchDiff 按行对比报告中两个主机的输出
+func (this_ *api) batchDiff(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BatchReportRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	report, err := this_.getUserBatchReport(userId, request.ReportId)
+	if err != nil {
+		return
+	}
+	if report.Status == batchStatusRunning {
+		err = errors.New("报告[" + strconv.FormatInt(request.ReportId, 10) + "]执行中")
+		return
+	}
+	var resultList []*BatchHostResult
+	if report.Result != "" {
+		err = json.Unmarshal([]byte(report.Result), &resultList)
+		if err != nil {
+			return
+		}
+	}
+	if request.Base < 0 || request.Base >= len(resultList) || request.Target < 0 || request.Target >= len(resultList) {
+		err = errors.New("对比的主机不存在")
+		return
+	}
+	response := &BatchDiffResponse{
+		Base:   resultList[request.Base],
+		Target: resultList[request.Target],
+	}
+	switch request.Field {
+	case "", "stdout":
+		response.Lines, err = diffBatchOutput(response.Base.Stdout, response.Target.Stdout)
+	case "stderr":
+		response.Lines, err = diffBatchOutput(response.Base.Stderr, response.Target.Stderr)
+	default:
+		err = errors.New("不支持对比[" + request.Field + "]")
+	}
+	if err != nil {
+		return
+	}
+	res = response
+	return
+}
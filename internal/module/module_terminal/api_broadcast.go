@@ -0,0 +1,78 @@
+package module_terminal
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"teamide/pkg/base"
+)
+
+type BroadcastRequest struct {
+	GroupId   string   `json:"groupId,omitempty"`
+	Name      string   `json:"name,omitempty"`
+	Keys      []string `json:"keys,omitempty"`
+	MutedKeys []string `json:"mutedKeys,omitempty"`
+	Key       string   `json:"key,omitempty"`
+	Muted     bool     `json:"muted,omitempty"`
+}
+
+func getRequestUserId(requestBean *base.RequestBean) (userId int64, err error) {
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	userId = requestBean.JWT.UserId
+	return
+}
+
+func (this_ *api) broadcastList(requestBean *base.RequestBean, _ *gin.Context) (res interface{}, err error) {
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	res = this_.WorkerFactory.getBroadcastGroups(userId)
+	return
+}
+
+// broadcastSave 创建广播组，或修改广播组名称、成员
+func (this_ *api) broadcastSave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BroadcastRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	if len(request.Keys) == 0 {
+		err = errors.New("广播组成员不能为空")
+		return
+	}
+	res, err = this_.WorkerFactory.saveBroadcastGroup(userId, request.GroupId, request.Name, request.Keys, request.MutedKeys)
+	return
+}
+
+func (this_ *api) broadcastMute(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BroadcastRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	res, err = this_.WorkerFactory.muteBroadcastMember(userId, request.GroupId, request.Key, request.Muted)
+	return
+}
+
+func (this_ *api) broadcastDissolve(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &BroadcastRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	err = this_.WorkerFactory.dissolveBroadcastGroup(userId, request.GroupId)
+	return
+}
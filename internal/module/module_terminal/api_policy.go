@@ -0,0 +1,80 @@
+package module_terminal
+
+import (
+	"github.com/gin-gonic/gin"
+	"teamide/pkg/base"
+)
+
+type PolicyRequest struct {
+	PolicyId int64 `json:"policyId,omitempty"`
+	// UserId、Place、PlaceId、Command 用于测试命令在用户终端上的检查结果
+	UserId  int64  `json:"userId,omitempty"`
+	Place   string `json:"place,omitempty"`
+	PlaceId string `json:"placeId,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+type PolicyTestResponse struct {
+	Action int    `json:"action"`
+	Policy string `json:"policy,omitempty"`
+	Rule   string `json:"rule,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func (this_ *api) policyList(_ *base.RequestBean, _ *gin.Context) (res interface{}, err error) {
+	res, err = this_.terminalCommandService.QueryPolicy()
+	return
+}
+
+func (this_ *api) policySave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &TerminalPolicyModel{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	request.UserId = userId
+	err = this_.terminalCommandService.SavePolicy(request)
+	if err != nil {
+		return
+	}
+	this_.reloadPolicy()
+	res = request
+	return
+}
+
+func (this_ *api) policyDelete(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &PolicyRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	err = this_.terminalCommandService.DeletePolicy(request.PolicyId)
+	if err != nil {
+		return
+	}
+	this_.reloadPolicy()
+	return
+}
+
+func (this_ *api) policyTest(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &PolicyRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &PolicyTestResponse{}
+	checker, err := this_.terminalCommandService.GetCommandChecker(request.UserId, request.Place, request.PlaceId)
+	if err != nil {
+		return
+	}
+	if checker != nil {
+		result := checker.Check(request.Command)
+		response.Action = result.Action
+		response.Policy = result.Policy
+		response.Rule = result.Rule
+		response.Reason = result.Reason
+	}
+	res = response
+	return
+}
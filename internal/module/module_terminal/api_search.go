@@ -0,0 +1,80 @@
+package module_terminal
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"strings"
+	"teamide/pkg/base"
+)
+
+type ReadLogRequest struct {
+	Place    string `json:"place,omitempty"`
+	PlaceId  string `json:"placeId,omitempty"`
+	WorkerId string `json:"workerId,omitempty"`
+	Offset   int64  `json:"offset,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+}
+
+// search 在当前用户可见的会话日志和历史命令中搜索
+func (this_ *api) search(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &SearchRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	if strings.TrimSpace(request.Keyword) == "" {
+		err = errors.New("搜索内容不能为空")
+		return
+	}
+	if request.Limit <= 0 {
+		request.Limit = searchDefaultLimit
+	}
+	if request.Limit > searchMaxLimit {
+		request.Limit = searchMaxLimit
+	}
+	if request.Context < 0 {
+		request.Context = 0
+	}
+	if request.Context > searchMaxContext {
+		request.Context = searchMaxContext
+	}
+
+	visibility, err := this_.WorkerFactory.getSessionVisibility(userId)
+	if err != nil {
+		return
+	}
+	data := map[string]interface{}{}
+	if !request.NotLog {
+		if data["logs"], err = this_.WorkerFactory.searchLogs(visibility, request); err != nil {
+			return
+		}
+	}
+	if !request.NotCommand {
+		if data["commands"], err = this_.terminalCommandService.Search(visibility, request); err != nil {
+			return
+		}
+	}
+	res = data
+	return
+}
+
+// readLog 读取会话日志指定位置的内容
+func (this_ *api) readLog(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ReadLogRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	visibility, err := this_.WorkerFactory.getSessionVisibility(userId)
+	if err != nil {
+		return
+	}
+	res, err = this_.WorkerFactory.readSessionLog(visibility, request.Place, request.PlaceId, request.WorkerId, request.Offset, request.Size)
+	return
+}
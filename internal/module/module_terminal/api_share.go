@@ -0,0 +1,141 @@
+package module_terminal
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"teamide/internal/module/module_user"
+	"teamide/pkg/base"
+)
+
+type ShareRequest struct {
+	Key     string `json:"key,omitempty"`
+	ShareId string `json:"shareId,omitempty"`
+	UserId  int64  `json:"userId,omitempty"`
+	Write   bool   `json:"write,omitempty"`
+}
+
+// getOwnerWorker 获取当前用户自己的会话，只有会话所有者可以管理共享
+func (this_ *api) getOwnerWorker(requestBean *base.RequestBean, key string) (worker *Worker, err error) {
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	worker = this_.GetService(key)
+	if worker == nil {
+		err = errors.New("会话[" + key + "]不存在")
+		return
+	}
+	if worker.userId != requestBean.JWT.UserId {
+		worker = nil
+		err = errors.New("只有会话所有者可以管理共享")
+		return
+	}
+	return
+}
+
+func (this_ *api) shareInvite(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ShareRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	worker, err := this_.getOwnerWorker(requestBean, request.Key)
+	if err != nil {
+		return
+	}
+	if request.UserId == worker.userId {
+		err = errors.New("不能邀请自己")
+		return
+	}
+	var user *module_user.UserModel
+	user, err = this_.userService.Get(request.UserId)
+	if err != nil {
+		return
+	}
+	if user == nil || user.Deleted == 1 || user.Enabled == 2 {
+		err = errors.New("用户不存在或已禁用")
+		return
+	}
+	invite := &ShareInvite{
+		UserId:   user.UserId,
+		UserName: user.Name,
+		Write:    request.Write,
+	}
+	worker.shareInvite(invite)
+
+	res = invite
+	return
+}
+
+func (this_ *api) shareRevoke(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ShareRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	worker, err := this_.getOwnerWorker(requestBean, request.Key)
+	if err != nil {
+		return
+	}
+	worker.shareRevoke(request.UserId)
+	return
+}
+
+func (this_ *api) shareInfo(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &ShareRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	worker := this_.getShareService(request.ShareId)
+	if worker == nil {
+		err = errors.New("会话[" + request.ShareId + "]不存在")
+		return
+	}
+	if worker.userId != requestBean.JWT.UserId && worker.getShareInvite(requestBean.JWT.UserId) == nil {
+		err = errors.New("未被邀请加入会话[" + request.ShareId + "]")
+		return
+	}
+	res = worker.getShareInfo()
+	return
+}
+
+// shareWebsocket 被邀请的用户加入会话，只读邀请只接收输出
+func (this_ *api) shareWebsocket(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	shareId := c.Query("shareId")
+	if shareId == "" {
+		err = errors.New("shareId获取失败")
+		return
+	}
+	worker := this_.getShareService(shareId)
+	if worker == nil {
+		err = errors.New("会话[" + shareId + "]不存在")
+		return
+	}
+	if worker.getShareInvite(requestBean.JWT.UserId) == nil {
+		err = errors.New("未被邀请加入会话[" + shareId + "]")
+		return
+	}
+
+	ws, err := upGrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	err = worker.shareJoin(requestBean.JWT.UserId, ws)
+	if err != nil {
+		_ = ws.WriteMessage(websocket.BinaryMessage, []byte("share join error:"+err.Error()))
+		this_.Logger.Error("websocket share join error", zap.Error(err))
+		_ = ws.Close()
+		return
+	}
+
+	res = base.HttpNotResponse
+	return
+}
@@ -0,0 +1,205 @@
+package module_terminal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/team-ide/go-tool/util"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"teamide/pkg/base"
+)
+
+type TransferRequest struct {
+	Key        string   `json:"key,omitempty"`
+	TransferId string   `json:"transferId,omitempty"`
+	Names      []string `json:"names,omitempty"`
+}
+
+// getTransferWorker 获取当前用户自己的会话，只有会话所有者可以操作文件传输
+func (this_ *api) getTransferWorker(requestBean *base.RequestBean, key string) (worker *Worker, err error) {
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	worker = this_.GetService(key)
+	if worker == nil {
+		err = errors.New("会话[" + key + "]不存在")
+		return
+	}
+	if worker.userId != requestBean.JWT.UserId {
+		worker = nil
+		err = errors.New("只有会话所有者可以操作文件传输")
+		return
+	}
+	return
+}
+
+// transferUpload 远程执行 rz、trz 后，以表单 key、transferId、file 上传文件到会话的上传目录并开始发送
+// 以 JSON 请求时 names 为已在上传目录中的文件
+func (this_ *api) transferUpload(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	if c.ContentType() == "multipart/form-data" {
+		res, err = this_.transferUploadForm(requestBean, c)
+		return
+	}
+	request := &TransferRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	worker, err := this_.getTransferWorker(requestBean, request.Key)
+	if err != nil {
+		return
+	}
+	err = worker.transferUploadFiles(request.TransferId, request.Names)
+	return
+}
+
+func (this_ *api) transferUploadForm(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	worker, err := this_.getTransferWorker(requestBean, c.PostForm("key"))
+	if err != nil {
+		return
+	}
+	mF, err := c.MultipartForm()
+	if err != nil {
+		return
+	}
+	defer func() { _ = mF.RemoveAll() }()
+	fileList := mF.File["file"]
+	if len(fileList) == 0 {
+		err = errors.New("上传文件不能为空")
+		return
+	}
+	dir, err := worker.getTransferDir(transferTypeUpload)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, fileHeader := range fileList {
+		name := filepath.Base(fileHeader.Filename)
+		if err = checkPathName(name); err != nil {
+			return
+		}
+		if err = c.SaveUploadedFile(fileHeader, dir+name); err != nil {
+			return
+		}
+		names = append(names, name)
+	}
+	err = worker.transferUploadFiles(c.PostForm("transferId"), names)
+	if err != nil {
+		for _, name := range names {
+			_ = os.Remove(dir + name)
+		}
+	}
+	return
+}
+
+func (this_ *api) transferCancel(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &TransferRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	worker, err := this_.getTransferWorker(requestBean, request.Key)
+	if err != nil {
+		return
+	}
+	t := worker.getTransfer()
+	if t == nil || (request.TransferId != "" && t.transferId != request.TransferId) {
+		err = errors.New("传输[" + request.TransferId + "]不存在或已结束")
+		return
+	}
+	worker.cancelTransfer(t)
+	return
+}
+
+// transferFiles 会话通过 sz、tsz 下载的文件，会话关闭时删除
+func (this_ *api) transferFiles(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &TransferRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	worker, err := this_.getTransferWorker(requestBean, request.Key)
+	if err != nil {
+		return
+	}
+	dir, err := worker.getTransferDir(transferTypeDownload)
+	if err != nil {
+		return
+	}
+	var list []*TransferFile
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, e := entry.Info()
+		if e != nil {
+			continue
+		}
+		list = append(list, &TransferFile{
+			Name:    entry.Name(),
+			Size:    info.Size(),
+			ModTime: util.GetMilliByTime(info.ModTime()),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ModTime > list[j].ModTime
+	})
+	res = list
+	return
+}
+
+func (this_ *api) transferDownload(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	res = base.HttpNotResponse
+	defer func() {
+		if err != nil {
+			_, _ = c.Writer.WriteString(err.Error())
+		}
+	}()
+
+	request := map[string]string{}
+	err = c.Bind(&request)
+	if err != nil {
+		return
+	}
+
+	worker, err := this_.getTransferWorker(requestBean, request["key"])
+	if err != nil {
+		return
+	}
+	dir, err := worker.getTransferDir(transferTypeDownload)
+	if err != nil {
+		return
+	}
+	fileName := request["fileName"]
+	if fileName == "" {
+		err = errors.New("文件名称不能为空")
+		return
+	}
+	if err = checkPathName(fileName); err != nil {
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=utf-8''%s", url.QueryEscape(fileName)))
+	c.Header("download-file-name", fileName)
+
+	f, err := os.Open(dir + fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = errors.New("文件不存在")
+		}
+		return
+	}
+	defer func() { _ = f.Close() }()
+	_, err = io.Copy(c.Writer, f)
+	c.Status(http.StatusOK)
+	return
+}
@@ -0,0 +1,79 @@
+package module_terminal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"teamide/pkg/base"
+)
+
+type TriggerRequest struct {
+	TriggerId int64 `json:"triggerId,omitempty"`
+}
+
+// getUserTrigger 查询触发器，只能操作自己的触发器
+func (this_ *api) getUserTrigger(userId int64, triggerId int64) (trigger *TerminalTriggerModel, err error) {
+	trigger, err = this_.terminalCommandService.GetTrigger(triggerId)
+	if err != nil {
+		return
+	}
+	if trigger == nil || trigger.UserId != userId {
+		err = errors.New(fmt.Sprint("触发器[", triggerId, "]不存在"))
+		trigger = nil
+		return
+	}
+	return
+}
+
+func (this_ *api) triggerList(requestBean *base.RequestBean, _ *gin.Context) (res interface{}, err error) {
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	res, err = this_.terminalCommandService.QueryTrigger(userId)
+	return
+}
+
+func (this_ *api) triggerSave(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &TerminalTriggerModel{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	if request.TriggerId > 0 {
+		if _, err = this_.getUserTrigger(userId, request.TriggerId); err != nil {
+			return
+		}
+	}
+	request.UserId = userId
+	err = this_.terminalCommandService.SaveTrigger(request)
+	if err != nil {
+		return
+	}
+	this_.reloadTrigger(userId)
+	res = request
+	return
+}
+
+func (this_ *api) triggerDelete(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+	request := &TriggerRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	userId, err := getRequestUserId(requestBean)
+	if err != nil {
+		return
+	}
+	if _, err = this_.getUserTrigger(userId, request.TriggerId); err != nil {
+		return
+	}
+	err = this_.terminalCommandService.DeleteTrigger(request.TriggerId)
+	if err != nil {
+		return
+	}
+	this_.reloadTrigger(userId)
+	return
+}
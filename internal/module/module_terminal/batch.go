@@ -0,0 +1,421 @@
+package module_terminal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"teamide/internal/context"
+	"teamide/internal/module/module_node"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/ssh"
+	"teamide/pkg/terminal"
+	"time"
+)
+
+const (
+	batchDefaultConcurrency = 5
+	batchMaxConcurrency     = 50
+	batchDefaultTimeout     = 60
+	// batchNodeMaxTimeout 节点调用最长等待 60 秒，节点上执行的命令需在此之前结束
+	batchNodeMaxTimeout = 55
+	// batchDiffMaxLines 对比时去掉相同的首尾后，最多对比的行数
+	batchDiffMaxLines = 2000
+)
+
+// BatchHost 批量执行的主机，place 为 ssh 或 node，Params 覆盖公共参数
+type BatchHost struct {
+	Place   string            `json:"place"`
+	PlaceId string            `json:"placeId"`
+	Name    string            `json:"name,omitempty"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// BatchHostResult 单个主机的执行结果，Error 为连接、参数等错误，命令本身失败通过 ExitCode 体现
+type BatchHostResult struct {
+	Place     string `json:"place"`
+	PlaceId   string `json:"placeId"`
+	Name      string `json:"name,omitempty"`
+	Command   string `json:"command,omitempty"`
+	ExitCode  int    `json:"exitCode"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+	StartTime int64  `json:"startTime,omitempty"`
+	EndTime   int64  `json:"endTime,omitempty"`
+}
+
+func (this_ *BatchHostResult) isSuccess() bool {
+	return this_.Error == "" && this_.ExitCode == 0
+}
+
+type BatchRunRequest struct {
+	QuickCommandId int64             `json:"quickCommandId,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Command        string            `json:"command,omitempty"`
+	Params         map[string]string `json:"params,omitempty"`
+	HostList       []*BatchHost      `json:"hostList,omitempty"`
+	Concurrency    int               `json:"concurrency,omitempty"`
+	Timeout        int               `json:"timeout,omitempty"` // 秒
+}
+
+// BatchEvent 批量执行进度，通过 terminal-batch 事件通知，每个主机结束时 HostResult 不为空，全部结束时 Finished 为 true
+type BatchEvent struct {
+	ReportId     int64            `json:"reportId"`
+	HostResult   *BatchHostResult `json:"hostResult,omitempty"`
+	Finished     bool             `json:"finished,omitempty"`
+	SuccessCount int              `json:"successCount"`
+	FailCount    int              `json:"failCount"`
+}
+
+var batchParamRegexp = regexp.MustCompile(`\$\{(\w+)}`)
+
+// formatBatchCommand 替换命令中的 ${var} 参数，参数未设置时返回错误
+func formatBatchCommand(command string, params map[string]string) (res string, err error) {
+	var missing []string
+	res = batchParamRegexp.ReplaceAllStringFunc(command, func(s string) string {
+		name := batchParamRegexp.FindStringSubmatch(s)[1]
+		value, ok := params[name]
+		if !ok {
+			if util.StringIndexOf(missing, name) < 0 {
+				missing = append(missing, name)
+			}
+			return s
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		err = errors.New("参数[" + strings.Join(missing, ",") + "]未设置")
+	}
+	return
+}
+
+// getQuickCommandText 快速指令的命令内容，option 为 JSON 时取 command 属性
+func getQuickCommandText(option string) (command string) {
+	data := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(option), &data); err != nil {
+		return option
+	}
+	return util.GetStringValue(data["command"])
+}
+
+// startBatch 保存报告后异步执行，返回执行中的报告
+func (this_ *api) startBatch(userId int64, request *BatchRunRequest) (report *TerminalBatchReportModel, err error) {
+	command := request.Command
+	name := request.Name
+	if request.QuickCommandId != 0 {
+		var quickCommand *module_toolbox.ToolboxQuickCommandModel
+		quickCommand, err = this_.toolboxService.GetQuickCommand(request.QuickCommandId)
+		if err != nil {
+			return
+		}
+		if quickCommand == nil || quickCommand.UserId != userId {
+			err = errors.New("快速指令[" + strconv.FormatInt(request.QuickCommandId, 10) + "]不存在")
+			return
+		}
+		command = getQuickCommandText(quickCommand.Option)
+		if name == "" {
+			name = quickCommand.Name
+		}
+	}
+	if strings.TrimSpace(command) == "" {
+		err = errors.New("执行命令不能为空")
+		return
+	}
+	if len(request.HostList) == 0 {
+		err = errors.New("执行主机不能为空")
+		return
+	}
+	for _, host := range request.HostList {
+		if host.Place != "ssh" && host.Place != "node" {
+			err = errors.New("[" + host.Place + "]不支持批量执行")
+			return
+		}
+	}
+	concurrency := request.Concurrency
+	if concurrency <= 0 {
+		concurrency = batchDefaultConcurrency
+	}
+	if concurrency > batchMaxConcurrency {
+		concurrency = batchMaxConcurrency
+	}
+	timeout := request.Timeout
+	if timeout <= 0 {
+		timeout = batchDefaultTimeout
+	}
+	params, _ := json.Marshal(request.Params)
+
+	report = &TerminalBatchReportModel{
+		UserId:         userId,
+		QuickCommandId: request.QuickCommandId,
+		Name:           name,
+		Command:        command,
+		Params:         string(params),
+		Concurrency:    concurrency,
+		Timeout:        timeout,
+		Status:         batchStatusRunning,
+		HostCount:      len(request.HostList),
+	}
+	err = this_.terminalCommandService.InsertBatchReport(report)
+	if err != nil {
+		return
+	}
+
+	res := *report
+	go this_.batchExecute(&res, request)
+	return
+}
+
+// batchExecute 按并发数执行所有主机，每个主机结束时通知进度，全部结束后保存结果
+func (this_ *api) batchExecute(report *TerminalBatchReportModel, request *BatchRunRequest) {
+	var lock sync.Mutex
+	var wait sync.WaitGroup
+	limit := make(chan struct{}, report.Concurrency)
+	results := make([]*BatchHostResult, len(request.HostList))
+
+	for index, host := range request.HostList {
+		wait.Add(1)
+		limit <- struct{}{}
+		go func(index int, host *BatchHost) {
+			defer func() {
+				<-limit
+				wait.Done()
+			}()
+			result := this_.batchExecuteHost(report.UserId, host, report.Command, request.Params, report.Timeout)
+
+			lock.Lock()
+			results[index] = result
+			if result.isSuccess() {
+				report.SuccessCount++
+			} else {
+				report.FailCount++
+			}
+			event := &BatchEvent{
+				ReportId:     report.ReportId,
+				HostResult:   result,
+				SuccessCount: report.SuccessCount,
+				FailCount:    report.FailCount,
+			}
+			lock.Unlock()
+			context.CallUserEvent(report.UserId, context.NewListenEvent("terminal-batch", event))
+		}(index, host)
+	}
+	wait.Wait()
+
+	bs, _ := json.Marshal(results)
+	report.Result = string(bs)
+	report.Status = batchStatusFinished
+	err := this_.terminalCommandService.FinishBatchReport(report)
+	if err != nil {
+		this_.Logger.Error("batch report finish error", zap.Any("reportId", report.ReportId), zap.Error(err))
+	}
+	context.CallUserEvent(report.UserId, context.NewListenEvent("terminal-batch", &BatchEvent{
+		ReportId:     report.ReportId,
+		Finished:     true,
+		SuccessCount: report.SuccessCount,
+		FailCount:    report.FailCount,
+	}))
+}
+
+func (this_ *api) batchExecuteHost(userId int64, host *BatchHost, command string, params map[string]string, timeout int) (result *BatchHostResult) {
+	result = &BatchHostResult{
+		Place:     host.Place,
+		PlaceId:   host.PlaceId,
+		Name:      host.Name,
+		StartTime: util.GetNowMilli(),
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			result.Error = fmt.Sprint(e)
+		}
+		result.EndTime = util.GetNowMilli()
+	}()
+
+	var err error
+	var execResult *terminal.ExecResult
+	switch host.Place {
+	case "ssh":
+		var config *ssh.Config
+		config, err = this_.getBatchSSHConfig(result)
+		if err != nil {
+			break
+		}
+		result.Command, err = formatBatchCommand(command, getBatchParams(result, params, host.Params))
+		if err != nil {
+			break
+		}
+		if err = this_.checkBatchPolicy(userId, result); err != nil {
+			break
+		}
+		execResult, err = ssh.Exec(*config, result.Command, time.Duration(timeout)*time.Second)
+	case "node":
+		if result.Name == "" {
+			result.Name = host.PlaceId
+		}
+		result.Command, err = formatBatchCommand(command, getBatchParams(result, params, host.Params))
+		if err != nil {
+			break
+		}
+		if err = this_.checkBatchPolicy(userId, result); err != nil {
+			break
+		}
+		if timeout > batchNodeMaxTimeout {
+			timeout = batchNodeMaxTimeout
+		}
+		execResult, err = module_node.NewTerminalService(host.PlaceId, this_.nodeService).Exec(result.Command, time.Duration(timeout)*time.Second)
+	}
+	if execResult != nil {
+		result.ExitCode = execResult.ExitCode
+		result.Stdout = execResult.Stdout
+		result.Stderr = execResult.Stderr
+		result.Truncated = execResult.Truncated
+	}
+	if err != nil {
+		result.Error = err.Error()
+		this_.Logger.Warn("batch execute host error", zap.Any("place", host.Place), zap.Any("placeId", host.PlaceId), zap.Error(err))
+	}
+	return
+}
+
+// checkBatchPolicy 批量执行无法确认，命中拒绝或确认规则的命令都不执行
+func (this_ *api) checkBatchPolicy(userId int64, result *BatchHostResult) (err error) {
+	checker, err := this_.terminalCommandService.GetCommandChecker(userId, result.Place, result.PlaceId)
+	if err != nil || checker == nil {
+		return
+	}
+	for _, line := range strings.Split(result.Command, "\n") {
+		res := checker.Check(line)
+		if res.Action == terminal.PolicyAllow {
+			continue
+		}
+		reason := formatPolicyReason(res)
+		e := this_.terminalCommandService.Save(&TerminalCommandModel{
+			UserId:      userId,
+			Place:       result.Place,
+			PlaceId:     result.PlaceId,
+			Command:     line,
+			Comment:     reason + "，批量执行",
+			CommandType: CommandTypeBlocked,
+		})
+		if e != nil {
+			this_.Logger.Error("save policy command error", zap.Error(e))
+		}
+		err = errors.New("[命令已拦截] " + reason)
+		return
+	}
+	return
+}
+
+// getBatchParams 合并参数，内置 place、placeId、name，主机参数覆盖公共参数
+func getBatchParams(result *BatchHostResult, params map[string]string, hostParams map[string]string) (res map[string]string) {
+	res = map[string]string{
+		"place":   result.Place,
+		"placeId": result.PlaceId,
+		"name":    result.Name,
+	}
+	for k, v := range params {
+		res[k] = v
+	}
+	for k, v := range hostParams {
+		res[k] = v
+	}
+	return
+}
+
+func (this_ *api) getBatchSSHConfig(result *BatchHostResult) (config *ssh.Config, err error) {
+	id, err := strconv.ParseInt(result.PlaceId, 10, 64)
+	if err != nil {
+		return
+	}
+	tD, err := this_.toolboxService.Get(id)
+	if err != nil {
+		return
+	}
+	if tD == nil || tD.Option == "" {
+		err = errors.New("SSH[" + result.PlaceId + "]配置不存在")
+		return
+	}
+	if result.Name == "" {
+		result.Name = tD.Name
+	}
+	config, sshConfig, err := this_.toolboxService.GetSSHConfig(tD.Option)
+	if err != nil {
+		return
+	}
+	config.JumpConfig = sshConfig
+	return
+}
+
+// BatchDiffLine 对比结果行，Type 为 = 相同、- 仅在基准主机、+ 仅在对比主机
+type BatchDiffLine struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// diffBatchOutput 按行对比两个主机的输出，先去掉相同的首尾，剩余部分按最长公共子序列对比
+func diffBatchOutput(a string, b string) (lines []*BatchDiffLine, err error) {
+	aLines := strings.Split(a, "\n")
+	bLines := strings.Split(b, "\n")
+
+	var prefix, suffix int
+	for prefix < len(aLines) && prefix < len(bLines) && aLines[prefix] == bLines[prefix] {
+		prefix++
+	}
+	for suffix < len(aLines)-prefix && suffix < len(bLines)-prefix &&
+		aLines[len(aLines)-1-suffix] == bLines[len(bLines)-1-suffix] {
+		suffix++
+	}
+	aMiddle := aLines[prefix : len(aLines)-suffix]
+	bMiddle := bLines[prefix : len(bLines)-suffix]
+	if len(aMiddle) > batchDiffMaxLines || len(bMiddle) > batchDiffMaxLines {
+		err = fmt.Errorf("不同的内容超过%d行，无法对比", batchDiffMaxLines)
+		return
+	}
+
+	for _, line := range aLines[:prefix] {
+		lines = append(lines, &BatchDiffLine{Type: "=", Text: line})
+	}
+
+	// lcs[i][j] 为 aMiddle[i:] 和 bMiddle[j:] 的最长公共子序列长度
+	n, m := len(aMiddle), len(bMiddle)
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if aMiddle[i] == bMiddle[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var i, j int
+	for i < n || j < m {
+		if i < n && j < m && aMiddle[i] == bMiddle[j] {
+			lines = append(lines, &BatchDiffLine{Type: "=", Text: aMiddle[i]})
+			i++
+			j++
+		} else if j >= m || (i < n && lcs[i+1][j] >= lcs[i][j+1]) {
+			lines = append(lines, &BatchDiffLine{Type: "-", Text: aMiddle[i]})
+			i++
+		} else {
+			lines = append(lines, &BatchDiffLine{Type: "+", Text: bMiddle[j]})
+			j++
+		}
+	}
+
+	for _, line := range aLines[len(aLines)-suffix:] {
+		lines = append(lines, &BatchDiffLine{Type: "=", Text: line})
+	}
+	return
+}
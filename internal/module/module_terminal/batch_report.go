@@ -0,0 +1,133 @@
+package module_terminal
+
+import (
+	"go.uber.org/zap"
+	"teamide/internal/module/module_id"
+	"time"
+)
+
+const (
+	batchStatusRunning     = 1
+	batchStatusFinished    = 2
+	batchStatusInterrupted = 3
+)
+
+// InsertBatchReport 新增批量执行报告
+func (this_ *TerminalCommandService) InsertBatchReport(report *TerminalBatchReportModel) (err error) {
+	if report.ReportId == 0 {
+		report.ReportId, err = this_.idService.GetNextID(module_id.IDTypeTerminalBatchReport)
+		if err != nil {
+			return
+		}
+	}
+	if report.CreateTime.IsZero() {
+		report.CreateTime = time.Now()
+	}
+
+	sql := `INSERT INTO ` + TableTerminalBatchReport +
+		`(reportId, userId, quickCommandId, name, command, params, concurrency, timeout, status, hostCount, successCount, failCount, result, createTime) 
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{
+		report.ReportId,
+		report.UserId,
+		report.QuickCommandId,
+		report.Name,
+		report.Command,
+		report.Params,
+		report.Concurrency,
+		report.Timeout,
+		report.Status,
+		report.HostCount,
+		report.SuccessCount,
+		report.FailCount,
+		report.Result,
+		report.CreateTime,
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// FinishBatchReport 执行结束后保存结果
+func (this_ *TerminalCommandService) FinishBatchReport(report *TerminalBatchReportModel) (err error) {
+	if report.EndTime.IsZero() {
+		report.EndTime = time.Now()
+	}
+
+	sql := `UPDATE ` + TableTerminalBatchReport + ` SET status=?,successCount=?,failCount=?,result=?,endTime=? WHERE reportId=? `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{
+		report.Status,
+		report.SuccessCount,
+		report.FailCount,
+		report.Result,
+		report.EndTime,
+		report.ReportId,
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// GetBatchReport 查询单个报告，包含执行结果
+func (this_ *TerminalCommandService) GetBatchReport(reportId int64) (res *TerminalBatchReportModel, err error) {
+	res = &TerminalBatchReportModel{}
+
+	sql := `SELECT * FROM ` + TableTerminalBatchReport + ` WHERE reportId=? `
+	find, err := this_.DatabaseWorker.QueryOne(sql, []interface{}{reportId}, res)
+	if err != nil {
+		return
+	}
+	if !find {
+		res = nil
+	}
+	return
+}
+
+// QueryBatchReport 查询报告列表，不包含执行结果
+func (this_ *TerminalCommandService) QueryBatchReport(report *TerminalBatchReportModel) (list []*TerminalBatchReportModel, err error) {
+
+	var sqlInfo = "SELECT reportId,userId,quickCommandId,name,command,params,concurrency,timeout,status,hostCount,successCount,failCount,createTime,endTime FROM " + TableTerminalBatchReport + " WHERE 1=1 "
+	var values []interface{}
+
+	if report.UserId != 0 {
+		sqlInfo += " AND userId=? "
+		values = append(values, report.UserId)
+	}
+	if report.QuickCommandId != 0 {
+		sqlInfo += " AND quickCommandId=? "
+		values = append(values, report.QuickCommandId)
+	}
+
+	sqlInfo += " ORDER BY createTime DESC "
+
+	err = this_.DatabaseWorker.Query(sqlInfo, values, &list)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this_ *TerminalCommandService) DeleteBatchReport(reportId int64) (err error) {
+
+	var sqlInfo = "DELETE FROM " + TableTerminalBatchReport + " WHERE reportId=? "
+	var values = []interface{}{reportId}
+
+	_, err = this_.DatabaseWorker.Exec(sqlInfo, values)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// interruptBatchReport 服务重启后，未结束的报告标记为中断
+func (this_ *TerminalCommandService) interruptBatchReport() {
+	sql := `UPDATE ` + TableTerminalBatchReport + ` SET status=? WHERE status=? `
+	_, err := this_.DatabaseWorker.Exec(sql, []interface{}{batchStatusInterrupted, batchStatusRunning})
+	if err != nil {
+		this_.Logger.Error("interrupt batch report error", zap.Error(err))
+	}
+}
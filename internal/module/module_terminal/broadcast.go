@@ -0,0 +1,275 @@
+package module_terminal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+)
+
+// BroadcastMember 广播组成员，Muted 为 true 时既不同步输入到其它成员，也不接收其它成员的输入
+type BroadcastMember struct {
+	Key      string `json:"key"`
+	Place    string `json:"place,omitempty"`
+	PlaceId  string `json:"placeId,omitempty"`
+	WorkerId string `json:"workerId,omitempty"`
+	Muted    bool   `json:"muted"`
+}
+
+// BroadcastGroup 广播组，写入任一成员的输入同步写入其它成员，用于同时在多台主机上执行相同操作
+type BroadcastGroup struct {
+	GroupId    string             `json:"groupId"`
+	Name       string             `json:"name,omitempty"`
+	UserId     int64              `json:"userId"`
+	MemberList []*BroadcastMember `json:"memberList"`
+	CreateTime int64              `json:"createTime,omitempty"`
+}
+
+type broadcastCache struct {
+	groups map[string]*BroadcastGroup
+	lock   sync.Mutex
+}
+
+func (this_ *BroadcastGroup) getMember(key string) *BroadcastMember {
+	for _, one := range this_.MemberList {
+		if one.Key == key {
+			return one
+		}
+	}
+	return nil
+}
+
+func (this_ *BroadcastGroup) copy() (res *BroadcastGroup) {
+	res = &BroadcastGroup{}
+	*res = *this_
+	res.MemberList = []*BroadcastMember{}
+	for _, one := range this_.MemberList {
+		member := *one
+		res.MemberList = append(res.MemberList, &member)
+	}
+	return
+}
+
+// newBroadcastMembers 校验会话属于当前用户，生成广播组成员
+func (this_ *WorkerFactory) newBroadcastMembers(userId int64, keys []string, mutedKeys []string) (members []*BroadcastMember, err error) {
+	for _, key := range keys {
+		worker := this_.GetService(key)
+		if worker == nil {
+			err = errors.New("会话[" + key + "]不存在")
+			return
+		}
+		if worker.userId != userId {
+			err = errors.New("会话[" + key + "]不属于当前用户")
+			return
+		}
+		var find bool
+		for _, one := range members {
+			if one.Key == key {
+				find = true
+				break
+			}
+		}
+		if find {
+			continue
+		}
+		members = append(members, &BroadcastMember{
+			Key:      key,
+			Place:    worker.place,
+			PlaceId:  worker.placeId,
+			WorkerId: worker.workerId,
+			Muted:    util.StringIndexOf(mutedKeys, key) >= 0,
+		})
+	}
+	return
+}
+
+// saveBroadcastGroup 新增或修改广播组，成员变更时在录像和日志中记录加入、退出
+func (this_ *WorkerFactory) saveBroadcastGroup(userId int64, groupId string, name string, keys []string, mutedKeys []string) (res *BroadcastGroup, err error) {
+	members, err := this_.newBroadcastMembers(userId, keys, mutedKeys)
+	if err != nil {
+		return
+	}
+
+	this_.broadcast.lock.Lock()
+	var group *BroadcastGroup
+	var oldMembers []*BroadcastMember
+	if groupId == "" {
+		group = &BroadcastGroup{
+			GroupId:    util.GetUUID(),
+			UserId:     userId,
+			CreateTime: util.GetNowMilli(),
+		}
+		this_.broadcast.groups[group.GroupId] = group
+	} else {
+		group = this_.broadcast.groups[groupId]
+		if group == nil || group.UserId != userId {
+			this_.broadcast.lock.Unlock()
+			err = errors.New("广播组[" + groupId + "]不存在")
+			return
+		}
+		oldMembers = group.MemberList
+	}
+	group.Name = name
+	group.MemberList = members
+	res = group.copy()
+	this_.broadcast.lock.Unlock()
+
+	for _, one := range members {
+		var find bool
+		for _, old := range oldMembers {
+			find = find || old.Key == one.Key
+		}
+		if !find {
+			this_.recordBroadcastByKey(one.Key, fmt.Sprintf("加入广播组[%s]", group.Name))
+		}
+	}
+	for _, old := range oldMembers {
+		if res.getMember(old.Key) == nil {
+			this_.recordBroadcastByKey(old.Key, fmt.Sprintf("退出广播组[%s]", group.Name))
+		}
+	}
+	return
+}
+
+// muteBroadcastMember 设置成员是否静音
+func (this_ *WorkerFactory) muteBroadcastMember(userId int64, groupId string, key string, muted bool) (res *BroadcastGroup, err error) {
+	this_.broadcast.lock.Lock()
+	defer this_.broadcast.lock.Unlock()
+
+	group := this_.broadcast.groups[groupId]
+	if group == nil || group.UserId != userId {
+		err = errors.New("广播组[" + groupId + "]不存在")
+		return
+	}
+	member := group.getMember(key)
+	if member == nil {
+		err = errors.New("会话[" + key + "]不在广播组中")
+		return
+	}
+	member.Muted = muted
+	res = group.copy()
+	return
+}
+
+// dissolveBroadcastGroup 解散广播组
+func (this_ *WorkerFactory) dissolveBroadcastGroup(userId int64, groupId string) (err error) {
+	this_.broadcast.lock.Lock()
+	group := this_.broadcast.groups[groupId]
+	if group == nil || group.UserId != userId {
+		this_.broadcast.lock.Unlock()
+		err = errors.New("广播组[" + groupId + "]不存在")
+		return
+	}
+	delete(this_.broadcast.groups, groupId)
+	this_.broadcast.lock.Unlock()
+
+	for _, one := range group.MemberList {
+		this_.recordBroadcastByKey(one.Key, fmt.Sprintf("广播组[%s]已解散", group.Name))
+	}
+	return
+}
+
+func (this_ *WorkerFactory) getBroadcastGroups(userId int64) (res []*BroadcastGroup) {
+	this_.broadcast.lock.Lock()
+	defer this_.broadcast.lock.Unlock()
+
+	res = []*BroadcastGroup{}
+	for _, one := range this_.broadcast.groups {
+		if one.UserId == userId {
+			res = append(res, one.copy())
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].CreateTime < res[j].CreateTime
+	})
+	return
+}
+
+// removeBroadcastMember 会话关闭后从所有广播组中移除
+func (this_ *WorkerFactory) removeBroadcastMember(key string) {
+	this_.broadcast.lock.Lock()
+	defer this_.broadcast.lock.Unlock()
+
+	for _, group := range this_.broadcast.groups {
+		var members []*BroadcastMember
+		for _, one := range group.MemberList {
+			if one.Key != key {
+				members = append(members, one)
+			}
+		}
+		group.MemberList = members
+	}
+}
+
+// getBroadcastTargets 输入需要同步到的会话，发送方静音时不同步
+func (this_ *WorkerFactory) getBroadcastTargets(key string) (groupNames []string, targets []string) {
+	this_.broadcast.lock.Lock()
+	defer this_.broadcast.lock.Unlock()
+
+	for _, group := range this_.broadcast.groups {
+		source := group.getMember(key)
+		if source == nil || source.Muted {
+			continue
+		}
+		groupNames = append(groupNames, group.Name)
+		for _, one := range group.MemberList {
+			if one.Key == key || one.Muted || util.StringIndexOf(targets, one.Key) >= 0 {
+				continue
+			}
+			targets = append(targets, one.Key)
+		}
+	}
+	return
+}
+
+func (this_ *WorkerFactory) recordBroadcastByKey(key string, label string) {
+	worker := this_.GetService(key)
+	if worker == nil {
+		return
+	}
+	worker.recordBroadcast(label)
+}
+
+// recordBroadcast 在录像和日志中记录广播相关的标记，区分输入来源
+func (this_ *Worker) recordBroadcast(label string) {
+	this_.recordMarker(label)
+	this_.writeCommandLog("\n[" + label + "]\n")
+}
+
+// broadcastInput 输入同步写入广播组的其它成员，提交命令（回车）时在成员的录像和日志中记录输入来源
+func (this_ *Worker) broadcastInput(buf []byte) {
+	groupNames, targets := this_.getBroadcastTargets(this_.key)
+	if len(targets) == 0 {
+		return
+	}
+	var label string
+	if bytes.ContainsAny(buf, "\r\n") {
+		label = fmt.Sprintf("广播输入%v 来自[%s-%s]", groupNames, this_.place, this_.placeId)
+	}
+	for _, key := range targets {
+		worker := this_.GetService(key)
+		if worker == nil || worker.isStopped {
+			continue
+		}
+		// 文件传输中的终端不接收广播输入
+		if worker.getTransfer() != nil {
+			continue
+		}
+		// 广播到的终端按各自的命令策略检查
+		bs := worker.checkInput(buf, false)
+		if len(bs) == 0 {
+			continue
+		}
+		_, err := worker.service.Write(bs)
+		if err != nil {
+			this_.Logger.Error("broadcast write error", zap.Any("key", key), zap.Error(err))
+			continue
+		}
+		if label != "" {
+			worker.recordBroadcast(label)
+		}
+	}
+}
@@ -28,6 +28,12 @@ type TerminalCommandService struct {
 func (this_ *TerminalCommandService) ServerReady() (err error) {
 
 	this_.cleanDeprecatedLog()
+
+	this_.interruptBatchReport()
+
+	this_.cleanRecording()
+	// 每天 2 点 30 分执行
+	_, err = this_.CronHandler.AddFunc("0 30 2 * * ?", this_.cleanRecording)
 	return
 }
 
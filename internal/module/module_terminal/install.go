@@ -156,5 +156,166 @@ CREATE TABLE ` + TableTerminalCommand + ` (
 			},
 		},
 		/** 终端命令 添加 类型、注释 结束**/
+
+		// 创建 批量执行报告 表 开始
+		{
+			Version: "1.0.5",
+			Module:  ModuleTerminalBatch,
+			Stage:   `创建表[` + TableTerminalBatchReport + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableTerminalBatchReport + ` (
+	reportId bigint(20) NOT NULL COMMENT '报告ID',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	quickCommandId bigint(20) DEFAULT NULL COMMENT '快速指令ID',
+	name varchar(200) DEFAULT NULL COMMENT '名称',
+	command text DEFAULT NULL COMMENT '命令模板',
+	params text DEFAULT NULL COMMENT '参数',
+	concurrency int(10) DEFAULT NULL COMMENT '并发数',
+	timeout int(10) DEFAULT NULL COMMENT '超时秒数',
+	status int(10) DEFAULT NULL COMMENT '状态',
+	hostCount int(10) DEFAULT NULL COMMENT '主机数',
+	successCount int(10) DEFAULT NULL COMMENT '成功数',
+	failCount int(10) DEFAULT NULL COMMENT '失败数',
+	result longtext DEFAULT NULL COMMENT '执行结果',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	endTime datetime DEFAULT NULL COMMENT '结束时间',
+	PRIMARY KEY (reportId),
+	KEY index_userId (userId),
+	KEY index_quickCommandId (quickCommandId),
+	KEY index_createTime (createTime)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableTerminalBatchReportComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableTerminalBatchReport + ` (
+	reportId bigint(20) NOT NULL,
+	userId bigint(20) DEFAULT NULL,
+	quickCommandId bigint(20) DEFAULT NULL,
+	name varchar(200) DEFAULT NULL,
+	command text DEFAULT NULL,
+	params text DEFAULT NULL,
+	concurrency int(10) DEFAULT NULL,
+	timeout int(10) DEFAULT NULL,
+	status int(10) DEFAULT NULL,
+	hostCount int(10) DEFAULT NULL,
+	successCount int(10) DEFAULT NULL,
+	failCount int(10) DEFAULT NULL,
+	result text DEFAULT NULL,
+	createTime datetime NOT NULL,
+	endTime datetime DEFAULT NULL,
+	PRIMARY KEY (reportId)
+);
+`,
+					`CREATE INDEX ` + TableTerminalBatchReport + `_index_userId on ` + TableTerminalBatchReport + ` (userId);`,
+					`CREATE INDEX ` + TableTerminalBatchReport + `_index_quickCommandId on ` + TableTerminalBatchReport + ` (quickCommandId);`,
+					`CREATE INDEX ` + TableTerminalBatchReport + `_index_createTime on ` + TableTerminalBatchReport + ` (createTime);`,
+				},
+			},
+		},
+		// 创建 批量执行报告 表 结束
+
+		// 创建 命令策略 表 开始
+		{
+			Version: "1.0.6",
+			Module:  ModuleTerminalPolicy,
+			Stage:   `创建表[` + TableTerminalPolicy + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableTerminalPolicy + ` (
+	policyId bigint(20) NOT NULL COMMENT '策略ID',
+	name varchar(200) DEFAULT NULL COMMENT '名称',
+	place varchar(20) DEFAULT NULL COMMENT '位置',
+	placeId varchar(20) DEFAULT NULL COMMENT '位置ID',
+	powerRoleId bigint(20) DEFAULT NULL COMMENT '权限角色ID',
+	allowOnly int(2) DEFAULT NULL COMMENT '只允许模式',
+	denyRules text DEFAULT NULL COMMENT '拒绝规则',
+	confirmRules text DEFAULT NULL COMMENT '确认规则',
+	allowRules text DEFAULT NULL COMMENT '允许规则',
+	comment varchar(500) DEFAULT NULL COMMENT '说明',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	updateTime datetime DEFAULT NULL COMMENT '修改时间',
+	PRIMARY KEY (policyId),
+	KEY index_place (place),
+	KEY index_placeId (placeId),
+	KEY index_powerRoleId (powerRoleId)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableTerminalPolicyComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableTerminalPolicy + ` (
+	policyId bigint(20) NOT NULL,
+	name varchar(200) DEFAULT NULL,
+	place varchar(20) DEFAULT NULL,
+	placeId varchar(20) DEFAULT NULL,
+	powerRoleId bigint(20) DEFAULT NULL,
+	allowOnly int(2) DEFAULT NULL,
+	denyRules text DEFAULT NULL,
+	confirmRules text DEFAULT NULL,
+	allowRules text DEFAULT NULL,
+	comment varchar(500) DEFAULT NULL,
+	userId bigint(20) DEFAULT NULL,
+	createTime datetime NOT NULL,
+	updateTime datetime DEFAULT NULL,
+	PRIMARY KEY (policyId)
+);
+`,
+					`CREATE INDEX ` + TableTerminalPolicy + `_index_place on ` + TableTerminalPolicy + ` (place);`,
+					`CREATE INDEX ` + TableTerminalPolicy + `_index_placeId on ` + TableTerminalPolicy + ` (placeId);`,
+					`CREATE INDEX ` + TableTerminalPolicy + `_index_powerRoleId on ` + TableTerminalPolicy + ` (powerRoleId);`,
+				},
+			},
+		},
+		// 创建 命令策略 表 结束
+
+		// 创建 输出触发器 表 开始
+		{
+			Version: "1.0.7",
+			Module:  ModuleTerminalTrigger,
+			Stage:   `创建表[` + TableTerminalTrigger + `]`,
+			Sql: &install.StageSqlModel{
+				Mysql: []string{`
+CREATE TABLE ` + TableTerminalTrigger + ` (
+	triggerId bigint(20) NOT NULL COMMENT '触发器ID',
+	name varchar(200) DEFAULT NULL COMMENT '名称',
+	place varchar(20) DEFAULT NULL COMMENT '位置',
+	placeId varchar(20) DEFAULT NULL COMMENT '位置ID',
+	pattern varchar(2000) DEFAULT NULL COMMENT '规则',
+	highlight varchar(20) DEFAULT NULL COMMENT '高亮颜色',
+	notify int(2) DEFAULT NULL COMMENT '发送通知',
+	sendText varchar(2000) DEFAULT NULL COMMENT '发送内容',
+	cooldown int(10) DEFAULT NULL COMMENT '动作间隔',
+	enable int(2) DEFAULT NULL COMMENT '启用',
+	comment varchar(500) DEFAULT NULL COMMENT '说明',
+	userId bigint(20) DEFAULT NULL COMMENT '用户ID',
+	createTime datetime NOT NULL COMMENT '创建时间',
+	updateTime datetime DEFAULT NULL COMMENT '修改时间',
+	PRIMARY KEY (triggerId),
+	KEY index_userId (userId)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='` + TableTerminalTriggerComment + `';
+`},
+				Sqlite: []string{`
+CREATE TABLE ` + TableTerminalTrigger + ` (
+	triggerId bigint(20) NOT NULL,
+	name varchar(200) DEFAULT NULL,
+	place varchar(20) DEFAULT NULL,
+	placeId varchar(20) DEFAULT NULL,
+	pattern varchar(2000) DEFAULT NULL,
+	highlight varchar(20) DEFAULT NULL,
+	notify int(2) DEFAULT NULL,
+	sendText varchar(2000) DEFAULT NULL,
+	cooldown int(10) DEFAULT NULL,
+	enable int(2) DEFAULT NULL,
+	comment varchar(500) DEFAULT NULL,
+	userId bigint(20) DEFAULT NULL,
+	createTime datetime NOT NULL,
+	updateTime datetime DEFAULT NULL,
+	PRIMARY KEY (triggerId)
+);
+`,
+					`CREATE INDEX ` + TableTerminalTrigger + `_index_userId on ` + TableTerminalTrigger + ` (userId);`,
+				},
+			},
+		},
+		// 创建 输出触发器 表 结束
 	}
 }
@@ -14,6 +14,31 @@ const (
 	// TableTerminalCommand 控制台日志表
 	TableTerminalCommand        = "TM_TERMINAL_COMMAND"
 	TableTerminalCommandComment = "控制台日志"
+
+	// ModuleTerminalBatch 批量执行模块
+	ModuleTerminalBatch = "terminal_batch"
+	// TableTerminalBatchReport 批量执行报告表
+	TableTerminalBatchReport        = "TM_TERMINAL_BATCH_REPORT"
+	TableTerminalBatchReportComment = "批量执行报告"
+
+	// ModuleTerminalPolicy 命令策略模块
+	ModuleTerminalPolicy = "terminal_policy"
+	// TableTerminalPolicy 命令策略表
+	TableTerminalPolicy        = "TM_TERMINAL_POLICY"
+	TableTerminalPolicyComment = "命令策略"
+
+	// ModuleTerminalTrigger 输出触发器模块
+	ModuleTerminalTrigger = "terminal_trigger"
+	// TableTerminalTrigger 输出触发器表
+	TableTerminalTrigger        = "TM_TERMINAL_TRIGGER"
+	TableTerminalTriggerComment = "输出触发器"
+)
+
+const (
+	// CommandTypeBlocked 命令策略拦截的命令，comment 为拦截原因
+	CommandTypeBlocked = 10
+	// CommandTypeConfirmed 命令策略要求确认，用户确认后执行的命令
+	CommandTypeConfirmed = 11
 )
 
 // TerminalCommandModel 控制台命令
@@ -42,3 +67,58 @@ type TerminalCommand struct {
 	CommandType       int       `json:"commandType,omitempty"`
 	CreateTime        time.Time `json:"createTime,omitempty"`
 }
+
+// TerminalBatchReportModel 批量执行报告，Result 为各主机执行结果的 JSON
+type TerminalBatchReportModel struct {
+	ReportId       int64     `json:"reportId,omitempty"`
+	UserId         int64     `json:"userId,omitempty"`
+	QuickCommandId int64     `json:"quickCommandId,omitempty"`
+	Name           string    `json:"name,omitempty"`
+	Command        string    `json:"command,omitempty"`
+	Params         string    `json:"params,omitempty"`
+	Concurrency    int       `json:"concurrency,omitempty"`
+	Timeout        int       `json:"timeout,omitempty"`
+	Status         int       `json:"status,omitempty"`
+	HostCount      int       `json:"hostCount,omitempty"`
+	SuccessCount   int       `json:"successCount,omitempty"`
+	FailCount      int       `json:"failCount,omitempty"`
+	Result         string    `json:"result,omitempty"`
+	CreateTime     time.Time `json:"createTime,omitempty"`
+	EndTime        time.Time `json:"endTime,omitempty"`
+}
+
+// TerminalPolicyModel 命令策略，规则为正则表达式，多个规则按行分隔
+// Place、PlaceId、PowerRoleId 为空时匹配所有，设置的条件都匹配时策略生效
+type TerminalPolicyModel struct {
+	PolicyId     int64     `json:"policyId,omitempty"`
+	Name         string    `json:"name,omitempty"`
+	Place        string    `json:"place,omitempty"`
+	PlaceId      string    `json:"placeId,omitempty"`
+	PowerRoleId  int64     `json:"powerRoleId,omitempty"`
+	AllowOnly    int       `json:"allowOnly,omitempty"` // 1 为只允许模式，只能执行 AllowRules 中的命令
+	DenyRules    string    `json:"denyRules,omitempty"`
+	ConfirmRules string    `json:"confirmRules,omitempty"`
+	AllowRules   string    `json:"allowRules,omitempty"`
+	Comment      string    `json:"comment,omitempty"`
+	UserId       int64     `json:"userId,omitempty"`
+	CreateTime   time.Time `json:"createTime,omitempty"`
+	UpdateTime   time.Time `json:"updateTime,omitempty"`
+}
+
+// TerminalTriggerModel 输出触发器，Pattern 为正则表达式，Place、PlaceId 为空时对用户所有终端生效
+type TerminalTriggerModel struct {
+	TriggerId  int64     `json:"triggerId,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	Place      string    `json:"place,omitempty"`
+	PlaceId    string    `json:"placeId,omitempty"`
+	Pattern    string    `json:"pattern,omitempty"`
+	Highlight  string    `json:"highlight,omitempty"` // 高亮颜色，为空时不高亮
+	Notify     int       `json:"notify,omitempty"`    // 1 为命中后发送通知
+	SendText   string    `json:"sendText,omitempty"`  // 命中后发送到终端的内容
+	Cooldown   int       `json:"cooldown,omitempty"`  // 两次动作的最小间隔，秒
+	Enable     int       `json:"enable,omitempty"`    // 1 为启用
+	Comment    string    `json:"comment,omitempty"`
+	UserId     int64     `json:"userId,omitempty"`
+	CreateTime time.Time `json:"createTime,omitempty"`
+	UpdateTime time.Time `json:"updateTime,omitempty"`
+}
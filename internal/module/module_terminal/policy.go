@@ -0,0 +1,294 @@
+package module_terminal
+
+import (
+	"bytes"
+	"errors"
+	"go.uber.org/zap"
+	"strings"
+	"teamide/internal/module/module_id"
+	"teamide/internal/module/module_power"
+	"teamide/pkg/terminal"
+	"time"
+)
+
+// toCommandPolicy 策略规则按行分隔
+func (this_ *TerminalPolicyModel) toCommandPolicy() *terminal.CommandPolicy {
+	return &terminal.CommandPolicy{
+		Name:        this_.Name,
+		DenyList:    strings.Split(this_.DenyRules, "\n"),
+		ConfirmList: strings.Split(this_.ConfirmRules, "\n"),
+		AllowOnly:   this_.AllowOnly == 1,
+		AllowList:   strings.Split(this_.AllowRules, "\n"),
+	}
+}
+
+// match 设置的条件都匹配时策略生效
+func (this_ *TerminalPolicyModel) match(place string, placeId string, powerRoleIds map[int64]bool) bool {
+	if this_.Place != "" && this_.Place != place {
+		return false
+	}
+	if this_.PlaceId != "" && this_.PlaceId != placeId {
+		return false
+	}
+	if this_.PowerRoleId != 0 && !powerRoleIds[this_.PowerRoleId] {
+		return false
+	}
+	return true
+}
+
+// SavePolicy 新增或更新命令策略，保存前校验规则
+func (this_ *TerminalCommandService) SavePolicy(policy *TerminalPolicyModel) (err error) {
+	if strings.TrimSpace(policy.Name) == "" {
+		err = errors.New("策略名称不能为空")
+		return
+	}
+	if _, err = terminal.NewCommandChecker(policy.toCommandPolicy()); err != nil {
+		return
+	}
+	policy.UpdateTime = time.Now()
+
+	if policy.PolicyId > 0 {
+		sql := `UPDATE ` + TableTerminalPolicy + ` SET name=?,place=?,placeId=?,powerRoleId=?,allowOnly=?,denyRules=?,confirmRules=?,allowRules=?,comment=?,updateTime=? WHERE policyId=? `
+
+		_, err = this_.DatabaseWorker.Exec(sql, []interface{}{
+			policy.Name,
+			policy.Place,
+			policy.PlaceId,
+			policy.PowerRoleId,
+			policy.AllowOnly,
+			policy.DenyRules,
+			policy.ConfirmRules,
+			policy.AllowRules,
+			policy.Comment,
+			policy.UpdateTime,
+			policy.PolicyId,
+		})
+		if err != nil {
+			return
+		}
+		return
+	}
+	policy.PolicyId, err = this_.idService.GetNextID(module_id.IDTypeTerminalPolicy)
+	if err != nil {
+		return
+	}
+	if policy.CreateTime.IsZero() {
+		policy.CreateTime = time.Now()
+	}
+
+	sql := `INSERT INTO ` + TableTerminalPolicy +
+		`(policyId, name, place, placeId, powerRoleId, allowOnly, denyRules, confirmRules, allowRules, comment, userId, createTime, updateTime)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{
+		policy.PolicyId,
+		policy.Name,
+		policy.Place,
+		policy.PlaceId,
+		policy.PowerRoleId,
+		policy.AllowOnly,
+		policy.DenyRules,
+		policy.ConfirmRules,
+		policy.AllowRules,
+		policy.Comment,
+		policy.UserId,
+		policy.CreateTime,
+		policy.UpdateTime,
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// QueryPolicy 查询所有命令策略
+func (this_ *TerminalCommandService) QueryPolicy() (list []*TerminalPolicyModel, err error) {
+
+	var sqlInfo = "SELECT * FROM " + TableTerminalPolicy + " ORDER BY createTime ASC "
+
+	err = this_.DatabaseWorker.Query(sqlInfo, []interface{}{}, &list)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// DeletePolicy 删除命令策略
+func (this_ *TerminalCommandService) DeletePolicy(policyId int64) (err error) {
+
+	var sqlInfo = "DELETE FROM " + TableTerminalPolicy + " WHERE policyId=? "
+
+	_, err = this_.DatabaseWorker.Exec(sqlInfo, []interface{}{policyId})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// GetCommandChecker 获取用户在终端位置上生效的命令策略，只在服务端模式下生效，没有策略时返回 nil
+func (this_ *TerminalCommandService) GetCommandChecker(userId int64, place string, placeId string) (checker *terminal.CommandChecker, err error) {
+	if !this_.IsServer {
+		return
+	}
+	list, err := this_.QueryPolicy()
+	if err != nil || len(list) == 0 {
+		return
+	}
+	roles, err := module_power.NewPowerUserService(this_.ServerContext).QueryPowerRolesByUserId(userId)
+	if err != nil {
+		return
+	}
+	powerRoleIds := map[int64]bool{}
+	for _, role := range roles {
+		powerRoleIds[role.PowerRoleId] = true
+	}
+	var policies []*terminal.CommandPolicy
+	for _, one := range list {
+		if one.match(place, placeId, powerRoleIds) {
+			policies = append(policies, one.toCommandPolicy())
+		}
+	}
+	if len(policies) == 0 {
+		return
+	}
+	checker, err = terminal.NewCommandChecker(policies...)
+	if err != nil {
+		return
+	}
+	if checker.IsEmpty() {
+		checker = nil
+	}
+	return
+}
+
+const (
+	// policyCancelLine 清除远程当前输入的行（Ctrl+E、Ctrl+U）并回车显示新的提示符
+	policyCancelLine = "\x05\x15\r"
+	// policySecretCommand 密码提示后被拦截的输入，记录时代替输入的内容
+	policySecretCommand = "******"
+)
+
+// policyConfirm 等待用户确认的命令
+type policyConfirm struct {
+	command string
+	result  *terminal.PolicyResult
+}
+
+// checkInput 按命令策略检查用户输入，回车提交的命令被拒绝时清除远程当前行，需要确认时暂停提交等待用户按键确认
+// interactive 为 false 时（广播输入、上传）无法确认，需要确认的命令直接拒绝
+func (this_ *Worker) checkInput(buf []byte, interactive bool) (out []byte) {
+	this_.policyLock.Lock()
+	defer this_.policyLock.Unlock()
+
+	if this_.commandChecker == nil {
+		out = buf
+		return
+	}
+	if this_.policyConfirm != nil {
+		out = this_.onPolicyConfirm(buf, interactive)
+		return
+	}
+	for len(buf) > 0 {
+		index := bytes.IndexAny(buf, "\r\n")
+		if index < 0 {
+			this_.commandLine.Input(buf)
+			out = append(out, buf...)
+			return
+		}
+		this_.commandLine.Input(buf[:index])
+		out = append(out, buf[:index]...)
+
+		command, secret := this_.commandLine.Line()
+		res := this_.commandChecker.Check(command)
+		if secret {
+			// 密码提示后输入的内容不记录
+			command = policySecretCommand
+		}
+		if res.Action == terminal.PolicyConfirm && !interactive {
+			res = &terminal.PolicyResult{Action: terminal.PolicyDeny, Policy: res.Policy, Rule: res.Rule, Reason: res.Reason + "，当前输入方式无法确认"}
+		}
+		switch res.Action {
+		case terminal.PolicyDeny:
+			this_.commandLine.Reset()
+			out = append(out, policyCancelLine...)
+			this_.writeWS([]byte("\r\n\x1b[31m[命令已拦截] " + formatPolicyReason(res) + "\x1b[0m\r\n"))
+			this_.savePolicyCommand(command, CommandTypeBlocked, formatPolicyReason(res))
+			// 拦截后丢弃剩余的输入，避免粘贴的多行命令继续执行
+			return
+		case terminal.PolicyConfirm:
+			this_.policyConfirm = &policyConfirm{command: command, result: res}
+			this_.writeWS([]byte("\r\n\x1b[33m[命令需要确认] " + formatPolicyReason(res) + "，输入 y 执行，其它键取消：\x1b[0m"))
+			return
+		}
+		this_.commandLine.Reset()
+		out = append(out, buf[index])
+		buf = buf[index+1:]
+	}
+	return
+}
+
+// onPolicyConfirm 用户确认等待中的命令，y 执行，其它键取消，确认后剩余的输入丢弃
+func (this_ *Worker) onPolicyConfirm(buf []byte, interactive bool) (out []byte) {
+	if !interactive || len(buf) == 0 {
+		return
+	}
+	confirm := this_.policyConfirm
+	this_.policyConfirm = nil
+	this_.commandLine.Reset()
+	reason := formatPolicyReason(confirm.result)
+	if buf[0] == 'y' || buf[0] == 'Y' {
+		this_.writeWS([]byte("y\r\n"))
+		this_.savePolicyCommand(confirm.command, CommandTypeConfirmed, reason)
+		out = []byte{'\r'}
+		return
+	}
+	this_.writeWS([]byte("\r\n"))
+	this_.savePolicyCommand(confirm.command, CommandTypeBlocked, reason+"，用户取消执行")
+	out = []byte(policyCancelLine)
+	return
+}
+
+func formatPolicyReason(res *terminal.PolicyResult) string {
+	if res.Policy == "" {
+		return res.Reason
+	}
+	return "策略[" + res.Policy + "]" + res.Reason
+}
+
+// savePolicyCommand 拦截和确认的命令记录到终端命令
+func (this_ *Worker) savePolicyCommand(command string, commandType int, reason string) {
+	err := this_.terminalCommandService.Save(&TerminalCommandModel{
+		WorkerId:    this_.workerId,
+		UserId:      this_.userId,
+		UserName:    this_.userName,
+		Place:       this_.place,
+		PlaceId:     this_.placeId,
+		Command:     command,
+		Comment:     reason,
+		CommandType: commandType,
+	})
+	if err != nil {
+		this_.Logger.Error("save policy command error", zap.Any("key", this_.key), zap.Error(err))
+	}
+}
+
+// reloadPolicy 命令策略变更后重新加载所有终端的策略
+func (this_ *WorkerFactory) reloadPolicy() {
+	this_.workerCacheLock.Lock()
+	var workers []*Worker
+	for _, worker := range this_.workerCache {
+		workers = append(workers, worker)
+	}
+	this_.workerCacheLock.Unlock()
+
+	for _, worker := range workers {
+		checker, err := this_.terminalCommandService.GetCommandChecker(worker.userId, worker.place, worker.placeId)
+		if err != nil {
+			this_.Logger.Error("reload policy error", zap.Any("key", worker.key), zap.Error(err))
+			continue
+		}
+		worker.policyLock.Lock()
+		worker.commandChecker = checker
+		worker.policyLock.Unlock()
+	}
+}
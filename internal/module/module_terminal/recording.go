@@ -0,0 +1,188 @@
+package module_terminal
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"os"
+	"sort"
+	"strings"
+	"teamide/pkg/terminal"
+	"time"
+)
+
+const (
+	// recordingFileName 终端录像文件，asciicast v2 格式，与 command.log 同目录
+	recordingFileName = "session.cast"
+)
+
+type RecordingInfo struct {
+	PlaceId   string `json:"placeId"`
+	WorkerId  string `json:"workerId"`
+	Size      int64  `json:"size"`
+	ModTime   int64  `json:"modTime,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	StartTime int64  `json:"startTime,omitempty"`
+	// Recording 会话是否仍在录制
+	Recording bool `json:"recording,omitempty"`
+}
+
+// checkPathName 校验拼接到路径中的名称，防止跳出工作目录
+func checkPathName(names ...string) (err error) {
+	for _, name := range names {
+		if strings.Contains(name, "..") || strings.ContainsAny(name, "/\\") {
+			err = errors.New("名称[" + name + "]不合法")
+			return
+		}
+	}
+	return
+}
+
+func (this_ *WorkerFactory) getRecordingPath(place string, placeId string, workerId string) (path string, err error) {
+	err = checkPathName(place, placeId, workerId)
+	if err != nil {
+		return
+	}
+	if workerId == "" {
+		err = errors.New("workerId获取失败")
+		return
+	}
+	path = this_.getParentDir(place, placeId) + workerId + "/" + recordingFileName
+	return
+}
+
+func (this_ *WorkerFactory) isRecording(place string, placeId string, workerId string) bool {
+	this_.workerCacheLock.Lock()
+	defer this_.workerCacheLock.Unlock()
+
+	for _, one := range this_.workerCache {
+		if one.place == place && one.placeId == placeId && one.workerId == workerId && one.recorder != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (this_ *WorkerFactory) getRecordings(place string, placeId string) (recordings []*RecordingInfo, err error) {
+	err = checkPathName(place, placeId)
+	if err != nil {
+		return
+	}
+	parentDir := this_.getParentDir(place, placeId)
+
+	ex, _ := util.PathExists(parentDir)
+	if !ex {
+		return
+	}
+
+	fileList, err := os.ReadDir(parentDir)
+	if err != nil {
+		return
+	}
+	for _, f := range fileList {
+		if !f.IsDir() {
+			continue
+		}
+		path := parentDir + f.Name() + "/" + recordingFileName
+		stat, e := os.Stat(path)
+		if e != nil {
+			continue
+		}
+		info := &RecordingInfo{
+			PlaceId:   placeId,
+			WorkerId:  f.Name(),
+			Size:      stat.Size(),
+			ModTime:   util.GetMilliByTime(stat.ModTime()),
+			Recording: this_.isRecording(place, placeId, f.Name()),
+		}
+		header, e := terminal.ReadRecordHeader(path)
+		if e == nil {
+			info.Width = header.Width
+			info.Height = header.Height
+			info.StartTime = header.Timestamp * 1000
+		}
+		recordings = append(recordings, info)
+	}
+
+	sort.Slice(recordings, func(i, j int) bool {
+		return recordings[i].ModTime > recordings[j].ModTime
+	})
+	return
+}
+
+// startRecord 服务启动后开始录像
+func (this_ *Worker) startRecord(size *terminal.Size) {
+	if this_.dir == "" {
+		return
+	}
+	header := &terminal.RecordHeader{
+		Title: this_.place + "-" + this_.placeId,
+		Env: map[string]string{
+			"TERM": "xterm-256color",
+		},
+	}
+	if size != nil {
+		header.Width = size.Cols
+		header.Height = size.Rows
+	}
+	recorder, err := terminal.NewRecorder(this_.dir+recordingFileName, header)
+	if err != nil {
+		this_.Logger.Error("terminal recorder create error", zap.Any("dir", this_.dir), zap.Error(err))
+		return
+	}
+	this_.recorder = recorder
+}
+
+func (this_ *Worker) recordMarker(label string) {
+	if this_.recorder != nil {
+		this_.recorder.Marker(label)
+	}
+}
+
+func (this_ *Worker) recordResize(size *terminal.Size) {
+	if this_.recorder != nil && size != nil {
+		this_.recorder.Resize(size.Cols, size.Rows)
+	}
+}
+
+// cleanRecording 删除超过保留天数的终端录像
+func (this_ *TerminalCommandService) cleanRecording() {
+	saveDays := this_.ServerConfig.LogDataSaveDays
+	if saveDays <= 0 {
+		return
+	}
+	deleteBeforeTime := time.Now().AddDate(0, 0, -saveDays)
+	var deleteCount int
+	this_.Logger.Info("terminal recording clean task start", zap.Any("saveDays", saveDays))
+	defer func() {
+		this_.Logger.Info("terminal recording clean task end", zap.Any("saveDays", saveDays), zap.Any("deleteCount", deleteCount))
+	}()
+
+	workersDir := this_.GetFilesDir() + "toolbox-workers/"
+	placeDirs, err := os.ReadDir(workersDir)
+	if err != nil {
+		return
+	}
+	for _, placeDir := range placeDirs {
+		if !placeDir.IsDir() {
+			continue
+		}
+		workerDirs, e := os.ReadDir(workersDir + placeDir.Name())
+		if e != nil {
+			continue
+		}
+		for _, workerDir := range workerDirs {
+			path := workersDir + placeDir.Name() + "/" + workerDir.Name() + "/" + recordingFileName
+			stat, e := os.Stat(path)
+			if e != nil || !stat.ModTime().Before(deleteBeforeTime) {
+				continue
+			}
+			if e = os.Remove(path); e != nil {
+				this_.Logger.Error("terminal recording remove error", zap.Any("path", path), zap.Error(e))
+				continue
+			}
+			deleteCount++
+		}
+	}
+}
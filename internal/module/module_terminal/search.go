@@ -0,0 +1,526 @@
+package module_terminal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/logindex"
+	"time"
+)
+
+const (
+	// sessionMetaFileName 会话信息，记录会话所属用户，搜索时用于判断是否可见
+	sessionMetaFileName = "session.json"
+	// commandIndexFileName command.log 的倒排索引，搜索时增量更新
+	commandIndexFileName = "command.idx"
+	// commandTimeFileName command.log 的时间标记，用于按时间范围搜索和显示命中行的时间
+	commandTimeFileName = "command.time"
+
+	// timeMarkInterval 写入日志时，距离上次时间标记超过该间隔（毫秒）则追加时间标记
+	timeMarkInterval = 60 * 1000
+
+	searchDefaultLimit = 100
+	searchMaxLimit     = 1000
+	searchMaxContext   = 10
+	// searchCommandScan 正则搜索历史命令时最多读取的记录数
+	searchCommandScan = 10000
+
+	logReadDefaultSize = 16 * 1024
+	logReadMaxSize     = 256 * 1024
+)
+
+// SessionMeta 会话信息，与 command.log 同目录
+type SessionMeta struct {
+	UserId    int64  `json:"userId,omitempty"`
+	UserName  string `json:"userName,omitempty"`
+	Place     string `json:"place,omitempty"`
+	PlaceId   string `json:"placeId,omitempty"`
+	WorkerId  string `json:"workerId,omitempty"`
+	StartTime int64  `json:"startTime,omitempty"`
+	// LastTime 日志最后写入时间，读取时根据日志文件修改时间设置
+	LastTime int64 `json:"lastTime,omitempty"`
+}
+
+// SearchRequest 搜索终端日志和历史命令，Keyword 为文本时忽略大小写按词匹配，Regexp 为 true 时按正则表达式匹配
+// UserId 为会话所属用户，只能在当前用户可见的会话中筛选
+type SearchRequest struct {
+	Keyword   string `json:"keyword,omitempty"`
+	Regexp    bool   `json:"regexp,omitempty"`
+	StartTime int64  `json:"startTime,omitempty"`
+	EndTime   int64  `json:"endTime,omitempty"`
+	Place     string `json:"place,omitempty"`
+	PlaceId   string `json:"placeId,omitempty"`
+	UserId    int64  `json:"userId,omitempty"`
+	Context   int    `json:"context,omitempty"`
+	Limit     int    `json:"limit,omitempty"`
+	// NotLog、NotCommand 不搜索终端日志、历史命令
+	NotLog     bool `json:"notLog,omitempty"`
+	NotCommand bool `json:"notCommand,omitempty"`
+}
+
+// SearchLogHit 日志命中的行，Place、PlaceId、WorkerId、Offset 定位到日志位置
+type SearchLogHit struct {
+	*logindex.Hit
+	Place    string `json:"place"`
+	PlaceId  string `json:"placeId"`
+	WorkerId string `json:"workerId"`
+	UserId   int64  `json:"userId,omitempty"`
+	UserName string `json:"userName,omitempty"`
+}
+
+// sessionVisibility 当前用户可以看到的会话：自己的会话，以及自己创建的工具上的所有会话
+type sessionVisibility struct {
+	userId     int64
+	toolboxIds map[string]bool
+	// legacyWorkerIds 没有会话信息的旧会话中，当前用户执行过命令的会话
+	legacyWorkerIds map[string]bool
+}
+
+func (this_ *sessionVisibility) canSee(meta *SessionMeta) bool {
+	if meta.UserId == this_.userId {
+		return true
+	}
+	if isToolboxPlace(meta.Place) && this_.toolboxIds[meta.PlaceId] {
+		return true
+	}
+	return meta.UserId == 0 && this_.legacyWorkerIds[meta.WorkerId]
+}
+
+// isToolboxPlace SSH、Telnet 会话的 placeId 为工具ID，其它位置（本地、节点）的 placeId 不是工具ID
+func isToolboxPlace(place string) bool {
+	return place == "ssh" || place == "telnet"
+}
+
+// escapeLike 转义 LIKE 中的通配符，配合 ESCAPE '/' 使用
+func escapeLike(str string) string {
+	str = strings.ReplaceAll(str, "/", "//")
+	str = strings.ReplaceAll(str, "%", "/%")
+	str = strings.ReplaceAll(str, "_", "/_")
+	return str
+}
+
+func (this_ *WorkerFactory) getSessionVisibility(userId int64) (visibility *sessionVisibility, err error) {
+	visibility = &sessionVisibility{
+		userId:          userId,
+		toolboxIds:      map[string]bool{},
+		legacyWorkerIds: map[string]bool{},
+	}
+	toolboxList, err := this_.toolboxService.Query(&module_toolbox.ToolboxModel{UserId: userId})
+	if err != nil {
+		return
+	}
+	for _, one := range toolboxList {
+		visibility.toolboxIds[fmt.Sprint(one.ToolboxId)] = true
+	}
+	workerIds, err := this_.terminalCommandService.QueryUserWorkerIds(userId)
+	if err != nil {
+		return
+	}
+	for _, workerId := range workerIds {
+		visibility.legacyWorkerIds[workerId] = true
+	}
+	return
+}
+
+// writeSessionMeta 会话开始时记录会话信息，重新连接同一个会话时保留原信息
+func (this_ *Worker) writeSessionMeta() {
+	path := this_.dir + sessionMetaFileName
+	if ex, _ := util.PathExists(path); ex {
+		return
+	}
+	bs, err := json.Marshal(&SessionMeta{
+		UserId:    this_.userId,
+		UserName:  this_.userName,
+		Place:     this_.place,
+		PlaceId:   this_.placeId,
+		WorkerId:  this_.workerId,
+		StartTime: util.GetNowMilli(),
+	})
+	if err != nil {
+		return
+	}
+	if err = os.WriteFile(path, bs, 0666); err != nil {
+		this_.Logger.Error("terminal session meta write error", zap.Any("path", path), zap.Error(err))
+	}
+}
+
+// writeTimeMark 写入日志前调用，offset 为本次写入的开始位置，调用方持有 commandLogLock
+func (this_ *Worker) writeTimeMark(offset int64) {
+	now := util.GetNowMilli()
+	if now-this_.lastTimeMark < timeMarkInterval {
+		return
+	}
+	f, err := os.OpenFile(this_.dir+commandTimeFileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	if err = logindex.AppendTimeMark(f, offset, now); err != nil {
+		return
+	}
+	this_.lastTimeMark = now
+}
+
+// parseParentDirName 解析 toolbox-{place}-{placeId} 目录名
+func parseParentDirName(name string) (place string, placeId string, ok bool) {
+	if !strings.HasPrefix(name, "toolbox-") {
+		return
+	}
+	name = strings.TrimPrefix(name, "toolbox-")
+	index := strings.Index(name, "-")
+	if index <= 0 {
+		return
+	}
+	return name[:index], name[index+1:], true
+}
+
+// readSessionMeta 读取会话信息，旧会话没有会话信息时根据目录生成
+func (this_ *WorkerFactory) readSessionMeta(place string, placeId string, workerId string) (meta *SessionMeta) {
+	meta = &SessionMeta{}
+	bs, err := os.ReadFile(this_.getParentDir(place, placeId) + workerId + "/" + sessionMetaFileName)
+	if err == nil {
+		_ = json.Unmarshal(bs, meta)
+	}
+	meta.Place = place
+	meta.PlaceId = placeId
+	meta.WorkerId = workerId
+	return
+}
+
+// getVisibleSessions 查询当前用户可以看到的、符合条件的会话
+func (this_ *WorkerFactory) getVisibleSessions(visibility *sessionVisibility, request *SearchRequest) (sessions []*SessionMeta, err error) {
+	workersDir := this_.GetFilesDir() + "toolbox-workers/"
+	if ex, _ := util.PathExists(workersDir); !ex {
+		return
+	}
+	placeDirs, err := os.ReadDir(workersDir)
+	if err != nil {
+		return
+	}
+	for _, placeDir := range placeDirs {
+		if !placeDir.IsDir() {
+			continue
+		}
+		place, placeId, ok := parseParentDirName(placeDir.Name())
+		if !ok {
+			continue
+		}
+		if (request.Place != "" && request.Place != place) || (request.PlaceId != "" && request.PlaceId != placeId) {
+			continue
+		}
+		workerDirs, e := os.ReadDir(workersDir + placeDir.Name())
+		if e != nil {
+			continue
+		}
+		for _, workerDir := range workerDirs {
+			if !workerDir.IsDir() {
+				continue
+			}
+			meta := this_.readSessionMeta(place, placeId, workerDir.Name())
+			if !visibility.canSee(meta) {
+				continue
+			}
+			if request.UserId != 0 && request.UserId != meta.UserId {
+				continue
+			}
+			if request.EndTime > 0 && meta.StartTime > request.EndTime {
+				continue
+			}
+			stat, e := os.Stat(this_.getLogPath(place, placeId, meta.WorkerId))
+			if e != nil {
+				continue
+			}
+			meta.LastTime = util.GetMilliByTime(stat.ModTime())
+			if request.StartTime > 0 && meta.LastTime < request.StartTime {
+				continue
+			}
+			sessions = append(sessions, meta)
+		}
+	}
+	// 最近的会话在前
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastTime > sessions[j].LastTime
+	})
+	return
+}
+
+// loadCommandIndex 增量更新会话日志的索引，索引有变化时保存
+func (this_ *WorkerFactory) loadCommandIndex(dir string, f *os.File) (index *logindex.Index, err error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return
+	}
+	this_.commandIndexLock.Lock()
+	defer this_.commandIndexLock.Unlock()
+
+	index = logindex.Load(dir + commandIndexFileName)
+	changed, err := index.Update(f, stat.Size())
+	if err != nil {
+		return
+	}
+	if changed {
+		if e := index.Save(dir + commandIndexFileName); e != nil {
+			this_.Logger.Warn("terminal log index save error", zap.Any("dir", dir), zap.Error(e))
+		}
+	}
+	return
+}
+
+func getSearchMatcher(request *SearchRequest) (match logindex.Matcher, query string, err error) {
+	if request.Regexp {
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(request.Keyword); err != nil {
+			err = errors.New("正则表达式[" + request.Keyword + "]错误:" + err.Error())
+			return
+		}
+		match = re.MatchString
+		return
+	}
+	match = logindex.TextMatcher(request.Keyword)
+	query = request.Keyword
+	return
+}
+
+// searchLogs 在可见会话的日志中搜索，按会话从新到旧返回命中的行
+func (this_ *WorkerFactory) searchLogs(visibility *sessionVisibility, request *SearchRequest) (hits []*SearchLogHit, err error) {
+	match, query, err := getSearchMatcher(request)
+	if err != nil {
+		return
+	}
+	sessions, err := this_.getVisibleSessions(visibility, request)
+	if err != nil {
+		return
+	}
+	for _, session := range sessions {
+		if len(hits) >= request.Limit {
+			break
+		}
+		var sessionHits []*logindex.Hit
+		sessionHits, err = this_.searchSessionLog(session, match, query, request, request.Limit-len(hits))
+		if err != nil {
+			return
+		}
+		for _, hit := range sessionHits {
+			hits = append(hits, &SearchLogHit{
+				Hit:      hit,
+				Place:    session.Place,
+				PlaceId:  session.PlaceId,
+				WorkerId: session.WorkerId,
+				UserId:   session.UserId,
+				UserName: session.UserName,
+			})
+		}
+	}
+	return
+}
+
+func (this_ *WorkerFactory) searchSessionLog(session *SessionMeta, match logindex.Matcher, query string, request *SearchRequest, limit int) (hits []*logindex.Hit, err error) {
+	dir := this_.getParentDir(session.Place, session.PlaceId) + session.WorkerId + "/"
+	f, err := os.Open(dir + "command.log")
+	if err != nil {
+		// 会话日志可能已被删除
+		err = nil
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	index, err := this_.loadCommandIndex(dir, f)
+	if err != nil {
+		return
+	}
+	marks := logindex.ReadTimeMarks(dir + commandTimeFileName)
+	option := &logindex.SearchOption{
+		Query:   query,
+		Match:   match,
+		Context: request.Context,
+		Limit:   limit,
+	}
+	// 没有时间标记的旧日志不按时间过滤
+	if len(marks) > 0 && (request.StartTime > 0 || request.EndTime > 0) {
+		option.Accept = func(offset int64) bool {
+			t := logindex.TimeAt(marks, offset)
+			return (request.StartTime <= 0 || t >= request.StartTime) && (request.EndTime <= 0 || t <= request.EndTime)
+		}
+	}
+	hits, err = index.Search(f, option)
+	if err != nil {
+		return
+	}
+	for _, hit := range hits {
+		hit.Time = logindex.TimeAt(marks, hit.Offset)
+	}
+	return
+}
+
+// readSessionLog 读取会话日志 offset 开始的内容，用于从搜索结果跳转到日志位置
+func (this_ *WorkerFactory) readSessionLog(visibility *sessionVisibility, place string, placeId string, workerId string, offset int64, size int64) (res map[string]interface{}, err error) {
+	if err = checkPathName(place, placeId, workerId); err != nil {
+		return
+	}
+	meta := this_.readSessionMeta(place, placeId, workerId)
+	if !visibility.canSee(meta) {
+		err = errors.New("会话日志[" + workerId + "]不存在")
+		return
+	}
+	f, err := os.Open(this_.getLogPath(place, placeId, workerId))
+	if err != nil {
+		err = errors.New("会话日志[" + workerId + "]不存在")
+		return
+	}
+	defer func() { _ = f.Close() }()
+	stat, err := f.Stat()
+	if err != nil {
+		return
+	}
+	if size <= 0 {
+		size = logReadDefaultSize
+	}
+	if size > logReadMaxSize {
+		size = logReadMaxSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > stat.Size() {
+		offset = stat.Size()
+	}
+	if offset+size > stat.Size() {
+		size = stat.Size() - offset
+	}
+	buf := make([]byte, size)
+	read, err := f.ReadAt(buf, offset)
+	if read == len(buf) {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	res = map[string]interface{}{
+		"offset":   offset,
+		"content":  string(buf),
+		"fileSize": stat.Size(),
+		"session":  meta,
+	}
+	return
+}
+
+// removeCommandIndex 删除、清空日志时删除索引和时间标记
+func (this_ *WorkerFactory) removeCommandIndex(place string, placeId string, workerId string) {
+	if checkPathName(place, placeId, workerId) != nil {
+		return
+	}
+	dir := this_.getParentDir(place, placeId) + workerId + "/"
+	this_.commandIndexLock.Lock()
+	defer this_.commandIndexLock.Unlock()
+
+	_ = os.Remove(dir + commandIndexFileName)
+	_ = os.Remove(dir + commandTimeFileName)
+
+	// 会话仍在进行时，重新记录日志大小和时间标记
+	this_.workerCacheLock.Lock()
+	defer this_.workerCacheLock.Unlock()
+	for _, one := range this_.workerCache {
+		if one.place != place || one.placeId != placeId || one.workerId != workerId {
+			continue
+		}
+		one.commandLogLock.Lock()
+		if one.commandLogFile != nil {
+			_ = one.commandLogFile.Close()
+			one.commandLogFile = nil
+		}
+		one.lastTimeMark = 0
+		one.commandLogLock.Unlock()
+	}
+}
+
+// QueryUserWorkerIds 查询用户执行过命令的会话
+func (this_ *TerminalCommandService) QueryUserWorkerIds(userId int64) (workerIds []string, err error) {
+	var sqlInfo = "SELECT DISTINCT workerId FROM " + TableTerminalCommand + " WHERE userId=? "
+	var list []*TerminalCommandModel
+	err = this_.DatabaseWorker.Query(sqlInfo, []interface{}{userId}, &list)
+	if err != nil {
+		return
+	}
+	for _, one := range list {
+		if one.WorkerId != "" {
+			workerIds = append(workerIds, one.WorkerId)
+		}
+	}
+	return
+}
+
+// Search 搜索历史命令，文本按包含匹配，正则表达式在读取后过滤
+func (this_ *TerminalCommandService) Search(visibility *sessionVisibility, request *SearchRequest) (list []*TerminalCommandModel, err error) {
+	var sqlInfo = "SELECT terminalCommandId,workerId,userId,userName,place,placeId,command,commandType,createTime,comment FROM " + TableTerminalCommand + " WHERE "
+	var values []interface{}
+
+	// 自己执行的命令，以及自己创建的工具上的命令
+	sqlInfo += "(userId=? "
+	values = append(values, visibility.userId)
+	if len(visibility.toolboxIds) > 0 {
+		var marks []string
+		for toolboxId := range visibility.toolboxIds {
+			marks = append(marks, "?")
+			values = append(values, toolboxId)
+		}
+		sqlInfo += " OR (place IN ('ssh','telnet') AND placeId IN (" + strings.Join(marks, ",") + "))"
+	}
+	sqlInfo += ") "
+
+	if request.UserId != 0 {
+		sqlInfo += " AND userId=? "
+		values = append(values, request.UserId)
+	}
+	if request.Place != "" {
+		sqlInfo += " AND place=? "
+		values = append(values, request.Place)
+	}
+	if request.PlaceId != "" {
+		sqlInfo += " AND placeId=? "
+		values = append(values, request.PlaceId)
+	}
+	if request.StartTime > 0 {
+		sqlInfo += " AND createTime>=? "
+		values = append(values, time.UnixMilli(request.StartTime))
+	}
+	if request.EndTime > 0 {
+		sqlInfo += " AND createTime<=? "
+		values = append(values, time.UnixMilli(request.EndTime))
+	}
+	limit := request.Limit
+	var re *regexp.Regexp
+	if request.Regexp {
+		if re, err = regexp.Compile(request.Keyword); err != nil {
+			err = errors.New("正则表达式[" + request.Keyword + "]错误:" + err.Error())
+			return
+		}
+		limit = searchCommandScan
+	} else {
+		sqlInfo += " AND command LIKE ? ESCAPE '/' "
+		values = append(values, "%"+escapeLike(request.Keyword)+"%")
+	}
+	sqlInfo += fmt.Sprintf(" ORDER BY createTime DESC LIMIT %d ", limit)
+
+	var find []*TerminalCommandModel
+	err = this_.DatabaseWorker.Query(sqlInfo, values, &find)
+	if err != nil {
+		return
+	}
+	for _, one := range find {
+		if re != nil && !re.MatchString(one.Command) {
+			continue
+		}
+		list = append(list, one)
+		if len(list) >= request.Limit {
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,148 @@
+package module_terminal
+
+import (
+	"errors"
+	"github.com/gorilla/websocket"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"sort"
+	"time"
+)
+
+const (
+	// scrollbackSize 服务端保留的回滚内容大小，重新连接时回放
+	scrollbackSize = 512 * 1024
+)
+
+type SessionInfo struct {
+	// Key 只返回给会话所有者，共享的会话只返回 ShareId
+	Key      string `json:"key,omitempty"`
+	ShareId  string `json:"shareId"`
+	Place    string `json:"place"`
+	PlaceId  string `json:"placeId"`
+	WorkerId string `json:"workerId"`
+	OwnerId  int64  `json:"ownerId"`
+	// Shared 其它用户共享给当前用户的会话，通过共享连接加入，Write 为是否可以输入
+	Shared bool `json:"shared,omitempty"`
+	Write  bool `json:"write"`
+	// Attached 是否有 ws 连接
+	Attached bool `json:"attached"`
+	// DetachTime ws 断开的时间，超过保留时间后关闭服务
+	DetachTime int64 `json:"detachTime,omitempty"`
+}
+
+// getSessions 用户的终端会话，包含已断开、等待重新连接的会话，以及其它用户共享的会话
+func (this_ *WorkerFactory) getSessions(userId int64) (sessions []*SessionInfo) {
+	this_.workerCacheLock.Lock()
+	defer this_.workerCacheLock.Unlock()
+
+	for key, one := range this_.workerCache {
+		one.wsLock.Lock()
+		invite := one.shareInvites[userId]
+		if one.userId == userId || invite != nil {
+			info := &SessionInfo{
+				ShareId:    one.shareId,
+				Place:      one.place,
+				PlaceId:    one.placeId,
+				WorkerId:   one.workerId,
+				OwnerId:    one.userId,
+				Shared:     one.userId != userId,
+				Write:      one.userId == userId || invite.Write,
+				Attached:   one.ws != nil,
+				DetachTime: one.detachTime,
+			}
+			if one.userId == userId {
+				info.Key = key
+			}
+			sessions = append(sessions, info)
+		}
+		one.wsLock.Unlock()
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].WorkerId < sessions[j].WorkerId
+	})
+	return
+}
+
+// writeWS 服务输出写入回滚缓冲区，并发送到当前连接的 ws
+func (this_ *Worker) writeWS(bs []byte) {
+	this_.wsLock.Lock()
+	defer this_.wsLock.Unlock()
+
+	_, _ = this_.scrollback.Write(bs)
+	this_.writeShareWS(bs)
+	if this_.ws == nil {
+		return
+	}
+	err := this_.ws.WriteMessage(websocket.BinaryMessage, bs)
+	if err != nil {
+		// 关闭后 ws 读取结束，由 detach 处理
+		this_.Logger.Error("ws write error", zap.Any("key", this_.key), zap.Error(err))
+		_ = this_.ws.Close()
+	}
+}
+
+// attach 新的 ws 连接到会话，回放回滚内容，原有的 ws 连接将被关闭
+func (this_ *Worker) attach(ws *websocket.Conn) (err error) {
+	this_.wsLock.Lock()
+	if this_.isStopped {
+		this_.wsLock.Unlock()
+		err = errors.New("会话[" + this_.key + "]已关闭")
+		return
+	}
+	old := this_.ws
+	if this_.detachTimer != nil {
+		this_.detachTimer.Stop()
+		this_.detachTimer = nil
+	}
+	this_.detachTime = 0
+	this_.ws = ws
+	if bs := this_.scrollback.Bytes(); len(bs) > 0 {
+		err = ws.WriteMessage(websocket.BinaryMessage, bs)
+	}
+	this_.wsLock.Unlock()
+	if err != nil {
+		this_.detach(ws)
+		return
+	}
+
+	if old != nil {
+		_ = old.WriteMessage(websocket.BinaryMessage, []byte("\r\n会话已在其它窗口打开\r\n"))
+		_ = old.Close()
+	}
+	this_.Logger.Info("terminal attach", zap.Any("key", this_.key))
+	go this_.startReadWS(ws)
+	return
+}
+
+// detach ws 断开，会话保留 TerminalReattachSeconds 秒，期间可通过 key 重新连接，超时后关闭服务
+func (this_ *Worker) detach(ws *websocket.Conn) {
+	_ = ws.Close()
+
+	this_.wsLock.Lock()
+	if this_.ws != ws || this_.isStopped {
+		this_.wsLock.Unlock()
+		return
+	}
+	this_.ws = nil
+	seconds := this_.Setting.TerminalReattachSeconds
+	if seconds > 0 {
+		this_.detachTime = util.GetNowMilli()
+		this_.detachTimer = time.AfterFunc(time.Duration(seconds)*time.Second, func() {
+			this_.wsLock.Lock()
+			detached := this_.ws == nil
+			this_.wsLock.Unlock()
+			if detached {
+				this_.Logger.Info("terminal detach timeout", zap.Any("key", this_.key))
+				this_.stopAll()
+			}
+		})
+	}
+	this_.wsLock.Unlock()
+
+	if seconds <= 0 {
+		this_.stopAll()
+		return
+	}
+	this_.Logger.Info("terminal detach", zap.Any("key", this_.key), zap.Any("seconds", seconds))
+}
@@ -0,0 +1,346 @@
+package module_terminal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"io"
+	"sync"
+	"teamide/internal/context"
+	"time"
+)
+
+const (
+	// shareSendSize 共享连接待发送的输出缓存条数，写满时断开该连接，不阻塞终端输出
+	shareSendSize = 256
+	// shareWriteTimeout 共享连接单次写入的超时时间
+	shareWriteTimeout = 10 * time.Second
+)
+
+// ShareInvite 终端共享邀请，Write 为 false 时只能观看
+type ShareInvite struct {
+	UserId     int64  `json:"userId"`
+	UserName   string `json:"userName,omitempty"`
+	Write      bool   `json:"write"`
+	InviteTime int64  `json:"inviteTime,omitempty"`
+}
+
+// ShareEvent 终端共享事件，通过 terminal-share 事件通知会话所有者和被邀请的用户
+type ShareEvent struct {
+	Type string `json:"type"` // invite、revoke、join、leave
+	// ShareId 共享会话的标识，被邀请的用户通过 ShareId 加入，不暴露会话 key
+	ShareId   string `json:"shareId"`
+	Place     string `json:"place"`
+	PlaceId   string `json:"placeId"`
+	WorkerId  string `json:"workerId"`
+	OwnerId   int64  `json:"ownerId"`
+	OwnerName string `json:"ownerName,omitempty"`
+	UserId    int64  `json:"userId"`
+	UserName  string `json:"userName,omitempty"`
+	Write     bool   `json:"write"`
+}
+
+type ShareInfo struct {
+	OwnerId    int64          `json:"ownerId"`
+	OwnerName  string         `json:"ownerName,omitempty"`
+	InviteList []*ShareInvite `json:"inviteList"`
+	// OnlineList 在线的被邀请用户
+	OnlineList []*ShareInvite `json:"onlineList"`
+}
+
+// shareClient 被邀请用户的连接，输出经 sendChan 由单独的协程写入，慢的连接不影响其它连接
+type shareClient struct {
+	invite     *ShareInvite
+	ws         *websocket.Conn
+	sendChan   chan []byte
+	finishChan chan struct{}
+	finishOnce sync.Once
+}
+
+func newShareClient(invite *ShareInvite, ws *websocket.Conn) *shareClient {
+	return &shareClient{
+		invite:     invite,
+		ws:         ws,
+		sendChan:   make(chan []byte, shareSendSize),
+		finishChan: make(chan struct{}),
+	}
+}
+
+// send 不阻塞，缓存已满时返回 false
+func (this_ *shareClient) send(bs []byte) bool {
+	select {
+	case this_.sendChan <- bs:
+		return true
+	default:
+		return false
+	}
+}
+
+// finish 写完已缓存的输出后关闭连接
+func (this_ *shareClient) finish() {
+	this_.finishOnce.Do(func() {
+		close(this_.finishChan)
+	})
+}
+
+func (this_ *shareClient) isFinished() bool {
+	select {
+	case <-this_.finishChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// close 立即关闭连接，不再写入缓存的输出
+func (this_ *shareClient) close() {
+	this_.finish()
+	_ = this_.ws.Close()
+}
+
+func (this_ *shareClient) write(bs []byte) (err error) {
+	_ = this_.ws.SetWriteDeadline(time.Now().Add(shareWriteTimeout))
+	err = this_.ws.WriteMessage(websocket.BinaryMessage, bs)
+	return
+}
+
+// startWrite 写入缓存的输出，写入失败或结束时关闭连接
+func (this_ *shareClient) startWrite() {
+	defer func() { _ = this_.ws.Close() }()
+
+	for {
+		select {
+		case bs := <-this_.sendChan:
+			if this_.write(bs) != nil {
+				return
+			}
+		case <-this_.finishChan:
+			for {
+				select {
+				case bs := <-this_.sendChan:
+					if this_.write(bs) != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (this_ *Worker) newShareEvent(eventType string, invite *ShareInvite) *ShareEvent {
+	return &ShareEvent{
+		Type:      eventType,
+		ShareId:   this_.shareId,
+		Place:     this_.place,
+		PlaceId:   this_.placeId,
+		WorkerId:  this_.workerId,
+		OwnerId:   this_.userId,
+		OwnerName: this_.userName,
+		UserId:    invite.UserId,
+		UserName:  invite.UserName,
+		Write:     invite.Write,
+	}
+}
+
+// callShareEvent 通知会话所有者和所有被邀请的用户，被移除的用户额外通知
+func (this_ *Worker) callShareEvent(event *ShareEvent, userIds ...int64) {
+	this_.wsLock.Lock()
+	userIds = append(userIds, this_.userId)
+	for userId := range this_.shareInvites {
+		if util.Int64IndexOf(userIds, userId) < 0 {
+			userIds = append(userIds, userId)
+		}
+	}
+	this_.wsLock.Unlock()
+
+	for _, userId := range userIds {
+		context.CallUserEvent(userId, context.NewListenEvent("terminal-share", event))
+	}
+}
+
+// shareInvite 邀请用户加入会话，已邀请时更新读写权限
+func (this_ *Worker) shareInvite(invite *ShareInvite) {
+	invite.InviteTime = util.GetNowMilli()
+
+	this_.wsLock.Lock()
+	this_.shareInvites[invite.UserId] = invite
+	for _, one := range this_.shareClients {
+		if one.invite.UserId == invite.UserId {
+			one.invite = invite
+		}
+	}
+	this_.wsLock.Unlock()
+
+	this_.callShareEvent(this_.newShareEvent("invite", invite))
+}
+
+// shareRevoke 取消邀请，断开该用户的共享连接
+func (this_ *Worker) shareRevoke(userId int64) {
+	this_.wsLock.Lock()
+	invite := this_.shareInvites[userId]
+	delete(this_.shareInvites, userId)
+	var clients []*shareClient
+	for _, one := range this_.shareClients {
+		if one.invite.UserId == userId {
+			one.close()
+		} else {
+			clients = append(clients, one)
+		}
+	}
+	this_.shareClients = clients
+	this_.wsLock.Unlock()
+
+	if invite != nil {
+		this_.callShareEvent(this_.newShareEvent("revoke", invite), userId)
+	}
+}
+
+func (this_ *Worker) getShareInvite(userId int64) (invite *ShareInvite) {
+	this_.wsLock.Lock()
+	defer this_.wsLock.Unlock()
+
+	invite = this_.shareInvites[userId]
+	return
+}
+
+// canWrite 会话所有者，或者有读写权限的被邀请用户
+func (this_ *Worker) canWrite(userId int64) bool {
+	this_.wsLock.Lock()
+	defer this_.wsLock.Unlock()
+
+	if this_.userId == userId {
+		return true
+	}
+	invite := this_.shareInvites[userId]
+	return invite != nil && invite.Write
+}
+
+func (this_ *Worker) getShareInfo() (info *ShareInfo) {
+	this_.wsLock.Lock()
+	defer this_.wsLock.Unlock()
+
+	info = &ShareInfo{
+		OwnerId:    this_.userId,
+		OwnerName:  this_.userName,
+		InviteList: []*ShareInvite{},
+		OnlineList: []*ShareInvite{},
+	}
+	for _, one := range this_.shareInvites {
+		info.InviteList = append(info.InviteList, one)
+	}
+	for _, one := range this_.shareClients {
+		info.OnlineList = append(info.OnlineList, one.invite)
+	}
+	return
+}
+
+// shareJoin 被邀请的用户连接到会话，回放回滚内容
+func (this_ *Worker) shareJoin(userId int64, ws *websocket.Conn) (err error) {
+	this_.wsLock.Lock()
+	invite := this_.shareInvites[userId]
+	if invite == nil {
+		this_.wsLock.Unlock()
+		err = errors.New("未被邀请加入会话[" + this_.shareId + "]")
+		return
+	}
+	if this_.isStopped {
+		this_.wsLock.Unlock()
+		err = errors.New("会话[" + this_.shareId + "]已关闭")
+		return
+	}
+	client := newShareClient(invite, ws)
+	if bs := this_.scrollback.Bytes(); len(bs) > 0 {
+		client.send(bs)
+	}
+	this_.shareClients = append(this_.shareClients, client)
+	this_.wsLock.Unlock()
+
+	this_.Logger.Info("terminal share join", zap.Any("key", this_.key), zap.Any("userId", userId))
+	this_.callShareEvent(this_.newShareEvent("join", invite))
+	go client.startWrite()
+	go this_.startReadShareWS(client)
+	return
+}
+
+func (this_ *Worker) shareLeave(client *shareClient) {
+	client.close()
+
+	this_.wsLock.Lock()
+	var find bool
+	var clients []*shareClient
+	for _, one := range this_.shareClients {
+		if one == client {
+			find = true
+		} else {
+			clients = append(clients, one)
+		}
+	}
+	this_.shareClients = clients
+	this_.wsLock.Unlock()
+
+	if find {
+		this_.Logger.Info("terminal share leave", zap.Any("key", this_.key), zap.Any("userId", client.invite.UserId))
+		this_.callShareEvent(this_.newShareEvent("leave", client.invite))
+	}
+}
+
+// writeShareWS 输出复制后放入共享连接的缓存，跟不上输出的连接直接断开，调用方需持有 wsLock
+func (this_ *Worker) writeShareWS(bs []byte) {
+	if len(this_.shareClients) == 0 {
+		return
+	}
+	bs = append([]byte{}, bs...)
+	for _, one := range this_.shareClients {
+		if one.isFinished() {
+			continue
+		}
+		if !one.send(bs) {
+			this_.Logger.Warn("terminal share client too slow, disconnect", zap.Any("key", this_.key), zap.Any("userId", one.invite.UserId))
+			one.close()
+		}
+	}
+}
+
+// closeShareWS 写完缓存的输出后关闭所有共享的连接，调用方需持有 wsLock
+func (this_ *Worker) closeShareWS() {
+	for _, one := range this_.shareClients {
+		one.send([]byte("\r\n会话已关闭\r\n"))
+		one.finish()
+	}
+}
+
+// startReadShareWS 读取共享连接的输入，只读的连接忽略输入
+func (this_ *Worker) startReadShareWS(client *shareClient) {
+	defer func() {
+		if e := recover(); e != nil {
+			err := errors.New(fmt.Sprint(e))
+			this_.Logger.Error("startReadShareWS panic error", zap.Error(err))
+		}
+	}()
+	defer func() { this_.shareLeave(client) }()
+
+	for {
+		_, buf, err := client.ws.ReadMessage()
+		if err != nil {
+			if err != io.EOF {
+				this_.Logger.Debug("share ws read error", zap.Error(err))
+			}
+			return
+		}
+		this_.wsLock.Lock()
+		write := client.invite.Write
+		this_.wsLock.Unlock()
+		if !write {
+			continue
+		}
+		err = this_.writeInput(buf, false)
+		if err != nil {
+			this_.Logger.Error("service write error", zap.Error(err))
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package module_terminal
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"teamide/internal/module/module_node"
+	"teamide/internal/module/module_toolbox"
+	"teamide/pkg/ssh"
+	"teamide/pkg/telnet"
+	"teamide/pkg/terminal"
+	"time"
+)
+
+// createTelnetService 配置了节点时由节点发起连接，否则直接连接或经由 SSH 隧道连接
+// 返回的 sshConfig 为 SSH 隧道配置，用于键盘交互认证
+func (this_ *WorkerFactory) createTelnetService(placeId string) (service terminal.Service, sshConfig *ssh.Config, err error) {
+	if placeId == "" {
+		err = errors.New("Telnet配置不能为空")
+		return
+	}
+	id, err := strconv.ParseInt(placeId, 10, 64)
+	if err != nil {
+		return
+	}
+	var tD *module_toolbox.ToolboxModel
+	tD, err = this_.toolboxService.Get(id)
+	if err != nil {
+		return
+	}
+	if tD == nil || tD.Option == "" {
+		err = errors.New("Telnet[" + placeId + "]配置不存在")
+		return
+	}
+	config := &telnet.Config{}
+	sshConfig, err = this_.toolboxService.BindConfigByOption(tD.Option, config)
+	if err != nil {
+		return
+	}
+	if config.Address == "" {
+		err = errors.New("Telnet[" + placeId + "]连接地址不能为空")
+		return
+	}
+	if config.NodeId != "" {
+		sshConfig = nil
+		service = module_node.NewTelnetTerminalService(config.NodeId, config, this_.nodeService)
+		return
+	}
+	var dial telnet.DialFunc
+	if sshConfig != nil {
+		tunnelConfig := sshConfig
+		dial = func(network string, address string, timeout time.Duration) (conn net.Conn, err error) {
+			return ssh.Dial(tunnelConfig, network, address)
+		}
+	}
+	service = telnet.NewTerminalService(config, dial)
+	return
+}
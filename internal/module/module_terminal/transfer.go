@@ -0,0 +1,439 @@
+package module_terminal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"teamide/internal/context"
+	"teamide/pkg/zmodem"
+	"time"
+)
+
+const (
+	transferProtocolZmodem = "zmodem"
+	transferProtocolTrzsz  = "trzsz"
+
+	transferTypeUpload   = "upload"
+	transferTypeDownload = "download"
+
+	// transferReadTimeout 等待远程输出的超时时间
+	transferReadTimeout = 30 * time.Second
+	// transferWaitTimeout 远程执行 rz 后等待用户选择上传文件的超时时间
+	transferWaitTimeout = 60 * time.Second
+	// transferProgressInterval 传输进度事件的最小间隔，单位毫秒
+	transferProgressInterval = 500
+)
+
+// TransferEvent 文件传输事件，通过 terminal-transfer 事件通知会话所有者
+// Status 为 wait（等待选择上传文件）、progress、success、error、cancel，Dir 为上传目录在会话目录下的相对路径
+type TransferEvent struct {
+	Key         string `json:"key"`
+	TransferId  string `json:"transferId"`
+	Protocol    string `json:"protocol"`
+	Type        string `json:"type"`
+	Status      string `json:"status"`
+	Dir         string `json:"dir,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	Transferred int64  `json:"transferred,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// TransferFile 已下载的文件
+type TransferFile struct {
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+}
+
+type transfer struct {
+	transferId   string
+	protocol     string
+	transferType string
+	isDir        bool
+	conn         *zmodem.Conn
+	// files 用户选择的上传文件名称，文件已通过文件管理器上传到上传目录
+	files      chan []string
+	cancel     chan struct{}
+	cancelOnce sync.Once
+	// fileName 当前传输的文件，下载时为保存的文件名称
+	fileName     string
+	lastProgress int64
+}
+
+func (this_ *Worker) getTransfer() *transfer {
+	this_.transferLock.Lock()
+	defer this_.transferLock.Unlock()
+
+	return this_.transfer
+}
+
+func (this_ *Worker) getTransferDir(transferType string) (dir string, err error) {
+	if this_.dir == "" {
+		err = errors.New("会话目录不存在")
+		return
+	}
+	dir = this_.dir + "transfer/" + transferType + "/"
+	ex, err := util.PathExists(dir)
+	if err != nil {
+		return
+	}
+	if !ex {
+		err = os.MkdirAll(dir, fs.ModePerm)
+	}
+	return
+}
+
+// removeTransferDir 会话关闭时删除上传、下载的文件
+func (this_ *Worker) removeTransferDir() {
+	if this_.dir == "" {
+		return
+	}
+	if err := os.RemoveAll(this_.dir + "transfer/"); err != nil {
+		this_.Logger.Error("remove transfer dir error", zap.Any("dir", this_.dir), zap.Error(err))
+	}
+}
+
+// onServiceOutput 服务输出，进行中的文件传输由传输会话处理，其它输出写入日志和 ws
+func (this_ *Worker) onServiceOutput(bs []byte) {
+	bs = this_.transferOutput(bs)
+	if len(bs) == 0 {
+		return
+	}
+	this_.commandLine.Output(bs)
+	this_.onServiceRead(bs)
+	this_.writeWS(this_.applyTrigger(bs))
+}
+
+// transferOutput 返回需要按普通输出处理的部分，发现 rz、sz、trz、tsz 的标识时开始传输
+func (this_ *Worker) transferOutput(bs []byte) (out []byte) {
+	this_.transferLock.Lock()
+	defer this_.transferLock.Unlock()
+
+	if t := this_.transfer; t != nil {
+		if t.conn.Feed(bs) {
+			return
+		}
+		// 传输已结束，剩余的输出按普通输出处理
+		out = append(t.conn.Remaining(), bs...)
+		this_.transfer = nil
+		return
+	}
+	if this_.dir == "" {
+		return bs
+	}
+
+	protocol := transferProtocolZmodem
+	index, mode := zmodem.Detect(bs)
+	var isDir bool
+	if index < 0 {
+		protocol = transferProtocolTrzsz
+		index, mode, isDir = zmodem.DetectTrzsz(bs)
+	}
+	if index < 0 {
+		return bs
+	}
+	t := &transfer{
+		transferId: util.GetUUID(),
+		protocol:   protocol,
+		isDir:      isDir,
+		files:      make(chan []string, 1),
+		cancel:     make(chan struct{}),
+	}
+	if mode == zmodem.ModeDownload {
+		t.transferType = transferTypeDownload
+	} else {
+		t.transferType = transferTypeUpload
+	}
+	t.conn = zmodem.NewConn(func(data []byte) (err error) {
+		_, err = this_.service.Write(data)
+		return
+	}, transferReadTimeout)
+	t.conn.Feed(bs[index:])
+	this_.transfer = t
+	go this_.runTransfer(t)
+
+	return bs[:index]
+}
+
+// endTransfer 传输结束，未处理的输出按普通输出处理
+func (this_ *Worker) endTransfer(t *transfer) {
+	this_.transferLock.Lock()
+	defer this_.transferLock.Unlock()
+
+	if this_.transfer != t {
+		return
+	}
+	this_.transfer = nil
+	remaining := t.conn.Remaining()
+	if len(remaining) > 0 {
+		this_.onServiceRead(remaining)
+		this_.writeWS(remaining)
+	}
+}
+
+// cancelTransfer 通知远程取消传输，并结束等待中的读取
+func (this_ *Worker) cancelTransfer(t *transfer) {
+	t.cancelOnce.Do(func() {
+		close(t.cancel)
+		if t.protocol == transferProtocolTrzsz {
+			zmodem.TrzszAbort(t.conn)
+		} else {
+			zmodem.Abort(t.conn)
+		}
+		t.conn.Close()
+	})
+}
+
+func (this_ *Worker) runTransfer(t *transfer) {
+	defer func() {
+		if e := recover(); e != nil {
+			this_.Logger.Error("runTransfer panic error", zap.Any("error", e))
+		}
+		t.conn.Close()
+		this_.endTransfer(t)
+	}()
+
+	label := "上传文件"
+	if t.transferType == transferTypeDownload {
+		label = "下载文件"
+	}
+	this_.recordMarker("开始" + label)
+	this_.writeCommandLog(fmt.Sprintf("\n开始%s:%s\n", label, util.TimeFormat(time.Now(), "2006-01-02 15:04:05.000")))
+
+	var err error
+	if t.transferType == transferTypeDownload {
+		err = this_.transferDownload(t)
+	} else {
+		err = this_.transferUpload(t)
+	}
+
+	event := &TransferEvent{Status: "success"}
+	text := label + "完成"
+	select {
+	case <-t.cancel:
+		err = zmodem.ErrCancelled
+	default:
+	}
+	if err == zmodem.ErrCancelled {
+		event.Status = "cancel"
+		text = label + "已取消"
+	} else if err != nil {
+		event.Status = "error"
+		event.Error = err.Error()
+		text = label + "失败:" + err.Error()
+		this_.Logger.Warn("terminal transfer error", zap.Any("key", this_.key), zap.Any("protocol", t.protocol), zap.Error(err))
+	}
+	this_.callTransferEvent(t, event)
+	this_.writeTransferText(t, "\r\n"+text+"\r\n")
+
+	this_.recordMarker("结束" + label)
+	this_.writeCommandLog(fmt.Sprintf("\n结束%s:%s\n", label, util.TimeFormat(time.Now(), "2006-01-02 15:04:05.000")))
+}
+
+// transferUpload 远程执行 rz、trz，等待用户通过文件管理器上传文件后发送给远程
+func (this_ *Worker) transferUpload(t *transfer) (err error) {
+	abort := func() {
+		if t.protocol == transferProtocolTrzsz {
+			_ = zmodem.TrzszCancel(t.conn)
+		} else {
+			zmodem.Abort(t.conn)
+		}
+	}
+	if t.isDir {
+		abort()
+		err = errors.New("不支持上传目录")
+		return
+	}
+	dir, err := this_.getTransferDir(transferTypeUpload)
+	if err != nil {
+		abort()
+		return
+	}
+	this_.callTransferEvent(t, &TransferEvent{Status: "wait", Dir: "transfer/" + transferTypeUpload + "/"})
+	this_.writeTransferText(t, "\r\n等待选择上传的文件，按 Ctrl+C 取消\r\n")
+
+	var names []string
+	timer := time.NewTimer(transferWaitTimeout)
+	defer timer.Stop()
+	select {
+	case names = <-t.files:
+	case <-t.cancel:
+		err = zmodem.ErrCancelled
+		return
+	case <-timer.C:
+		abort()
+		err = errors.New("等待选择上传文件超时")
+		return
+	}
+
+	var files []*zmodem.SendFile
+	defer func() {
+		for _, name := range names {
+			_ = os.Remove(dir + name)
+		}
+	}()
+	for _, name := range names {
+		var f *os.File
+		f, err = os.Open(dir + name)
+		if err != nil {
+			abort()
+			return
+		}
+		defer func() { _ = f.Close() }()
+		var stat os.FileInfo
+		stat, err = f.Stat()
+		if err != nil {
+			abort()
+			return
+		}
+		files = append(files, &zmodem.SendFile{
+			FileInfo: zmodem.FileInfo{Name: name, Size: stat.Size(), ModTime: stat.ModTime()},
+			Reader:   f,
+		})
+	}
+	onProgress := func(progress *zmodem.Progress) {
+		t.fileName = progress.Name
+		this_.onTransferProgress(t, progress)
+	}
+	if t.protocol == transferProtocolTrzsz {
+		err = zmodem.TrzszSend(t.conn, files, onProgress)
+	} else {
+		err = zmodem.Send(t.conn, files, onProgress)
+	}
+	return
+}
+
+// transferDownload 远程执行 sz、tsz，文件保存到会话的下载目录，可通过 transfer/download 下载
+func (this_ *Worker) transferDownload(t *transfer) (err error) {
+	dir, err := this_.getTransferDir(transferTypeDownload)
+	if err != nil {
+		if t.protocol == transferProtocolTrzsz {
+			_ = zmodem.TrzszCancel(t.conn)
+		} else {
+			zmodem.Abort(t.conn)
+		}
+		return
+	}
+	onFile := func(info *zmodem.FileInfo) (writer io.WriteCloser, err error) {
+		t.fileName = getTransferFileName(dir, info.Name)
+		writer, err = os.Create(dir + t.fileName)
+		return
+	}
+	onProgress := func(progress *zmodem.Progress) {
+		this_.onTransferProgress(t, progress)
+	}
+	if t.protocol == transferProtocolTrzsz {
+		err = zmodem.TrzszReceive(t.conn, onFile, onProgress)
+	} else {
+		err = zmodem.Receive(t.conn, onFile, onProgress)
+	}
+	return
+}
+
+// getTransferFileName 去除远程文件名中的路径，已存在时追加序号
+func getTransferFileName(dir string, name string) (fileName string) {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "" || name == "." || name == ".." || name == "/" {
+		name = "file"
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	fileName = name
+	for i := 1; ; i++ {
+		if ex, _ := util.PathExists(dir + fileName); !ex {
+			return
+		}
+		fileName = fmt.Sprintf("%s(%d)%s", base, i, ext)
+	}
+}
+
+func (this_ *Worker) onTransferProgress(t *transfer, progress *zmodem.Progress) {
+	nowTime := util.GetNowMilli()
+	if !progress.Done && nowTime-t.lastProgress < transferProgressInterval {
+		return
+	}
+	t.lastProgress = nowTime
+	this_.callTransferEvent(t, &TransferEvent{
+		Status:      "progress",
+		FileName:    t.fileName,
+		Size:        progress.Size,
+		Transferred: progress.Transferred,
+	})
+	var percent float64 = 100
+	if progress.Size > 0 {
+		percent = float64(progress.Transferred) * 100 / float64(progress.Size)
+	}
+	text := fmt.Sprintf("\r\u001B[K%s %.1f%% (%s/%s)", t.fileName, percent, formatTransferSize(progress.Transferred), formatTransferSize(progress.Size))
+	if progress.Done {
+		text += "\r\n"
+	}
+	this_.writeTransferText(t, text)
+}
+
+func formatTransferSize(size int64) string {
+	switch {
+	case size >= 1024*1024*1024:
+		return fmt.Sprintf("%.2fGB", float64(size)/1024/1024/1024)
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.2fMB", float64(size)/1024/1024)
+	case size >= 1024:
+		return fmt.Sprintf("%.2fKB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// writeTransferText 传输提示写入 ws，不支持 ZMODEM 的客户端也可以看到传输状态
+func (this_ *Worker) writeTransferText(t *transfer, text string) {
+	this_.writeWS([]byte("[" + t.protocol + "] " + text))
+}
+
+func (this_ *Worker) callTransferEvent(t *transfer, event *TransferEvent) {
+	event.Key = this_.key
+	event.TransferId = t.transferId
+	event.Protocol = t.protocol
+	event.Type = t.transferType
+	if event.FileName == "" {
+		event.FileName = t.fileName
+	}
+	context.CallUserEvent(this_.userId, context.NewListenEvent("terminal-transfer", event))
+}
+
+// transferUploadFiles 用户选择的上传文件，文件需已上传到会话的上传目录
+func (this_ *Worker) transferUploadFiles(transferId string, names []string) (err error) {
+	t := this_.getTransfer()
+	if t == nil || t.transferId != transferId || t.transferType != transferTypeUpload {
+		err = errors.New("传输[" + transferId + "]不存在或已结束")
+		return
+	}
+	if len(names) == 0 {
+		err = errors.New("上传文件不能为空")
+		return
+	}
+	if err = checkPathName(names...); err != nil {
+		return
+	}
+	dir, err := this_.getTransferDir(transferTypeUpload)
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		if ex, _ := util.PathExists(dir + name); !ex {
+			err = errors.New("文件[" + name + "]未上传")
+			return
+		}
+	}
+	select {
+	case t.files <- names:
+	default:
+		err = errors.New("传输[" + transferId + "]已选择文件")
+	}
+	return
+}
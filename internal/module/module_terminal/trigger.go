@@ -0,0 +1,250 @@
+package module_terminal
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"strings"
+	"teamide/internal/context"
+	"teamide/internal/module/module_id"
+	"teamide/pkg/terminal"
+	"time"
+)
+
+// TriggerEvent 触发器命中后推送给会话用户的通知
+type TriggerEvent struct {
+	Key        string `json:"key"`
+	Place      string `json:"place"`
+	PlaceId    string `json:"placeId"`
+	WorkerId   string `json:"workerId"`
+	Name       string `json:"name"`
+	Line       string `json:"line"`
+	Text       string `json:"text,omitempty"`
+	Suppressed int    `json:"suppressed,omitempty"`
+	Time       int64  `json:"time"`
+}
+
+func (this_ *TerminalTriggerModel) toOutputTrigger() *terminal.OutputTrigger {
+	return &terminal.OutputTrigger{
+		Name:      this_.Name,
+		Pattern:   this_.Pattern,
+		Highlight: this_.Highlight,
+		Notify:    this_.Notify == 1,
+		SendText:  this_.SendText,
+		Cooldown:  time.Duration(this_.Cooldown) * time.Second,
+	}
+}
+
+// match Place、PlaceId 为空时匹配所有终端
+func (this_ *TerminalTriggerModel) match(place string, placeId string) bool {
+	if this_.Place != "" && this_.Place != place {
+		return false
+	}
+	if this_.PlaceId != "" && this_.PlaceId != placeId {
+		return false
+	}
+	return true
+}
+
+// SaveTrigger 新增或更新输出触发器，保存前校验规则
+func (this_ *TerminalCommandService) SaveTrigger(trigger *TerminalTriggerModel) (err error) {
+	if strings.TrimSpace(trigger.Name) == "" {
+		err = errors.New("触发器名称不能为空")
+		return
+	}
+	if trigger.Cooldown < 0 {
+		err = errors.New("触发器动作间隔不能小于 0")
+		return
+	}
+	if _, err = terminal.NewTriggerEngine(trigger.toOutputTrigger()); err != nil {
+		return
+	}
+	trigger.UpdateTime = time.Now()
+
+	if trigger.TriggerId > 0 {
+		sql := `UPDATE ` + TableTerminalTrigger + ` SET name=?,place=?,placeId=?,pattern=?,highlight=?,notify=?,sendText=?,cooldown=?,enable=?,comment=?,updateTime=? WHERE triggerId=? `
+
+		_, err = this_.DatabaseWorker.Exec(sql, []interface{}{
+			trigger.Name,
+			trigger.Place,
+			trigger.PlaceId,
+			trigger.Pattern,
+			trigger.Highlight,
+			trigger.Notify,
+			trigger.SendText,
+			trigger.Cooldown,
+			trigger.Enable,
+			trigger.Comment,
+			trigger.UpdateTime,
+			trigger.TriggerId,
+		})
+		if err != nil {
+			return
+		}
+		return
+	}
+	trigger.TriggerId, err = this_.idService.GetNextID(module_id.IDTypeTerminalTrigger)
+	if err != nil {
+		return
+	}
+	if trigger.CreateTime.IsZero() {
+		trigger.CreateTime = time.Now()
+	}
+
+	sql := `INSERT INTO ` + TableTerminalTrigger +
+		`(triggerId, name, place, placeId, pattern, highlight, notify, sendText, cooldown, enable, comment, userId, createTime, updateTime)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) `
+
+	_, err = this_.DatabaseWorker.Exec(sql, []interface{}{
+		trigger.TriggerId,
+		trigger.Name,
+		trigger.Place,
+		trigger.PlaceId,
+		trigger.Pattern,
+		trigger.Highlight,
+		trigger.Notify,
+		trigger.SendText,
+		trigger.Cooldown,
+		trigger.Enable,
+		trigger.Comment,
+		trigger.UserId,
+		trigger.CreateTime,
+		trigger.UpdateTime,
+	})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// QueryTrigger 查询用户的输出触发器
+func (this_ *TerminalCommandService) QueryTrigger(userId int64) (list []*TerminalTriggerModel, err error) {
+
+	var sqlInfo = "SELECT * FROM " + TableTerminalTrigger + " WHERE userId=? ORDER BY createTime ASC "
+
+	err = this_.DatabaseWorker.Query(sqlInfo, []interface{}{userId}, &list)
+	if err != nil {
+		return
+	}
+	return
+}
+
+// GetTrigger 查询单个输出触发器
+func (this_ *TerminalCommandService) GetTrigger(triggerId int64) (res *TerminalTriggerModel, err error) {
+
+	var sqlInfo = "SELECT * FROM " + TableTerminalTrigger + " WHERE triggerId=? "
+	var list []*TerminalTriggerModel
+
+	err = this_.DatabaseWorker.Query(sqlInfo, []interface{}{triggerId}, &list)
+	if err != nil {
+		return
+	}
+	if len(list) > 0 {
+		res = list[0]
+	}
+	return
+}
+
+// DeleteTrigger 删除输出触发器
+func (this_ *TerminalCommandService) DeleteTrigger(triggerId int64) (err error) {
+
+	var sqlInfo = "DELETE FROM " + TableTerminalTrigger + " WHERE triggerId=? "
+
+	_, err = this_.DatabaseWorker.Exec(sqlInfo, []interface{}{triggerId})
+	if err != nil {
+		return
+	}
+	return
+}
+
+// GetTriggerEngine 获取用户在终端位置上启用的输出触发器，没有触发器时返回 nil
+func (this_ *TerminalCommandService) GetTriggerEngine(userId int64, place string, placeId string) (engine *terminal.TriggerEngine, err error) {
+	list, err := this_.QueryTrigger(userId)
+	if err != nil || len(list) == 0 {
+		return
+	}
+	var triggers []*terminal.OutputTrigger
+	for _, one := range list {
+		if one.Enable == 1 && one.match(place, placeId) {
+			triggers = append(triggers, one.toOutputTrigger())
+		}
+	}
+	engine, err = terminal.NewTriggerEngine(triggers...)
+	return
+}
+
+// reloadTrigger 用户的输出触发器变更后重新加载该用户所有终端的触发器
+func (this_ *WorkerFactory) reloadTrigger(userId int64) {
+	this_.workerCacheLock.Lock()
+	var workers []*Worker
+	for _, worker := range this_.workerCache {
+		if worker.userId == userId {
+			workers = append(workers, worker)
+		}
+	}
+	this_.workerCacheLock.Unlock()
+
+	for _, worker := range workers {
+		engine, err := this_.terminalCommandService.GetTriggerEngine(worker.userId, worker.place, worker.placeId)
+		if err != nil {
+			this_.Logger.Error("reload trigger error", zap.Any("key", worker.key), zap.Error(err))
+			continue
+		}
+		worker.triggerLock.Lock()
+		worker.triggerEngine = engine
+		worker.triggerLock.Unlock()
+	}
+}
+
+// applyTrigger 对输出执行触发器，返回高亮后发送到 ws 的内容
+func (this_ *Worker) applyTrigger(bs []byte) (out []byte) {
+	this_.triggerLock.Lock()
+	engine := this_.triggerEngine
+	this_.triggerLock.Unlock()
+	if engine == nil {
+		return bs
+	}
+	now := time.Now()
+	out, matches := engine.Feed(bs, now)
+	for _, match := range matches {
+		if match.Notify {
+			context.CallUserEvent(this_.userId, context.NewListenEvent("terminal-trigger", &TriggerEvent{
+				Key:        this_.key,
+				Place:      this_.place,
+				PlaceId:    this_.placeId,
+				WorkerId:   this_.workerId,
+				Name:       match.Name,
+				Line:       match.Line,
+				Text:       match.Text,
+				Suppressed: match.Suppressed,
+				Time:       now.UnixMilli(),
+			}))
+		}
+		if match.SendText != "" {
+			// 在读取输出的协程中写入可能阻塞读取，另起协程发送
+			go this_.sendTriggerText(match.Name, match.SendText)
+		}
+	}
+	return
+}
+
+// sendTriggerText 触发器发送的内容同样需要经过命令策略检查，无法确认的命令直接拒绝
+func (this_ *Worker) sendTriggerText(name string, text string) {
+	defer func() {
+		if e := recover(); e != nil {
+			this_.Logger.Error("trigger send text panic", zap.Any("key", this_.key), zap.Any("error", e))
+		}
+	}()
+	if this_.isStopped || this_.getTransfer() != nil {
+		return
+	}
+	buf := this_.checkInput([]byte(text), false)
+	if len(buf) == 0 {
+		return
+	}
+	if _, err := this_.service.Write(buf); err != nil {
+		this_.Logger.Error("trigger send text error", zap.Any("key", this_.key), zap.Any("trigger", name), zap.Error(err))
+		return
+	}
+	this_.recordMarker(fmt.Sprint("触发器[", name, "]发送内容"))
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
-	goSSH "golang.org/x/crypto/ssh"
 	"io"
 	"io/fs"
 	"os"
@@ -31,6 +30,10 @@ func NewWorkerFactory(toolboxService_ *module_toolbox.ToolboxService, nodeServic
 		toolboxService: toolboxService_,
 		nodeService:    nodeService_,
 		workerCache:    make(map[string]*Worker),
+		workerStarting: make(map[string]bool),
+		broadcast: &broadcastCache{
+			groups: make(map[string]*BroadcastGroup),
+		},
 	}
 }
 
@@ -40,6 +43,13 @@ type WorkerFactory struct {
 	nodeService     *module_node.NodeService
 	workerCache     map[string]*Worker
 	workerCacheLock sync.Mutex
+	// workerStarting 正在启动的会话，启动过程（如等待用户输入 OTP）不持有 workerCacheLock
+	workerStarting map[string]bool
+	broadcast      *broadcastCache
+	// commandIndexLock 保护日志索引文件的读写
+	commandIndexLock sync.Mutex
+
+	terminalCommandService *TerminalCommandService
 }
 
 func (this_ *WorkerFactory) GetService(key string) (res *Worker) {
@@ -50,7 +60,23 @@ func (this_ *WorkerFactory) GetService(key string) (res *Worker) {
 	return
 }
 
+// getShareService 被邀请的用户通过 shareId 获取共享的会话
+func (this_ *WorkerFactory) getShareService(shareId string) (res *Worker) {
+	this_.workerCacheLock.Lock()
+	defer this_.workerCacheLock.Unlock()
+
+	for _, one := range this_.workerCache {
+		if one.shareId == shareId {
+			res = one
+			return
+		}
+	}
+	return
+}
+
 type CreateParam struct {
+	userId   int64
+	userName string
 	place    string
 	placeId  string
 	workerId string
@@ -67,6 +93,7 @@ func (this_ *WorkerFactory) createService(param *CreateParam) (worker *Worker, c
 		}
 	}()
 	var service terminal.Service
+	var terminalSSHConfig *ssh.Config
 	switch param.place {
 	case "local":
 		service = terminal.NewLocalService()
@@ -96,19 +123,13 @@ func (this_ *WorkerFactory) createService(param *CreateParam) (worker *Worker, c
 		if err != nil {
 			return
 		}
-		if sshConfig != nil {
-			var sshClient *goSSH.Client
-			sshClient, err = ssh.NewClient(*sshConfig)
-			if err != nil {
-				util.Logger.Error("getSSHService ssh NewClient error", zap.Any("address", sshConfig.Address), zap.Error(err))
-				return
-			}
-			config.SSHClient = sshClient
-		}
+		// 经由跳板链连接，跳板随 SSH 连接一起关闭
+		config.JumpConfig = sshConfig
 		if config != nil {
 			command = config.Command
 		}
 
+		terminalSSHConfig = config
 		service = ssh.NewTerminalService(config, param.lastUser, param.lastDir)
 	case "node":
 		if param.placeId == "" {
@@ -116,6 +137,11 @@ func (this_ *WorkerFactory) createService(param *CreateParam) (worker *Worker, c
 			return
 		}
 		service = module_node.NewTerminalService(param.placeId, this_.nodeService)
+	case "telnet":
+		service, terminalSSHConfig, err = this_.createTelnetService(param.placeId)
+		if err != nil {
+			return
+		}
 	}
 	if service == nil {
 		err = errors.New("[" + param.place + "]终端服务不存在")
@@ -123,18 +149,53 @@ func (this_ *WorkerFactory) createService(param *CreateParam) (worker *Worker, c
 	}
 
 	worker = &Worker{
+		userId:        param.userId,
+		userName:      param.userName,
+		shareId:       util.GetUUID(),
+		shareInvites:  make(map[int64]*ShareInvite),
+		scrollback:    terminal.NewRingBuffer(scrollbackSize),
 		place:         param.place,
 		placeId:       param.placeId,
 		workerId:      param.workerId,
 		service:       service,
 		WorkerFactory: this_,
+		commandLine:   &terminal.CommandLine{},
+	}
+	worker.commandChecker, err = this_.terminalCommandService.GetCommandChecker(param.userId, param.place, param.placeId)
+	if err != nil {
+		return
+	}
+	worker.triggerEngine, err = this_.terminalCommandService.GetTriggerEngine(param.userId, param.place, param.placeId)
+	if err != nil {
+		return
 	}
 	worker.init()
+	// 键盘交互认证（如跳板机 OTP）的问题发送到终端，由用户在终端中输入
+	for one := terminalSSHConfig; one != nil; one = one.JumpConfig {
+		one.KeyboardInteractive = worker.keyboardInteractive(one.Username + "@" + one.Address)
+	}
 	return
 }
 
 func (this_ *WorkerFactory) Start(key string, param *CreateParam, size *terminal.Size, ws *websocket.Conn) (err error) {
-
+	var worker *Worker
+	var isWindow, reserved bool
+	// 启动结束后再放入缓存，需在 recover 之后执行以获取 panic 的错误
+	defer func() {
+		if !reserved {
+			return
+		}
+		this_.workerCacheLock.Lock()
+		delete(this_.workerStarting, key)
+		if err == nil && worker != nil {
+			this_.workerCache[key] = worker
+		}
+		this_.workerCacheLock.Unlock()
+		if err == nil && worker != nil {
+			go worker.startReadWS(ws)
+			go worker.startReadService(isWindow)
+		}
+	}()
 	defer func() {
 		if e := recover(); e != nil {
 			err = errors.New(fmt.Sprint(e))
@@ -142,29 +203,36 @@ func (this_ *WorkerFactory) Start(key string, param *CreateParam, size *terminal
 		}
 	}()
 
+	// 只在检查和占用 key 时加锁，SSH 握手、键盘交互认证可能长时间等待，不能阻塞其它会话
 	this_.workerCacheLock.Lock()
-	defer this_.workerCacheLock.Unlock()
-
-	worker := this_.workerCache[key]
-	if worker != nil {
+	if this_.workerCache[key] != nil || this_.workerStarting[key] {
+		this_.workerCacheLock.Unlock()
 		err = errors.New("会话服务[" + key + "]已存在")
 		return
 	}
+	this_.workerStarting[key] = true
+	reserved = true
+	this_.workerCacheLock.Unlock()
+
 	var cmd string
 	worker, cmd, err = this_.createService(param)
 	if err != nil {
 		return
 	}
 	// 执行配置的命令
+	worker.key = key
 	worker.ws = ws
-	isWindow, err := worker.service.IsWindows()
+	isWindow, err = worker.service.IsWindows()
 	if err != nil {
+		worker.service.Stop()
 		return
 	}
 	err = worker.service.Start(size)
 	if err != nil {
+		worker.service.Stop()
 		return
 	}
+	worker.startRecord(size)
 	if cmd != "" {
 		go func() {
 			cmd = strings.ReplaceAll(cmd, "\n\r", "\n")
@@ -184,19 +252,14 @@ func (this_ *WorkerFactory) Start(key string, param *CreateParam, size *terminal
 					}
 					continue
 				}
-				_, err = worker.service.Write([]byte(c + "\n"))
-				if err != nil {
-					this_.Logger.Error("SSH start run line error", zap.Error(err))
+				_, e := worker.service.Write([]byte(c + "\n"))
+				if e != nil {
+					this_.Logger.Error("SSH start run line error", zap.Error(e))
 				}
 			}
 		}()
 
 	}
-
-	go worker.startReadWS(isWindow)
-	go worker.startReadService(isWindow)
-
-	this_.workerCache[key] = worker
 	return
 }
 
@@ -277,7 +340,9 @@ func (this_ *WorkerFactory) getLogPath(place string, placeId string, workerId st
 }
 
 type Worker struct {
-	key      string
+	key string
+	// shareId 共享给其它用户时使用的标识，key 只给会话所有者
+	shareId  string
 	place    string
 	placeId  string
 	workerId string
@@ -286,8 +351,34 @@ type Worker struct {
 	service        terminal.Service
 	ws             *websocket.Conn
 	commandLogFile *os.File
-	isRz           bool
-	isSz           bool
+	commandLogLock sync.Mutex
+	// commandLogSize command.log 的大小，lastTimeMark 最后一次写入时间标记的时间，由 commandLogLock 保护
+	commandLogSize int64
+	lastTimeMark   int64
+	recorder       *terminal.Recorder
+	userId         int64
+	userName       string
+	// shareInvites 共享会话邀请的用户，shareClients 被邀请用户的连接，由 wsLock 保护
+	shareInvites map[int64]*ShareInvite
+	shareClients []*shareClient
+	// scrollback 最近的输出，重新连接时回放
+	scrollback  *terminal.RingBuffer
+	wsLock      sync.Mutex
+	detachTime  int64
+	detachTimer *time.Timer
+	// transfer 进行中的 ZMODEM、trzsz 文件传输，由 transferLock 保护
+	transfer     *transfer
+	transferLock sync.Mutex
+
+	// commandLine 当前输入的命令行，commandChecker 生效的命令策略，policyConfirm 等待确认的命令，由 policyLock 保护
+	commandLine    *terminal.CommandLine
+	commandChecker *terminal.CommandChecker
+	policyConfirm  *policyConfirm
+	policyLock     sync.Mutex
+
+	// triggerEngine 生效的输出触发器，由 triggerLock 保护
+	triggerEngine *terminal.TriggerEngine
+	triggerLock   sync.Mutex
 
 	isStopped bool
 }
@@ -307,44 +398,10 @@ func (this_ *Worker) init() {
 		return
 	}
 	this_.dir = dir
+	this_.writeSessionMeta()
 	return
 }
 
-var (
-	// sshSZStart sz fmt.Sprintf("%+q", "rz\r**\x18B00000000000000\r\x8a\x11")
-	//sshSZStart = []byte{13, 42, 42, 24, 66, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 13, 138, 17}
-	sshSZStart = []byte{42, 42, 24, 66, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 48, 13, 138, 17}
-	// sshSZEnd sz 结束 fmt.Sprintf("%+q", "\r**\x18B0800000000022d\r\x8a")
-	//sshSZEnd = []byte{13, 42, 42, 24, 66, 48, 56, 48, 48, 48, 48, 48, 48, 48, 48, 48, 50, 50, 100, 13, 138}
-	sshSZEnd = []byte{42, 42, 24, 66, 48, 56, 48, 48, 48, 48, 48, 48, 48, 48, 48, 50, 50, 100, 13, 138}
-	// sshSZEndOO sz 结束后可能还会发送两个 OO，但是经过测试发现不一定每次都会发送 fmt.Sprintf("%+q", "OO")
-	sshSZEndOO = []byte{79, 79}
-
-	// rz fmt.Sprintf("%+q", "**\x18B0100000023be50\r\x8a\x11")
-	sshRZStart = []byte{42, 42, 24, 66, 48, 49, 48, 48, 48, 48, 48, 48, 50, 51, 98, 101, 53, 48, 13, 138, 17}
-	// rz -e fmt.Sprintf("%+q", "**\x18B0100000063f694\r\x8a\x11")
-	sshRZEStart = []byte{42, 42, 24, 66, 48, 49, 48, 48, 48, 48, 48, 48, 54, 51, 102, 54, 57, 52, 13, 138, 17}
-	// rz -S fmt.Sprintf("%+q", "**\x18B0100000223d832\r\x8a\x11")
-	sshRZSStart = []byte{42, 42, 24, 66, 48, 49, 48, 48, 48, 48, 48, 50, 50, 51, 100, 56, 51, 50, 13, 138, 17}
-	// rz -e -S fmt.Sprintf("%+q", "**\x18B010000026390f6\r\x8a\x11")
-	sshRZESStart = []byte{42, 42, 24, 66, 48, 49, 48, 48, 48, 48, 48, 50, 54, 51, 57, 48, 102, 54, 13, 138, 17}
-	// rz 结束 fmt.Sprintf("%+q", "**\x18B0800000000022d\r\x8a")
-	sshRZEnd = []byte{42, 42, 24, 66, 48, 56, 48, 48, 48, 48, 48, 48, 48, 48, 48, 50, 50, 100, 13, 138}
-
-	// **\x18B0
-	sshRZCtrlStart = []byte{42, 42, 24, 66, 48}
-	// \r\x8a\x11
-	sshRZCtrlEnd1 = []byte{13, 138, 17}
-	// \r\x8a
-	sshRZCtrlEnd2 = []byte{13, 138}
-
-	// zmodem 取消 \x18\x18\x18\x18\x18\x08\x08\x08\x08\x08
-	sshCancel = []byte{24, 24, 24, 24, 24, 8, 8, 8, 8, 8}
-
-	sshSZError1End = []byte{42, 42, 7, 48, 56, 48, 48, 48, 48, 48, 48, 48, 48, 48, 50, 50, 100, 13, 10}
-	sshSZError2End = []byte{241, 164, 13, 42, 42, 24, 66, 48, 56, 48, 48, 48, 48, 48, 48, 48, 48, 48, 50, 50}
-)
-
 func (this_ *Worker) onServiceRead(bs []byte) {
 	if this_.dir == "" {
 		return
@@ -355,32 +412,22 @@ func (this_ *Worker) onServiceRead(bs []byte) {
 			util.Logger.Error("onServiceRead error", zap.Any("err", e))
 		}
 	}()
-	//var l = len(bs)
-	//if l > 20 {
-	//	l = 20
-	//}
-	//fmt.Println("onServiceRead bytes:", bs[:l])
-	//fmt.Println("onServiceRead string:", string(bs[:l]))
-
-	if !this_.isRz && (bytes.Contains(bs, sshRZStart) || bytes.Contains(bs, sshRZSStart) || bytes.Contains(bs, sshRZEStart) || bytes.Contains(bs, sshRZESStart)) {
-		this_.isRz = true
-		bs = []byte(fmt.Sprintf("\n开始上传文件:%s\n", util.TimeFormat(time.Now(), "2006-01-02 15:04:05.000")))
-
-	} else if this_.isRz && bytes.Contains(bs, sshRZEnd) {
-		this_.isRz = false
-		bs = []byte(fmt.Sprintf("\n结束上传文件:%s\n", util.TimeFormat(time.Now(), "2006-01-02 15:04:05.000")))
-	} else if !this_.isSz && bytes.Contains(bs, sshSZStart) {
-		this_.isSz = true
-		bs = []byte(fmt.Sprintf("\n开始下载文件:%s\n", util.TimeFormat(time.Now(), "2006-01-02 15:04:05.000")))
-	} else if this_.isSz && (bytes.Contains(bs, sshSZEnd) || bytes.Equal(bs, sshSZError1End) || bytes.Equal(bs, sshSZError2End)) {
-		this_.isSz = false
-		bs = []byte(fmt.Sprintf("\n结束下载文件:%s\n", util.TimeFormat(time.Now(), "2006-01-02 15:04:05.000")))
-	} else {
-		if this_.isSz || this_.isRz {
-			return
-		}
+	// 录像保留原始输出（含配色、光标控制），文件传输内容只记录标记
+	if this_.recorder != nil {
+		this_.recorder.Output(bs)
 	}
 
+	this_.writeCommandLog(string(bs))
+}
+
+// writeCommandLog 去除配色等控制字符后写入 command.log
+func (this_ *Worker) writeCommandLog(str string) {
+	if this_.dir == "" {
+		return
+	}
+	this_.commandLogLock.Lock()
+	defer this_.commandLogLock.Unlock()
+
 	if this_.commandLogFile == nil {
 		ex, err := util.PathExists(this_.dir)
 		if err != nil {
@@ -395,11 +442,16 @@ func (this_ *Worker) onServiceRead(bs []byte) {
 		} else {
 			this_.commandLogFile, _ = os.Create(path)
 		}
+		if this_.commandLogFile != nil {
+			this_.commandLogSize = 0
+			if stat, e := this_.commandLogFile.Stat(); e == nil {
+				this_.commandLogSize = stat.Size()
+			}
+		}
 	}
 	if this_.commandLogFile == nil {
 		return
 	}
-	str := string(bs)
 	// 配色
 	re, err := regexp.Compile("\u001B\\[[0-9]+[;0-9]*m")
 	if err != nil {
@@ -424,6 +476,10 @@ func (this_ *Worker) onServiceRead(bs []byte) {
 	} else {
 		str = re.ReplaceAllString(str, "")
 	}
+	if str == "" {
+		return
+	}
+	this_.writeTimeMark(this_.commandLogSize)
 	writer := bufio.NewWriter(this_.commandLogFile)
 	_, err = writer.WriteString(str)
 
@@ -435,9 +491,37 @@ func (this_ *Worker) onServiceRead(bs []byte) {
 		return
 	}
 	_ = writer.Flush()
+	this_.commandLogSize += int64(len(str))
 }
 
-func (this_ *Worker) startReadWS(isWindow bool) {
+// writeInput 用户输入写入服务，broadcast 为 true 时同步到所在的广播组，被邀请用户的输入不同步
+func (this_ *Worker) writeInput(buf []byte, broadcast bool) (err error) {
+	// 文件传输中的输入不写入服务，Ctrl+C 取消传输
+	if t := this_.getTransfer(); t != nil {
+		if bytes.IndexByte(buf, 3) >= 0 {
+			this_.cancelTransfer(t)
+		}
+		return
+	}
+	// 命令策略拦截的命令不写入服务，也不同步到广播组
+	buf = this_.checkInput(buf, true)
+	if len(buf) == 0 {
+		return
+	}
+	_, err = this_.service.Write(buf)
+	if err != nil {
+		return
+	}
+	if this_.recorder != nil && this_.Setting.TerminalRecordInput {
+		this_.recorder.Input(buf)
+	}
+	if broadcast {
+		this_.broadcastInput(buf)
+	}
+	return
+}
+
+func (this_ *Worker) startReadWS(ws *websocket.Conn) {
 
 	defer func() {
 		if e := recover(); e != nil {
@@ -446,23 +530,24 @@ func (this_ *Worker) startReadWS(isWindow bool) {
 		}
 	}()
 
-	defer func() { this_.stopAll() }()
+	// ws 断开后不立即关闭服务，保留一段时间等待重新连接
+	defer func() { this_.detach(ws) }()
 	var buf []byte
 	var readErr error
 	var writeErr error
 
 	var isClosed bool
-	this_.ws.SetCloseHandler(func(code int, text string) error {
+	ws.SetCloseHandler(func(code int, text string) error {
 		isClosed = true
 		return nil
 	})
 	for !isClosed {
-		_, buf, readErr = this_.ws.ReadMessage()
+		_, buf, readErr = ws.ReadMessage()
 		if readErr != nil && readErr != io.EOF {
 			break
 		}
 		//this_.Logger.Info("ws on read", zap.Any("bs", string(buf)))
-		_, writeErr = this_.service.Write(buf)
+		writeErr = this_.writeInput(buf, true)
 
 		if writeErr != nil {
 			break
@@ -507,7 +592,6 @@ func (this_ *Worker) startReadService(isWindow bool) {
 	var n int
 	var buf = make([]byte, 1024*32)
 	var readErr error
-	var writeErr error
 	this_.onServiceRead([]byte(fmt.Sprintf("\n\n开始时间:%s\n\n", util.TimeFormat(time.Now(), "2006-01-02 15:04:05.000"))))
 	for {
 		n, readErr = this_.service.Read(buf)
@@ -522,11 +606,7 @@ func (this_ *Worker) startReadService(isWindow bool) {
 		//}
 
 		if n > 0 {
-			this_.onServiceRead(buf[:n])
-			writeErr = this_.ws.WriteMessage(websocket.BinaryMessage, buf[:n])
-			if writeErr != nil {
-				break
-			}
+			this_.onServiceOutput(buf[:n])
 		}
 		if readErr == io.EOF {
 			readErr = nil
@@ -542,10 +622,6 @@ func (this_ *Worker) startReadService(isWindow bool) {
 		this_.Logger.Error("service read error", zap.Error(readErr))
 	}
 
-	if writeErr != nil {
-		this_.Logger.Error("ws write error", zap.Error(writeErr))
-	}
-
 	this_.Logger.Info("service read is end")
 
 	return
@@ -568,6 +644,7 @@ func (this_ *WorkerFactory) stopService(key string) {
 		return
 	}
 	delete(this_.workerCache, key)
+	this_.removeBroadcastMember(key)
 	this_.Logger.Info("stop service", zap.Any("key", key))
 	find.service.Stop()
 }
@@ -582,18 +659,119 @@ func (this_ *Worker) stopAll() {
 
 	this_.Logger.Info("stopAll", zap.Any("key", this_.key))
 	this_.stopService(this_.key)
+	if t := this_.getTransfer(); t != nil {
+		t.conn.Close()
+	}
+	this_.removeTransferDir()
 	if this_ != nil {
 		this_.service.Stop()
 	}
+	this_.wsLock.Lock()
+	if this_.detachTimer != nil {
+		this_.detachTimer.Stop()
+		this_.detachTimer = nil
+	}
 	if this_.ws != nil {
 		_ = this_.ws.Close()
 	}
+	this_.closeShareWS()
+	this_.wsLock.Unlock()
 	if this_.commandLogFile != nil {
 		_ = this_.commandLogFile.Close()
 	}
+	if this_.recorder != nil {
+		this_.recorder.Close()
+	}
 }
 
 func (this_ *Worker) IsStopped() bool {
 
 	return this_.isStopped
 }
+
+// keyboardInteractive 键盘交互认证，在服务启动前调用，此时还未开始读取 ws，可直接读取用户输入
+func (this_ *Worker) keyboardInteractive(host string) func(name, instruction string, questions []string, echos []bool) (answers []string, err error) {
+	return func(name, instruction string, questions []string, echos []bool) (answers []string, err error) {
+		if this_.ws == nil {
+			err = errors.New("SSH[" + host + "]需要键盘交互认证，当前会话无法输入")
+			return
+		}
+		defer func() { _ = this_.ws.SetReadDeadline(time.Time{}) }()
+		_ = this_.ws.SetReadDeadline(time.Now().Add(keyboardInteractiveTimeout))
+
+		text := "\r\n[" + host + "]"
+		if name != "" {
+			text += " " + name
+		}
+		if instruction != "" {
+			text += "\r\n" + instruction
+		}
+		err = this_.wsWriteText(text + "\r\n")
+		if err != nil {
+			return
+		}
+		for i, question := range questions {
+			err = this_.wsWriteText(question)
+			if err != nil {
+				return
+			}
+			var answer string
+			answer, err = this_.wsReadLine(i < len(echos) && echos[i])
+			if err != nil {
+				this_.Logger.Error("keyboard interactive read error", zap.Any("host", host), zap.Error(err))
+				return
+			}
+			answers = append(answers, answer)
+		}
+		return
+	}
+}
+
+var (
+	// keyboardInteractiveTimeout 键盘交互认证等待用户输入的超时时间
+	keyboardInteractiveTimeout = 3 * time.Minute
+)
+
+func (this_ *Worker) wsWriteText(text string) (err error) {
+	err = this_.ws.WriteMessage(websocket.BinaryMessage, []byte(text))
+	return
+}
+
+// wsReadLine 读取用户在终端输入的一行，支持退格，Ctrl+C 取消
+func (this_ *Worker) wsReadLine(echo bool) (line string, err error) {
+	var buf []rune
+	for {
+		var bs []byte
+		_, bs, err = this_.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		for _, r := range string(bs) {
+			switch r {
+			case '\r', '\n':
+				line = string(buf)
+				err = this_.wsWriteText("\r\n")
+				return
+			case 0x7f, '\b':
+				if len(buf) > 0 {
+					buf = buf[:len(buf)-1]
+					if echo {
+						err = this_.wsWriteText("\b \b")
+					}
+				}
+			case 0x03:
+				_ = this_.wsWriteText("^C\r\n")
+				err = errors.New("键盘交互认证已取消")
+				return
+			default:
+				buf = append(buf, r)
+				if echo {
+					err = this_.wsWriteText(string(r))
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
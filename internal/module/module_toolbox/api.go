@@ -56,6 +56,11 @@ var (
 	PowerQuickCommandUpdate = base.AppendPower(&base.PowerAction{Action: "update", Text: "工具快速指令修改", Parent: PowerQuickCommand, ShouldLogin: true, StandAlone: true})
 	PowerQuickCommandDelete = base.AppendPower(&base.PowerAction{Action: "delete", Text: "工具快速指令删除", Parent: PowerQuickCommand, ShouldLogin: true, StandAlone: true})
 
+	knownHost       = base.AppendPower(&base.PowerAction{Action: "knownHost", Text: "SSH主机密钥", Parent: Power, ShouldLogin: true, StandAlone: true, ShouldPower: true})
+	knownHostList   = base.AppendPower(&base.PowerAction{Action: "list", Text: "SSH主机密钥列表", Parent: knownHost, ShouldLogin: true, StandAlone: true, ShouldPower: true})
+	knownHostAccept = base.AppendPower(&base.PowerAction{Action: "accept", Text: "SSH主机密钥接受", Parent: knownHost, ShouldLogin: true, StandAlone: true, ShouldPower: true})
+	knownHostRemove = base.AppendPower(&base.PowerAction{Action: "remove", Text: "SSH主机密钥删除", Parent: knownHost, ShouldLogin: true, StandAlone: true, ShouldPower: true})
+
 	extend         = base.AppendPower(&base.PowerAction{Action: "extend", Text: "扩展", Parent: Power, ShouldLogin: true, StandAlone: true})
 	extendGet      = base.AppendPower(&base.PowerAction{Action: "get", Text: "获取单个", Parent: extend, ShouldLogin: true, StandAlone: true})
 	extendQuery    = base.AppendPower(&base.PowerAction{Action: "query", Text: "查询", Parent: extend, ShouldLogin: true, StandAlone: true})
@@ -63,6 +68,9 @@ var (
 	extendDelete   = base.AppendPower(&base.PowerAction{Action: "delete", Text: "删除", Parent: extend, ShouldLogin: true, StandAlone: true})
 	extendLoadFile = base.AppendPower(&base.PowerAction{Action: "loadFile", Text: "加载文件", Parent: extend, ShouldLogin: true, StandAlone: true})
 	extendSaveFile = base.AppendPower(&base.PowerAction{Action: "saveFile", Text: "保存文件", Parent: extend, ShouldLogin: true, StandAlone: true})
+
+	importPower    = base.AppendPower(&base.PowerAction{Action: "import", Text: "导入", Parent: Power, ShouldLogin: true, StandAlone: true})
+	importSSHPower = base.AppendPower(&base.PowerAction{Action: "ssh", Text: "导入SSH主机", Parent: importPower, ShouldLogin: true, StandAlone: true})
 )
 
 func (this_ *ToolboxApi) GetApis() (apis []*base.ApiWorker) {
@@ -98,6 +106,10 @@ func (this_ *ToolboxApi) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: PowerQuickCommandUpdate, Do: this_.updateQuickCommand})
 	apis = append(apis, &base.ApiWorker{Power: PowerQuickCommandDelete, Do: this_.deleteQuickCommand})
 
+	apis = append(apis, &base.ApiWorker{Power: knownHostList, Do: this_.listKnownHost})
+	apis = append(apis, &base.ApiWorker{Power: knownHostAccept, Do: this_.acceptKnownHost})
+	apis = append(apis, &base.ApiWorker{Power: knownHostRemove, Do: this_.removeKnownHost})
+
 	apis = append(apis, &base.ApiWorker{Power: extendGet, Do: this_.extendGet})
 	apis = append(apis, &base.ApiWorker{Power: extendQuery, Do: this_.extendQuery})
 	apis = append(apis, &base.ApiWorker{Power: extendSave, Do: this_.extendSave})
@@ -105,6 +117,8 @@ func (this_ *ToolboxApi) GetApis() (apis []*base.ApiWorker) {
 	apis = append(apis, &base.ApiWorker{Power: extendLoadFile, Do: this_.extendLoadFile})
 	apis = append(apis, &base.ApiWorker{Power: extendSaveFile, Do: this_.extendSaveFile})
 
+	apis = append(apis, &base.ApiWorker{Power: importSSHPower, Do: this_.importSSH})
+
 	return
 }
 
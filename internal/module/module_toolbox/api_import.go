@@ -0,0 +1,317 @@
+package module_toolbox
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"teamide/pkg/base"
+	"teamide/pkg/sshimport"
+)
+
+const (
+	importStatusNew       = "new"
+	importStatusDuplicate = "duplicate"
+	importStatusError     = "error"
+	importStatusImported  = "imported"
+)
+
+type ImportSSHFile struct {
+	Name string `json:"name,omitempty"`
+	// Format 为空时根据文件名和内容识别：openssh、putty、xshell、mobaxterm
+	Format  string `json:"format,omitempty"`
+	Content string `json:"content,omitempty"`
+	// Base64 内容为 base64 编码的原始文件，用于 UTF-16 编码的注册表导出和 Xshell 文件
+	Base64 bool `json:"base64,omitempty"`
+}
+
+type ImportSSHRequest struct {
+	GroupId int64            `json:"groupId,omitempty"`
+	DryRun  bool             `json:"dryRun,omitempty"`
+	Files   []*ImportSSHFile `json:"files,omitempty"`
+}
+
+type ImportSSHItem struct {
+	*sshimport.Host
+	Address string `json:"address"`
+	File    string `json:"file,omitempty"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	// ToolboxId 已导入或重复的工具ID
+	ToolboxId int64 `json:"toolboxId,omitempty"`
+	// JumpToolboxNames 跳板机对应的工具名称，按连接顺序
+	JumpToolboxNames []string `json:"jumpToolboxNames,omitempty"`
+
+	jumpItems   []*ImportSSHItem
+	jumpIds     []int64
+	resolved    bool
+	resolving   bool
+	resolvedErr error
+}
+
+type ImportSSHResponse struct {
+	ItemList []*ImportSSHItem `json:"itemList"`
+}
+
+// sshImporter 一次导入的上下文，用于检测重复和解析跳板机
+type sshImporter struct {
+	*ToolboxApi
+	userId   int64
+	groupId  int64
+	items    []*ImportSSHItem
+	existing []*ToolboxModel
+}
+
+func (this_ *ToolboxApi) importSSH(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &ImportSSHRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &ImportSSHResponse{}
+
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	if request.GroupId != 0 {
+		var group *ToolboxGroupModel
+		group, err = this_.ToolboxService.GetGroup(request.GroupId)
+		if err != nil {
+			return
+		}
+		if group == nil || group.UserId != requestBean.JWT.UserId {
+			err = errors.New(fmt.Sprint("分组[", request.GroupId, "]不存在"))
+			return
+		}
+	}
+
+	importer := &sshImporter{
+		ToolboxApi: this_,
+		userId:     requestBean.JWT.UserId,
+		groupId:    request.GroupId,
+	}
+	importer.existing, err = this_.ToolboxService.Query(&ToolboxModel{
+		ToolboxType: sshWorker_.Name,
+		UserId:      requestBean.JWT.UserId,
+	})
+	if err != nil {
+		return
+	}
+	for _, file := range request.Files {
+		content := []byte(file.Content)
+		if file.Base64 {
+			content, err = base64.StdEncoding.DecodeString(file.Content)
+			if err != nil {
+				err = errors.New("文件[" + file.Name + "]内容解码失败:" + err.Error())
+				return
+			}
+		}
+		var hosts []*sshimport.Host
+		hosts, err = sshimport.Parse(file.Format, file.Name, content)
+		if err != nil {
+			return
+		}
+		for _, host := range hosts {
+			importer.items = append(importer.items, &ImportSSHItem{
+				Host:    host,
+				Address: host.Address(),
+				File:    file.Name,
+			})
+		}
+	}
+
+	importer.checkDuplicate()
+	for _, item := range importer.items {
+		if item.Status != importStatusNew {
+			continue
+		}
+		if e := importer.resolve(item); e != nil {
+			item.Status = importStatusError
+			item.Message = e.Error()
+		}
+	}
+	if !request.DryRun {
+		for _, item := range importer.items {
+			if item.Status == importStatusNew {
+				if e := importer.insert(item); e != nil {
+					item.Status = importStatusError
+					item.Message = e.Error()
+				}
+			}
+		}
+	}
+
+	response.ItemList = importer.items
+	res = response
+	return
+}
+
+// getOptionAddress 获取已有 SSH 工具的地址和用户名
+func getOptionAddress(toolbox *ToolboxModel) (address string, username string) {
+	optionData := map[string]interface{}{}
+	_ = json.Unmarshal([]byte(toolbox.Option), &optionData)
+	address, _ = optionData["address"].(string)
+	username, _ = optionData["username"].(string)
+	return
+}
+
+// checkDuplicate 名称相同或地址、用户名都相同的视为重复，导入时跳过
+func (this_ *sshImporter) checkDuplicate() {
+	names := map[string]bool{}
+	for _, item := range this_.items {
+		item.Status = importStatusNew
+		if item.Host.Host == "" {
+			item.Status = importStatusError
+			item.Message = "主机地址为空"
+			continue
+		}
+		for _, one := range this_.existing {
+			address, username := getOptionAddress(one)
+			if one.Name == item.Name || (address == item.Address && username == item.Username) {
+				item.Status = importStatusDuplicate
+				item.ToolboxId = one.ToolboxId
+				item.Message = "与已有工具[" + one.Name + "]重复"
+				break
+			}
+		}
+		if item.Status == importStatusNew && names[item.Name] {
+			item.Status = importStatusDuplicate
+			item.Message = "导入列表中名称[" + item.Name + "]重复"
+		}
+		names[item.Name] = true
+	}
+}
+
+// canJump 导入列表中新增的主机或与已有工具重复的主机可以作为跳板机
+func (this_ *ImportSSHItem) canJump() bool {
+	return this_.Status == importStatusNew || this_.ToolboxId != 0
+}
+
+// findJump 按名称或地址查找跳板机，优先导入列表中的主机，其次已有的 SSH 工具
+func (this_ *sshImporter) findJump(item *ImportSSHItem, jump string) (jumpItem *ImportSSHItem, toolbox *ToolboxModel) {
+	for _, one := range this_.items {
+		if one != item && one.Name == jump && one.canJump() {
+			return one, nil
+		}
+	}
+	for _, one := range this_.existing {
+		if one.Name == jump {
+			return nil, one
+		}
+	}
+	username, address := sshimport.ParseJump(jump)
+	for _, one := range this_.items {
+		if one != item && one.Address == address && (username == "" || one.Username == username) && one.canJump() {
+			return one, nil
+		}
+	}
+	for _, one := range this_.existing {
+		a, u := getOptionAddress(one)
+		if a == address && (username == "" || u == username) {
+			return nil, one
+		}
+	}
+	return
+}
+
+// resolve 解析跳板机，跳板机同在导入列表中时先解析跳板机，检测循环引用
+func (this_ *sshImporter) resolve(item *ImportSSHItem) (err error) {
+	if item.resolved {
+		return item.resolvedErr
+	}
+	if item.resolving {
+		return errors.New("跳板机[" + item.Name + "]存在循环引用")
+	}
+	item.resolving = true
+	defer func() {
+		item.resolving = false
+		item.resolved = true
+		item.resolvedErr = err
+	}()
+
+	for _, jump := range item.ProxyJump {
+		jumpItem, toolbox := this_.findJump(item, jump)
+		if toolbox != nil {
+			item.jumpItems = append(item.jumpItems, nil)
+			item.jumpIds = append(item.jumpIds, toolbox.ToolboxId)
+			item.JumpToolboxNames = append(item.JumpToolboxNames, toolbox.Name)
+			continue
+		}
+		if jumpItem == nil {
+			err = errors.New("跳板机[" + jump + "]未找到")
+			return
+		}
+		if jumpItem.Status == importStatusNew {
+			if err = this_.resolve(jumpItem); err != nil {
+				err = errors.New("跳板机[" + jump + "]:" + err.Error())
+				return
+			}
+		}
+		item.jumpItems = append(item.jumpItems, jumpItem)
+		item.jumpIds = append(item.jumpIds, jumpItem.ToolboxId)
+		item.JumpToolboxNames = append(item.JumpToolboxNames, jumpItem.Name)
+	}
+	return
+}
+
+// insert 新增 SSH 工具，跳板机同在导入列表中时先新增跳板机
+func (this_ *sshImporter) insert(item *ImportSSHItem) (err error) {
+	if item.Status != importStatusNew {
+		if item.Status == importStatusError {
+			err = errors.New(item.Message)
+		}
+		return
+	}
+	var jumpIds []int64
+	for i, jumpItem := range item.jumpItems {
+		if jumpItem == nil {
+			jumpIds = append(jumpIds, item.jumpIds[i])
+			continue
+		}
+		if err = this_.insert(jumpItem); err != nil {
+			err = errors.New("跳板机[" + jumpItem.Name + "]导入失败:" + err.Error())
+			return
+		}
+		jumpIds = append(jumpIds, jumpItem.ToolboxId)
+	}
+
+	optionData := map[string]interface{}{
+		"address":  item.Address,
+		"username": item.Username,
+	}
+	if len(jumpIds) > 0 {
+		// 最后一个跳板作为 sshToolboxId，之前的按顺序作为 sshToolboxChain
+		var chain []map[string]interface{}
+		for _, id := range jumpIds[:len(jumpIds)-1] {
+			chain = append(chain, map[string]interface{}{"sshToolboxId": id})
+		}
+		if len(chain) > 0 {
+			optionData["sshToolboxChain"] = chain
+		}
+		optionData["sshToolboxId"] = jumpIds[len(jumpIds)-1]
+	}
+	option, err := json.Marshal(optionData)
+	if err != nil {
+		return
+	}
+	toolbox := &ToolboxModel{
+		ToolboxType: sshWorker_.Name,
+		GroupId:     this_.groupId,
+		Name:        item.Name,
+		Option:      string(option),
+		UserId:      this_.userId,
+	}
+	// 密钥文件在客户端本地，无法直接使用，记录在说明中由用户上传
+	if item.IdentityFile != "" {
+		toolbox.Comment = "IdentityFile: " + item.IdentityFile
+	}
+	_, err = this_.ToolboxService.Insert(toolbox)
+	if err != nil {
+		return
+	}
+	item.ToolboxId = toolbox.ToolboxId
+	item.Status = importStatusImported
+	return
+}
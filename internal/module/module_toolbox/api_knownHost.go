@@ -0,0 +1,166 @@
+package module_toolbox
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"teamide/internal/module/module_power"
+	"teamide/pkg/base"
+	"teamide/pkg/ssh"
+)
+
+type ListKnownHostRequest struct {
+}
+
+type ListKnownHostResponse struct {
+	KnownHostList []*ssh.KnownHost `json:"knownHostList,omitempty"`
+	PendingList   []*ssh.KnownHost `json:"pendingList,omitempty"`
+}
+
+func (this_ *ToolboxApi) listKnownHost(_ *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &ListKnownHostRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &ListKnownHostResponse{}
+
+	store := ssh.GetKnownHostsStore()
+	response.KnownHostList = store.List()
+	response.PendingList = store.Pending()
+
+	res = response
+	return
+}
+
+type AcceptKnownHostRequest struct {
+	Address     string `json:"address,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// ToolboxId 连接该主机的工具，固定了指纹的 SSH 工具接受后同步更新其固定指纹
+	ToolboxId int64 `json:"toolboxId,omitempty"`
+}
+
+type AcceptKnownHostResponse struct {
+	KnownHost *ssh.KnownHost `json:"knownHost,omitempty"`
+}
+
+func (this_ *ToolboxApi) acceptKnownHost(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &AcceptKnownHostRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &AcceptKnownHostResponse{}
+
+	err = this_.checkKnownHostSuper(requestBean)
+	if err != nil {
+		return
+	}
+	find, isToolboxHost, err := this_.checkKnownHostToolbox(request.ToolboxId, request.Address)
+	if err != nil {
+		return
+	}
+
+	response.KnownHost, err = ssh.GetKnownHostsStore().Accept(request.Address, request.Fingerprint)
+	if err != nil {
+		return
+	}
+	// 跳板主机的密钥不影响工具自身固定的指纹
+	if isToolboxHost {
+		err = this_.ToolboxService.UpdateHostKeyFingerprint(find, request.Fingerprint)
+		if err != nil {
+			return
+		}
+	}
+
+	res = response
+	return
+}
+
+type RemoveKnownHostRequest struct {
+	Address string `json:"address,omitempty"`
+}
+
+type RemoveKnownHostResponse struct {
+}
+
+func (this_ *ToolboxApi) removeKnownHost(requestBean *base.RequestBean, c *gin.Context) (res interface{}, err error) {
+
+	request := &RemoveKnownHostRequest{}
+	if !base.RequestJSON(request, c) {
+		return
+	}
+	response := &RemoveKnownHostResponse{}
+
+	err = this_.checkKnownHostSuper(requestBean)
+	if err != nil {
+		return
+	}
+
+	err = ssh.GetKnownHostsStore().Remove(request.Address)
+	if err != nil {
+		return
+	}
+
+	res = response
+	return
+}
+
+// checkKnownHostSuper 主机密钥为所有用户共用，任何用户都可以创建连接任意地址的工具，只有超管可以确认或删除主机密钥
+func (this_ *ToolboxApi) checkKnownHostSuper(requestBean *base.RequestBean) (err error) {
+	if !this_.IsServer {
+		return
+	}
+	if requestBean.JWT == nil || requestBean.JWT.UserId == 0 {
+		err = errors.New("登录用户获取失败")
+		return
+	}
+	roles, err := module_power.NewPowerUserService(this_.ServerContext).QueryPowerRolesByUserId(requestBean.JWT.UserId)
+	if err != nil {
+		return
+	}
+	for _, role := range roles {
+		if role.RoleType == base.SuperRoleType {
+			return
+		}
+	}
+	err = errors.New("只有超管可以修改主机密钥")
+	return
+}
+
+// checkKnownHostToolbox 确认的主机需要是工具（含跳板链）连接的主机
+// isToolboxHost 为 true 时主机为 SSH 工具自身，否则为跳板主机
+func (this_ *ToolboxApi) checkKnownHostToolbox(toolboxId int64, address string) (find *ToolboxModel, isToolboxHost bool, err error) {
+	if toolboxId == 0 {
+		err = errors.New("请指定连接该主机的工具")
+		return
+	}
+	find, err = this_.ToolboxService.Get(toolboxId)
+	if err != nil {
+		return
+	}
+	if find == nil {
+		err = errors.New("工具不存在")
+		return
+	}
+	if find.Option == "" {
+		err = errors.New("工具[" + find.Name + "]配置不存在")
+		return
+	}
+	config, sshConfig, err := this_.ToolboxService.GetSSHConfig(find.Option)
+	if err != nil {
+		return
+	}
+	address = knownhosts.Normalize(address)
+	if find.ToolboxType == sshWorker_.Name && knownhosts.Normalize(config.Address) == address {
+		isToolboxHost = true
+		return
+	}
+	for one := sshConfig; one != nil; one = one.JumpConfig {
+		if knownhosts.Normalize(one.Address) == address {
+			return
+		}
+	}
+	err = errors.New("主机[" + address + "]不是工具[" + find.Name + "]连接的主机")
+	return
+}
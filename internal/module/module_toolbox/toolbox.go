@@ -1,8 +1,10 @@
 package module_toolbox
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
 	"strings"
 	"teamide/internal/context"
@@ -26,6 +28,19 @@ func NewToolboxService(ServerContext *context.ServerContext) (res *ToolboxServic
 type ToolboxService struct {
 	*context.ServerContext
 	idService *module_id.IDService
+	// changeListeners 服务启动时注册，工具修改、删除后调用
+	changeListeners []func(toolboxId int64, deleted bool)
+}
+
+// AddChangeListener 注册工具修改、删除的监听，deleted 为 true 时工具已删除
+func (this_ *ToolboxService) AddChangeListener(listener func(toolboxId int64, deleted bool)) {
+	this_.changeListeners = append(this_.changeListeners, listener)
+}
+
+func (this_ *ToolboxService) callChange(toolboxId int64, deleted bool) {
+	for _, listener := range this_.changeListeners {
+		listener(toolboxId, deleted)
+	}
 }
 
 // Get 查询单个
@@ -490,11 +505,38 @@ func (this_ *ToolboxService) Update(toolbox *ToolboxModel) (rowsAffected int64,
 		this_.Logger.Error("Update Error", zap.Error(err))
 		return
 	}
+	this_.callChange(toolbox.ToolboxId, false)
 
 	return
 }
 
 // MoveGroup 更新
+// UpdateHostKeyFingerprint 更新 SSH 工具固定的主机密钥指纹，未固定指纹的工具不做修改
+func (this_ *ToolboxService) UpdateHostKeyFingerprint(toolbox *ToolboxModel, fingerprint string) (err error) {
+	if toolbox.ToolboxType != sshWorker_.Name || toolbox.Option == "" {
+		return
+	}
+	optionData := map[string]interface{}{}
+	err = util.JSONDecodeUseNumber([]byte(toolbox.Option), &optionData)
+	if err != nil {
+		return
+	}
+	if util.GetStringValue(optionData["hostKeyFingerprint"]) == "" {
+		return
+	}
+	optionData["hostKeyFingerprint"] = fingerprint
+	bs, err := json.Marshal(optionData)
+	if err != nil {
+		return
+	}
+	_, err = this_.Update(&ToolboxModel{
+		ToolboxId:   toolbox.ToolboxId,
+		ToolboxType: toolbox.ToolboxType,
+		Option:      string(bs),
+	})
+	return
+}
+
 func (this_ *ToolboxService) MoveGroup(toolbox *ToolboxModel) (rowsAffected int64, err error) {
 
 	var values []interface{}
@@ -548,6 +590,7 @@ func (this_ *ToolboxService) Delete(toolboxId int64) (rowsAffected int64, err er
 		this_.Logger.Error("Delete Error", zap.Error(err))
 		return
 	}
+	this_.callChange(toolboxId, true)
 
 	return
 }
@@ -3,11 +3,13 @@ package module_toolbox
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strconv"
 	"sync"
 	"teamide/pkg/base"
 	"teamide/pkg/form"
 	"teamide/pkg/ssh"
+	"teamide/pkg/telnet"
 
 	"github.com/gin-gonic/gin"
 	"github.com/team-ide/go-tool/db"
@@ -167,7 +169,7 @@ func (this_ *ToolboxService) FormatOption(toolboxData *ToolboxModel, decrypt boo
 			}
 		}
 		break
-	case mongodbWorker_:
+	case mongodbWorker_, telnetWorker_, rdpWorker_, vncWorker_:
 		if optionMap["password"] != nil {
 			str, ok := optionMap["password"].(string)
 			if ok {
@@ -193,7 +195,79 @@ func (this_ *ToolboxService) FormatOption(toolboxData *ToolboxModel, decrypt boo
 
 func (this_ *ToolboxService) GetSSHConfig(option string) (config *ssh.Config, sshConfig *ssh.Config, err error) {
 	config = &ssh.Config{}
-	sshConfig, err = this_.BindConfigByOption(option, config, nil)
+	sshConfig, err = this_.BindConfigByOption(option, config)
+	return
+}
+
+// maxSSHChainSize SSH 跳板链最大跳数，防止配置错误导致无限递归
+const maxSSHChainSize = 10
+
+// getSSHToolboxIds 获取配置的 SSH 跳板，sshToolboxChain 为按顺序连接的跳板，sshToolboxId 为最后一跳
+func getSSHToolboxIds(optionData map[string]interface{}) (sshToolboxIds []int64) {
+	appendId := func(v interface{}) {
+		s := util.GetStringValue(v)
+		if s == "" {
+			return
+		}
+		sshToolboxId, _ := strconv.ParseInt(s, 10, 64)
+		if sshToolboxId > 0 {
+			sshToolboxIds = append(sshToolboxIds, sshToolboxId)
+		}
+	}
+	if list, ok := optionData["sshToolboxChain"].([]interface{}); ok {
+		for _, one := range list {
+			if hop, hopOk := one.(map[string]interface{}); hopOk {
+				appendId(hop["sshToolboxId"])
+			}
+		}
+	}
+	if optionData["sshToolboxId"] != nil {
+		appendId(optionData["sshToolboxId"])
+	}
+	return
+}
+
+// getSSHChainConfig 解析 SSH 跳板链，返回最后一跳，之前的跳板通过 JumpConfig 逐级关联
+// 第一跳自身配置的跳板会作为其前置跳板，因此跳板机可以各自配置到达自己的路径
+func (this_ *ToolboxService) getSSHChainConfig(optionData map[string]interface{}, chain []int64) (sshConfig *ssh.Config, err error) {
+	for i, sshToolboxId := range getSSHToolboxIds(optionData) {
+		for _, one := range chain {
+			if one == sshToolboxId {
+				err = errors.New(fmt.Sprint("ssh toolbox [", sshToolboxId, "] 跳板链存在循环"))
+				return
+			}
+		}
+		chain = append(chain, sshToolboxId)
+		if len(chain) > maxSSHChainSize {
+			err = errors.New(fmt.Sprint("ssh toolbox 跳板链超过最大跳数[", maxSSHChainSize, "]"))
+			return
+		}
+
+		var sshToolbox *ToolboxModel
+		sshToolbox, err = this_.Get(sshToolboxId)
+		if err != nil {
+			err = errors.New("ssh toolbox get error:" + err.Error())
+			return
+		}
+		if sshToolbox == nil {
+			continue
+		}
+		hopConfig := &ssh.Config{}
+		var jumpConfig *ssh.Config
+		if i == 0 {
+			jumpConfig, err = this_.bindConfigByOption(sshToolbox.Option, hopConfig, chain)
+		} else {
+			jumpConfig = sshConfig
+			_, err = this_.bindConfigByOption(sshToolbox.Option, hopConfig, nil)
+		}
+		if err != nil {
+			err = errors.New("ssh toolbox config error:" + err.Error())
+			return
+		}
+		hopConfig.JumpConfig = jumpConfig
+		sshConfig = hopConfig
+		//this_.Logger.Info("BindConfig find sshConfig", zap.Any("sshConfig", sshConfig))
+	}
 	return
 }
 
@@ -275,11 +349,17 @@ func (this_ *ToolboxService) BindConfigById(toolboxId int64, config interface{})
 	if find != nil {
 		option = find.Option
 	}
-	sshConfig, err = this_.BindConfigByOption(option, config, nil)
+	sshConfig, err = this_.BindConfigByOption(option, config)
+	return
+}
+
+func (this_ *ToolboxService) BindConfigByOption(option string, config interface{}) (sshConfig *ssh.Config, err error) {
+	sshConfig, err = this_.bindConfigByOption(option, config, []int64{})
 	return
 }
 
-func (this_ *ToolboxService) BindConfigByOption(option string, config interface{}, sshOptions []string) (sshConfig *ssh.Config, err error) {
+// bindConfigByOption chain 为 nil 时不解析 SSH 跳板
+func (this_ *ToolboxService) bindConfigByOption(option string, config interface{}, chain []int64) (sshConfig *ssh.Config, err error) {
 
 	sshConfig = nil
 
@@ -300,26 +380,10 @@ func (this_ *ToolboxService) BindConfigByOption(option string, config interface{
 				optionBytes, _ = json.Marshal(optionData)
 			}
 		}
-		if optionData["sshToolboxId"] != nil {
-			s := util.GetStringValue(optionData["sshToolboxId"])
-			if s != "" {
-				sshToolboxId, _ := strconv.ParseInt(s, 10, 64)
-				if sshToolboxId > 0 {
-					var sshToolbox *ToolboxModel
-					sshToolbox, err = this_.Get(sshToolboxId)
-					if err != nil {
-						err = errors.New("ssh toolbox get error:" + err.Error())
-						return
-					}
-					if sshToolbox != nil {
-						sshConfig, _, err = this_.GetSSHConfig(sshToolbox.Option)
-						if err != nil {
-							err = errors.New("ssh toolbox config error:" + err.Error())
-							return
-						}
-						//this_.Logger.Info("BindConfig find sshConfig", zap.Any("sshConfig", sshConfig))
-					}
-				}
+		if chain != nil {
+			sshConfig, err = this_.getSSHChainConfig(optionData, chain)
+			if err != nil {
+				return
 			}
 		}
 	}
@@ -345,6 +409,9 @@ func (this_ *ToolboxService) BindConfigByOption(option string, config interface{
 		if conf.PublicKey != "" {
 			conf.PublicKey = this_.GetFilesFile(conf.PublicKey)
 		}
+		if conf.Certificate != "" {
+			conf.Certificate = this_.GetFilesFile(conf.Certificate)
+		}
 		conf.Password = this_.DecryptOptionAttr(conf.Password)
 		break
 	case *redis.Config:
@@ -374,6 +441,9 @@ func (this_ *ToolboxService) BindConfigByOption(option string, config interface{
 		}
 		conf.Password = this_.DecryptOptionAttr(conf.Password)
 		break
+	case *telnet.Config:
+		conf.Password = this_.DecryptOptionAttr(conf.Password)
+		break
 	}
 	if err != nil {
 		return
@@ -396,7 +466,7 @@ func (this_ *ToolboxService) BindConfig(requestBean *base.RequestBean, c *gin.Co
 		option = find.Option
 	}
 
-	sshConfig, err = this_.BindConfigByOption(option, config, nil)
+	sshConfig, err = this_.BindConfigByOption(option, config)
 
 	return
 }
@@ -426,8 +496,13 @@ var (
 	thriftWorker_ = thriftWorker()
 	httpWorker_   = httpWorker()
 	serialWorker_ = serialWorker()
+	telnetWorker_ = telnetWorker()
+	rdpWorker_    = rdpWorker()
+	vncWorker_    = vncWorker()
 	makerWorker_  = makerWorker()
 
+	forwardWorker_ = forwardWorker()
+
 	otherWorker_ = otherWorker()
 )
 
@@ -450,6 +525,10 @@ func initToolboxTypes() {
 	*toolboxTypes = append(*toolboxTypes, thriftWorker_)
 	*toolboxTypes = append(*toolboxTypes, httpWorker_)
 	*toolboxTypes = append(*toolboxTypes, serialWorker_)
+	*toolboxTypes = append(*toolboxTypes, telnetWorker_)
+	*toolboxTypes = append(*toolboxTypes, rdpWorker_)
+	*toolboxTypes = append(*toolboxTypes, vncWorker_)
+	*toolboxTypes = append(*toolboxTypes, forwardWorker_)
 	//*toolboxTypes = append(*toolboxTypes, otherWorker_)
 }
 
@@ -471,6 +550,16 @@ func GetToolboxType(name string) (res *ToolboxType) {
 	return
 }
 
+// sshToolboxChainField SSH 跳板链，按顺序逐跳连接，最后一跳为「SSH隧道」
+func sshToolboxChainField(vIf string) *form.Field {
+	return &form.Field{
+		Label: "SSH跳板链（按顺序逐跳连接，最后经由SSH隧道）", Name: "sshToolboxChain", Type: "list", VIf: vIf,
+		Fields: []*form.Field{
+			{Label: "SSH跳板", Name: "sshToolboxId", Type: "select", OptionsName: "sshToolboxOptions"},
+		},
+	}
+}
+
 func databaseWorker() *ToolboxType {
 
 	worker_ := &ToolboxType{
@@ -501,6 +590,7 @@ func databaseWorker() *ToolboxType {
 					OptionsName: "sshToolboxOptions",
 					Rules:       []*form.Rule{},
 				},
+				sshToolboxChainField(`type == 'mysql' || type == 'kingbase' || type == 'postgresql' || type == 'opengauss' || type == 'dameng' || type == 'shentong' || type == 'oracle'`),
 				{
 					Label: "Host（127.0.0.1）", Name: "host", DefaultValue: "127.0.0.1", VIf: `type != 'sqlite' && type != 'odbc' && type != 'gbase'`,
 					Rules: []*form.Rule{
@@ -583,6 +673,7 @@ func elasticsearchWorker() *ToolboxType {
 					Rules:       []*form.Rule{},
 					Col:         12,
 				},
+				sshToolboxChainField(""),
 				{
 					Label: "连接地址（http://127.0.0.1:9200）", Name: "url", DefaultValue: "http://127.0.0.1:9200",
 					Rules: []*form.Rule{
@@ -641,6 +732,7 @@ func kafkaWorker() *ToolboxType {
 					Rules:       []*form.Rule{},
 					Col:         12,
 				},
+				sshToolboxChainField(""),
 				{Label: "连接地址（127.0.0.1:9092）", Name: "address", DefaultValue: "127.0.0.1:9092",
 					Rules: []*form.Rule{
 						{Required: true, Message: "连接地址不能为空"},
@@ -791,6 +883,7 @@ func sshWorker() *ToolboxType {
 					Rules:       []*form.Rule{},
 					Col:         12,
 				},
+				sshToolboxChainField(""),
 				{
 					Label: "连接地址（127.0.0.1:22）", Name: "address", DefaultValue: "127.0.0.1:22",
 					Rules: []*form.Rule{
@@ -803,11 +896,25 @@ func sshWorker() *ToolboxType {
 
 				{Label: `连接超时时间（秒）`, Name: "timeout", IsNumber: true, Col: 6, DefaultValue: 5},
 
+				{Label: "主机密钥校验", Name: "hostKeyMode", Type: "select", Col: 9, DefaultValue: "tofu",
+					Options: []*form.Option{
+						{Text: "首次信任（记录首次连接的密钥）", Value: "tofu"},
+						{Text: "严格（只接受已记录的密钥）", Value: "strict"},
+						{Text: "关闭（不校验，不安全）", Value: "off"},
+					},
+				},
+				{Label: "固定主机密钥指纹（SHA256:xxx，配置后只接受该指纹）", Name: "hostKeyFingerprint", Col: 15, VIf: "hostKeyMode != 'off'"},
+
 				{Label: "空闲自动发送（防止会话超时）", Name: "idleSendOpen", Type: "switch", Col: 8, DefaultValue: false},
 				{Label: `发送间隔（秒）`, Name: "idleSendTime", IsNumber: true, Col: 8, DefaultValue: 60, VIf: "idleSendOpen == true"},
 				{Label: `发送字符（^C：Ctrl+C、\n：回车）`, Name: "idleSendChar", Col: 8, DefaultValue: "^C", VIf: "idleSendOpen == true"},
 
 				{Label: "PrivateKey（通常跳板机需要的密钥文件）", Name: "publicKey", Type: "file", Placeholder: "请上传PrivateKey文件"},
+				{Label: "证书（OpenSSH 用户证书 -cert.pub，不上传时使用私钥同名证书）", Name: "certificate", Type: "file", Placeholder: "请上传证书文件"},
+				{Label: "使用 SSH Agent 认证", Name: "useAgent", Type: "switch", Col: 8, DefaultValue: false},
+				{Label: "Agent Socket（默认 SSH_AUTH_SOCK）", Name: "agentSocket", Col: 8, VIf: "useAgent == true"},
+				{Label: "Agent 转发", Name: "agentForward", Type: "switch", Col: 8, DefaultValue: false, VIf: "useAgent == true"},
+				{Label: "键盘交互认证以密码回答（不在终端中连接时使用）", Name: "keyboardInteractivePassword", Type: "switch", Col: 8, DefaultValue: false},
 				{Label: "连接后执行命令(回车执行多条，sleep 5，表示等待5秒执行下一条)", Name: "command", Type: "textarea"},
 			},
 		},
@@ -828,6 +935,7 @@ func redisWorker() *ToolboxType {
 					Rules:       []*form.Rule{},
 					Col:         12,
 				},
+				sshToolboxChainField(""),
 				{Label: "连接地址（127.0.0.1:6379）", Name: "address", DefaultValue: "127.0.0.1:6379",
 					Rules: []*form.Rule{
 						{Required: true, Message: "连接地址不能为空"},
@@ -856,6 +964,7 @@ func zookeeperWorker() *ToolboxType {
 					OptionsName: "sshToolboxOptions",
 					Rules:       []*form.Rule{},
 				},
+				sshToolboxChainField(""),
 				{
 					Label: "连接地址（127.0.0.1:2181）", Name: "address", DefaultValue: "127.0.0.1:2181",
 					Rules: []*form.Rule{
@@ -883,6 +992,7 @@ func mongodbWorker() *ToolboxType {
 					Rules:       []*form.Rule{},
 					Col:         12,
 				},
+				sshToolboxChainField(""),
 				{
 					Label: "连接地址（127.0.0.1:27017）", Name: "address", DefaultValue: "127.0.0.1:27017",
 					Rules: []*form.Rule{
@@ -911,6 +1021,7 @@ func netConn() *ToolboxType {
 					Rules:       []*form.Rule{},
 					Col:         12,
 				},
+				sshToolboxChainField(""),
 				{
 					Label: "连接地址（127.0.0.1:6379）", Name: "address", DefaultValue: "127.0.0.1:6379",
 					Rules: []*form.Rule{
@@ -1011,6 +1122,187 @@ func serialWorker() *ToolboxType {
 	return worker_
 }
 
+func telnetWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "telnet",
+		Text: "Telnet",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select",
+					OptionsName: "sshToolboxOptions",
+					Rules:       []*form.Rule{},
+					Col:         12,
+					VIf:         "!nodeId",
+				},
+				{
+					Label: "节点（由节点发起连接，配置后不使用SSH隧道）", Name: "nodeId", Type: "select",
+					OptionsName: "nodeOptions",
+					Rules:       []*form.Rule{},
+					Col:         12,
+				},
+				sshToolboxChainField("!nodeId"),
+				{
+					Label: "连接地址（127.0.0.1:23）", Name: "address", DefaultValue: "127.0.0.1:23",
+					Rules: []*form.Rule{
+						{Required: true, Message: "连接地址不能为空"},
+					},
+					Col: 12,
+				},
+				{Label: "终端类型", Name: "terminalType", DefaultValue: "xterm", Col: 6},
+				{Label: `连接超时时间（秒）`, Name: "timeout", IsNumber: true, Col: 6, DefaultValue: 5},
+				{Label: "Username（匹配到登录提示后自动输入）", Name: "username", Col: 12},
+				{Label: "Password（匹配到密码提示后自动输入）", Name: "password", Type: "password", Col: 12, ShowPlaintextBtn: true},
+			},
+		},
+	}
+
+	return worker_
+}
+
+// remoteDesktopFields 远程桌面由 guacd 连接，SSH 隧道、网络代理用于连接 guacd
+func remoteDesktopFields(defaultPort int) []*form.Field {
+	return []*form.Field{
+		{
+			Label: "guacd地址（127.0.0.1:4822）", Name: "guacdAddress", DefaultValue: "127.0.0.1:4822",
+			Col: 12, VIf: "!netProxyId",
+		},
+		{
+			Label: "网络代理（由节点线路连接guacd，配置后不使用guacd地址和SSH隧道）", Name: "netProxyId", Type: "select",
+			OptionsName: "netProxyOptions",
+			Col:         12,
+		},
+		{
+			Label: "SSH隧道（经由SSH连接guacd）", Name: "sshToolboxId", Type: "select",
+			OptionsName: "sshToolboxOptions",
+			Col:         12,
+			VIf:         "!netProxyId",
+		},
+		sshToolboxChainField("!netProxyId"),
+		{
+			Label: "主机（由guacd连接）", Name: "hostname",
+			Rules: []*form.Rule{
+				{Required: true, Message: "主机不能为空"},
+			},
+			Col: 8,
+		},
+		{Label: "端口", Name: "port", DefaultValue: defaultPort, IsNumber: true, Col: 4},
+	}
+}
+
+func rdpWorker() *ToolboxType {
+	fields := remoteDesktopFields(3389)
+	fields = append(fields, []*form.Field{
+		{Label: "Username", Name: "username", Col: 12},
+		{Label: "Password", Name: "password", Type: "password", Col: 12, ShowPlaintextBtn: true},
+		{Label: "域", Name: "domain", Col: 12},
+		{Label: "安全模式", Name: "security", Type: "select", Col: 12, DefaultValue: "any",
+			Options: []*form.Option{
+				{Text: "自动", Value: "any"},
+				{Text: "NLA", Value: "nla"},
+				{Text: "TLS", Value: "tls"},
+				{Text: "RDP", Value: "rdp"},
+			},
+		},
+		{Label: "忽略证书验证", Name: "ignoreCert", Type: "switch", Col: 8, DefaultValue: true},
+		{Label: "颜色深度", Name: "colorDepth", Type: "select", Col: 8, DefaultValue: "",
+			Options: []*form.Option{
+				{Text: "默认", Value: ""},
+				{Text: "16位", Value: "16"},
+				{Text: "24位", Value: "24"},
+				{Text: "32位", Value: "32"},
+			},
+		},
+		{Label: "禁止复制到本地", Name: "disableCopy", Type: "switch", Col: 8, DefaultValue: false},
+		{Label: "禁止粘贴到远程", Name: "disablePaste", Type: "switch", Col: 8, DefaultValue: false},
+		{Label: "会话录像", Name: "enableRecording", Type: "switch", Col: 8, DefaultValue: false},
+	}...)
+	worker_ := &ToolboxType{
+		Name: "rdp",
+		Text: "RDP",
+		ConfigForm: &form.Form{
+			Fields: fields,
+		},
+	}
+
+	return worker_
+}
+
+func vncWorker() *ToolboxType {
+	fields := remoteDesktopFields(5900)
+	fields = append(fields, []*form.Field{
+		{Label: "Username（部分VNC服务需要）", Name: "username", Col: 12},
+		{Label: "Password", Name: "password", Type: "password", Col: 12, ShowPlaintextBtn: true},
+		{Label: "颜色深度", Name: "colorDepth", Type: "select", Col: 8, DefaultValue: "",
+			Options: []*form.Option{
+				{Text: "默认", Value: ""},
+				{Text: "8位", Value: "8"},
+				{Text: "16位", Value: "16"},
+				{Text: "24位", Value: "24"},
+				{Text: "32位", Value: "32"},
+			},
+		},
+		{Label: "禁止复制到本地", Name: "disableCopy", Type: "switch", Col: 8, DefaultValue: false},
+		{Label: "禁止粘贴到远程", Name: "disablePaste", Type: "switch", Col: 8, DefaultValue: false},
+		{Label: "会话录像", Name: "enableRecording", Type: "switch", Col: 8, DefaultValue: false},
+	}...)
+	worker_ := &ToolboxType{
+		Name: "vnc",
+		Text: "VNC",
+		ConfigForm: &form.Form{
+			Fields: fields,
+		},
+	}
+
+	return worker_
+}
+
+func forwardWorker() *ToolboxType {
+	worker_ := &ToolboxType{
+		Name: "portForward",
+		Text: "端口转发",
+		ConfigForm: &form.Form{
+			Fields: []*form.Field{
+				{
+					Label: "SSH隧道", Name: "sshToolboxId", Type: "select",
+					OptionsName: "sshToolboxOptions",
+					Rules: []*form.Rule{
+						{Required: true, Message: "SSH隧道不能为空"},
+					},
+					Col: 12,
+				},
+				sshToolboxChainField(""),
+				{Label: "转发类型", Name: "forwardType", Type: "select", Col: 12, DefaultValue: "local",
+					Options: []*form.Option{
+						{Text: "本地转发（-L）", Value: "local"},
+						{Text: "远程转发（-R）", Value: "remote"},
+						{Text: "动态转发（-D，SOCKS5）", Value: "dynamic"},
+					},
+					Rules: []*form.Rule{
+						{Required: true, Message: "转发类型不能为空"},
+					},
+				},
+				{
+					Label: "监听地址（远程转发在SSH服务器上监听）", Name: "listenAddress", DefaultValue: "127.0.0.1:10080",
+					Rules: []*form.Rule{
+						{Required: true, Message: "监听地址不能为空"},
+					},
+					Col: 12,
+				},
+				{
+					Label: "目标地址（本地转发由SSH服务器连接，远程转发由本服务连接）", Name: "targetAddress", VIf: "forwardType != 'dynamic'",
+					Col: 12,
+				},
+				{Label: "启用（服务启动时自动开启）", Name: "enable", Type: "switch", Col: 8, DefaultValue: false},
+				{Label: "断开自动重连", Name: "autoReconnect", Type: "switch", Col: 8, DefaultValue: true},
+				{Label: `重连间隔（秒）`, Name: "reconnectInterval", IsNumber: true, Col: 8, DefaultValue: 5, VIf: "autoReconnect == true"},
+			},
+		},
+	}
+
+	return worker_
+}
+
 func otherWorker() *ToolboxType {
 	worker_ := &ToolboxType{
 		Name: "other",
@@ -62,8 +62,7 @@ func getService(zkConfig *zookeeper.Config, sshConfig *ssh.Config) (res zookeepe
 		key += "-" + base.GetMd5String(key+zkConfig.Password)
 	}
 	if sshConfig != nil {
-		key += "-ssh-" + sshConfig.Address
-		key += "-ssh-" + sshConfig.Username
+		key += "-ssh-" + sshConfig.GetChainKey()
 	}
 	var serviceInfo *base.ServiceInfo
 	serviceInfo, err = base.GetService(key, func() (res *base.ServiceInfo, err error) {
@@ -41,12 +41,18 @@ func NewGuacamoleTunnel(guacadAddr, protocol, host, port, user, password, uuid s
 		util.Logger.Error("NewGuacamoleTunnel DialTCP error", zap.Error(err))
 		return nil, err
 	}
+	return NewTunnel(conn, config)
+}
+
+// NewTunnel 在已建立的 guacd 连接上握手，连接可以经由 SSH 隧道或节点线路建立，握手失败时关闭连接
+func NewTunnel(conn net.Conn, config *Config) (tunnel *SimpleTunnel, err error) {
 	stream := NewStream(conn, SocketTimeout)
 	// 这一步才是初始化 rdp/vnc guacd 并认证资产的身份
 	err = stream.Handshake(config)
 	if err != nil {
-		return nil, err
+		_ = conn.Close()
+		return
 	}
-	tunnel := NewSimpleTunnel(stream)
-	return tunnel, nil
+	tunnel = NewSimpleTunnel(stream)
+	return
 }
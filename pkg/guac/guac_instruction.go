@@ -3,6 +3,7 @@ package guac
 import (
 	"fmt"
 	"strconv"
+	"unicode/utf8"
 )
 
 //The Guacamole protocol consists of instructions. Each instruction is a comma-delimited list followed by a terminating semicolon, where the first element of the list is the instruction opcode, and all following elements are the arguments for that instruction:
@@ -41,9 +42,10 @@ func (i *Instruction) String() string {
 		return i.cache
 	}
 
-	i.cache = fmt.Sprintf("%d.%s", len(i.Opcode), i.Opcode)
+	// 长度为 Unicode 字符数，用户名、密码等参数可能包含中文
+	i.cache = fmt.Sprintf("%d.%s", utf8.RuneCountInString(i.Opcode), i.Opcode)
 	for _, value := range i.Args {
-		i.cache += fmt.Sprintf(",%d.%s", len(value), value)
+		i.cache += fmt.Sprintf(",%d.%s", utf8.RuneCountInString(value), value)
 	}
 	i.cache += ";"
 
@@ -83,13 +85,17 @@ func Parse(data []byte) (*Instruction, error) {
 
 		// Parse element from just after period
 		elementStart = lengthEnd + 1
-		element := string(data[elementStart : elementStart+length])
+		elementEnd, ok := ElementEnd(data, elementStart, length)
+		if !ok || elementEnd >= len(data) {
+			return nil, ErrServer.NewError("ReadSome returned incomplete instruction.")
+		}
+		element := string(data[elementStart:elementEnd])
 
 		// Append element to list of elements
 		elements = append(elements, element)
 
 		// ReadSome terminator after element
-		elementStart += length
+		elementStart = elementEnd
 		terminator := data[elementStart]
 
 		// Continue reading instructions after terminator
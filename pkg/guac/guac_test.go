@@ -0,0 +1,60 @@
+package guac
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTunnel(t *testing.T) {
+	client, server := net.Pipe()
+	defer func() { _ = server.Close() }()
+
+	done := make(chan string, 1)
+	go func() {
+		guacd := NewStream(server, time.Second)
+		var connect *Instruction
+		for {
+			ins, err := ReadOne(guacd)
+			if err != nil {
+				done <- err.Error()
+				return
+			}
+			guacd.Flush()
+			switch ins.Opcode {
+			case "select":
+				_, _ = guacd.Write(NewInstruction("args", "hostname", "username", "disable-copy").Byte())
+			case "connect":
+				connect = ins
+				_, _ = guacd.Write(NewInstruction("ready", "$conn").Byte())
+				done <- strings.Join(connect.Args, "|")
+				return
+			}
+		}
+	}()
+
+	config := NewGuacamoleConfiguration()
+	config.Protocol = "rdp"
+	config.Parameters["hostname"] = "10.0.0.1"
+	config.Parameters["username"] = "管理员"
+	tunnel, err := NewTunnel(client, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = tunnel.Close() }()
+
+	// 未配置的参数发送空值，参数顺序与 guacd 返回的 args 一致
+	if args := <-done; args != "10.0.0.1|管理员|" {
+		t.Fatal(args)
+	}
+	if tunnel.ConnectionID() != "$conn" {
+		t.Fatal(tunnel.ConnectionID())
+	}
+}
+
+func TestInstructionLength(t *testing.T) {
+	if s := NewInstruction("name", "桌面").String(); s != "4.name,2.桌面;" {
+		t.Fatal(s)
+	}
+}
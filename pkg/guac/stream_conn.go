@@ -6,6 +6,7 @@ import (
 	"go.uber.org/zap"
 	"net"
 	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -87,15 +88,16 @@ func (s *Stream) ReadSome() (instruction []byte, err error) {
 
 			// If not digit, check for end-of-length character
 			case '.':
-				if i+elementLength >= len(s.buffer) {
+				elementEnd, ok := ElementEnd(s.buffer, i, elementLength)
+				if !ok || elementEnd >= len(s.buffer) {
 					// break for i < s.usedLength { ... }
 					// Otherwise, read more data
 					break parseLoop
 				}
 				// Check if element present in buffer
-				terminator := s.buffer[i+elementLength]
+				terminator := s.buffer[elementEnd]
 				// Move to character after terminator
-				i += elementLength + 1
+				i = elementEnd + 1
 
 				// Reset length
 				elementLength = 0
@@ -151,6 +153,27 @@ func (s *Stream) ReadSome() (instruction []byte, err error) {
 	}
 }
 
+// ElementEnd 元素长度为 Unicode 字符数，返回从 start 开始 length 个字符后的位置，内容不完整时 ok 为 false
+func ElementEnd(data []byte, start int, length int) (end int, ok bool) {
+	end = start
+	for ; length > 0; length-- {
+		if end >= len(data) {
+			return
+		}
+		if data[end] < utf8.RuneSelf {
+			end++
+			continue
+		}
+		if !utf8.FullRune(data[end:]) {
+			return
+		}
+		_, size := utf8.DecodeRune(data[end:])
+		end += size
+	}
+	ok = true
+	return
+}
+
 // Close closes the underlying network connection
 func (s *Stream) Close() error {
 	return s.conn.Close()
@@ -0,0 +1,229 @@
+package logindex
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxTokenLength 词的最大长度，超过的部分截断，查询时按同样规则截断
+	maxTokenLength = 64
+	// maxLineSize 超过该长度仍未换行的内容按一行索引，避免进度条等输出无法索引
+	maxLineSize = 4 * 1024
+	// readChunkSize 每次读取的字节数
+	readChunkSize = 1024 * 1024
+)
+
+// Index 单个日志文件的倒排索引，记录每个词出现的行的起始偏移，偏移按升序排列
+type Index struct {
+	// Size 已索引的字节数，只索引完整的行
+	Size     int64
+	Postings map[string][]int64
+}
+
+func New() *Index {
+	return &Index{
+		Postings: map[string][]int64{},
+	}
+}
+
+// Load 读取索引文件，文件不存在或损坏时返回空索引，由 Update 重新建立
+func Load(path string) (index *Index) {
+	index = New()
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var find = &Index{}
+	if err = gob.NewDecoder(bytes.NewReader(bs)).Decode(find); err != nil {
+		return
+	}
+	if find.Postings == nil {
+		find.Postings = map[string][]int64{}
+	}
+	index = find
+	return
+}
+
+// Save 写入临时文件后重命名，避免写入中断导致索引损坏
+func (this_ *Index) Save(path string) (err error) {
+	var buf bytes.Buffer
+	if err = gob.NewEncoder(&buf).Encode(this_); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, buf.Bytes(), 0666); err != nil {
+		return
+	}
+	err = os.Rename(tmp, path)
+	return
+}
+
+// Update 索引 Size 之后新增的完整行，文件变小（被清空）时重新建立索引
+func (this_ *Index) Update(r io.ReaderAt, size int64) (changed bool, err error) {
+	if size < this_.Size {
+		this_.Size = 0
+		this_.Postings = map[string][]int64{}
+		changed = true
+	}
+	buf := make([]byte, readChunkSize)
+	var pending []byte
+	lineStart := this_.Size
+	offset := this_.Size
+	for offset < size {
+		n := int64(len(buf))
+		if size-offset < n {
+			n = size - offset
+		}
+		var read int
+		read, err = r.ReadAt(buf[:n], offset)
+		if read <= 0 {
+			if err == nil || err == io.EOF {
+				err = nil
+			}
+			break
+		}
+		err = nil
+		offset += int64(read)
+		pending = append(pending, buf[:read]...)
+		for {
+			index := bytes.IndexByte(pending, '\n')
+			if index < 0 && len(pending) < maxLineSize {
+				break
+			}
+			lineSize := index + 1
+			if index < 0 || index >= maxLineSize {
+				lineSize = maxLineSize
+			}
+			this_.addLine(lineStart, string(pending[:lineSize]))
+			lineStart += int64(lineSize)
+			pending = pending[lineSize:]
+			changed = true
+		}
+	}
+	this_.Size = lineStart
+	return
+}
+
+func (this_ *Index) addLine(offset int64, line string) {
+	for _, token := range Tokenize(line) {
+		list := this_.Postings[token]
+		if len(list) > 0 && list[len(list)-1] == offset {
+			continue
+		}
+		this_.Postings[token] = append(list, offset)
+	}
+}
+
+func isHan(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// Tokenize 分词：字母、数字、下划线组成的词转为小写，长度小于 2 的忽略；中日韩文字每个字单独作为一个词
+func Tokenize(text string) (tokens []string) {
+	var word strings.Builder
+	var wordLength int
+	flush := func() {
+		if wordLength >= 2 {
+			tokens = append(tokens, word.String())
+		}
+		word.Reset()
+		wordLength = 0
+	}
+	for _, r := range text {
+		if isHan(r) {
+			flush()
+			tokens = append(tokens, string(r))
+			continue
+		}
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if wordLength < maxTokenLength {
+				word.WriteRune(unicode.ToLower(r))
+			}
+			wordLength++
+			continue
+		}
+		flush()
+	}
+	flush()
+	return
+}
+
+// Candidates 返回可能包含查询内容的行偏移，查询中的词需完整出现，最后一个词可以是前缀
+// 查询中没有可索引的词时 ok 为 false，需要扫描全文
+func (this_ *Index) Candidates(query string) (offsets []int64, ok bool) {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return
+	}
+	ok = true
+	// 查询以最后一个词结尾时，最后一个词可能未输入完整
+	last := tokens[len(tokens)-1]
+	lastPrefix := utf8.RuneCountInString(last) < maxTokenLength && !isHan([]rune(last)[0]) && strings.HasSuffix(strings.ToLower(query), last)
+	for i, token := range tokens {
+		var list []int64
+		if i == len(tokens)-1 && lastPrefix {
+			list = this_.prefixPostings(token)
+		} else {
+			list = this_.Postings[token]
+		}
+		if i == 0 {
+			offsets = list
+		} else {
+			offsets = intersect(offsets, list)
+		}
+		if len(offsets) == 0 {
+			offsets = nil
+			return
+		}
+	}
+	return
+}
+
+func (this_ *Index) prefixPostings(prefix string) (offsets []int64) {
+	var lists [][]int64
+	for token, list := range this_.Postings {
+		if strings.HasPrefix(token, prefix) {
+			lists = append(lists, list)
+		}
+	}
+	if len(lists) == 1 {
+		return lists[0]
+	}
+	seen := map[int64]bool{}
+	for _, list := range lists {
+		for _, offset := range list {
+			if !seen[offset] {
+				seen[offset] = true
+				offsets = append(offsets, offset)
+			}
+		}
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i] < offsets[j]
+	})
+	return
+}
+
+func intersect(a []int64, b []int64) (res []int64) {
+	var i, j int
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			res = append(res, a[i])
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return
+}
@@ -0,0 +1,85 @@
+package logindex
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize("ERROR: conn_refused at 10.0.0.1, 连接失败 a")
+	if strings.Join(tokens, "|") != "error|conn_refused|at|10|连|接|失|败" {
+		t.Fatal(tokens)
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	content := "$ systemctl restart nginx\nJob for nginx.service failed\r\nsee journalctl -xe\n$ ls\npartial"
+	r := strings.NewReader(content)
+
+	index := New()
+	changed, err := index.Update(r, int64(len(content)))
+	if err != nil || !changed {
+		t.Fatal(changed, err)
+	}
+	// 最后不完整的行不索引
+	if index.Size != int64(strings.Index(content, "partial")) {
+		t.Fatal(index.Size)
+	}
+
+	path := filepath.Join(t.TempDir(), "command.idx")
+	if err = index.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	index = Load(path)
+
+	hits, err := index.Search(r, &SearchOption{Query: "NGINX.service fail", Match: TextMatcher("NGINX.service fail"), Context: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Offset != 26 || hits[0].Line != "Job for nginx.service failed" {
+		t.Fatal(hits)
+	}
+	if strings.Join(hits[0].Before, "|") != "$ systemctl restart nginx" || strings.Join(hits[0].After, "|") != "see journalctl -xe" {
+		t.Fatal(hits[0].Before, hits[0].After)
+	}
+
+	// 只有最后一个词可以是前缀
+	if offsets, ok := index.Candidates("ngin."); !ok || len(offsets) != 0 {
+		t.Fatal(offsets)
+	}
+	if offsets, _ := index.Candidates("nginx.serv"); len(offsets) != 1 || offsets[0] != 26 {
+		t.Fatal(offsets)
+	}
+
+	// 正则表达式扫描全文
+	re := regexp.MustCompile(`^\$ \w+$`)
+	hits, _ = index.Search(r, &SearchOption{Match: re.MatchString})
+	if len(hits) != 1 || hits[0].Line != "$ ls" {
+		t.Fatal(hits)
+	}
+
+	// 追加内容后增量索引
+	content += " line\n"
+	r = strings.NewReader(content)
+	_, _ = index.Update(r, int64(len(content)))
+	hits, _ = index.Search(r, &SearchOption{Query: "partial", Match: TextMatcher("partial")})
+	if len(hits) != 1 || hits[0].Line != "partial line" {
+		t.Fatal(hits)
+	}
+
+	// 文件变小后重新索引
+	content = "new\n"
+	_, _ = index.Update(strings.NewReader(content), int64(len(content)))
+	if index.Size != 4 || len(index.Postings) != 1 {
+		t.Fatal(index.Size, index.Postings)
+	}
+}
+
+func TestTimeAt(t *testing.T) {
+	marks := []*TimeMark{{Offset: 0, Time: 100}, {Offset: 50, Time: 200}}
+	if TimeAt(marks, 10) != 100 || TimeAt(marks, 50) != 200 || TimeAt(marks, 99) != 200 || TimeAt(nil, 1) != 0 {
+		t.Fatal("time at")
+	}
+}
@@ -0,0 +1,190 @@
+package logindex
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+const (
+	// contextWindow 读取上下文时向前、向后读取的最大字节数
+	contextWindow = 8 * 1024
+)
+
+// Hit 命中的行，Offset 为行在日志文件中的起始偏移，用于定位到日志位置
+type Hit struct {
+	Offset int64    `json:"offset"`
+	Line   string   `json:"line"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+	Time   int64    `json:"time,omitempty"`
+}
+
+// Matcher 判断行是否命中
+type Matcher func(line string) bool
+
+// TextMatcher 忽略大小写包含查询内容
+func TextMatcher(query string) Matcher {
+	query = strings.ToLower(query)
+	return func(line string) bool {
+		return strings.Contains(strings.ToLower(line), query)
+	}
+}
+
+// SearchOption 搜索参数，Query 为空时不使用索引，逐行使用 Match 判断
+type SearchOption struct {
+	Query   string
+	Match   Matcher
+	Context int
+	// Accept 根据偏移过滤命中的行，如按时间范围过滤
+	Accept func(offset int64) bool
+	Limit  int
+}
+
+// Search 在已索引的范围内搜索，有可用的索引词时只读取候选行，否则扫描全文
+func (this_ *Index) Search(r io.ReaderAt, option *SearchOption) (hits []*Hit, err error) {
+	add := func(offset int64, raw string) (full bool) {
+		if option.Accept != nil && !option.Accept(offset) {
+			return
+		}
+		line := trimLine(raw)
+		if !option.Match(line) {
+			return
+		}
+		hit := &Hit{
+			Offset: offset,
+			Line:   line,
+		}
+		if option.Context > 0 {
+			hit.Before, hit.After = readContext(r, offset, offset+int64(len(raw)), this_.Size, option.Context)
+		}
+		hits = append(hits, hit)
+		return option.Limit > 0 && len(hits) >= option.Limit
+	}
+
+	if option.Query != "" {
+		if offsets, ok := this_.Candidates(option.Query); ok {
+			for _, offset := range offsets {
+				var line string
+				if line, err = readLine(r, offset, this_.Size); err != nil {
+					return
+				}
+				if add(offset, line) {
+					return
+				}
+			}
+			return
+		}
+	}
+
+	reader := bufio.NewReaderSize(io.NewSectionReader(r, 0, this_.Size), maxLineSize)
+	var offset int64
+	for {
+		line, e := readIndexLine(reader)
+		if len(line) > 0 {
+			if add(offset, line) {
+				return
+			}
+			offset += int64(len(line))
+		}
+		if e != nil {
+			if e != io.EOF {
+				err = e
+			}
+			return
+		}
+	}
+}
+
+// readIndexLine 按索引时的规则读取一行，超过 maxLineSize 的内容拆分为多行，保证偏移与索引一致
+func readIndexLine(reader *bufio.Reader) (line string, err error) {
+	var buf []byte
+	for len(buf) < maxLineSize {
+		var b byte
+		if b, err = reader.ReadByte(); err != nil {
+			break
+		}
+		buf = append(buf, b)
+		if b == '\n' {
+			break
+		}
+	}
+	line = string(buf)
+	return
+}
+
+func trimLine(line string) string {
+	return strings.TrimRight(line, "\r\n")
+}
+
+// readLine 读取 offset 开始的一行
+func readLine(r io.ReaderAt, offset int64, size int64) (line string, err error) {
+	n := int64(maxLineSize)
+	if size-offset < n {
+		n = size - offset
+	}
+	if n <= 0 {
+		return
+	}
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, offset)
+	if err == io.EOF {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	buf = buf[:read]
+	if index := bytes.IndexByte(buf, '\n'); index >= 0 {
+		buf = buf[:index+1]
+	}
+	line = string(buf)
+	return
+}
+
+// readContext 读取命中行前后各 count 行
+func readContext(r io.ReaderAt, start int64, end int64, size int64, count int) (before []string, after []string) {
+	from := start - contextWindow
+	if from < 0 {
+		from = 0
+	}
+	if from < start {
+		buf := make([]byte, start-from)
+		if read, _ := r.ReadAt(buf, from); read == len(buf) {
+			lines := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+			// 窗口开始位置不是文件开头时，第一行可能不完整
+			if from > 0 && len(lines) > 0 {
+				lines = lines[1:]
+			}
+			if len(lines) > count {
+				lines = lines[len(lines)-count:]
+			}
+			for _, line := range lines {
+				before = append(before, trimLine(line))
+			}
+		}
+	}
+
+	to := end + contextWindow
+	if to > size {
+		to = size
+	}
+	if end < to {
+		buf := make([]byte, to-end)
+		if read, _ := r.ReadAt(buf, end); read > 0 {
+			lines := strings.Split(string(buf[:read]), "\n")
+			for _, line := range lines {
+				if len(after) >= count {
+					break
+				}
+				after = append(after, trimLine(line))
+			}
+			// 最后一个元素是换行后的空内容
+			if len(after) > 0 && len(after) == len(lines) && after[len(after)-1] == "" {
+				after = after[:len(after)-1]
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,61 @@
+package logindex
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TimeMark 时间标记，表示从 Offset 开始的内容在 Time（毫秒）之后写入
+type TimeMark struct {
+	Offset int64
+	Time   int64
+}
+
+// AppendTimeMark 追加一条时间标记，每行为 “偏移 时间”
+func AppendTimeMark(w io.Writer, offset int64, time int64) (err error) {
+	_, err = fmt.Fprintf(w, "%d %d\n", offset, time)
+	return
+}
+
+// ReadTimeMarks 读取时间标记文件，文件不存在时返回空
+func ReadTimeMarks(path string) (marks []*TimeMark) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(bs), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		offset, e1 := strconv.ParseInt(fields[0], 10, 64)
+		t, e2 := strconv.ParseInt(fields[1], 10, 64)
+		if e1 != nil || e2 != nil {
+			continue
+		}
+		// 日志被清空后偏移重新开始，只保留最后一段
+		if len(marks) > 0 && offset < marks[len(marks)-1].Offset {
+			marks = nil
+		}
+		marks = append(marks, &TimeMark{Offset: offset, Time: t})
+	}
+	return
+}
+
+// TimeAt 返回偏移所在内容的写入时间，没有时间标记时返回 0
+func TimeAt(marks []*TimeMark, offset int64) int64 {
+	if len(marks) == 0 {
+		return 0
+	}
+	index := sort.Search(len(marks), func(i int) bool {
+		return marks[i].Offset > offset
+	})
+	if index == 0 {
+		return marks[0].Time
+	}
+	return marks[index-1].Time
+}
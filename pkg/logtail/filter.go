@@ -0,0 +1,202 @@
+package logtail
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Highlight 高亮规则
+type Highlight struct {
+	Pattern string `json:"pattern,omitempty"`
+	Color   string `json:"color,omitempty"`
+}
+
+// HighlightRange 行内需要高亮的范围，Start、End 为字符（rune）下标
+type HighlightRange struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Color string `json:"color,omitempty"`
+}
+
+type highlightRule struct {
+	re    *regexp.Regexp
+	color string
+}
+
+// Matcher 按包含、排除规则过滤行，并计算高亮范围
+type Matcher struct {
+	includeList   []*regexp.Regexp
+	excludeList   []*regexp.Regexp
+	highlightList []*highlightRule
+}
+
+// NewMatcher 编译过滤规则，包含规则为空时包含所有行
+func NewMatcher(includes []string, excludes []string, highlights []*Highlight) (matcher *Matcher, err error) {
+	matcher = &Matcher{}
+	compile := func(pattern string) (re *regexp.Regexp, err error) {
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			err = errors.New("规则[" + pattern + "]错误:" + err.Error())
+		}
+		return
+	}
+	for _, pattern := range includes {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		var re *regexp.Regexp
+		if re, err = compile(pattern); err != nil {
+			return
+		}
+		matcher.includeList = append(matcher.includeList, re)
+	}
+	for _, pattern := range excludes {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		var re *regexp.Regexp
+		if re, err = compile(pattern); err != nil {
+			return
+		}
+		matcher.excludeList = append(matcher.excludeList, re)
+	}
+	for _, one := range highlights {
+		if one == nil || strings.TrimSpace(one.Pattern) == "" {
+			continue
+		}
+		var re *regexp.Regexp
+		if re, err = compile(one.Pattern); err != nil {
+			return
+		}
+		matcher.highlightList = append(matcher.highlightList, &highlightRule{re: re, color: one.Color})
+	}
+	return
+}
+
+// Match 行是否需要输出，命中任意排除规则时排除
+func (this_ *Matcher) Match(line string) bool {
+	for _, re := range this_.excludeList {
+		if re.MatchString(line) {
+			return false
+		}
+	}
+	if len(this_.includeList) == 0 {
+		return true
+	}
+	for _, re := range this_.includeList {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// Highlight 计算行内所有高亮规则命中的范围
+func (this_ *Matcher) Highlight(line string) (ranges []*HighlightRange) {
+	for _, rule := range this_.highlightList {
+		for _, loc := range rule.re.FindAllStringIndex(line, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			start := utf8.RuneCountInString(line[:loc[0]])
+			ranges = append(ranges, &HighlightRange{
+				Start: start,
+				End:   start + utf8.RuneCountInString(line[loc[0]:loc[1]]),
+				Color: rule.color,
+			})
+		}
+	}
+	return
+}
+
+// RateLimiter 限制每秒输出的行数，超出的行丢弃并计数
+type RateLimiter struct {
+	// MaxRate 每秒最大行数，小于等于 0 时不限制
+	MaxRate int
+
+	second  int64
+	count   int
+	Dropped int64
+}
+
+// Allow 是否允许输出
+func (this_ *RateLimiter) Allow(now time.Time) bool {
+	if this_.MaxRate <= 0 {
+		return true
+	}
+	if second := now.Unix(); second != this_.second {
+		this_.second = second
+		this_.count = 0
+	}
+	if this_.count >= this_.MaxRate {
+		this_.Dropped++
+		return false
+	}
+	this_.count++
+	return true
+}
+
+var (
+	// lineTimeRegexp 行首附近的时间，如 2006-01-02 15:04:05.000、2006/01/02T15:04:05,000
+	lineTimeRegexp = regexp.MustCompile(`(\d{4})[-/](\d{2})[-/](\d{2})[ T](\d{2}):(\d{2}):(\d{2})(?:[.,](\d{1,9}))?`)
+)
+
+// lineTimeSearchSize 只在行首的部分内容中查找时间
+const lineTimeSearchSize = 64
+
+// ParseLineTime 解析行中的时间，按本地时区解析，没有时间时返回零值
+func ParseLineTime(line string) (t time.Time) {
+	if len(line) > lineTimeSearchSize {
+		line = line[:lineTimeSearchSize]
+	}
+	match := lineTimeRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return
+	}
+	value := match[1] + "-" + match[2] + "-" + match[3] + " " + match[4] + ":" + match[5] + ":" + match[6]
+	if match[7] != "" {
+		value += "." + match[7]
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05.999999999", value, time.Local)
+	if err != nil {
+		t = time.Time{}
+	}
+	return
+}
+
+// Line 输出的日志行
+type Line struct {
+	Source     string            `json:"source"`
+	Time       int64             `json:"time,omitempty"`
+	Text       string            `json:"text"`
+	Highlights []*HighlightRange `json:"highlights,omitempty"`
+}
+
+// Stamp 为一个来源的行设置时间，没有时间的行（如异常堆栈）沿用上一行的时间，last 为该来源上一行的时间
+func Stamp(lines []*Line, last int64) int64 {
+	for _, line := range lines {
+		if t := ParseLineTime(line.Text); !t.IsZero() {
+			last = t.UnixMilli()
+		}
+		line.Time = last
+	}
+	return last
+}
+
+// Merge 合并多个来源的行，按时间排序，时间相同时保持原有顺序，没有时间的行排在前面
+func Merge(sources ...[]*Line) (lines []*Line) {
+	for _, one := range sources {
+		lines = append(lines, one...)
+	}
+	if len(sources) < 2 {
+		return
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Time < lines[j].Time
+	})
+	return
+}
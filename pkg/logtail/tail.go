@@ -0,0 +1,260 @@
+package logtail
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"teamide/pkg/filework"
+	"teamide/pkg/terminal"
+	"time"
+)
+
+const (
+	// tailInitialSize 首次读取文件末尾的字节数
+	tailInitialSize = 64 * 1024
+	// tailReadLimit 单次读取的最大字节数
+	tailReadLimit = 512 * 1024
+	// tailHeadSize 用于判断轮转的文件开头字节数
+	tailHeadSize = 128
+	// tailLineLimit 单行最大长度，超出后直接作为一行输出
+	tailLineLimit = 64 * 1024
+)
+
+// Reader 读取日志文件
+type Reader interface {
+	// Size 获取文件大小
+	Size(path string) (size int64, err error)
+	// ReadAt 从 offset 开始读取最多 limit 字节
+	ReadAt(path string, offset int64, limit int) (bs []byte, err error)
+}
+
+// NewFileReader 基于文件服务读取，OpenReader 返回的文件需要支持 Seek（本地、SFTP）
+func NewFileReader(service filework.Service) Reader {
+	return &fileReader{service: service}
+}
+
+type fileReader struct {
+	service filework.Service
+}
+
+func (this_ *fileReader) Size(path string) (size int64, err error) {
+	file, err := this_.service.File(path)
+	if err != nil {
+		return
+	}
+	if file == nil || file.IsSham {
+		err = errors.New("文件[" + path + "]不存在")
+		return
+	}
+	if file.IsDir {
+		err = errors.New("[" + path + "]是目录")
+		return
+	}
+	size = file.Size
+	return
+}
+
+func (this_ *fileReader) ReadAt(path string, offset int64, limit int) (bs []byte, err error) {
+	reader, err := this_.service.OpenReader(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = reader.Close() }()
+
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		err = errors.New("文件[" + path + "]不支持随机读取")
+		return
+	}
+	if _, err = seeker.Seek(offset, io.SeekStart); err != nil {
+		return
+	}
+	bs = make([]byte, limit)
+	n, err := io.ReadFull(reader, bs)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	bs = bs[:n]
+	return
+}
+
+// ExecFunc 非交互执行命令
+type ExecFunc func(command string, timeout time.Duration) (res *terminal.ExecResult, err error)
+
+// NewExecReader 通过执行 wc、tail、head 命令读取，用于不支持随机读取的节点
+func NewExecReader(exec ExecFunc) Reader {
+	return &execReader{exec: exec}
+}
+
+type execReader struct {
+	exec ExecFunc
+}
+
+const execReaderTimeout = 30 * time.Second
+
+// shellQuote 单引号包裹，内部的单引号转义
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (this_ *execReader) run(command string) (stdout string, err error) {
+	res, err := this_.exec(command, execReaderTimeout)
+	if err != nil {
+		return
+	}
+	if res.ExitCode != 0 {
+		err = errors.New(strings.TrimSpace(res.Stderr))
+		if res.Stderr == "" {
+			err = errors.New("命令[" + command + "]执行失败，退出码:" + strconv.Itoa(res.ExitCode))
+		}
+		return
+	}
+	stdout = res.Stdout
+	return
+}
+
+func (this_ *execReader) Size(path string) (size int64, err error) {
+	stdout, err := this_.run("wc -c < " + shellQuote(path))
+	if err != nil {
+		return
+	}
+	size, err = strconv.ParseInt(strings.TrimSpace(stdout), 10, 64)
+	return
+}
+
+func (this_ *execReader) ReadAt(path string, offset int64, limit int) (bs []byte, err error) {
+	if limit > terminal.ExecOutputLimit {
+		limit = terminal.ExecOutputLimit
+	}
+	stdout, err := this_.run("tail -c +" + strconv.FormatInt(offset+1, 10) + " " + shellQuote(path) + " | head -c " + strconv.Itoa(limit))
+	if err != nil {
+		return
+	}
+	bs = []byte(stdout)
+	return
+}
+
+// NewTail 跟踪文件新增的行，首次读取文件末尾的部分内容
+func NewTail(reader Reader, path string) *Tail {
+	return &Tail{
+		Path:   path,
+		reader: reader,
+	}
+}
+
+// Tail 跟踪文件新增的行
+type Tail struct {
+	Path string
+
+	reader    Reader
+	started   bool
+	offset    int64
+	head      []byte
+	partial   []byte
+	skipFirst bool
+}
+
+// readHead 读取文件开头用于判断轮转
+func (this_ *Tail) readHead(size int64) (err error) {
+	limit := int64(tailHeadSize)
+	if size < limit {
+		limit = size
+	}
+	this_.head = nil
+	if limit == 0 {
+		return
+	}
+	this_.head, err = this_.reader.ReadAt(this_.Path, 0, int(limit))
+	return
+}
+
+// checkRotated 文件变小或开头内容变化时认为日志已轮转
+func (this_ *Tail) checkRotated(size int64) (rotated bool, err error) {
+	if size < this_.offset {
+		rotated = true
+		return
+	}
+	if size == this_.offset || len(this_.head) == 0 {
+		return
+	}
+	head, err := this_.reader.ReadAt(this_.Path, 0, len(this_.head))
+	if err != nil {
+		return
+	}
+	rotated = !bytes.Equal(head, this_.head)
+	return
+}
+
+// Poll 读取新增的完整行，日志轮转后从头开始读取
+func (this_ *Tail) Poll() (lines []string, rotated bool, err error) {
+	size, err := this_.reader.Size(this_.Path)
+	if err != nil {
+		return
+	}
+	if !this_.started {
+		this_.started = true
+		if size > tailInitialSize {
+			this_.offset = size - tailInitialSize
+			this_.skipFirst = true
+		}
+		if err = this_.readHead(size); err != nil {
+			return
+		}
+	} else {
+		rotated, err = this_.checkRotated(size)
+		if err != nil {
+			return
+		}
+		if rotated {
+			this_.offset = 0
+			this_.partial = nil
+			this_.skipFirst = false
+			if err = this_.readHead(size); err != nil {
+				return
+			}
+		} else if len(this_.head) < tailHeadSize && size > int64(len(this_.head)) {
+			if err = this_.readHead(size); err != nil {
+				return
+			}
+		}
+	}
+	if size <= this_.offset {
+		return
+	}
+	limit := size - this_.offset
+	if limit > tailReadLimit {
+		limit = tailReadLimit
+	}
+	bs, err := this_.reader.ReadAt(this_.Path, this_.offset, int(limit))
+	if err != nil {
+		return
+	}
+	this_.offset += int64(len(bs))
+
+	data := append(this_.partial, bs...)
+	this_.partial = nil
+	for {
+		index := bytes.IndexByte(data, '\n')
+		if index < 0 {
+			break
+		}
+		line := strings.TrimSuffix(string(data[:index]), "\r")
+		data = data[index+1:]
+		if this_.skipFirst {
+			// 从文件中间开始读取时，第一行不完整
+			this_.skipFirst = false
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(data) > tailLineLimit {
+		lines = append(lines, string(data))
+		data = nil
+	}
+	if len(data) > 0 {
+		this_.partial = append([]byte{}, data...)
+	}
+	return
+}
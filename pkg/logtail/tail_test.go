@@ -0,0 +1,88 @@
+package logtail
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"teamide/pkg/filework"
+	"testing"
+	"time"
+)
+
+func TestTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	write := func(flag int, content string) {
+		f, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = f.WriteString(content)
+		_ = f.Close()
+	}
+	write(os.O_TRUNC, "first line\nsecond")
+
+	tail := NewTail(NewFileReader(filework.NewLocalService()), path)
+	lines, rotated, err := tail.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated || strings.Join(lines, "|") != "first line" {
+		t.Fatal(lines)
+	}
+
+	write(os.O_APPEND, " line\r\nthird line\n")
+	lines, _, _ = tail.Poll()
+	if strings.Join(lines, "|") != "second line|third line" {
+		t.Fatal(lines)
+	}
+
+	// 轮转后文件变小
+	write(os.O_TRUNC, "new\n")
+	lines, rotated, _ = tail.Poll()
+	if !rotated || strings.Join(lines, "|") != "new" {
+		t.Fatal(rotated, lines)
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	matcher, err := NewMatcher([]string{"ERROR|WARN"}, []string{"health"}, []*Highlight{{Pattern: "ERROR", Color: "red"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matcher.Match("中文 ERROR x") || matcher.Match("INFO x") || matcher.Match("ERROR health check") {
+		t.Fatal("match")
+	}
+	ranges := matcher.Highlight("中文 ERROR x")
+	if len(ranges) != 1 || ranges[0].Start != 3 || ranges[0].End != 8 {
+		t.Fatal(ranges)
+	}
+	if _, err = NewMatcher([]string{"("}, nil, nil); err == nil {
+		t.Fatal("should error")
+	}
+
+	limiter := &RateLimiter{MaxRate: 2}
+	now := time.Now()
+	if !limiter.Allow(now) || !limiter.Allow(now) || limiter.Allow(now) || limiter.Dropped != 1 {
+		t.Fatal("rate")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := []*Line{
+		{Source: "a", Text: "2023-01-01 10:00:01.100 a1"},
+		{Source: "a", Text: "\tat stack"},
+		{Source: "a", Text: "2023-01-01 10:00:03 a2"},
+	}
+	b := []*Line{
+		{Source: "b", Text: "[2023/01/01T10:00:02,000] b1"},
+	}
+	Stamp(a, 0)
+	Stamp(b, 0)
+	var texts []string
+	for _, line := range Merge(a, b) {
+		texts = append(texts, line.Source+":"+line.Text[len(line.Text)-2:])
+	}
+	if strings.Join(texts, "|") != "a:a1|a:ck|b:b1|a:a2" {
+		t.Fatal(texts)
+	}
+}
@@ -0,0 +1,153 @@
+package metric
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+)
+
+const (
+	// AlertFiring 告警触发
+	AlertFiring = "firing"
+	// AlertResolved 告警恢复
+	AlertResolved = "resolved"
+)
+
+// AlertRule 告警规则，指标满足条件并持续 Duration 毫秒后触发
+type AlertRule struct {
+	RuleId    int64   `json:"ruleId"`
+	Name      string  `json:"name"`
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+	Duration  int64   `json:"duration"`
+}
+
+// Check 校验规则
+func (this_ *AlertRule) Check() (err error) {
+	if _, ok := (&Sample{}).Value(this_.Metric); !ok {
+		err = errors.New("不支持的指标[" + this_.Metric + "]")
+		return
+	}
+	switch this_.Operator {
+	case ">", ">=", "<", "<=":
+	default:
+		err = errors.New("不支持的比较方式[" + this_.Operator + "]")
+		return
+	}
+	if this_.Duration < 0 {
+		err = errors.New("持续时间不能小于0")
+		return
+	}
+	return
+}
+
+// Match 采样是否满足告警条件
+func (this_ *AlertRule) Match(sample *Sample) (value float64, matched bool) {
+	value, ok := this_.Value(sample)
+	if !ok {
+		return
+	}
+	switch this_.Operator {
+	case ">":
+		matched = value > this_.Threshold
+	case ">=":
+		matched = value >= this_.Threshold
+	case "<":
+		matched = value < this_.Threshold
+	case "<=":
+		matched = value <= this_.Threshold
+	}
+	return
+}
+
+// Value 获取规则指标的值
+func (this_ *AlertRule) Value(sample *Sample) (value float64, ok bool) {
+	return sample.Value(this_.Metric)
+}
+
+// AlertEvent 告警事件
+type AlertEvent struct {
+	Key       string  `json:"key"`
+	RuleId    int64   `json:"ruleId"`
+	RuleName  string  `json:"ruleName"`
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+	Value     float64 `json:"value"`
+	Status    string  `json:"status"`
+	// StartTime 条件开始满足的时间
+	StartTime int64 `json:"startTime"`
+	Time      int64 `json:"time"`
+}
+
+type alertState struct {
+	since  int64
+	firing bool
+}
+
+// Evaluator 按主机和规则记录告警状态，状态变化时产生事件
+type Evaluator struct {
+	states map[string]*alertState
+	lock   sync.Mutex
+}
+
+// NewEvaluator 创建告警计算
+func NewEvaluator() *Evaluator {
+	return &Evaluator{
+		states: map[string]*alertState{},
+	}
+}
+
+// Evaluate 计算采样，key 为主机标识，触发或恢复时返回事件
+func (this_ *Evaluator) Evaluate(key string, rule *AlertRule, sample *Sample) (event *AlertEvent) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	stateKey := key + "/" + strconv.FormatInt(rule.RuleId, 10)
+	state := this_.states[stateKey]
+	value, matched := rule.Match(sample)
+	newEvent := func(status string) *AlertEvent {
+		return &AlertEvent{
+			Key:       key,
+			RuleId:    rule.RuleId,
+			RuleName:  rule.Name,
+			Metric:    rule.Metric,
+			Operator:  rule.Operator,
+			Threshold: rule.Threshold,
+			Value:     value,
+			Status:    status,
+			StartTime: state.since,
+			Time:      sample.Time,
+		}
+	}
+	if !matched {
+		if state != nil && state.firing {
+			event = newEvent(AlertResolved)
+		}
+		delete(this_.states, stateKey)
+		return
+	}
+	if state == nil {
+		state = &alertState{since: sample.Time}
+		this_.states[stateKey] = state
+	}
+	if !state.firing && sample.Time-state.since >= rule.Duration {
+		state.firing = true
+		event = newEvent(AlertFiring)
+	}
+	return
+}
+
+// Remove 删除规则的所有状态
+func (this_ *Evaluator) Remove(ruleId int64) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	suffix := "/" + strconv.FormatInt(ruleId, 10)
+	for key := range this_.states {
+		if len(key) > len(suffix) && key[len(key)-len(suffix):] == suffix {
+			delete(this_.states, key)
+		}
+	}
+}
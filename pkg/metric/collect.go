@@ -0,0 +1,206 @@
+package metric
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"teamide/pkg/terminal"
+	"time"
+)
+
+// ExecFunc 非交互执行命令
+type ExecFunc func(command string, timeout time.Duration) (res *terminal.ExecResult, err error)
+
+const (
+	// execTimeout 执行采集命令的超时时间
+	execTimeout = 30 * time.Second
+	// collectSeconds 两次读取计数器的间隔，用于计算 CPU 使用率和速率
+	collectSeconds = 1
+)
+
+// collectScript 间隔 1 秒两次读取 CPU、网卡、磁盘计数器，输出以 @@ 开头的行分段
+const collectScript = `echo @@cpu1; head -n1 /proc/stat
+echo @@net1; cat /proc/net/dev
+echo @@disk1; cat /proc/diskstats 2>/dev/null
+sleep 1
+echo @@cpu2; head -n1 /proc/stat
+echo @@net2; cat /proc/net/dev
+echo @@disk2; cat /proc/diskstats 2>/dev/null
+echo @@block; ls /sys/block 2>/dev/null
+echo @@meminfo; cat /proc/meminfo
+echo @@df; df -P -k 2>/dev/null
+echo @@end
+`
+
+// CollectExec 通过执行命令读取 /proc 采集，用于 SSH 等只能执行命令的主机，只支持 Linux
+func CollectExec(exec ExecFunc) (sample *Sample, err error) {
+	startTime := time.Now().UnixMilli()
+	res, err := exec(collectScript, execTimeout)
+	if err != nil {
+		return
+	}
+	if !strings.Contains(res.Stdout, "@@end") {
+		err = errors.New("采集失败，只支持 Linux 系统:" + strings.TrimSpace(res.Stderr))
+		return
+	}
+	sample = parseCollect(splitSections(res.Stdout))
+	sample.Time = startTime
+	return
+}
+
+// splitSections 按 @@ 开头的行分段
+func splitSections(stdout string) (sections map[string]string) {
+	sections = map[string]string{}
+	var name string
+	var builder strings.Builder
+	for _, line := range strings.SplitAfter(stdout, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			if name != "" {
+				sections[name] = builder.String()
+			}
+			name = strings.TrimSpace(line[2:])
+			builder.Reset()
+			continue
+		}
+		builder.WriteString(line)
+	}
+	if name != "" {
+		sections[name] = builder.String()
+	}
+	return
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v
+}
+
+// parseCpu 解析 /proc/stat 第一行，返回总时间和空闲时间（idle + iowait）
+func parseCpu(text string) (total float64, idle float64) {
+	fields := strings.Fields(text)
+	for i := 1; i < len(fields); i++ {
+		v := parseFloat(fields[i])
+		total += v
+		if i == 4 || i == 5 {
+			idle += v
+		}
+	}
+	return
+}
+
+// parseNet 解析 /proc/net/dev，返回接收、发送的总字节数
+func parseNet(text string) (recv float64, sent float64) {
+	for _, line := range strings.Split(text, "\n") {
+		index := strings.IndexByte(line, ':')
+		if index < 0 {
+			continue
+		}
+		if isVirtualNet(strings.TrimSpace(line[:index])) {
+			continue
+		}
+		fields := strings.Fields(line[index+1:])
+		if len(fields) < 9 {
+			continue
+		}
+		recv += parseFloat(fields[0])
+		sent += parseFloat(fields[8])
+	}
+	return
+}
+
+// parseDisk 解析 /proc/diskstats，只统计 /sys/block 中的整块磁盘，返回读、写的总字节数（扇区为 512 字节）
+func parseDisk(text string, blocks map[string]bool) (read float64, write float64) {
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+		name := fields[2]
+		if !blocks[name] || strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			continue
+		}
+		read += parseFloat(fields[5]) * 512
+		write += parseFloat(fields[9]) * 512
+	}
+	return
+}
+
+// parseMem 根据 MemTotal、MemAvailable 计算内存使用率
+func parseMem(text string) (usage float64) {
+	var total, available float64
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = parseFloat(fields[1])
+		case "MemAvailable:":
+			available = parseFloat(fields[1])
+		}
+	}
+	if total > 0 {
+		usage = round((total - available) / total * 100)
+	}
+	return
+}
+
+// parseDf 解析 df -P，忽略内存文件系统和 loop 设备，返回使用率最高的文件系统的使用率
+func parseDf(text string) (usage float64) {
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		switch fields[0] {
+		case "tmpfs", "devtmpfs", "overlay", "shm", "udev", "none", "Filesystem":
+			continue
+		}
+		if strings.HasPrefix(fields[0], "/dev/loop") {
+			continue
+		}
+		used := parseFloat(fields[2])
+		available := parseFloat(fields[3])
+		if used+available <= 0 {
+			continue
+		}
+		if v := used / (used + available) * 100; v > usage {
+			usage = v
+		}
+	}
+	usage = round(usage)
+	return
+}
+
+func parseCollect(sections map[string]string) (sample *Sample) {
+	sample = &Sample{Count: 1}
+
+	total1, idle1 := parseCpu(sections["cpu1"])
+	total2, idle2 := parseCpu(sections["cpu2"])
+	if total2 > total1 {
+		sample.Cpu = round((1 - (idle2-idle1)/(total2-total1)) * 100)
+	}
+
+	recv1, sent1 := parseNet(sections["net1"])
+	recv2, sent2 := parseNet(sections["net2"])
+	if recv2 >= recv1 && sent2 >= sent1 {
+		sample.NetRecv = (recv2 - recv1) / collectSeconds
+		sample.NetSent = (sent2 - sent1) / collectSeconds
+	}
+
+	blocks := map[string]bool{}
+	for _, name := range strings.Fields(sections["block"]) {
+		blocks[name] = true
+	}
+	read1, write1 := parseDisk(sections["disk1"], blocks)
+	read2, write2 := parseDisk(sections["disk2"], blocks)
+	if read2 >= read1 && write2 >= write1 {
+		sample.DiskRead = (read2 - read1) / collectSeconds
+		sample.DiskWrite = (write2 - write1) / collectSeconds
+	}
+
+	sample.Mem = parseMem(sections["meminfo"])
+	sample.Disk = parseDf(sections["df"])
+	return
+}
@@ -0,0 +1,64 @@
+package metric
+
+import (
+	"runtime"
+	"teamide/pkg/system"
+	"teamide/pkg/terminal"
+	"testing"
+)
+
+func TestDownsample(t *testing.T) {
+	list := Downsample([]*Sample{
+		{Time: 61000, Cpu: 10, Count: 1},
+		{Time: 1000, Cpu: 20, Count: 1},
+		{Time: 30000, Cpu: 50, Count: 3},
+	}, 60000)
+	if len(list) != 2 || list[0].Time != 0 || list[0].Cpu != 42.5 || list[0].Count != 4 || list[1].Time != 60000 {
+		t.Fatal(list)
+	}
+
+	sample := FromMonitorData(&system.MonitorData{
+		CpuPercents:        []float64{10, 30},
+		NetIOCountersStats: []*system.NetIOCountersStat{{Name: "lo", SpeedRecv: 100}, {Name: "eth0", SpeedRecv: 10}},
+	}, []*system.DiskUsageStat{{Total: 10, UsedPercent: 30}, {Total: 10, UsedPercent: 80}})
+	if sample.Cpu != 20 || sample.NetRecv != 10 || sample.Disk != 80 {
+		t.Fatal(sample)
+	}
+}
+
+func TestEvaluator(t *testing.T) {
+	rule := &AlertRule{RuleId: 1, Metric: "cpu", Operator: ">", Threshold: 80, Duration: 60000}
+	if err := rule.Check(); err != nil {
+		t.Fatal(err)
+	}
+	evaluator := NewEvaluator()
+	if e := evaluator.Evaluate("h", rule, &Sample{Time: 0, Cpu: 90}); e != nil {
+		t.Fatal(e)
+	}
+	e := evaluator.Evaluate("h", rule, &Sample{Time: 60000, Cpu: 95})
+	if e == nil || e.Status != AlertFiring || e.StartTime != 0 {
+		t.Fatal(e)
+	}
+	if e = evaluator.Evaluate("h", rule, &Sample{Time: 90000, Cpu: 99}); e != nil {
+		t.Fatal(e)
+	}
+	if e = evaluator.Evaluate("h", rule, &Sample{Time: 120000, Cpu: 10}); e == nil || e.Status != AlertResolved {
+		t.Fatal(e)
+	}
+	if err := (&AlertRule{Metric: "x", Operator: ">"}).Check(); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCollectExec(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only linux")
+	}
+	sample, err := CollectExec(terminal.Exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.Mem <= 0 || sample.Time == 0 {
+		t.Fatal(sample)
+	}
+}
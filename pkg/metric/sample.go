@@ -0,0 +1,178 @@
+package metric
+
+import (
+	"sort"
+	"strings"
+	"teamide/pkg/system"
+)
+
+const (
+	// LevelRaw 原始采样
+	LevelRaw = 0
+	// LevelMinute 按分钟聚合
+	LevelMinute = 1
+	// LevelHour 按小时聚合
+	LevelHour = 2
+)
+
+// LevelBucket 各聚合级别的时间跨度，毫秒
+var LevelBucket = map[int]int64{
+	LevelMinute: 60 * 1000,
+	LevelHour:   60 * 60 * 1000,
+}
+
+// Sample 主机指标采样，使用率为百分比，速率为每秒字节数
+type Sample struct {
+	// Time 采样时间，聚合后为时间段的开始时间，毫秒
+	Time int64   `json:"time"`
+	Cpu  float64 `json:"cpu"`
+	Mem  float64 `json:"mem"`
+	// Disk 使用率最高的文件系统的使用率
+	Disk      float64 `json:"disk"`
+	NetRecv   float64 `json:"netRecv"`
+	NetSent   float64 `json:"netSent"`
+	DiskRead  float64 `json:"diskRead"`
+	DiskWrite float64 `json:"diskWrite"`
+	// Count 聚合的原始采样数
+	Count int `json:"count"`
+}
+
+// Metrics 可以设置告警的指标
+var Metrics = []string{"cpu", "mem", "disk", "netRecv", "netSent", "diskRead", "diskWrite"}
+
+// Value 获取指标的值
+func (this_ *Sample) Value(metric string) (value float64, ok bool) {
+	ok = true
+	switch metric {
+	case "cpu":
+		value = this_.Cpu
+	case "mem":
+		value = this_.Mem
+	case "disk":
+		value = this_.Disk
+	case "netRecv":
+		value = this_.NetRecv
+	case "netSent":
+		value = this_.NetSent
+	case "diskRead":
+		value = this_.DiskRead
+	case "diskWrite":
+		value = this_.DiskWrite
+	default:
+		ok = false
+	}
+	return
+}
+
+// isVirtualNet 回环和容器的虚拟网卡不计入网络流量
+func isVirtualNet(name string) bool {
+	return name == "lo" || strings.HasPrefix(name, "veth") || strings.HasPrefix(name, "docker") ||
+		strings.HasPrefix(name, "br-") || strings.HasPrefix(name, "Loopback")
+}
+
+// FromMonitorData 根据监控数据和磁盘使用情况生成采样
+func FromMonitorData(data *system.MonitorData, disks []*system.DiskUsageStat) (sample *Sample) {
+	sample = &Sample{
+		Time:  data.StartTime,
+		Count: 1,
+	}
+	if len(data.CpuPercents) > 0 {
+		var total float64
+		for _, one := range data.CpuPercents {
+			total += one
+		}
+		sample.Cpu = round(total / float64(len(data.CpuPercents)))
+	}
+	if data.VirtualMemoryStat != nil {
+		sample.Mem = round(data.VirtualMemoryStat.UsedPercent)
+	}
+	for _, one := range data.NetIOCountersStats {
+		if isVirtualNet(one.Name) {
+			continue
+		}
+		sample.NetRecv += float64(one.SpeedRecv)
+		sample.NetSent += float64(one.SpeedSent)
+	}
+	for _, one := range data.DiskIOCountersStats {
+		sample.DiskRead += float64(one.ReadBytesSpeed)
+		sample.DiskWrite += float64(one.WriteBytesSpeed)
+	}
+	sample.Disk = maxDiskUsage(disks)
+	return
+}
+
+// ignoreFstypes 内存、只读镜像等文件系统不计入磁盘使用率
+var ignoreFstypes = map[string]bool{
+	"tmpfs": true, "devtmpfs": true, "overlay": true, "squashfs": true, "iso9660": true,
+	"proc": true, "sysfs": true, "cgroup": true, "cgroup2": true, "devfs": true, "autofs": true,
+}
+
+func maxDiskUsage(disks []*system.DiskUsageStat) (usage float64) {
+	for _, one := range disks {
+		if one.Total == 0 || ignoreFstypes[one.Fstype] {
+			continue
+		}
+		if one.UsedPercent > usage {
+			usage = one.UsedPercent
+		}
+	}
+	usage = round(usage)
+	return
+}
+
+func round(v float64) float64 {
+	return float64(int64(v*100+0.5)) / 100
+}
+
+// Downsample 按时间段聚合采样，各指标按原始采样数加权平均，结果按时间排序
+func Downsample(list []*Sample, bucket int64) (res []*Sample) {
+	cache := map[int64]*Sample{}
+	for _, one := range list {
+		count := one.Count
+		if count <= 0 {
+			count = 1
+		}
+		start := one.Time - one.Time%bucket
+		find := cache[start]
+		if find == nil {
+			find = &Sample{Time: start}
+			cache[start] = find
+			res = append(res, find)
+		}
+		weight := float64(count)
+		find.Cpu += one.Cpu * weight
+		find.Mem += one.Mem * weight
+		find.Disk += one.Disk * weight
+		find.NetRecv += one.NetRecv * weight
+		find.NetSent += one.NetSent * weight
+		find.DiskRead += one.DiskRead * weight
+		find.DiskWrite += one.DiskWrite * weight
+		find.Count += count
+	}
+	for _, one := range res {
+		count := float64(one.Count)
+		one.Cpu = round(one.Cpu / count)
+		one.Mem = round(one.Mem / count)
+		one.Disk = round(one.Disk / count)
+		one.NetRecv = round(one.NetRecv / count)
+		one.NetSent = round(one.NetSent / count)
+		one.DiskRead = round(one.DiskRead / count)
+		one.DiskWrite = round(one.DiskWrite / count)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Time < res[j].Time
+	})
+	return
+}
+
+// ChooseLevel 根据查询的时间跨度选择聚合级别，6 小时内使用原始采样，7 天内按分钟，超过按小时
+func ChooseLevel(startTime int64, endTime int64) int {
+	span := endTime - startTime
+	switch {
+	case span <= 6*60*60*1000:
+		return LevelRaw
+	case span <= 7*24*60*60*1000:
+		return LevelMinute
+	}
+	return LevelHour
+}
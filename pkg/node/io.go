@@ -11,6 +11,7 @@ import (
 	"sync"
 	"teamide/pkg/filework"
 	"teamide/pkg/system"
+	"teamide/pkg/telnet"
 	"teamide/pkg/terminal"
 )
 
@@ -42,6 +43,11 @@ type ConnData struct {
 	NodeId     string   `json:"nodeId,omitempty"`
 	NodeToken  string   `json:"nodeToken,omitempty"`
 	NodeIdList []string `json:"nodeIdList,omitempty"`
+	// Protocol 连接方发送支持的最高协议版本，被连接方返回协商后的版本，为空时为旧协议
+	Protocol int `json:"protocol,omitempty"`
+	// CompressList 连接方支持的压缩方式，Compress 为被连接方选择的压缩方式
+	CompressList []string `json:"compressList,omitempty"`
+	Compress     string   `json:"compress,omitempty"`
 }
 
 type SystemData struct {
@@ -60,11 +66,14 @@ type WorkData struct {
 	Version     string       `json:"version,omitempty"`
 	MonitorData *MonitorData `json:"monitorData,omitempty"`
 	Status      int8         `json:"status,omitempty"`
+	LinkList    []*NodeLink  `json:"linkList,omitempty"`
 }
 
 type NetProxyWorkData struct {
-	NetProxyId        string           `json:"netProxyId,omitempty"`
-	ConnId            string           `json:"connId,omitempty"`
+	NetProxyId string `json:"netProxyId,omitempty"`
+	ConnId     string `json:"connId,omitempty"`
+	// Address 新建连接时由 SOCKS5、HTTP 代理指定的目标地址
+	Address           string           `json:"address,omitempty"`
 	IsReverse         bool             `json:"isReverse,omitempty"`
 	MonitorData       *MonitorData     `json:"monitorData,omitempty"`
 	NetProxyInnerList []*NetProxyInner `json:"netProxyInnerList,omitempty"`
@@ -91,6 +100,13 @@ type TerminalWorkData struct {
 	ReadKey   string         `json:"readKey,omitempty"`
 	Size      *terminal.Size `json:"size,omitempty"`
 	IsWindows bool           `json:"isWindows,omitempty"`
+
+	Command    string               `json:"command,omitempty"`
+	Timeout    int64                `json:"timeout,omitempty"` // 毫秒
+	ExecResult *terminal.ExecResult `json:"execResult,omitempty"`
+
+	// Telnet 不为空时在节点上连接 Telnet，而不是启动本地终端
+	Telnet *telnet.Config `json:"telnet,omitempty"`
 }
 
 type StatusChange struct {
@@ -129,11 +145,21 @@ type MessageListener struct {
 	isClose   bool
 	isStop    bool
 	writeMu   sync.Mutex
+	// mux 协商为版本 2 时不为空
+	mux *muxConn
 }
 
 func (this_ *MessageListener) stop() {
 	this_.isStop = true
 	_ = this_.conn.Close()
+	if this_.mux != nil {
+		this_.mux.close()
+	}
+}
+
+// isMux 是否为多路复用连接，流消息按顺序处理，可以不等待结果
+func (this_ *MessageListener) isMux() bool {
+	return this_.mux != nil
 }
 
 func (this_ *MessageListener) listen(onClose func(), MonitorData *MonitorData) {
@@ -146,6 +172,9 @@ func (this_ *MessageListener) listen(onClose func(), MonitorData *MonitorData) {
 				Logger.Error("message listen error", zap.Error(err))
 			}
 			_ = this_.conn.Close()
+			if this_.mux != nil {
+				this_.mux.close()
+			}
 			onClose()
 		}()
 
@@ -154,7 +183,13 @@ func (this_ *MessageListener) listen(onClose func(), MonitorData *MonitorData) {
 				return
 			}
 			var msg *Message
-			msg, err = ReadMessage(this_.conn, MonitorData)
+			var streamId uint32
+			var size int
+			if this_.mux != nil {
+				msg, streamId, size, err = this_.mux.readMessage()
+			} else {
+				msg, err = ReadMessage(this_.conn, MonitorData)
+			}
 			if err != nil {
 				if this_.isStop {
 					return
@@ -165,6 +200,10 @@ func (this_ *MessageListener) listen(onClose func(), MonitorData *MonitorData) {
 				return
 			}
 			msg.listener = this_
+			if streamId != 0 {
+				this_.mux.dispatch(streamId, size, msg, this_.onMessage)
+				continue
+			}
 			go this_.onMessage(msg)
 		}
 	}()
@@ -178,6 +217,10 @@ func (this_ *MessageListener) Send(msg *Message, MonitorData *MonitorData) (err
 		err = ConnClosedError
 		return
 	}
+	if this_.mux != nil {
+		err = this_.mux.writeMessage(msg)
+		return
+	}
 	this_.writeMu.Lock()
 	defer this_.writeMu.Unlock()
 	err = WriteMessage(this_.conn, msg, MonitorData)
@@ -231,17 +274,13 @@ func ReadBytes(reader io.Reader, MonitorData *MonitorData) (bytes []byte, err er
 	start := util.GetNow().UnixNano()
 
 	var buf []byte
-	var n int
 
+	// 网络较慢时一次读取可能不完整，需要读满
 	buf = make([]byte, 4)
-	n, err = reader.Read(buf)
+	_, err = io.ReadFull(reader, buf)
 	if err != nil {
 		return
 	}
-	if n < 4 {
-		err = LengthError
-		return
-	}
 
 	length := int(binary.LittleEndian.Uint32(buf))
 	if length < 0 {
@@ -250,23 +289,10 @@ func ReadBytes(reader io.Reader, MonitorData *MonitorData) (bytes []byte, err er
 	}
 
 	if length > 0 {
-		var hasLen = length
-		for {
-
-			bs := make([]byte, hasLen)
-			n, err = reader.Read(bs)
-			if err != nil && err != io.EOF {
-				return
-			}
-			hasLen = hasLen - n
-			bytes = append(bytes, bs[0:n]...)
-			//Logger.Info("ReadBytes", zap.Any("should read", length), zap.Any("has size", hasLen))
-			if hasLen <= 0 {
-				break
-			}
-			if err == io.EOF {
-				break
-			}
+		bytes = make([]byte, length)
+		_, err = io.ReadFull(reader, bytes)
+		if err != nil {
+			return
 		}
 	}
 	end := util.GetNow().UnixNano()
@@ -0,0 +1,480 @@
+package node
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/team-ide/go-tool/util"
+	"hash/crc32"
+	"io"
+	"net"
+	"sync"
+)
+
+// 节点协议版本，在连接握手的 ConnData 中协商，旧节点不识别协商字段时使用版本 1
+// 版本 1：每个消息 JSON 编码，附带的字节流单独作为一帧，均为 4 字节长度前缀
+// 版本 2：二进制帧，消息和字节流合并为一帧并可压缩，流数据按流分片、排序并做流量控制，多路复用在少量连接上
+const (
+	protocolLegacy  = 1
+	protocolMux     = 2
+	protocolVersion = protocolMux
+)
+
+const (
+	compressZstd   = "zstd"
+	compressSnappy = "snappy"
+)
+
+// 帧头：版本 1 字节、类型 1 字节、标识 1 字节、流编号 4 字节、长度 4 字节
+const (
+	frameHeaderSize = 11
+
+	frameTypeMessage byte = 1
+	frameTypeWindow  byte = 2
+
+	// frameFlagMore 消息未结束，后续帧为同一消息的分片
+	frameFlagMore byte = 1
+	// frameFlagCompressed 消息已压缩
+	frameFlagCompressed byte = 2
+)
+
+var (
+	// supportCompressList 支持的压缩方式，按优先级排序
+	supportCompressList = []string{compressZstd, compressSnappy}
+	// muxConnSize 多路复用时与每个节点保持的连接数
+	muxConnSize = 1
+	// muxFragmentSize 流消息分片大小，避免大消息阻塞其它流
+	muxFragmentSize = 16 * 1024
+	// muxStreamWindow 每个流未处理完成的数据上限，超过后发送方等待
+	muxStreamWindow int64 = 1024 * 1024
+	// muxMaxMessageSize 单个消息最大长度
+	muxMaxMessageSize = 128 * 1024 * 1024
+	// muxCompressMinSize 小于该长度的消息不压缩
+	muxCompressMinSize = 256
+
+	FrameVersionError = errors.New("节点协议帧版本错误")
+)
+
+// chooseCompress 按客户端优先级选择本节点也支持的压缩方式，没有时不压缩
+func chooseCompress(compressList []string) string {
+	for _, one := range compressList {
+		for _, support := range supportCompressList {
+			if one == support {
+				return one
+			}
+		}
+	}
+	return ""
+}
+
+type compressor interface {
+	encode(src []byte) []byte
+	decode(src []byte) ([]byte, error)
+}
+
+func getCompressor(name string) compressor {
+	switch name {
+	case compressZstd:
+		return &zstdCompressor{}
+	case compressSnappy:
+		return &snappyCompressor{}
+	}
+	return nil
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+// zstdCompressor 每个消息独立压缩，编码器、解码器并发安全，全局共用
+type zstdCompressor struct {
+}
+
+func (this_ *zstdCompressor) init() {
+	zstdOnce.Do(func() {
+		zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+		zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(muxMaxMessageSize)), zstd.WithDecoderConcurrency(0))
+	})
+}
+
+func (this_ *zstdCompressor) encode(src []byte) []byte {
+	this_.init()
+	return zstdEncoder.EncodeAll(src, nil)
+}
+
+func (this_ *zstdCompressor) decode(src []byte) ([]byte, error) {
+	this_.init()
+	return zstdDecoder.DecodeAll(src, nil)
+}
+
+type snappyCompressor struct {
+}
+
+func (this_ *snappyCompressor) encode(src []byte) []byte {
+	return snappy.Encode(nil, src)
+}
+
+func (this_ *snappyCompressor) decode(src []byte) (bs []byte, err error) {
+	size, err := snappy.DecodedLen(src)
+	if err != nil {
+		return
+	}
+	if size > muxMaxMessageSize {
+		err = LengthError
+		return
+	}
+	return snappy.Decode(nil, src)
+}
+
+// getStreamKey 需要按顺序处理的消息所属的流，返回结果的消息没有 Method，不属于任何流
+func (this_ *Message) getStreamKey() string {
+	switch this_.Method {
+	case methodNetProxyNewConn, methodNetProxyCloseConn, methodNetProxySend:
+		if this_.NetProxyWorkData != nil {
+			return this_.NetProxyWorkData.ConnId
+		}
+	case methodSendBytesStart, methodSendBytes, methodSendBytesEnd:
+		return this_.SendKey
+	}
+	return ""
+}
+
+func getStreamId(key string) (streamId uint32) {
+	if key == "" {
+		return
+	}
+	streamId = crc32.ChecksumIEEE([]byte(key))
+	if streamId == 0 {
+		streamId = 1
+	}
+	return
+}
+
+// encodeMessage JSON 长度 4 字节、JSON、字节流
+func encodeMessage(message *Message) (data []byte, err error) {
+	bs, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	size := 4 + len(bs)
+	if message.HasBytes {
+		size += len(message.Bytes)
+	}
+	data = make([]byte, 4, size)
+	binary.LittleEndian.PutUint32(data, uint32(len(bs)))
+	data = append(data, bs...)
+	if message.HasBytes {
+		data = append(data, message.Bytes...)
+	}
+	return
+}
+
+func decodeMessage(data []byte) (message *Message, err error) {
+	if len(data) < 4 {
+		err = LengthError
+		return
+	}
+	jsonSize := int(binary.LittleEndian.Uint32(data))
+	if jsonSize > len(data)-4 {
+		err = LengthError
+		return
+	}
+	message = &Message{}
+	err = json.Unmarshal(data[4:4+jsonSize], message)
+	if err != nil {
+		return
+	}
+	if message.HasBytes {
+		message.Bytes = data[4+jsonSize:]
+	}
+	return
+}
+
+// muxWindow 发送方流的剩余窗口，writing 保证同一流的消息分片连续发送
+type muxWindow struct {
+	avail   int64
+	writing bool
+}
+
+type muxRecvMessage struct {
+	message *Message
+	size    int
+}
+
+// muxRecvStream 接收方的流，消息按接收顺序处理，处理完成后归还窗口
+type muxRecvStream struct {
+	queue   []*muxRecvMessage
+	running bool
+}
+
+// muxConn 版本 2 的连接，无流的消息（请求结果、控制消息等）不分片、不做流量控制，与旧协议一样并发处理
+type muxConn struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	compressor  compressor
+	MonitorData *MonitorData
+	writeMu     sync.Mutex
+
+	windowLock sync.Mutex
+	windowCond *sync.Cond
+	windows    map[uint32]*muxWindow
+	isClose    bool
+
+	recvLock    sync.Mutex
+	recvStreams map[uint32]*muxRecvStream
+	// fragments 未接收完成的分片，只在读取协程中使用
+	fragments map[uint32][]byte
+}
+
+func newMuxConn(conn net.Conn, compress string, MonitorData *MonitorData) *muxConn {
+	res := &muxConn{
+		conn:        conn,
+		reader:      bufio.NewReaderSize(conn, 64*1024),
+		compressor:  getCompressor(compress),
+		MonitorData: MonitorData,
+		windows:     make(map[uint32]*muxWindow),
+		recvStreams: make(map[uint32]*muxRecvStream),
+		fragments:   make(map[uint32][]byte),
+	}
+	res.windowCond = sync.NewCond(&res.windowLock)
+	return res
+}
+
+// close 唤醒等待窗口的发送方
+func (this_ *muxConn) close() {
+	this_.windowLock.Lock()
+	defer this_.windowLock.Unlock()
+	this_.isClose = true
+	this_.windowCond.Broadcast()
+}
+
+func (this_ *muxConn) writeFrame(frameType byte, flags byte, streamId uint32, payload []byte) (err error) {
+	buf := make([]byte, frameHeaderSize+len(payload))
+	buf[0] = protocolVersion
+	buf[1] = frameType
+	buf[2] = flags
+	binary.LittleEndian.PutUint32(buf[3:], streamId)
+	binary.LittleEndian.PutUint32(buf[7:], uint32(len(payload)))
+	copy(buf[frameHeaderSize:], payload)
+
+	this_.writeMu.Lock()
+	defer this_.writeMu.Unlock()
+
+	start := util.GetNow().UnixNano()
+	_, err = this_.conn.Write(buf)
+	if err != nil {
+		return
+	}
+	end := util.GetNow().UnixNano()
+	this_.MonitorData.monitorWrite(int64(len(buf)), end-start)
+	return
+}
+
+// acquire 等待流窗口可用，窗口可以透支一个消息，避免大于窗口的消息无法发送
+func (this_ *muxConn) acquire(streamId uint32, size int) (err error) {
+	this_.windowLock.Lock()
+	defer this_.windowLock.Unlock()
+
+	window := this_.windows[streamId]
+	if window == nil {
+		window = &muxWindow{avail: muxStreamWindow}
+		this_.windows[streamId] = window
+	}
+	for !this_.isClose && (window.writing || window.avail <= 0) {
+		this_.windowCond.Wait()
+	}
+	if this_.isClose {
+		err = ConnClosedError
+		return
+	}
+	window.writing = true
+	window.avail -= int64(size)
+	return
+}
+
+func (this_ *muxConn) release(streamId uint32) {
+	this_.windowLock.Lock()
+	defer this_.windowLock.Unlock()
+
+	window := this_.windows[streamId]
+	if window == nil {
+		return
+	}
+	window.writing = false
+	if window.avail >= muxStreamWindow {
+		delete(this_.windows, streamId)
+	}
+	this_.windowCond.Broadcast()
+}
+
+// onWindow 接收方处理完成后归还窗口，窗口恢复且没有发送中的消息时移除
+func (this_ *muxConn) onWindow(streamId uint32, size int64) {
+	this_.windowLock.Lock()
+	defer this_.windowLock.Unlock()
+
+	window := this_.windows[streamId]
+	if window == nil {
+		return
+	}
+	window.avail += size
+	if !window.writing && window.avail >= muxStreamWindow {
+		delete(this_.windows, streamId)
+	}
+	this_.windowCond.Broadcast()
+}
+
+func (this_ *muxConn) writeMessage(message *Message) (err error) {
+	data, err := encodeMessage(message)
+	if err != nil {
+		return
+	}
+	var flags byte
+	if this_.compressor != nil && len(data) >= muxCompressMinSize {
+		// 已压缩或加密的数据压缩后可能更大，此时发送原数据
+		if bs := this_.compressor.encode(data); len(bs) < len(data) {
+			data = bs
+			flags |= frameFlagCompressed
+		}
+	}
+	if len(data) > muxMaxMessageSize {
+		err = LengthError
+		return
+	}
+	streamId := getStreamId(message.getStreamKey())
+	if streamId == 0 {
+		err = this_.writeFrame(frameTypeMessage, flags, 0, data)
+		return
+	}
+
+	if err = this_.acquire(streamId, len(data)); err != nil {
+		return
+	}
+	defer this_.release(streamId)
+	for {
+		fragment := data
+		fragmentFlags := flags
+		if len(fragment) > muxFragmentSize {
+			fragment = data[:muxFragmentSize]
+			fragmentFlags |= frameFlagMore
+		}
+		if err = this_.writeFrame(frameTypeMessage, fragmentFlags, streamId, fragment); err != nil {
+			return
+		}
+		data = data[len(fragment):]
+		if len(data) == 0 {
+			return
+		}
+	}
+}
+
+// readMessage 读取下一个完整的消息，窗口帧在读取中处理，size 为需要归还的窗口大小
+func (this_ *muxConn) readMessage() (message *Message, streamId uint32, size int, err error) {
+	header := make([]byte, frameHeaderSize)
+	for {
+		start := util.GetNow().UnixNano()
+		if _, err = io.ReadFull(this_.reader, header); err != nil {
+			return
+		}
+		if header[0] != protocolVersion {
+			err = FrameVersionError
+			return
+		}
+		frameType := header[1]
+		flags := header[2]
+		streamId = binary.LittleEndian.Uint32(header[3:])
+		length := int(binary.LittleEndian.Uint32(header[7:]))
+		if length > muxMaxMessageSize {
+			err = LengthError
+			return
+		}
+		payload := make([]byte, length)
+		if _, err = io.ReadFull(this_.reader, payload); err != nil {
+			return
+		}
+		end := util.GetNow().UnixNano()
+		this_.MonitorData.monitorRead(int64(frameHeaderSize+length), end-start)
+
+		switch frameType {
+		case frameTypeWindow:
+			if length == 4 {
+				this_.onWindow(streamId, int64(binary.LittleEndian.Uint32(payload)))
+			}
+			continue
+		case frameTypeMessage:
+		default:
+			err = errors.New(fmt.Sprint("节点协议帧类型[", frameType, "]不支持"))
+			return
+		}
+
+		data := payload
+		if partial, ok := this_.fragments[streamId]; ok {
+			data = append(partial, payload...)
+			if len(data) > muxMaxMessageSize {
+				err = LengthError
+				return
+			}
+		}
+		if flags&frameFlagMore != 0 {
+			this_.fragments[streamId] = data
+			continue
+		}
+		delete(this_.fragments, streamId)
+		size = len(data)
+
+		if flags&frameFlagCompressed != 0 {
+			if this_.compressor == nil {
+				err = errors.New("节点协议未协商压缩方式")
+				return
+			}
+			if data, err = this_.compressor.decode(data); err != nil {
+				return
+			}
+		}
+		message, err = decodeMessage(data)
+		return
+	}
+}
+
+// dispatch 流消息按顺序处理，处理完成后归还窗口
+func (this_ *muxConn) dispatch(streamId uint32, size int, message *Message, onMessage func(msg *Message)) {
+	this_.recvLock.Lock()
+	defer this_.recvLock.Unlock()
+
+	stream := this_.recvStreams[streamId]
+	if stream == nil {
+		stream = &muxRecvStream{}
+		this_.recvStreams[streamId] = stream
+	}
+	stream.queue = append(stream.queue, &muxRecvMessage{message: message, size: size})
+	if !stream.running {
+		stream.running = true
+		go this_.runStream(streamId, stream, onMessage)
+	}
+}
+
+func (this_ *muxConn) runStream(streamId uint32, stream *muxRecvStream, onMessage func(msg *Message)) {
+	window := make([]byte, 4)
+	for {
+		this_.recvLock.Lock()
+		if len(stream.queue) == 0 {
+			stream.running = false
+			delete(this_.recvStreams, streamId)
+			this_.recvLock.Unlock()
+			return
+		}
+		one := stream.queue[0]
+		stream.queue = stream.queue[1:]
+		this_.recvLock.Unlock()
+
+		onMessage(one.message)
+
+		binary.LittleEndian.PutUint32(window, uint32(one.size))
+		_ = this_.writeFrame(frameTypeWindow, 0, streamId, window)
+	}
+}
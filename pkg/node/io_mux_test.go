@@ -0,0 +1,213 @@
+package node
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMuxMessage(t *testing.T) {
+	for _, compress := range []string{"", compressZstd, compressSnappy} {
+		client, server := net.Pipe()
+		sender := newMuxConn(client, compress, &MonitorData{})
+		receiver := newMuxConn(server, compress, &MonitorData{})
+		// 发送方读取归还的窗口
+		go func() {
+			for {
+				if _, _, _, e := sender.readMessage(); e != nil {
+					return
+				}
+			}
+		}()
+
+		data := []byte(strings.Repeat("team ide node ", 20*1024))
+		random := make([]byte, 64*1024)
+		_, _ = rand.Read(random)
+		go func() {
+			for i, bs := range [][]byte{data, random, []byte("end")} {
+				_ = sender.writeMessage(&Message{
+					Method:           methodNetProxySend,
+					HasBytes:         true,
+					Bytes:            bs,
+					NetProxyWorkData: &NetProxyWorkData{ConnId: "conn", NetProxyId: string(rune('a' + i))},
+				})
+			}
+			_ = sender.writeMessage(&Message{Id: "result"})
+		}()
+
+		var received [][]byte
+		var lock sync.Mutex
+		getReceived := func() [][]byte {
+			lock.Lock()
+			defer lock.Unlock()
+			return received
+		}
+		for i := 0; i < 3; i++ {
+			msg, streamId, size, err := receiver.readMessage()
+			if err != nil {
+				t.Fatal(compress, err)
+			}
+			if streamId == 0 {
+				t.Fatal(compress, "流消息应有流编号")
+			}
+			if compress != "" && i == 0 && size >= len(data) {
+				t.Fatal(compress, "可压缩的数据应压缩", size)
+			}
+			receiver.dispatch(streamId, size, msg, func(msg *Message) {
+				lock.Lock()
+				defer lock.Unlock()
+				received = append(received, msg.Bytes)
+			})
+		}
+		msg, streamId, _, err := receiver.readMessage()
+		if err != nil || streamId != 0 || msg.Id != "result" {
+			t.Fatal(compress, msg, streamId, err)
+		}
+		// 流消息在单独的协程中按顺序处理
+		var got [][]byte
+		for i := 0; i < 50 && len(got) < 3; i++ {
+			time.Sleep(10 * time.Millisecond)
+			got = getReceived()
+		}
+		if len(got) != 3 {
+			t.Fatal(compress, len(got))
+		}
+		if !bytes.Equal(got[0], data) || !bytes.Equal(got[1], random) || string(got[2]) != "end" {
+			t.Fatal(compress, "数据不一致")
+		}
+		// 处理完成后窗口全部归还
+		for i := 0; i < 50; i++ {
+			sender.windowLock.Lock()
+			size := len(sender.windows)
+			sender.windowLock.Unlock()
+			if size == 0 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		sender.windowLock.Lock()
+		size := len(sender.windows)
+		sender.windowLock.Unlock()
+		if size != 0 {
+			t.Fatal(compress, "窗口未归还")
+		}
+		_ = client.Close()
+		_ = server.Close()
+	}
+}
+
+func TestNodeMux(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootAddress := listener.Addr().String()
+	_ = listener.Close()
+
+	root := &Server{}
+	root.Start()
+	defer root.Stop()
+	root.AddLocalNode(&LocalNode{Id: "root", BindAddress: rootAddress, BindToken: "root-token"})
+
+	node := &Server{}
+	node.Start()
+	defer node.Stop()
+	node.AddLocalNode(&LocalNode{Id: "node-1"})
+	time.Sleep(100 * time.Millisecond)
+	_ = node.AddToNodeList([]string{"node-1"}, []*ToNode{
+		{Id: "root", ConnAddress: rootAddress, ConnToken: "root-token", ConnSize: 3},
+	})
+
+	getListeners := func() []*MessageListener {
+		pool := node.getToNodeListenerPool("root")
+		if pool == nil {
+			return nil
+		}
+		pool.listenerMu.Lock()
+		defer pool.listenerMu.Unlock()
+		return pool.listeners
+	}
+	for i := 0; i < 50; i++ {
+		if len(getListeners()) > 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if listeners := getListeners(); len(listeners) != muxConnSize || !listeners[0].isMux() {
+		t.Fatal("多路复用时只保留一个连接")
+	}
+
+	// 旧节点不发送协议版本，仍使用旧协议
+	conn, err := net.Dial("tcp", rootAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = conn.Write([]byte("root-token" + strings.Repeat(" ", tokenByteSize-len("root-token"))))
+	_ = WriteMessage(conn, &Message{ConnData: &ConnData{NodeIdList: []string{"old-node"}}}, &MonitorData{})
+	msg, err := ReadMessage(conn, &MonitorData{})
+	if err != nil || msg.ConnData == nil || msg.ConnData.NodeId != "root" || msg.ConnData.Protocol != 0 {
+		t.Fatal("旧节点握手异常", err)
+	}
+	_ = conn.Close()
+
+	// 经由多路复用连接代理，数据超过流窗口
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = target.Close() }()
+	go func() {
+		for {
+			c, e := target.Accept()
+			if e != nil {
+				return
+			}
+			go func() { _, _ = io.Copy(c, c) }()
+		}
+	}()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	innerAddress := inner.Addr().String()
+	_ = inner.Close()
+
+	lineNodeIdList := []string{"node-1", "root"}
+	if err = node.AddNetProxyOuterList(lineNodeIdList, []*NetProxyOuter{
+		{Id: "mux", NodeId: "root", Address: target.Addr().String(), ReverseLineNodeIdList: []string{"root", "node-1"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	_ = node.AddNetProxyInnerList([]string{"node-1"}, []*NetProxyInner{
+		{Id: "mux", NodeId: "node-1", Address: innerAddress, LineNodeIdList: lineNodeIdList},
+	})
+	for i := 0; i < 50; i++ {
+		if node.GetNetProxyInnerStatus([]string{"node-1"}, "mux") == StatusStarted && root.getNetProxyOuter("mux") != nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	conn, err = net.Dial("tcp", innerAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	data := make([]byte, 2*int(muxStreamWindow))
+	_, _ = rand.Read(data[:len(data)/2])
+	go func() { _, _ = conn.Write(data) }()
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	res := make([]byte, len(data))
+	if _, err = io.ReadFull(conn, res); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res, data) {
+		t.Fatal("代理数据不一致")
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
 	"sync"
 	"teamide/pkg/base"
 	"teamide/pkg/node"
@@ -31,9 +32,34 @@ func main() {
 	flag.StringVar(&connAddress, "connAddress", "", "上层节点连接地址")
 	flag.StringVar(&connToken, "connToken", "", "上层节点连接Token")
 
+	var cert string
+	var key string
+	var ca string
+	var fingerprints string
+	var genCert bool
+	flag.StringVar(&cert, "cert", "", "节点TLS证书，配置后节点之间使用TLS双向认证")
+	flag.StringVar(&key, "key", "", "节点TLS证书私钥")
+	flag.StringVar(&ca, "ca", "", "验证其它节点证书的CA证书")
+	flag.StringVar(&fingerprints, "fingerprints", "", "信任的其它节点证书SHA256指纹，多个用逗号分隔，用于自签名证书")
+	flag.BoolVar(&genCert, "genCert", false, "生成自签名证书到 -cert、-key 指定的文件并输出指纹")
+
 	//解析
 	flag.Parse()
 
+	if genCert {
+		if id == "" || cert == "" || key == "" {
+			flag.Usage()
+			panic("请设置 -id、-cert、-key")
+		}
+		fingerprint, err := node.GenerateCert(id, cert, key)
+		if err != nil {
+			panic(err)
+		}
+		println("生成证书 [" + cert + "][" + key + "] 成功")
+		println("证书指纹: " + fingerprint)
+		return
+	}
+
 	if id == "" {
 		flag.Usage()
 		panic("请设置 -id")
@@ -52,6 +78,23 @@ func main() {
 	}
 
 	server := &node.Server{}
+	if cert != "" {
+		tlsConfig := &node.TLSConfig{
+			CertFile: cert,
+			KeyFile:  key,
+			CAFile:   ca,
+		}
+		for _, one := range strings.Split(fingerprints, ",") {
+			if one = strings.TrimSpace(one); one != "" {
+				tlsConfig.Fingerprints = append(tlsConfig.Fingerprints, one)
+			}
+		}
+		if err := server.SetTLS(tlsConfig); err != nil {
+			panic(err)
+		}
+		fingerprint, _ := node.CertFileFingerprint(cert)
+		println("节点TLS已开启，本节点证书指纹: " + fingerprint)
+	}
 	server.Start()
 	localNode := &node.LocalNode{
 		Id:          id,
@@ -24,6 +24,12 @@ type MonitorData struct {
 	WriteLastTime      int64 `json:"writeLastTime,omitempty"`
 	WriteLastTimestamp int64 `json:"writeLastTimestamp,omitempty"`
 	writeLock          sync.Mutex
+	// RejectCount 因客户端列表、最大连接数被拒绝的连接数
+	RejectCount int64 `json:"rejectCount,omitempty"`
+	// ReadLimitTime、WriteLimitTime 因带宽限制等待的时间
+	ReadLimitTime  int64 `json:"readLimitTime,omitempty"`
+	WriteLimitTime int64 `json:"writeLimitTime,omitempty"`
+	limitLock      sync.Mutex
 }
 
 func (this_ *MonitorData) monitorRead(bytesSize int64, useTime int64) {
@@ -67,3 +73,51 @@ func (this_ *MonitorData) monitorWrite(bytesSize int64, useTime int64) {
 	this_.WriteSize += bytesSize
 	this_.WriteTime += useTime
 }
+
+func (this_ *MonitorData) monitorReject() {
+	this_.limitLock.Lock()
+	defer this_.limitLock.Unlock()
+
+	this_.RejectCount++
+}
+
+func (this_ *MonitorData) monitorReadLimit(useTime int64) {
+	this_.limitLock.Lock()
+	defer this_.limitLock.Unlock()
+
+	this_.ReadLimitTime += useTime
+}
+
+func (this_ *MonitorData) monitorWriteLimit(useTime int64) {
+	this_.limitLock.Lock()
+	defer this_.limitLock.Unlock()
+
+	this_.WriteLimitTime += useTime
+}
+
+// snapshot 加锁复制当前数据，用于返回给其它节点
+func (this_ *MonitorData) snapshot() (res *MonitorData) {
+	res = &MonitorData{}
+	this_.readLock.Lock()
+	res.ReadSize = this_.ReadSize
+	res.ReadTime = this_.ReadTime
+	res.ReadLastSize = this_.ReadLastSize
+	res.ReadLastTime = this_.ReadLastTime
+	res.ReadLastTimestamp = this_.ReadLastTimestamp
+	this_.readLock.Unlock()
+
+	this_.writeLock.Lock()
+	res.WriteSize = this_.WriteSize
+	res.WriteTime = this_.WriteTime
+	res.WriteLastSize = this_.WriteLastSize
+	res.WriteLastTime = this_.WriteLastTime
+	res.WriteLastTimestamp = this_.WriteLastTimestamp
+	this_.writeLock.Unlock()
+
+	this_.limitLock.Lock()
+	res.RejectCount = this_.RejectCount
+	res.ReadLimitTime = this_.ReadLimitTime
+	res.WriteLimitTime = this_.WriteLimitTime
+	this_.limitLock.Unlock()
+	return
+}
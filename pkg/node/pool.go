@@ -17,6 +17,27 @@ type MessageListenerPool struct {
 	timeout    int64
 	isStop     bool
 	getIndex   int
+	// nodeId 对端节点，isDial 是否由本节点主动连接
+	nodeId string
+	isDial bool
+	// MonitorData 该节点连接的读写数据，link 探测结果
+	MonitorData *MonitorData
+	link        *linkStat
+}
+
+func newMessageListenerPool(nodeId string, isDial bool) *MessageListenerPool {
+	return &MessageListenerPool{
+		nodeId:      nodeId,
+		isDial:      isDial,
+		MonitorData: &MonitorData{},
+		link:        &linkStat{},
+	}
+}
+
+func (this_ *MessageListenerPool) size() int {
+	this_.listenerMu.Lock()
+	defer this_.listenerMu.Unlock()
+	return len(this_.listeners)
 }
 
 func (this_ *MessageListenerPool) Remove(listener *MessageListener) {
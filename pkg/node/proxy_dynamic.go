@@ -0,0 +1,165 @@
+package node
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"teamide/pkg/socks5"
+	"time"
+)
+
+const (
+	// NetProxyTypeSocks5 输入端为 SOCKS5 代理，连接目标由客户端指定
+	NetProxyTypeSocks5 = "socks5"
+	// NetProxyTypeHttp 输入端为 HTTP CONNECT 代理，连接目标由客户端指定
+	NetProxyTypeHttp = "http"
+	// NetProxyTypeDynamic 输出端连接输入端传递的目标地址
+	NetProxyTypeDynamic = "dynamic"
+)
+
+// dynamicHandshakeTimeout 代理协议握手超时时间
+var dynamicHandshakeTimeout = 10 * time.Second
+
+// IsDynamicType 是否为按连接指定目标的代理类型
+func IsDynamicType(t string) bool {
+	switch strings.ToLower(t) {
+	case NetProxyTypeSocks5, NetProxyTypeHttp, NetProxyTypeDynamic:
+		return true
+	}
+	return false
+}
+
+// AllowTarget 目标地址是否在允许列表中，列表为空时不限制
+// 列表项格式为 主机[:端口]，主机可以是 IP、CIDR、域名、*.域名 或 *，不带端口或端口为 * 时不限制端口
+// 域名只与客户端请求的域名匹配，不做解析
+func AllowTarget(allowList []string, address string) bool {
+	if len(allowList) == 0 {
+		return true
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, one := range allowList {
+		one = strings.ToLower(strings.TrimSpace(one))
+		if one == "" {
+			continue
+		}
+		allowHost, allowPort, e := net.SplitHostPort(one)
+		if e != nil {
+			allowHost, allowPort = strings.Trim(one, "[]"), ""
+		}
+		if allowPort != "" && allowPort != "*" && allowPort != port {
+			continue
+		}
+		if allowHost == "*" {
+			return true
+		}
+		if ip != nil {
+			if strings.Contains(allowHost, "/") {
+				if _, ipNet, e := net.ParseCIDR(allowHost); e == nil && ipNet.Contains(ip) {
+					return true
+				}
+			} else if allowIp := net.ParseIP(allowHost); allowIp != nil && allowIp.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		if strings.HasPrefix(allowHost, "*.") {
+			if strings.HasSuffix(host, allowHost[1:]) {
+				return true
+			}
+		} else if allowHost == host {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAuth 未配置用户名时不需要认证
+func (this_ *NetProxyInner) checkAuth(username string, password string) bool {
+	u := subtle.ConstantTimeCompare([]byte(username), []byte(this_.Username))
+	p := subtle.ConstantTimeCompare([]byte(password), []byte(this_.Password))
+	return u&p == 1
+}
+
+// dynamicHandshake 完成 SOCKS5 或 HTTP CONNECT 握手，返回目标地址、握手时已读取的数据和应答连接结果的方法
+func (this_ *InnerServer) dynamicHandshake(conn net.Conn) (address string, pending []byte, reply func(err error) error, err error) {
+	_ = conn.SetDeadline(time.Now().Add(dynamicHandshakeTimeout))
+	defer func() { _ = conn.SetDeadline(time.Time{}) }()
+
+	switch strings.ToLower(this_.netProxy.GetType()) {
+	case NetProxyTypeSocks5:
+		var auth func(username string, password string) bool
+		if this_.netProxy.Username != "" {
+			auth = this_.netProxy.checkAuth
+		}
+		address, err = socks5.HandshakeAuth(conn, auth)
+		if err != nil {
+			return
+		}
+		if !AllowTarget(this_.netProxy.AllowList, address) {
+			_ = socks5.Reply(conn, socks5.ReplyNotAllowed)
+			err = errors.New("目标地址[" + address + "]不在允许列表中")
+			return
+		}
+		reply = func(e error) error {
+			if e != nil {
+				return socks5.Reply(conn, socks5.ReplyHostUnreachable)
+			}
+			return socks5.Reply(conn, socks5.ReplySucceeded)
+		}
+	case NetProxyTypeHttp:
+		reader := bufio.NewReader(conn)
+		var req *http.Request
+		req, err = http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		if req.Method != http.MethodConnect {
+			_, _ = conn.Write([]byte("HTTP/1.1 405 Method Not Allowed\r\nConnection: close\r\n\r\n"))
+			err = errors.New("HTTP 代理只支持 CONNECT 方法")
+			return
+		}
+		if this_.netProxy.Username != "" {
+			// 借用 BasicAuth 解析 Proxy-Authorization
+			authReq := &http.Request{Header: http.Header{"Authorization": req.Header["Proxy-Authorization"]}}
+			username, password, ok := authReq.BasicAuth()
+			if !ok || !this_.netProxy.checkAuth(username, password) {
+				_, _ = conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic realm=\"Team IDE\"\r\nConnection: close\r\n\r\n"))
+				err = errors.New("HTTP 代理用户名或密码错误")
+				return
+			}
+		}
+		address = req.Host
+		if _, _, e := net.SplitHostPort(address); e != nil {
+			address = net.JoinHostPort(strings.Trim(address, "[]"), "443")
+		}
+		if !AllowTarget(this_.netProxy.AllowList, address) {
+			_, _ = conn.Write([]byte("HTTP/1.1 403 Forbidden\r\nConnection: close\r\n\r\n"))
+			err = errors.New("目标地址[" + address + "]不在允许列表中")
+			return
+		}
+		// 客户端可能在应答前已发送后续数据
+		if reader.Buffered() > 0 {
+			bs, _ := reader.Peek(reader.Buffered())
+			pending = append([]byte{}, bs...)
+		}
+		reply = func(e error) (err error) {
+			if e != nil {
+				_, err = conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\nConnection: close\r\n\r\n"))
+				return
+			}
+			_, err = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+			return
+		}
+	default:
+		err = errors.New("代理类型[" + this_.netProxy.GetType() + "]不支持")
+	}
+	return
+}
@@ -0,0 +1,149 @@
+package node
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAllowTarget(t *testing.T) {
+	allowList := []string{"10.0.0.0/8:22", "192.168.1.10", "*.corp.local:*", "git.example.com:443"}
+	for address, want := range map[string]bool{
+		"10.1.2.3:22":         true,
+		"10.1.2.3:80":         false,
+		"192.168.1.10:3306":   true,
+		"192.168.1.11:3306":   false,
+		"db.corp.local:5432":  true,
+		"corp.local:5432":     false,
+		"GIT.example.com:443": true,
+		"git.example.com:22":  false,
+	} {
+		if AllowTarget(allowList, address) != want {
+			t.Fatal(address, want)
+		}
+	}
+	if !AllowTarget(nil, "any.host:1") {
+		t.Fatal("允许列表为空时不限制")
+	}
+}
+
+func TestDynamicNetProxy(t *testing.T) {
+	// 目标服务，连接后先发送 hello，之后原样返回
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = target.Close() }()
+	go func() {
+		for {
+			conn, e := target.Accept()
+			if e != nil {
+				return
+			}
+			go func() {
+				_, _ = conn.Write([]byte("hello"))
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	targetAddress := target.Addr().String()
+
+	server := &Server{}
+	server.Start()
+	defer server.Stop()
+	server.AddLocalNode(&LocalNode{Id: "dynamic-node"})
+	lineNodeIdList := []string{"dynamic-node"}
+	allowList := []string{targetAddress}
+	_ = server.AddNetProxyOuterList(lineNodeIdList, []*NetProxyOuter{
+		{Id: "socks5", NodeId: "dynamic-node", Type: NetProxyTypeDynamic, ReverseLineNodeIdList: lineNodeIdList, AllowList: allowList},
+		{Id: "http", NodeId: "dynamic-node", Type: NetProxyTypeDynamic, ReverseLineNodeIdList: lineNodeIdList, AllowList: allowList},
+	})
+	var addressList []string
+	for _, t_ := range []string{NetProxyTypeSocks5, NetProxyTypeHttp} {
+		listener, e := net.Listen("tcp", "127.0.0.1:0")
+		if e != nil {
+			t.Fatal(e)
+		}
+		addressList = append(addressList, listener.Addr().String())
+		_ = listener.Close()
+		_ = server.AddNetProxyInnerList(lineNodeIdList, []*NetProxyInner{
+			{Id: t_, NodeId: "dynamic-node", Type: t_, Address: listener.Addr().String(), LineNodeIdList: lineNodeIdList, Username: "user", Password: "pass", AllowList: allowList},
+		})
+	}
+	for i := 0; i < 50; i++ {
+		if server.GetNetProxyInnerStatus(lineNodeIdList, NetProxyTypeSocks5) == StatusStarted &&
+			server.GetNetProxyInnerStatus(lineNodeIdList, NetProxyTypeHttp) == StatusStarted {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	expect := func(conn net.Conn, reader io.Reader, data string) {
+		buf := make([]byte, len(data))
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, e := io.ReadFull(reader, buf); e != nil || string(buf) != data {
+			t.Fatal(string(buf), data, e)
+		}
+	}
+
+	// SOCKS5 用户名密码认证后连接目标
+	conn, err := net.Dial("tcp", addressList[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(targetAddress)
+	ip := net.ParseIP(host).To4()
+	p, _ := strconv.Atoi(port)
+	_, _ = conn.Write([]byte{5, 1, 2})
+	expect(conn, conn, string([]byte{5, 2}))
+	_, _ = conn.Write(append(append([]byte{1, 4}, "user"...), append([]byte{4}, "pass"...)...))
+	expect(conn, conn, string([]byte{1, 0}))
+	_, _ = conn.Write([]byte{5, 1, 0, 1, ip[0], ip[1], ip[2], ip[3], byte(p >> 8), byte(p)})
+	expect(conn, conn, string([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}))
+	expect(conn, conn, "hello")
+	_, _ = conn.Write([]byte("socks5"))
+	expect(conn, conn, "socks5")
+	_ = conn.Close()
+
+	// HTTP CONNECT，目标不在允许列表中时拒绝
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	connect := func(address string) (conn net.Conn, reader *bufio.Reader, res *http.Response) {
+		conn, err = net.Dial("tcp", addressList[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = conn.Write([]byte("CONNECT " + address + " HTTP/1.1\r\nHost: " + address + "\r\nProxy-Authorization: " + auth + "\r\n\r\n"))
+		reader = bufio.NewReader(conn)
+		res, err = http.ReadResponse(reader, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	conn, reader, res := connect("127.0.0.1:1")
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatal(res.Status)
+	}
+	_ = conn.Close()
+	conn, reader, res = connect(targetAddress)
+	if res.StatusCode != http.StatusOK {
+		t.Fatal(res.Status)
+	}
+	expect(conn, reader, "hello")
+	_, _ = conn.Write([]byte("http"))
+	expect(conn, reader, "http")
+	_ = conn.Close()
+
+	auth = "Basic " + base64.StdEncoding.EncodeToString([]byte("user:wrong"))
+	conn, _, res = connect(targetAddress)
+	if res.StatusCode != http.StatusProxyAuthRequired || !strings.HasPrefix(res.Header.Get("Proxy-Authenticate"), "Basic") {
+		t.Fatal(res.Status)
+	}
+	_ = conn.Close()
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,23 +14,39 @@ type InnerServer struct {
 	isStop   bool
 	*connCache
 	serverListener net.Listener
-	MonitorData    *MonitorData
-	worker         *Worker
-	status         int8
+	// packetConn 数据报类型的监听
+	packetConn  net.PacketConn
+	MonitorData *MonitorData
+	worker      *Worker
+	status      int8
+	// policy 连接策略 *proxyPolicy
+	policy        atomic.Value
+	connSize      int64
+	lastRejectLog int64
 }
 
 func (this_ *InnerServer) Start() {
 	this_.MonitorData = &MonitorData{}
 	this_.connCache = newConnCache(this_.MonitorData)
+	this_.setLimit(this_.netProxy.Limit)
 
-	go this_.serverListenerKeepAlive()
+	if IsPacketType(this_.netProxy.GetType()) {
+		go this_.packetListenerKeepAlive()
+	} else {
+		go this_.serverListenerKeepAlive()
+	}
 
 	return
 }
 
 func (this_ *InnerServer) Stop() {
 	this_.isStop = true
-	_ = this_.serverListener.Close()
+	if this_.serverListener != nil {
+		_ = this_.serverListener.Close()
+	}
+	if this_.packetConn != nil {
+		_ = this_.packetConn.Close()
+	}
 	this_.connCache.clean()
 	return
 }
@@ -53,7 +70,7 @@ func (this_ *InnerServer) serverListenerKeepAlive() {
 	var err error
 	Logger.Info("代理服务 " + this_.netProxy.GetInfoStr() + " 启动")
 
-	this_.serverListener, err = net.Listen(this_.netProxy.GetType(), this_.netProxy.GetAddress())
+	this_.serverListener, err = net.Listen(this_.netProxy.GetNetwork(), this_.netProxy.GetAddress())
 	if err != nil {
 		Logger.Error("代理服务 "+this_.netProxy.GetInfoStr()+" 监听异常", zap.Error(err))
 		return
@@ -84,23 +101,61 @@ func (this_ *InnerServer) onConn(conn net.Conn) {
 		_ = conn.Close()
 		return
 	}
+	if !this_.acceptConn(conn.RemoteAddr()) {
+		_ = conn.Close()
+		return
+	}
+	defer this_.releaseConn()
 	//Logger.Info(this_.server.GetServerInfo() + " 代理服务 " + this_.netProxy.Inner.GetInfoStr() + " 新连接")
 	var connId = util.GetUUID()
 	var netProxyId = this_.netProxy.Id
+	var err error
+
+	var address string
+	var pending []byte
+	var reply func(err error) error
+	if IsDynamicType(this_.netProxy.Type) {
+		address, pending, reply, err = this_.dynamicHandshake(conn)
+		if err != nil {
+			Logger.Warn("代理服务 "+this_.netProxy.GetInfoStr()+" 握手异常", zap.Error(err))
+			_ = conn.Close()
+			return
+		}
+	}
 	this_.setConn(connId, conn)
 
 	defer func() {
 		_ = this_.closeConn(connId)
 		_ = this_.worker.netProxyCloseConn(false, this_.netProxy.LineNodeIdList, netProxyId, connId)
 	}()
-	var err error
 
-	err = this_.worker.netProxyNewConn(this_.netProxy.LineNodeIdList, netProxyId, connId)
+	if reply != nil {
+		// 应答写入前阻塞输出端返回的数据，如 SSH 等服务连接后立即发送的数据
+		_, writeLock := this_.getConn(connId)
+		writeLock.Lock()
+		err = this_.worker.netProxyNewConn(this_.netProxy.LineNodeIdList, netProxyId, connId, address)
+		e := reply(err)
+		writeLock.Unlock()
+		if err == nil {
+			err = e
+		}
+	} else {
+		err = this_.worker.netProxyNewConn(this_.netProxy.LineNodeIdList, netProxyId, connId, address)
+	}
 
 	if err != nil {
 		Logger.Error("代理服务 "+this_.netProxy.GetInfoStr()+" 节点线连接创建异常", zap.Error(err))
 		return
 	}
+	if len(pending) > 0 {
+		this_.MonitorData.monitorRead(int64(len(pending)), 0)
+		this_.waitUpload(len(pending))
+		err = this_.worker.netProxySend(false, this_.netProxy.LineNodeIdList, netProxyId, connId, pending)
+		if err != nil {
+			Logger.Error(this_.netProxy.GetInfoStr()+" 节点线流发送异常", zap.Error(err))
+			return
+		}
+	}
 
 	var buf = make([]byte, 1024*32)
 
@@ -113,6 +168,7 @@ func (this_ *InnerServer) onConn(conn net.Conn) {
 
 		end := util.GetNow().UnixNano()
 		this_.MonitorData.monitorRead(int64(n), end-start)
+		this_.waitUpload(n)
 
 		e = this_.worker.netProxySend(false, this_.netProxy.LineNodeIdList, netProxyId, connId, buf[:n])
 		if e != nil {
@@ -0,0 +1,248 @@
+package node
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NetProxyLimit 代理连接策略，在客户端连接的输入端生效
+type NetProxyLimit struct {
+	// MaxConn 最大并发连接数，UDP 为最大会话数，为 0 时不限制
+	MaxConn int `json:"maxConn,omitempty"`
+	// UploadRate 客户端发送到目标的带宽，DownloadRate 目标返回客户端的带宽，单位字节每秒，为 0 时不限制
+	UploadRate   int64 `json:"uploadRate,omitempty"`
+	DownloadRate int64 `json:"downloadRate,omitempty"`
+	// AllowClientList、DenyClientList 客户端 IP 或 CIDR，拒绝列表优先，允许列表为空时不限制
+	AllowClientList []string `json:"allowClientList,omitempty"`
+	DenyClientList  []string `json:"denyClientList,omitempty"`
+}
+
+// Check 校验客户端列表格式
+func (this_ *NetProxyLimit) Check() (err error) {
+	if this_ == nil {
+		return
+	}
+	if this_.MaxConn < 0 || this_.UploadRate < 0 || this_.DownloadRate < 0 {
+		err = errors.New("代理连接数、带宽限制不能为负数")
+		return
+	}
+	if _, err = parseClientList(this_.AllowClientList); err != nil {
+		return
+	}
+	_, err = parseClientList(this_.DenyClientList)
+	return
+}
+
+func (this_ *NetProxyLimit) equal(other *NetProxyLimit) bool {
+	if this_ == nil || other == nil {
+		return this_ == other
+	}
+	return this_.MaxConn == other.MaxConn &&
+		this_.UploadRate == other.UploadRate &&
+		this_.DownloadRate == other.DownloadRate &&
+		stringListEqual(this_.AllowClientList, other.AllowClientList) &&
+		stringListEqual(this_.DenyClientList, other.DenyClientList)
+}
+
+// parseClientList 单个 IP 转换为只包含该 IP 的网段
+func parseClientList(list []string) (res []*net.IPNet, err error) {
+	for _, one := range list {
+		one = strings.TrimSpace(one)
+		if one == "" {
+			continue
+		}
+		if !strings.Contains(one, "/") {
+			ip := net.ParseIP(one)
+			if ip == nil {
+				err = errors.New("客户端地址[" + one + "]格式错误")
+				return
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			res = append(res, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		var ipNet *net.IPNet
+		if _, ipNet, err = net.ParseCIDR(one); err != nil {
+			err = errors.New("客户端地址[" + one + "]格式错误")
+			return
+		}
+		res = append(res, ipNet)
+	}
+	return
+}
+
+func ipInList(list []*net.IPNet, ip net.IP) bool {
+	for _, one := range list {
+		if one.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func getAddrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// rateLimiter 令牌桶，容量为一秒的流量，不足时预支令牌并等待，多个连接共用时总带宽不超过限制
+type rateLimiter struct {
+	lock   sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// wait 取出 n 个令牌，返回等待的时间
+func (this_ *rateLimiter) wait(n int) (waitTime time.Duration) {
+	if this_ == nil || n <= 0 {
+		return
+	}
+	this_.lock.Lock()
+	now := time.Now()
+	this_.tokens += now.Sub(this_.last).Seconds() * this_.rate
+	if this_.tokens > this_.rate {
+		this_.tokens = this_.rate
+	}
+	this_.last = now
+	this_.tokens -= float64(n)
+	if this_.tokens < 0 {
+		waitTime = time.Duration(-this_.tokens / this_.rate * float64(time.Second))
+	}
+	this_.lock.Unlock()
+
+	if waitTime > 0 {
+		time.Sleep(waitTime)
+	}
+	return
+}
+
+// proxyPolicy 由 NetProxyLimit 生成，更新策略时整体替换，不影响已有连接
+type proxyPolicy struct {
+	maxConn  int
+	upload   *rateLimiter
+	download *rateLimiter
+	allow    []*net.IPNet
+	deny     []*net.IPNet
+}
+
+// setLimit 设置连接策略，客户端列表格式错误的项忽略
+func (this_ *InnerServer) setLimit(limit *NetProxyLimit) {
+	policy := &proxyPolicy{}
+	if limit != nil {
+		var err error
+		policy.maxConn = limit.MaxConn
+		policy.upload = newRateLimiter(limit.UploadRate)
+		policy.download = newRateLimiter(limit.DownloadRate)
+		if policy.allow, err = parseClientList(limit.AllowClientList); err != nil {
+			Logger.Warn("代理服务 "+this_.netProxy.GetInfoStr()+" 客户端允许列表异常", zap.Error(err))
+		}
+		if policy.deny, err = parseClientList(limit.DenyClientList); err != nil {
+			Logger.Warn("代理服务 "+this_.netProxy.GetInfoStr()+" 客户端拒绝列表异常", zap.Error(err))
+		}
+	}
+	this_.policy.Store(policy)
+}
+
+func (this_ *InnerServer) getPolicy() *proxyPolicy {
+	policy, _ := this_.policy.Load().(*proxyPolicy)
+	if policy == nil {
+		policy = &proxyPolicy{}
+	}
+	return policy
+}
+
+// checkClient 返回拒绝原因，为空时允许连接，connSize 为当前连接数
+func (this_ *InnerServer) checkClient(addr net.Addr, connSize int) (reason string) {
+	policy := this_.getPolicy()
+	if len(policy.allow) > 0 || len(policy.deny) > 0 {
+		ip := getAddrIP(addr)
+		if ip == nil {
+			reason = "客户端地址无法解析"
+			return
+		}
+		if ipInList(policy.deny, ip) {
+			reason = "客户端在拒绝列表中"
+			return
+		}
+		if len(policy.allow) > 0 && !ipInList(policy.allow, ip) {
+			reason = "客户端不在允许列表中"
+			return
+		}
+	}
+	if policy.maxConn > 0 && connSize >= policy.maxConn {
+		reason = "超过最大连接数"
+		return
+	}
+	return
+}
+
+// acceptConn TCP 连接计数，允许时需要在连接结束后调用 releaseConn
+func (this_ *InnerServer) acceptConn(addr net.Addr) bool {
+	size := atomic.AddInt64(&this_.connSize, 1)
+	if reason := this_.checkClient(addr, int(size-1)); reason != "" {
+		atomic.AddInt64(&this_.connSize, -1)
+		this_.reject(addr, reason)
+		return false
+	}
+	return true
+}
+
+func (this_ *InnerServer) releaseConn() {
+	atomic.AddInt64(&this_.connSize, -1)
+}
+
+// reject 记录拒绝次数，日志每秒最多输出一次，避免 UDP 等大量拒绝时刷屏
+func (this_ *InnerServer) reject(addr net.Addr, reason string) {
+	this_.MonitorData.monitorReject()
+	now := util.GetNowMilli()
+	last := atomic.LoadInt64(&this_.lastRejectLog)
+	if now-last < 1000 || !atomic.CompareAndSwapInt64(&this_.lastRejectLog, last, now) {
+		return
+	}
+	Logger.Warn("代理服务 "+this_.netProxy.GetInfoStr()+" 拒绝连接 "+reason, zap.Any("client", addr.String()))
+}
+
+// waitUpload 客户端发送到目标前按上传带宽等待
+func (this_ *InnerServer) waitUpload(n int) {
+	if waitTime := this_.getPolicy().upload.wait(n); waitTime > 0 {
+		this_.MonitorData.monitorReadLimit(int64(waitTime))
+	}
+}
+
+// send 写入客户端前按下载带宽等待
+func (this_ *InnerServer) send(connId string, bytes []byte) (err error) {
+	if waitTime := this_.getPolicy().download.wait(len(bytes)); waitTime > 0 {
+		this_.MonitorData.monitorWriteLimit(int64(waitTime))
+	}
+	err = this_.connCache.send(connId, bytes)
+	return
+}
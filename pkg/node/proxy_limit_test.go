@@ -0,0 +1,122 @@
+package node
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(1000)
+	// 初始为一秒的令牌，不需要等待
+	if waitTime := limiter.wait(1000); waitTime != 0 {
+		t.Fatal("不应等待", waitTime)
+	}
+	start := time.Now()
+	limiter.wait(500)
+	if useTime := time.Since(start); useTime < 400*time.Millisecond || useTime > 2*time.Second {
+		t.Fatal("等待时间异常", useTime)
+	}
+	if newRateLimiter(0).wait(1000) != 0 {
+		t.Fatal("未限制时不应等待")
+	}
+}
+
+func TestNetProxyLimit(t *testing.T) {
+	// 目标服务，原样返回收到的数据
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = target.Close() }()
+	go func() {
+		for {
+			conn, e := target.Accept()
+			if e != nil {
+				return
+			}
+			go func() { _, _ = io.Copy(conn, conn) }()
+		}
+	}()
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	innerAddress := inner.Addr().String()
+	_ = inner.Close()
+
+	server := &Server{}
+	server.Start()
+	defer server.Stop()
+	server.AddLocalNode(&LocalNode{Id: "limit-node"})
+
+	lineNodeIdList := []string{"limit-node"}
+	_ = server.AddNetProxyOuterList(lineNodeIdList, []*NetProxyOuter{
+		{Id: "limit", NodeId: "limit-node", Type: "tcp", Address: target.Addr().String(), ReverseLineNodeIdList: lineNodeIdList},
+	})
+	_ = server.AddNetProxyInnerList(lineNodeIdList, []*NetProxyInner{
+		{Id: "limit", NodeId: "limit-node", Type: "tcp", Address: innerAddress, LineNodeIdList: lineNodeIdList, Limit: &NetProxyLimit{MaxConn: 1}},
+	})
+	for i := 0; i < 50 && server.GetNetProxyInnerStatus(lineNodeIdList, "limit") != StatusStarted; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	echo := func(conn net.Conn) error {
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, e := conn.Write([]byte("ping")); e != nil {
+			return e
+		}
+		buf := make([]byte, 4)
+		_, e := io.ReadFull(conn, buf)
+		return e
+	}
+	getRejectCount := func() int64 {
+		monitorData := server.GetNetProxyInnerMonitorData(lineNodeIdList, "limit")
+		monitorData.limitLock.Lock()
+		defer monitorData.limitLock.Unlock()
+		return monitorData.RejectCount
+	}
+
+	first, err := net.Dial("tcp", innerAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = first.Close() }()
+	if err = echo(first); err != nil {
+		t.Fatal(err)
+	}
+
+	// 超过最大连接数，连接被关闭
+	second, err := net.Dial("tcp", innerAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = echo(second); err == nil {
+		t.Fatal("超过最大连接数应被拒绝")
+	}
+	_ = second.Close()
+	if getRejectCount() != 1 {
+		t.Fatal("拒绝次数异常", getRejectCount())
+	}
+
+	// 只修改策略时不重启监听，已有连接不受影响
+	_ = server.AddNetProxyInnerList(lineNodeIdList, []*NetProxyInner{
+		{Id: "limit", NodeId: "limit-node", Type: "tcp", Address: innerAddress, LineNodeIdList: lineNodeIdList, Limit: &NetProxyLimit{DenyClientList: []string{"127.0.0.0/8"}}},
+	})
+	if err = echo(first); err != nil {
+		t.Fatal(err)
+	}
+	third, err := net.Dial("tcp", innerAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = echo(third); err == nil {
+		t.Fatal("拒绝列表中的客户端应被拒绝")
+	}
+	_ = third.Close()
+	if getRejectCount() != 2 {
+		t.Fatal("拒绝次数异常", getRejectCount())
+	}
+}
@@ -34,14 +34,28 @@ func (this_ *OuterListener) isStopped() bool {
 	return this_.isStop
 }
 
-func (this_ *OuterListener) newConn(connId string) (err error) {
+// newConn address 为 SOCKS5、HTTP 代理指定的目标地址，只有 dynamic 类型使用
+func (this_ *OuterListener) newConn(connId string, address string) (err error) {
 	if this_.isStopped() {
 		return
 	}
 
 	//Logger.Info(" OuterListener newConn [" + connId + "]")
+	if IsPacketType(this_.netProxy.GetType()) {
+		err = this_.newPacketConn(connId)
+		return
+	}
 
-	conn, err := net.Dial(this_.netProxy.GetType(), this_.netProxy.GetAddress())
+	dialAddress := this_.netProxy.GetAddress()
+	if IsDynamicType(this_.netProxy.Type) {
+		// 输出节点同样校验目标地址，不依赖输入节点
+		if address == "" || !AllowTarget(this_.netProxy.AllowList, address) {
+			err = errors.New(this_.netProxy.GetInfoStr() + " 目标地址[" + address + "]不在允许列表中")
+			return
+		}
+		dialAddress = address
+	}
+	conn, err := net.Dial(this_.netProxy.GetNetwork(), dialAddress)
 	if err != nil {
 		Logger.Error(this_.netProxy.GetInfoStr()+" 连接 ["+connId+"] 异常", zap.Error(err))
 		return
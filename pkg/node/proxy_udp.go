@@ -0,0 +1,285 @@
+package node
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// udpIdleTimeout UDP 会话无数据收发超过该时间后关闭
+	udpIdleTimeout = 60 * time.Second
+	// udpBufferSize 单个数据报最大长度
+	udpBufferSize = 64 * 1024
+)
+
+// IsPacketType 是否为数据报类型，数据报每次发送一个完整的数据报，节点线中一个消息对应一个数据报
+func IsPacketType(t string) bool {
+	return strings.HasPrefix(strings.ToLower(t), "udp")
+}
+
+// udpSession 输入端的 UDP 会话，按客户端地址区分，实现 net.Conn 以复用 connCache 的发送和监控
+type udpSession struct {
+	connId     string
+	packetConn net.PacketConn
+	addr       net.Addr
+	lastActive int64
+	// queue 待发送到节点线的数据报，由会话协程按顺序发送
+	queue     chan []byte
+	done      chan struct{}
+	onClose   func()
+	closeOnce sync.Once
+}
+
+func (this_ *udpSession) active() {
+	atomic.StoreInt64(&this_.lastActive, time.Now().UnixNano())
+}
+
+func (this_ *udpSession) idle(now time.Time) bool {
+	return now.UnixNano()-atomic.LoadInt64(&this_.lastActive) > int64(udpIdleTimeout)
+}
+
+func (this_ *udpSession) Read(_ []byte) (n int, err error) {
+	err = errors.New("udp session not support read")
+	return
+}
+
+func (this_ *udpSession) Write(b []byte) (n int, err error) {
+	this_.active()
+	return this_.packetConn.WriteTo(b, this_.addr)
+}
+
+func (this_ *udpSession) Close() error {
+	this_.closeOnce.Do(func() {
+		close(this_.done)
+		this_.onClose()
+	})
+	return nil
+}
+
+func (this_ *udpSession) LocalAddr() net.Addr                { return this_.packetConn.LocalAddr() }
+func (this_ *udpSession) RemoteAddr() net.Addr               { return this_.addr }
+func (this_ *udpSession) SetDeadline(_ time.Time) error      { return nil }
+func (this_ *udpSession) SetReadDeadline(_ time.Time) error  { return nil }
+func (this_ *udpSession) SetWriteDeadline(_ time.Time) error { return nil }
+
+// activeConn 输出端的 UDP 连接，发送数据报时同样刷新活跃时间，单向的数据报（如 syslog）不会因读取超时被关闭
+type activeConn struct {
+	net.Conn
+	lastActive int64
+}
+
+func (this_ *activeConn) Write(b []byte) (n int, err error) {
+	atomic.StoreInt64(&this_.lastActive, time.Now().UnixNano())
+	return this_.Conn.Write(b)
+}
+
+func (this_ *InnerServer) packetListenerKeepAlive() {
+	if this_.isStopped() {
+		return
+	}
+	defer func() {
+		this_.status = StatusStopped
+		if this_.isStopped() {
+			return
+		}
+		time.Sleep(5 * time.Second)
+		go this_.packetListenerKeepAlive()
+	}()
+	var err error
+	Logger.Info("代理服务 " + this_.netProxy.GetInfoStr() + " 启动")
+
+	packetConn, err := net.ListenPacket(this_.netProxy.GetType(), this_.netProxy.GetAddress())
+	if err != nil {
+		Logger.Error("代理服务 "+this_.netProxy.GetInfoStr()+" 监听异常", zap.Error(err))
+		return
+	}
+	this_.packetConn = packetConn
+	Logger.Info("代理服务 " + this_.netProxy.GetInfoStr() + " 启动成功")
+
+	this_.status = StatusStarted
+	sessions := map[string]*udpSession{}
+	var sessionsLock sync.Mutex
+
+	stopClean := make(chan struct{})
+	go this_.cleanIdleSession(stopClean, func(now time.Time) (list []*udpSession) {
+		sessionsLock.Lock()
+		defer sessionsLock.Unlock()
+		for _, one := range sessions {
+			if one.idle(now) {
+				list = append(list, one)
+			}
+		}
+		return
+	})
+	defer func() {
+		close(stopClean)
+		sessionsLock.Lock()
+		var list []*udpSession
+		for _, one := range sessions {
+			list = append(list, one)
+		}
+		sessionsLock.Unlock()
+		for _, one := range list {
+			_ = this_.closeConn(one.connId)
+		}
+		_ = packetConn.Close()
+	}()
+
+	var buf = make([]byte, udpBufferSize)
+	for {
+		if this_.isStopped() {
+			break
+		}
+		start := util.GetNow().UnixNano()
+		var n int
+		var addr net.Addr
+		n, addr, err = packetConn.ReadFrom(buf)
+		if err != nil {
+			if this_.isStopped() {
+				break
+			}
+			Logger.Error(this_.netProxy.GetInfoStr()+" listen read error", zap.Error(err))
+			break
+		}
+		end := util.GetNow().UnixNano()
+		this_.MonitorData.monitorRead(int64(n), end-start)
+
+		key := addr.String()
+		sessionsLock.Lock()
+		one := sessions[key]
+		isNew := one == nil
+		if isNew {
+			if reason := this_.checkClient(addr, len(sessions)); reason != "" {
+				sessionsLock.Unlock()
+				this_.reject(addr, reason)
+				continue
+			}
+			one = &udpSession{
+				connId:     util.GetUUID(),
+				packetConn: packetConn,
+				addr:       addr,
+				queue:      make(chan []byte, 256),
+				done:       make(chan struct{}),
+			}
+			session := one
+			session.onClose = func() {
+				sessionsLock.Lock()
+				if sessions[key] == session {
+					delete(sessions, key)
+				}
+				sessionsLock.Unlock()
+				// 在 connCache 锁内关闭，通知输出端需要另起协程
+				go func() {
+					_ = this_.worker.netProxyCloseConn(false, this_.netProxy.LineNodeIdList, this_.netProxy.Id, session.connId)
+				}()
+			}
+			sessions[key] = one
+		}
+		sessionsLock.Unlock()
+		one.active()
+		if isNew {
+			this_.setConn(one.connId, one)
+			go this_.runSession(one)
+		}
+
+		// 数据报需要拷贝，buf 会被下一个数据报覆盖
+		datagram := make([]byte, n)
+		copy(datagram, buf[:n])
+		select {
+		case one.queue <- datagram:
+		case <-one.done:
+		default:
+			// 节点线发送不及时，队列已满时丢弃，与 UDP 语义一致
+		}
+	}
+}
+
+// runSession 先在输出端创建连接，之后按接收顺序发送数据报
+func (this_ *InnerServer) runSession(one *udpSession) {
+	err := this_.worker.netProxyNewConn(this_.netProxy.LineNodeIdList, this_.netProxy.Id, one.connId, "")
+	if err != nil {
+		Logger.Error("代理服务 "+this_.netProxy.GetInfoStr()+" 节点线连接创建异常", zap.Error(err))
+		_ = this_.closeConn(one.connId)
+		return
+	}
+	for {
+		select {
+		case <-one.done:
+			return
+		case datagram := <-one.queue:
+			this_.waitUpload(len(datagram))
+			err = this_.worker.netProxySend(false, this_.netProxy.LineNodeIdList, this_.netProxy.Id, one.connId, datagram)
+			if err != nil {
+				Logger.Error(this_.netProxy.GetInfoStr()+" 节点线数据报发送异常", zap.Error(err))
+				_ = this_.closeConn(one.connId)
+				return
+			}
+		}
+	}
+}
+
+// cleanIdleSession 定时关闭空闲的 UDP 会话
+func (this_ *InnerServer) cleanIdleSession(stop chan struct{}, getIdle func(now time.Time) []*udpSession) {
+	ticker := time.NewTicker(udpIdleTimeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			for _, one := range getIdle(now) {
+				_ = this_.closeConn(one.connId)
+			}
+		}
+	}
+}
+
+// newPacketConn 输出端为每个会话创建一个 UDP 连接，空闲超时后关闭并通知输入端
+func (this_ *OuterListener) newPacketConn(connId string) (err error) {
+	conn, err := net.Dial(this_.netProxy.GetType(), this_.netProxy.GetAddress())
+	if err != nil {
+		Logger.Error(this_.netProxy.GetInfoStr()+" 连接 ["+connId+"] 异常", zap.Error(err))
+		return
+	}
+	udpConn := &activeConn{Conn: conn, lastActive: time.Now().UnixNano()}
+	this_.setConn(connId, udpConn)
+	go func() {
+		var netProxyId = this_.netProxy.Id
+		defer func() {
+			_ = this_.closeConn(connId)
+			_ = this_.worker.netProxyCloseConn(true, this_.netProxy.ReverseLineNodeIdList, netProxyId, connId)
+		}()
+
+		var buf = make([]byte, udpBufferSize)
+		for {
+			if this_.isStopped() {
+				return
+			}
+			start := util.GetNow().UnixNano()
+			_ = conn.SetReadDeadline(time.Now().Add(udpIdleTimeout))
+			n, e := conn.Read(buf)
+			if e != nil {
+				// 读取超时但最近发送过数据报时继续等待，否则为空闲超时或连接已被输入端关闭
+				if ne, ok := e.(net.Error); ok && ne.Timeout() && time.Now().UnixNano()-atomic.LoadInt64(&udpConn.lastActive) < int64(udpIdleTimeout) {
+					continue
+				}
+				return
+			}
+			end := util.GetNow().UnixNano()
+			this_.MonitorData.monitorRead(int64(n), end-start)
+
+			e = this_.worker.netProxySend(true, this_.netProxy.ReverseLineNodeIdList, netProxyId, connId, buf[:n])
+			if e != nil {
+				Logger.Error(this_.netProxy.GetInfoStr()+" 连接 发送异常", zap.Error(e))
+				return
+			}
+		}
+	}()
+	return
+}
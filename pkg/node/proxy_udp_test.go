@@ -0,0 +1,76 @@
+package node
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPNetProxy(t *testing.T) {
+	// 目标服务，原样返回收到的数据报
+	target, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = target.Close() }()
+	go func() {
+		buf := make([]byte, udpBufferSize)
+		for {
+			n, addr, e := target.ReadFrom(buf)
+			if e != nil {
+				return
+			}
+			_, _ = target.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	// 输入端口
+	inner, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	innerAddress := inner.LocalAddr().String()
+	_ = inner.Close()
+
+	server := &Server{}
+	server.Start()
+	defer server.Stop()
+	server.AddLocalNode(&LocalNode{Id: "udp-node"})
+
+	lineNodeIdList := []string{"udp-node"}
+	_ = server.AddNetProxyOuterList(lineNodeIdList, []*NetProxyOuter{
+		{Id: "udp", NodeId: "udp-node", Type: "udp", Address: target.LocalAddr().String(), ReverseLineNodeIdList: lineNodeIdList},
+	})
+	_ = server.AddNetProxyInnerList(lineNodeIdList, []*NetProxyInner{
+		{Id: "udp", NodeId: "udp-node", Type: "udp", Address: innerAddress, LineNodeIdList: lineNodeIdList},
+	})
+
+	var conn net.Conn
+	for i := 0; i < 50; i++ {
+		if server.GetNetProxyInnerStatus(lineNodeIdList, "udp") == StatusStarted {
+			conn, err = net.Dial("udp", innerAddress)
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if conn == nil || err != nil {
+		t.Fatal("代理服务未启动", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// 每个数据报独立转发，保持边界
+	buf := make([]byte, udpBufferSize)
+	for _, data := range []string{"query-1", "query-22", "query-333"} {
+		if _, err = conn.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, e := conn.Read(buf)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if string(buf[:n]) != data {
+			t.Fatal(string(buf[:n]), data)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"sync"
 	"teamide/pkg/system"
+	"time"
 )
 
 var tokenByteSize = 128
@@ -34,6 +35,23 @@ type Server struct {
 
 	connNodeListenerKeepAliveLock sync.Mutex
 	*Worker
+
+	// tls 节点之间的传输加密，为空时使用明文 TCP
+	tls *nodeTLS
+}
+
+// SetTLS 设置节点之间的传输加密，需要在添加本地节点之前设置
+func (this_ *Server) SetTLS(config *TLSConfig) (err error) {
+	if config == nil {
+		this_.tls = nil
+		return
+	}
+	nodeTLS, err := newNodeTLS(config)
+	if err != nil {
+		return
+	}
+	this_.tls = nodeTLS
+	return
 }
 
 func (this_ *Server) GetLocalNodeIdList() (localNodeIdList []string) {
@@ -89,8 +107,10 @@ func (this_ *Server) Start() {
 		server:      this_,
 		Space:       newSpace(),
 		MonitorData: &MonitorData{},
+		stopChan:    make(chan struct{}),
 	}
 	go system.StartCollectMonitorData()
+	go this_.linkProbeKeepAlive()
 	return
 }
 
@@ -157,6 +177,23 @@ func (this_ *Server) GetNodeMonitorData(lineNodeIdList []string) (monitorData *M
 	return
 }
 
+// GetNodeLinkList 节点与相邻节点的连接及探测结果
+func (this_ *Server) GetNodeLinkList(lineNodeIdList []string) (linkList []*NodeLink, err error) {
+	linkList, err = this_.getNodeLinkList(lineNodeIdList)
+	return
+}
+
+// PingNode 沿节点线探测，返回往返时间，单位纳秒
+func (this_ *Server) PingNode(lineNodeIdList []string) (rtt int64, err error) {
+	start := time.Now()
+	err = this_.ping(lineNodeIdList)
+	if err != nil {
+		return
+	}
+	rtt = time.Since(start).Nanoseconds()
+	return
+}
+
 func (this_ *Server) GetNetProxyInnerMonitorData(lineNodeIdList []string, netProxyId string) (monitorData *MonitorData) {
 	monitorData = this_.getNetProxyInnerMonitorData(lineNodeIdList, netProxyId)
 	return
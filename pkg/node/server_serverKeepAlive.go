@@ -3,6 +3,7 @@ package node
 import (
 	"fmt"
 	"go.uber.org/zap"
+	"io"
 	"net"
 	"strings"
 	"sync"
@@ -35,16 +36,33 @@ func (this_ *Server) serverListenerKeepAlive(localNode *LocalNode) {
 			Logger.Error("本地节点 监听 异常", zap.Any("localNode", localNode), zap.Error(err))
 			break
 		}
-		_ = this_.onServerConn(locker, localNode, conn)
+		if this_.tls == nil {
+			go func(conn net.Conn) {
+				_ = this_.onServerConn(locker, localNode, conn)
+			}(conn)
+			continue
+		}
+		// TLS 握手在单独的协程中进行，避免握手慢的连接阻塞监听
+		go func(conn net.Conn) {
+			tlsConn, e := this_.serverConn(conn)
+			if e != nil {
+				Logger.Error(localNode.GetServerInfo()+" 来之客户端连接 TLS握手异常", zap.Any("remoteAddr", conn.RemoteAddr().String()), zap.Error(e))
+				return
+			}
+			_ = this_.onServerConn(locker, localNode, tlsConn)
+		}(conn)
 	}
 	return
 }
 
+// serverConnHandshakeTimeout 来自客户端的连接发送 Token 及连接信息的超时时间
+var serverConnHandshakeTimeout = 10 * time.Second
+
+// onServerConn 读取 Token 及连接信息时设置超时，且在加锁之前完成，避免未发送数据的连接阻塞监听
 func (this_ *Server) onServerConn(locker sync.Locker, localNode *LocalNode, conn net.Conn) (err error) {
-	locker.Lock()
-	defer locker.Unlock()
+	_ = conn.SetReadDeadline(time.Now().Add(serverConnHandshakeTimeout))
 	var bytes = make([]byte, tokenByteSize)
-	_, err = conn.Read(bytes)
+	_, err = io.ReadFull(conn, bytes)
 	if err != nil {
 		_ = conn.Close()
 		return
@@ -68,6 +86,11 @@ func (this_ *Server) onServerConn(locker sync.Locker, localNode *LocalNode, conn
 		_ = conn.Close()
 		return
 	}
+	_ = conn.SetReadDeadline(time.Time{})
+
+	locker.Lock()
+	defer locker.Unlock()
+
 	var fromNodeIdList []string
 	if clientMsg.ConnData.NodeId != "" {
 		fromNodeIdList = append(fromNodeIdList, clientMsg.ConnData.NodeId)
@@ -76,11 +99,21 @@ func (this_ *Server) onServerConn(locker sync.Locker, localNode *LocalNode, conn
 		fromNodeIdList = append(fromNodeIdList, id)
 	}
 
+	// 连接方支持时使用版本 2，旧节点不发送协议版本
+	var protocol int
+	var compress string
+	if clientMsg.ConnData.Protocol >= protocolMux {
+		protocol = protocolVersion
+		compress = chooseCompress(clientMsg.ConnData.CompressList)
+	}
+
 	// 发送当前节点ID
 	err = WriteMessage(conn, &Message{
 		ConnData: &ConnData{
 			NodeId:    localNode.Id,
 			NodeToken: localNode.BindToken,
+			Protocol:  protocol,
+			Compress:  compress,
 		},
 	}, this_.MonitorData)
 	if err != nil {
@@ -88,8 +121,21 @@ func (this_ *Server) onServerConn(locker sync.Locker, localNode *LocalNode, conn
 		_ = conn.Close()
 		return
 	}
+	var poolList []*MessageListenerPool
+	var monitorList []*MonitorData
 	for _, fromNodeId := range fromNodeIdList {
 		pool := this_.getFromNodeListenerPoolIfAbsentCreate(fromNodeId)
+		poolList = append(poolList, pool)
+		monitorList = append(monitorList, pool.MonitorData)
+	}
+	conn = &linkConn{Conn: conn, monitorList: monitorList}
+	var mux *muxConn
+	if protocol >= protocolMux {
+		mux = newMuxConn(conn, compress, this_.MonitorData)
+	}
+	for i, fromNodeId := range fromNodeIdList {
+		fromNodeId := fromNodeId
+		pool := poolList[i]
 
 		if pool != nil && pool.isStop {
 			return
@@ -97,6 +143,7 @@ func (this_ *Server) onServerConn(locker sync.Locker, localNode *LocalNode, conn
 		messageListener := &MessageListener{
 			conn:      conn,
 			onMessage: this_.onMessage,
+			mux:       mux,
 		}
 		messageListener.listen(func() {
 			messageListener.stop()
@@ -3,10 +3,13 @@ package node
 import (
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
+	"teamide/pkg/telnet"
 	"teamide/pkg/terminal"
+	"time"
 )
 
-func (this_ *Server) TerminalStart(lineNodeIdList []string, size *terminal.Size, onRead func(buf []byte) (err error)) (key string, err error) {
+// TerminalStart telnetConfig 不为空时经由节点线路连接 Telnet
+func (this_ *Server) TerminalStart(lineNodeIdList []string, size *terminal.Size, telnetConfig *telnet.Config, onRead func(buf []byte) (err error)) (key string, err error) {
 	readKey := util.GetUUID()
 	this_.addOnBytesCache(readKey, &OnBytes{
 		start: func() (err error) {
@@ -24,7 +27,7 @@ func (this_ *Server) TerminalStart(lineNodeIdList []string, size *terminal.Size,
 	})
 	Logger.Info("terminal start add byte cache on ready", zap.Any("readKey", readKey), zap.Any("lineNodeIdList", lineNodeIdList))
 
-	key, err = this_.workTerminalStart(lineNodeIdList, size, readKey)
+	key, err = this_.workTerminalStart(lineNodeIdList, size, readKey, telnetConfig)
 	if err != nil {
 		return
 	}
@@ -65,3 +68,8 @@ func (this_ *Server) TerminalStop(lineNodeIdList []string, key string) (err erro
 	}
 	return
 }
+
+func (this_ *Server) TerminalExec(lineNodeIdList []string, command string, timeout time.Duration) (res *terminal.ExecResult, err error) {
+	res, err = this_.workTerminalExec(lineNodeIdList, command, timeout.Milliseconds())
+	return
+}
@@ -47,6 +47,8 @@ type OnBytes struct {
 	start func() (err error)
 	end   func() (err error)
 	on    func(buf []byte) (err error)
+	// err 写入异常，流消息不等待结果时在结束时返回
+	err error
 }
 
 func (this_ *Space) addOnBytesCache(key string, onBytes *OnBytes) {
@@ -138,12 +140,35 @@ type NetProxyInner struct {
 	Address        string   `json:"address,omitempty"`
 	LineNodeIdList []string `json:"lineNodeIdList,omitempty"`
 	Enabled        int8     `json:"enabled,omitempty"`
+	// Username、Password SOCKS5、HTTP 代理认证，为空时不需要认证
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// AllowList SOCKS5、HTTP 代理允许连接的目标地址，为空时不限制
+	AllowList []string `json:"allowList,omitempty"`
+	// Limit 连接数、带宽及客户端地址限制，为空时不限制
+	Limit *NetProxyLimit `json:"limit,omitempty"`
 }
 
 func (this_ *NetProxyInner) IsEnabled() bool {
 	return this_.Enabled != 2
 }
 
+// GetLogInfo 返回隐藏认证信息后的副本，用于日志输出
+func (this_ *NetProxyInner) GetLogInfo() (res *NetProxyInner) {
+	if this_ == nil {
+		return
+	}
+	info := *this_
+	if info.Username != "" {
+		info.Username = "******"
+	}
+	if info.Password != "" {
+		info.Password = "******"
+	}
+	res = &info
+	return
+}
+
 func (this_ *NetProxyInner) GetInfoStr() (str string) {
 	return fmt.Sprintf("[%s][%s]", this_.GetType(), this_.Address)
 }
@@ -160,6 +185,14 @@ func (this_ *NetProxyInner) GetAddress() (str string) {
 	return GetAddress(this_.Address)
 }
 
+// GetNetwork 监听使用的网络类型，SOCKS5、HTTP 代理监听 TCP
+func (this_ *NetProxyInner) GetNetwork() (str string) {
+	if IsDynamicType(this_.Type) {
+		return "tcp"
+	}
+	return this_.GetType()
+}
+
 type NetProxyOuter struct {
 	Id                    string   `json:"id,omitempty"`
 	NodeId                string   `json:"nodeId,omitempty"`
@@ -167,6 +200,8 @@ type NetProxyOuter struct {
 	Address               string   `json:"address,omitempty"`
 	ReverseLineNodeIdList []string `json:"reverseLineNodeIdList,omitempty"`
 	Enabled               int8     `json:"enabled,omitempty"`
+	// AllowList 类型为 dynamic 时允许连接的目标地址，为空时不限制
+	AllowList []string `json:"allowList,omitempty"`
 }
 
 func (this_ *NetProxyOuter) IsEnabled() bool {
@@ -189,6 +224,14 @@ func (this_ *NetProxyOuter) GetAddress() (str string) {
 	return GetAddress(this_.Address)
 }
 
+// GetNetwork 连接使用的网络类型，dynamic 连接 TCP
+func (this_ *NetProxyOuter) GetNetwork() (str string) {
+	if IsDynamicType(this_.Type) {
+		return "tcp"
+	}
+	return this_.GetType()
+}
+
 func GetAddress(address string) (str string) {
 	if address == "" {
 		return ""
@@ -242,7 +285,7 @@ func (this_ *Space) getToNodeListenerPoolIfAbsentCreate(toNodeId string) (pool *
 
 	pool, ok := this_.toNodeListenerPoolCache[toNodeId]
 	if !ok {
-		pool = &MessageListenerPool{}
+		pool = newMessageListenerPool(toNodeId, true)
 		this_.toNodeListenerPoolCache[toNodeId] = pool
 	}
 	return
@@ -295,7 +338,7 @@ func (this_ *Space) getFromNodeListenerPoolIfAbsentCreate(fromNodeId string) (po
 
 	pool, ok := this_.fromNodeListenerPoolCache[fromNodeId]
 	if !ok {
-		pool = &MessageListenerPool{}
+		pool = newMessageListenerPool(fromNodeId, false)
 		this_.fromNodeListenerPoolCache[fromNodeId] = pool
 	}
 	return
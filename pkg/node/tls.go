@@ -0,0 +1,231 @@
+package node
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// tlsHandshakeTimeout 节点连接 TLS 握手超时时间
+var tlsHandshakeTimeout = 10 * time.Second
+
+// TLSConfig 节点之间的传输加密配置，节点监听和连接其它节点都使用本节点证书，并双向验证对端证书
+// 对端证书验证方式：配置 CAFile 时验证证书由 CA 签发，配置 Fingerprints 时验证证书指纹，两者都配置时需同时满足
+type TLSConfig struct {
+	// CertFile、KeyFile 本节点证书和私钥，PEM 格式
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// CAFile 签发节点证书的 CA 证书
+	CAFile string `json:"caFile,omitempty"`
+	// Fingerprints 信任的对端证书 SHA256 指纹，用于自签名证书
+	Fingerprints []string `json:"fingerprints,omitempty"`
+}
+
+// nodeTLS 由 TLSConfig 生成的服务端、客户端配置
+type nodeTLS struct {
+	server *tls.Config
+	client *tls.Config
+}
+
+// FormatFingerprint 指纹统一为小写十六进制，忽略冒号和空格
+func FormatFingerprint(fingerprint string) string {
+	fingerprint = strings.ToLower(strings.TrimSpace(fingerprint))
+	fingerprint = strings.ReplaceAll(fingerprint, ":", "")
+	fingerprint = strings.ReplaceAll(fingerprint, " ", "")
+	return fingerprint
+}
+
+// CertFingerprint 证书的 SHA256 指纹
+func CertFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// CertFileFingerprint 读取 PEM 证书文件的 SHA256 指纹
+func CertFileFingerprint(certFile string) (fingerprint string, err error) {
+	bs, err := os.ReadFile(certFile)
+	if err != nil {
+		return
+	}
+	block, _ := pem.Decode(bs)
+	if block == nil || block.Type != "CERTIFICATE" {
+		err = errors.New("证书[" + certFile + "]格式错误")
+		return
+	}
+	fingerprint = CertFingerprint(block.Bytes)
+	return
+}
+
+// GenerateCert 生成自签名的节点证书和私钥，返回证书指纹，用于对端配置指纹验证
+func GenerateCert(id string, certFile string, keyFile string) (fingerprint string, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: id, Organization: []string{"Team IDE Node"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return
+	}
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		return
+	}
+	fingerprint = CertFingerprint(der)
+	return
+}
+
+// newNodeTLS 节点地址通常为 IP，不校验主机名，由 verifyPeer 验证对端证书
+func newNodeTLS(config *TLSConfig) (res *nodeTLS, err error) {
+	if config.CertFile == "" || config.KeyFile == "" {
+		err = errors.New("节点TLS证书、私钥不能为空")
+		return
+	}
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return
+	}
+	var roots *x509.CertPool
+	if config.CAFile != "" {
+		var bs []byte
+		bs, err = os.ReadFile(config.CAFile)
+		if err != nil {
+			return
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(bs) {
+			err = errors.New("CA证书[" + config.CAFile + "]格式错误")
+			return
+		}
+	}
+	fingerprints := map[string]bool{}
+	for _, one := range config.Fingerprints {
+		if one = FormatFingerprint(one); one != "" {
+			fingerprints[one] = true
+		}
+	}
+	if roots == nil && len(fingerprints) == 0 {
+		err = errors.New("节点TLS需要配置CA证书或对端证书指纹")
+		return
+	}
+	verify := func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		return verifyPeer(rawCerts, roots, fingerprints)
+	}
+	res = &nodeTLS{
+		server: &tls.Config{
+			Certificates:          []tls.Certificate{cert},
+			ClientAuth:            tls.RequireAnyClientCert,
+			VerifyPeerCertificate: verify,
+			MinVersion:            tls.VersionTLS12,
+		},
+		client: &tls.Config{
+			Certificates:          []tls.Certificate{cert},
+			InsecureSkipVerify:    true,
+			VerifyPeerCertificate: verify,
+			MinVersion:            tls.VersionTLS12,
+		},
+	}
+	return
+}
+
+func verifyPeer(rawCerts [][]byte, roots *x509.CertPool, fingerprints map[string]bool) (err error) {
+	if len(rawCerts) == 0 {
+		err = errors.New("对端未提供证书")
+		return
+	}
+	if len(fingerprints) > 0 && !fingerprints[CertFingerprint(rawCerts[0])] {
+		err = errors.New("对端证书指纹[" + CertFingerprint(rawCerts[0]) + "]不受信任")
+		return
+	}
+	if roots == nil {
+		return
+	}
+	var certs []*x509.Certificate
+	for _, raw := range rawCerts {
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(raw)
+		if err != nil {
+			return
+		}
+		certs = append(certs, cert)
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return
+}
+
+// handshake 完成 TLS 握手，握手失败时关闭连接
+func handshake(conn *tls.Conn) (err error) {
+	_ = conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout))
+	if err = conn.Handshake(); err != nil {
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return
+}
+
+// serverConn 配置了 TLS 时将接受的连接升级为 TLS
+func (this_ *Server) serverConn(conn net.Conn) (res net.Conn, err error) {
+	if this_.tls == nil {
+		res = conn
+		return
+	}
+	tlsConn := tls.Server(conn, this_.tls.server)
+	if err = handshake(tlsConn); err != nil {
+		return
+	}
+	res = tlsConn
+	return
+}
+
+// dialNode 连接其它节点，配置了 TLS 时使用本节点证书握手并验证对端证书
+func (this_ *Server) dialNode(address string) (conn net.Conn, err error) {
+	conn, err = net.Dial("tcp", GetAddress(address))
+	if err != nil || this_.tls == nil {
+		return
+	}
+	tlsConn := tls.Client(conn, this_.tls.client)
+	if err = handshake(tlsConn); err != nil {
+		return
+	}
+	conn = tlsConn
+	return
+}
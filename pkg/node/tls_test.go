@@ -0,0 +1,94 @@
+package node
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testTLSConfig 生成节点证书，返回未配置信任的 TLS 配置和证书指纹
+func testTLSConfig(t *testing.T, id string) (config *TLSConfig, fingerprint string) {
+	dir := t.TempDir()
+	config = &TLSConfig{
+		CertFile: filepath.Join(dir, id+".crt"),
+		KeyFile:  filepath.Join(dir, id+".key"),
+	}
+	fingerprint, err := GenerateCert(id, config.CertFile, config.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if find, _ := CertFileFingerprint(config.CertFile); find != fingerprint {
+		t.Fatal(find, fingerprint)
+	}
+	return
+}
+
+func testTLSServer(t *testing.T, config *TLSConfig, fingerprints ...string) (server *Server) {
+	config.Fingerprints = fingerprints
+	server = &Server{}
+	if err := server.SetTLS(config); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func testTLSConnect(t *testing.T, server *Server, client *Server) (serverErr error, clientErr error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	done := make(chan error, 1)
+	go func() {
+		conn, e := listener.Accept()
+		if e != nil {
+			done <- e
+			return
+		}
+		conn, e = server.serverConn(conn)
+		if e == nil {
+			_, e = conn.Write([]byte("ok"))
+			_ = conn.Close()
+		}
+		done <- e
+	}()
+	conn, clientErr := client.dialNode(listener.Addr().String())
+	if clientErr == nil {
+		bs := make([]byte, 2)
+		_, clientErr = conn.Read(bs)
+		_ = conn.Close()
+	}
+	serverErr = <-done
+	return
+}
+
+func TestNodeTLS(t *testing.T) {
+	rootConfig, rootFingerprint := testTLSConfig(t, "root")
+	nodeConfig, nodeFingerprint := testTLSConfig(t, "node-1")
+	otherConfig, _ := testTLSConfig(t, "other")
+
+	// 未配置 CA 和指纹时无法验证对端
+	if err := (&Server{}).SetTLS(rootConfig); err == nil {
+		t.Fatal("未配置信任时应返回错误")
+	}
+	if FormatFingerprint("AB:cd ef") != "abcdef" {
+		t.Fatal(FormatFingerprint("AB:cd ef"))
+	}
+
+	root := testTLSServer(t, rootConfig, strings.ToUpper(nodeFingerprint))
+	node := testTLSServer(t, nodeConfig, rootFingerprint)
+	if serverErr, clientErr := testTLSConnect(t, root, node); serverErr != nil || clientErr != nil {
+		t.Fatal(serverErr, clientErr)
+	}
+
+	// 不受信任的节点无法连接，也无法被连接
+	other := testTLSServer(t, otherConfig, rootFingerprint, nodeFingerprint)
+	if serverErr, _ := testTLSConnect(t, root, other); serverErr == nil {
+		t.Fatal("不受信任的客户端证书应握手失败")
+	}
+	if _, clientErr := testTLSConnect(t, other, node); clientErr == nil {
+		t.Fatal("不受信任的服务端证书应握手失败")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/team-ide/go-tool/util"
+	"sync"
 	"teamide/pkg/base"
 )
 
@@ -11,9 +12,13 @@ type Worker struct {
 	server *Server
 	*Space
 	MonitorData *MonitorData
+	// stopChan 停止连接探测等后台任务
+	stopChan chan struct{}
+	stopOnce sync.Once
 }
 
 func (this_ *Worker) Stop() {
+	this_.stopOnce.Do(func() { close(this_.stopChan) })
 	this_.removeToNodeListenerPoolList()
 	this_.removeFromNodeListenerPoolList()
 }
@@ -356,13 +356,16 @@ func (this_ *Worker) workSendBytesEnd(lineNodeIdList []string, key string) (err
 
 	err = onBytes.end()
 	this_.removeOnBytesCache(key)
+	if onBytes.err != nil {
+		err = onBytes.err
+	}
 
 	return
 }
 
 func (this_ *Worker) workSendBytes(lineNodeIdList []string, key string, buf []byte) (err error) {
 	send, err := this_.sendToNext(lineNodeIdList, key, func(listener *MessageListener) (e error) {
-		_, e = this_.Call(listener, methodSendBytes, &Message{
+		e = this_.callOrPost(listener, methodSendBytes, &Message{
 			LineNodeIdList: lineNodeIdList,
 			SendKey:        key,
 			HasBytes:       true,
@@ -384,7 +387,15 @@ func (this_ *Worker) workSendBytes(lineNodeIdList []string, key string, buf []by
 		return
 	}
 
+	// 多路复用连接中不等待结果，写入异常在结束时返回
+	if onBytes.err != nil {
+		err = onBytes.err
+		return
+	}
 	err = onBytes.on(buf)
+	if err != nil {
+		onBytes.err = err
+	}
 
 	return
 }
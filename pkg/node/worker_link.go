@@ -0,0 +1,224 @@
+package node
+
+import (
+	"github.com/team-ide/go-tool/util"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	// linkProbeInterval 相邻节点连接的探测间隔
+	linkProbeInterval = 10 * time.Second
+	// linkHistorySize 每个连接保留的探测记录数
+	linkHistorySize = 60
+)
+
+// NodeLinkProbe 一次探测结果，Rtt 为往返时间，单位纳秒
+type NodeLinkProbe struct {
+	Timestamp int64  `json:"timestamp,omitempty"`
+	Rtt       int64  `json:"rtt,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// NodeLink 本节点与相邻节点的连接，由本节点连接对端时 IsDial 为 true
+type NodeLink struct {
+	NodeId   string `json:"nodeId,omitempty"`
+	IsDial   bool   `json:"isDial"`
+	PoolSize int    `json:"poolSize"`
+	// Rtt 最近一次成功探测的往返时间，AvgRtt 探测记录中成功探测的平均往返时间，单位纳秒
+	Rtt           int64            `json:"rtt,omitempty"`
+	AvgRtt        int64            `json:"avgRtt,omitempty"`
+	ProbeCount    int64            `json:"probeCount,omitempty"`
+	ErrorCount    int64            `json:"errorCount,omitempty"`
+	LastError     string           `json:"lastError,omitempty"`
+	LastErrorTime int64            `json:"lastErrorTime,omitempty"`
+	LastProbeTime int64            `json:"lastProbeTime,omitempty"`
+	MonitorData   *MonitorData     `json:"monitorData,omitempty"`
+	HistoryList   []*NodeLinkProbe `json:"historyList,omitempty"`
+}
+
+// linkStat 连接探测结果，连接建立失败也记录为最近的异常
+type linkStat struct {
+	lock          sync.Mutex
+	probing       bool
+	rtt           int64
+	probeCount    int64
+	errorCount    int64
+	lastError     string
+	lastErrorTime int64
+	lastProbeTime int64
+	historyList   []*NodeLinkProbe
+}
+
+func (this_ *linkStat) startProbe() bool {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	if this_.probing {
+		return false
+	}
+	this_.probing = true
+	return true
+}
+
+func (this_ *linkStat) onProbe(rtt int64, err error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.probing = false
+	now := util.GetNowMilli()
+	probe := &NodeLinkProbe{
+		Timestamp: now,
+	}
+	this_.probeCount++
+	this_.lastProbeTime = now
+	if err != nil {
+		probe.Error = err.Error()
+		this_.errorCount++
+		this_.lastError = probe.Error
+		this_.lastErrorTime = now
+	} else {
+		probe.Rtt = rtt
+		this_.rtt = rtt
+	}
+	this_.historyList = append(this_.historyList, probe)
+	if len(this_.historyList) > linkHistorySize {
+		this_.historyList = this_.historyList[len(this_.historyList)-linkHistorySize:]
+	}
+}
+
+func (this_ *linkStat) onError(err error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.lastError = err.Error()
+	this_.lastErrorTime = util.GetNowMilli()
+}
+
+func (this_ *MessageListenerPool) getNodeLink() (link *NodeLink) {
+	link = &NodeLink{
+		NodeId:      this_.nodeId,
+		IsDial:      this_.isDial,
+		PoolSize:    this_.size(),
+		MonitorData: this_.MonitorData.snapshot(),
+	}
+	stat := this_.link
+	stat.lock.Lock()
+	defer stat.lock.Unlock()
+
+	link.Rtt = stat.rtt
+	link.ProbeCount = stat.probeCount
+	link.ErrorCount = stat.errorCount
+	link.LastError = stat.lastError
+	link.LastErrorTime = stat.lastErrorTime
+	link.LastProbeTime = stat.lastProbeTime
+	link.HistoryList = append(link.HistoryList, stat.historyList...)
+	var rttSum, rttCount int64
+	for _, one := range stat.historyList {
+		if one.Error == "" {
+			rttSum += one.Rtt
+			rttCount++
+		}
+	}
+	if rttCount > 0 {
+		link.AvgRtt = rttSum / rttCount
+	}
+	return
+}
+
+// linkConn 将节点连接的读写数据记录到连接池，多个本地节点共用连接时同时记录
+type linkConn struct {
+	net.Conn
+	monitorList []*MonitorData
+}
+
+func (this_ *linkConn) Read(b []byte) (n int, err error) {
+	n, err = this_.Conn.Read(b)
+	if n > 0 {
+		for _, one := range this_.monitorList {
+			one.monitorRead(int64(n), 0)
+		}
+	}
+	return
+}
+
+func (this_ *linkConn) Write(b []byte) (n int, err error) {
+	n, err = this_.Conn.Write(b)
+	if n > 0 {
+		for _, one := range this_.monitorList {
+			one.monitorWrite(int64(n), 0)
+		}
+	}
+	return
+}
+
+// linkProbeKeepAlive 定时探测所有相邻节点的连接
+func (this_ *Worker) linkProbeKeepAlive() {
+	for {
+		select {
+		case <-this_.stopChan:
+			return
+		case <-time.After(linkProbeInterval):
+		}
+		poolList := append(this_.getToNodeListenerPoolList(), this_.getFromNodeListenerPoolList()...)
+		for _, pool := range poolList {
+			go this_.probeLink(pool)
+		}
+	}
+}
+
+// probeLink 上一次探测未结束时跳过，避免连接阻塞时探测堆积
+func (this_ *Worker) probeLink(pool *MessageListenerPool) {
+	if pool.isStop || !pool.link.startProbe() {
+		return
+	}
+	listener, err := pool.GetOne("")
+	if err != nil {
+		pool.link.onProbe(0, err)
+		return
+	}
+	start := time.Now()
+	_, err = this_.Call(listener, methodNodePing, &Message{})
+	pool.link.onProbe(time.Since(start).Nanoseconds(), err)
+}
+
+// ping 沿节点线发送到最后一个节点，不带节点线时为相邻节点的探测，直接返回
+func (this_ *Worker) ping(lineNodeIdList []string) (err error) {
+	if len(lineNodeIdList) == 0 {
+		return
+	}
+	_, err = this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		_, e = this_.Call(listener, methodNodePing, &Message{
+			LineNodeIdList: lineNodeIdList,
+		})
+		return
+	})
+	return
+}
+
+func (this_ *Worker) getNodeLinkList(lineNodeIdList []string) (linkList []*NodeLink, err error) {
+	var resMsg *Message
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		resMsg, e = this_.Call(listener, methodNodeGetLinkList, &Message{
+			LineNodeIdList: lineNodeIdList,
+		})
+		return
+	})
+	if err != nil {
+		return
+	}
+	if send {
+		if resMsg != nil && resMsg.NodeWorkData != nil {
+			linkList = resMsg.NodeWorkData.LinkList
+		}
+		return
+	}
+
+	for _, pool := range this_.getToNodeListenerPoolList() {
+		linkList = append(linkList, pool.getNodeLink())
+	}
+	for _, pool := range this_.getFromNodeListenerPoolList() {
+		linkList = append(linkList, pool.getNodeLink())
+	}
+	return
+}
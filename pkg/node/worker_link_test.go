@@ -0,0 +1,73 @@
+package node
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNodeLink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootAddress := listener.Addr().String()
+	_ = listener.Close()
+
+	root := &Server{}
+	root.Start()
+	defer root.Stop()
+	root.AddLocalNode(&LocalNode{Id: "root", BindAddress: rootAddress, BindToken: "root-token"})
+
+	node := &Server{}
+	node.Start()
+	defer node.Stop()
+	node.AddLocalNode(&LocalNode{Id: "node-1"})
+	time.Sleep(100 * time.Millisecond)
+	_ = node.AddToNodeList([]string{"node-1"}, []*ToNode{
+		{Id: "root", ConnAddress: rootAddress, ConnToken: "root-token", ConnSize: 1},
+	})
+
+	var pool *MessageListenerPool
+	for i := 0; i < 50; i++ {
+		if pool = node.getToNodeListenerPool("root"); pool != nil && pool.size() > 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if pool == nil || pool.size() == 0 {
+		t.Fatal("节点未连接")
+	}
+
+	// 沿节点线探测
+	rtt, err := node.PingNode([]string{"node-1", "root"})
+	if err != nil || rtt <= 0 {
+		t.Fatal("节点线探测异常", rtt, err)
+	}
+
+	node.probeLink(pool)
+	linkList, err := node.GetNodeLinkList([]string{"node-1"})
+	if err != nil || len(linkList) != 1 {
+		t.Fatal("连接列表异常", err)
+	}
+	link := linkList[0]
+	if link.NodeId != "root" || !link.IsDial || link.PoolSize != 1 || link.ProbeCount != 1 || link.Rtt <= 0 || len(link.HistoryList) != 1 {
+		t.Fatal("连接探测结果异常", link)
+	}
+	if link.MonitorData.ReadSize == 0 || link.MonitorData.WriteSize == 0 {
+		t.Fatal("连接读写数据未记录")
+	}
+
+	// 对端的连接经由节点线查询
+	linkList, err = node.GetNodeLinkList([]string{"node-1", "root"})
+	if err != nil || len(linkList) != 1 || linkList[0].NodeId != "node-1" || linkList[0].IsDial {
+		t.Fatal("对端连接列表异常", err)
+	}
+
+	// 没有可用连接时记录异常
+	empty := newMessageListenerPool("empty", true)
+	node.probeLink(empty)
+	if link = empty.getNodeLink(); link.ErrorCount != 1 || link.LastError == "" || link.HistoryList[0].Error == "" {
+		t.Fatal("探测异常未记录", link)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"teamide/pkg/filework"
+	"teamide/pkg/terminal"
 	"time"
 )
 
@@ -17,6 +18,8 @@ var (
 	methodNodeRemoveToNodeList   MethodType = 102
 	methodNodeGetNodeMonitorData MethodType = 103
 	methodNodeGetStatus          MethodType = 104
+	methodNodePing               MethodType = 105
+	methodNodeGetLinkList        MethodType = 106
 
 	methodNetProxyNewConn                 MethodType = 201
 	methodNetProxyCloseConn               MethodType = 202
@@ -47,6 +50,8 @@ var (
 	methodTerminalChangeSize MethodType = 403
 	methodTerminalStop       MethodType = 404
 	methodTerminalIsWindows  MethodType = 405
+	methodTerminalExec       MethodType = 406
+	methodTerminalTelnet     MethodType = 407
 
 	methodSystemGetInfo          MethodType = 501
 	methodSystemQueryMonitorData MethodType = 502
@@ -69,6 +74,9 @@ func (this_ *Worker) onMessage(msg *Message) {
 		callback(msg)
 	} else {
 		res, err := this_.doMethod(msg.Method, msg)
+		if msg.Id == "" && err != nil {
+			Logger.Warn("message method error", zap.Any("method", msg.Method), zap.Error(err))
+		}
 		if msg.Id != "" {
 			if err != nil {
 				err = msg.ReturnError(err.Error(), this_.MonitorData)
@@ -136,6 +144,23 @@ func (this_ *Worker) Call(listener *MessageListener, method MethodType, msg *Mes
 	return
 }
 
+// Post 发送不等待结果的消息，只用于多路复用连接中的流消息，接收方按顺序处理，由流量控制限制未处理的数据
+func (this_ *Worker) Post(listener *MessageListener, method MethodType, msg *Message) (err error) {
+	msg.Method = method
+	err = listener.Send(msg, this_.MonitorData)
+	return
+}
+
+// callOrPost 多路复用连接中的流数据不等待结果，旧协议连接需要等待结果以保证顺序
+func (this_ *Worker) callOrPost(listener *MessageListener, method MethodType, msg *Message) (err error) {
+	if listener.isMux() {
+		err = this_.Post(listener, method, msg)
+		return
+	}
+	_, err = this_.Call(listener, method, msg)
+	return
+}
+
 func (this_ *Worker) doMethod(method MethodType, msg *Message) (res *Message, err error) {
 	if msg == nil {
 		return
@@ -169,6 +194,16 @@ func (this_ *Worker) doMethod(method MethodType, msg *Message) (res *Message, er
 			Status: status,
 		}
 		return
+	case methodNodePing:
+		err = this_.ping(msg.LineNodeIdList)
+		return
+	case methodNodeGetLinkList:
+		var linkList []*NodeLink
+		linkList, err = this_.getNodeLinkList(msg.LineNodeIdList)
+		res.NodeWorkData = &WorkData{
+			LinkList: linkList,
+		}
+		return
 	case methodNodeAddToNodeList:
 		if msg.NodeWorkData != nil {
 			this_.addToNodeList(msg.LineNodeIdList, msg.NodeWorkData.ToNodeList)
@@ -218,7 +253,7 @@ func (this_ *Worker) doMethod(method MethodType, msg *Message) (res *Message, er
 		return
 	case methodNetProxyNewConn:
 		if msg.NetProxyWorkData != nil {
-			err = this_.netProxyNewConn(msg.LineNodeIdList, msg.NetProxyWorkData.NetProxyId, msg.NetProxyWorkData.ConnId)
+			err = this_.netProxyNewConn(msg.LineNodeIdList, msg.NetProxyWorkData.NetProxyId, msg.NetProxyWorkData.ConnId, msg.NetProxyWorkData.Address)
 		}
 		return
 	case methodNetProxyCloseConn:
@@ -380,7 +415,23 @@ func (this_ *Worker) doMethod(method MethodType, msg *Message) (res *Message, er
 	case methodTerminalStart:
 		if msg.TerminalWorkData != nil {
 			var key string
-			key, err = this_.workTerminalStart(msg.LineNodeIdList, msg.TerminalWorkData.Size, msg.TerminalWorkData.ReadKey)
+			key, err = this_.workTerminalStart(msg.LineNodeIdList, msg.TerminalWorkData.Size, msg.TerminalWorkData.ReadKey, nil)
+			if err != nil {
+				return
+			}
+			res.TerminalWorkData = &TerminalWorkData{
+				Key: key,
+			}
+		}
+		return
+	case methodTerminalTelnet:
+		if msg.TerminalWorkData != nil {
+			if msg.TerminalWorkData.Telnet == nil {
+				err = errors.New("telnet 配置不能为空")
+				return
+			}
+			var key string
+			key, err = this_.workTerminalStart(msg.LineNodeIdList, msg.TerminalWorkData.Size, msg.TerminalWorkData.ReadKey, msg.TerminalWorkData.Telnet)
 			if err != nil {
 				return
 			}
@@ -401,6 +452,18 @@ func (this_ *Worker) doMethod(method MethodType, msg *Message) (res *Message, er
 			}
 		}
 		return
+	case methodTerminalExec:
+		if msg.TerminalWorkData != nil {
+			var execResult *terminal.ExecResult
+			execResult, err = this_.workTerminalExec(msg.LineNodeIdList, msg.TerminalWorkData.Command, msg.TerminalWorkData.Timeout)
+			if err != nil {
+				return
+			}
+			res.TerminalWorkData = &TerminalWorkData{
+				ExecResult: execResult,
+			}
+		}
+		return
 	case methodTerminalStop:
 		if msg.TerminalWorkData != nil {
 			err = this_.workTerminalStop(msg.LineNodeIdList, msg.TerminalWorkData.Key)
@@ -16,7 +16,7 @@ func (this_ *Worker) doAddNetProxyInnerList(netProxyList []*NetProxyInner) (err
 
 		var find = this_.findInnerNetProxy(netProxy.Id)
 		if find == nil {
-			Logger.Info(this_.server.GetServerInfo()+" doAddNetProxyInnerList ", zap.Any("netProxy", netProxy))
+			Logger.Info(this_.server.GetServerInfo()+" doAddNetProxyInnerList ", zap.Any("netProxy", netProxy.GetLogInfo()))
 			this_.netProxyInnerList = append(this_.netProxyInnerList, netProxy)
 
 			if netProxy.IsEnabled() {
@@ -39,13 +39,27 @@ func (this_ *Worker) doAddNetProxyInnerList(netProxyList []*NetProxyInner) (err
 				hasChange = true
 				find.Address = netProxy.Address
 			}
+			if netProxy.Username != find.Username || netProxy.Password != find.Password || !stringListEqual(netProxy.AllowList, find.AllowList) {
+				hasChange = true
+				find.Username = netProxy.Username
+				find.Password = netProxy.Password
+				find.AllowList = netProxy.AllowList
+			}
+			// 只修改连接策略时不重启监听，新策略对之后的连接和数据生效
+			limitChange := !netProxy.Limit.equal(find.Limit)
+			find.Limit = netProxy.Limit
 
 			if hasChange {
-				Logger.Info(this_.server.GetServerInfo()+" 更新网络代理 ", zap.Any("netProxy", netProxy))
+				Logger.Info(this_.server.GetServerInfo()+" 更新网络代理 ", zap.Any("netProxy", netProxy.GetLogInfo()))
 				_ = this_.removeNetProxyInner(netProxy.Id)
 				if find.IsEnabled() {
 					_ = this_.getNetProxyInnerIfAbsentCreate(netProxy, this_)
 				}
+			} else if limitChange {
+				Logger.Info(this_.server.GetServerInfo()+" 更新网络代理策略 ", zap.Any("netProxy", netProxy.GetLogInfo()))
+				if inner := this_.getNetProxyInner(netProxy.Id); inner != nil {
+					inner.setLimit(netProxy.Limit)
+				}
 			}
 
 		}
@@ -88,6 +102,10 @@ func (this_ *Worker) doAddNetProxyOuterList(netProxyList []*NetProxyOuter) (err
 				hasChange = true
 				find.Address = netProxy.Address
 			}
+			if !stringListEqual(netProxy.AllowList, find.AllowList) {
+				hasChange = true
+				find.AllowList = netProxy.AllowList
+			}
 
 			if hasChange {
 				Logger.Info(this_.server.GetServerInfo()+" 更新网络代理 ", zap.Any("netProxy", netProxy))
@@ -151,3 +169,15 @@ func (this_ *Worker) doRemoveNetProxyOuterList(removeNetProxyIdList []string) (e
 	this_.netProxyOuterList = newList
 	return
 }
+
+func stringListEqual(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -111,8 +111,10 @@ func (this_ *Worker) connNodeListener(pool *MessageListenerPool, connAddress, co
 		return
 	}
 	var messageListener *MessageListener
+	// isRedundant 对端支持多路复用时不再需要多个连接
+	var isRedundant bool
 	defer func() {
-		if messageListener != nil {
+		if messageListener != nil || isRedundant {
 			return
 		}
 		if pool != nil && pool.isStop {
@@ -124,9 +126,12 @@ func (this_ *Worker) connNodeListener(pool *MessageListenerPool, connAddress, co
 	var err error
 	var conn net.Conn
 	Logger.Info("连接 [" + connAddress + "] 开始")
-	conn, err = net.Dial("tcp", GetAddress(connAddress))
+	conn, err = this_.server.dialNode(connAddress)
 	if err != nil {
 		Logger.Warn("连接 ["+connAddress+"] 异常", zap.Any("error", err.Error()))
+		if pool != nil {
+			pool.link.onError(err)
+		}
 		return
 	}
 
@@ -148,8 +153,10 @@ func (this_ *Worker) connNodeListener(pool *MessageListenerPool, connAddress, co
 	var msg = &Message{
 		Method: methodOK,
 		ConnData: &ConnData{
-			ConnIndex:  connIndex,
-			NodeIdList: this_.server.GetLocalNodeIdList(),
+			ConnIndex:    connIndex,
+			NodeIdList:   this_.server.GetLocalNodeIdList(),
+			Protocol:     protocolVersion,
+			CompressList: supportCompressList,
 		},
 	}
 
@@ -162,6 +169,9 @@ func (this_ *Worker) connNodeListener(pool *MessageListenerPool, connAddress, co
 	msg, err = ReadMessage(conn, this_.MonitorData)
 	if err != nil {
 		Logger.Warn("连接 [" + connAddress + "] 接口异常")
+		if pool != nil {
+			pool.link.onError(err)
+		}
 		_ = conn.Close()
 		return
 	}
@@ -171,12 +181,24 @@ func (this_ *Worker) connNodeListener(pool *MessageListenerPool, connAddress, co
 		return
 	}
 	toNodeId := msg.ConnData.NodeId
+	if msg.ConnData.Protocol >= protocolMux && connIndex >= muxConnSize {
+		Logger.Info("连接 [" + toNodeId + "] [" + connAddress + "] 支持多路复用，关闭多余连接 " + fmt.Sprint(connIndex))
+		isRedundant = true
+		_ = conn.Close()
+		return
+	}
 	pool = this_.getToNodeListenerPoolIfAbsentCreate(toNodeId)
-	Logger.Info("连接 [" + toNodeId + "] [" + connAddress + "] 成功")
+	conn = &linkConn{Conn: conn, monitorList: []*MonitorData{pool.MonitorData}}
+	var mux *muxConn
+	if msg.ConnData.Protocol >= protocolMux {
+		mux = newMuxConn(conn, msg.ConnData.Compress, this_.MonitorData)
+	}
+	Logger.Info("连接 ["+toNodeId+"] ["+connAddress+"] 成功", zap.Any("protocol", msg.ConnData.Protocol), zap.Any("compress", msg.ConnData.Compress))
 
 	messageListener = &MessageListener{
 		conn:      conn,
 		onMessage: this_.onMessage,
+		mux:       mux,
 	}
 
 	messageListener.listen(func() {
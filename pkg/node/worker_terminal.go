@@ -4,16 +4,24 @@ import (
 	"errors"
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
+	"teamide/pkg/telnet"
 	"teamide/pkg/terminal"
+	"time"
 )
 
-func (this_ *Worker) workTerminalStart(lineNodeIdList []string, size *terminal.Size, readKey string) (key string, err error) {
+// workTerminalStart telnetConfig 不为空时由最后一个节点连接 Telnet
+func (this_ *Worker) workTerminalStart(lineNodeIdList []string, size *terminal.Size, readKey string, telnetConfig *telnet.Config) (key string, err error) {
 	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
-		res, e := this_.Call(listener, methodTerminalStart, &Message{
+		method := methodTerminalStart
+		if telnetConfig != nil {
+			method = methodTerminalTelnet
+		}
+		res, e := this_.Call(listener, method, &Message{
 			LineNodeIdList: lineNodeIdList,
 			TerminalWorkData: &TerminalWorkData{
 				Size:    size,
 				ReadKey: readKey,
+				Telnet:  telnetConfig,
 			},
 		})
 		if e != nil {
@@ -30,7 +38,12 @@ func (this_ *Worker) workTerminalStart(lineNodeIdList []string, size *terminal.S
 		return
 	}
 
-	service := terminal.NewLocalService()
+	var service terminal.Service
+	if telnetConfig != nil {
+		service = telnet.NewTerminalService(telnetConfig, nil)
+	} else {
+		service = terminal.NewLocalService()
+	}
 	err = service.Start(size)
 	if err != nil {
 		return
@@ -176,3 +189,32 @@ func (this_ *Worker) workTerminalWrite(lineNodeIdList []string, key string, buf
 
 	return
 }
+
+// workTerminalExec 在节点上非交互执行命令，受节点调用超时限制，timeout 需小于调用超时
+func (this_ *Worker) workTerminalExec(lineNodeIdList []string, command string, timeout int64) (res *terminal.ExecResult, err error) {
+	send, err := this_.sendToNext(lineNodeIdList, "", func(listener *MessageListener) (e error) {
+		r, e := this_.Call(listener, methodTerminalExec, &Message{
+			LineNodeIdList: lineNodeIdList,
+			TerminalWorkData: &TerminalWorkData{
+				Command: command,
+				Timeout: timeout,
+			},
+		})
+		if e != nil {
+			return
+		}
+
+		if r != nil && r.TerminalWorkData != nil {
+			res = r.TerminalWorkData.ExecResult
+		}
+
+		return
+	})
+	if err != nil || send {
+		return
+	}
+
+	res, err = terminal.Exec(command, time.Duration(timeout)*time.Millisecond)
+
+	return
+}
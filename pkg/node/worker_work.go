@@ -1,12 +1,13 @@
 package node
 
-func (this_ *Worker) netProxyNewConn(lineNodeIdList []string, netProxyId string, connId string) (err error) {
+func (this_ *Worker) netProxyNewConn(lineNodeIdList []string, netProxyId string, connId string, address string) (err error) {
 	send, err := this_.sendToNext(lineNodeIdList, connId, func(listener *MessageListener) (e error) {
 		_, e = this_.Call(listener, methodNetProxyNewConn, &Message{
 			LineNodeIdList: lineNodeIdList,
 			NetProxyWorkData: &NetProxyWorkData{
 				NetProxyId: netProxyId,
 				ConnId:     connId,
+				Address:    address,
 			},
 		})
 		return
@@ -19,7 +20,7 @@ func (this_ *Worker) netProxyNewConn(lineNodeIdList []string, netProxyId string,
 	}
 	outer := this_.getNetProxyOuter(netProxyId)
 	if outer != nil {
-		err = outer.newConn(connId)
+		err = outer.newConn(connId, address)
 	}
 	if err != nil {
 		return
@@ -64,7 +65,7 @@ func (this_ *Worker) netProxyCloseConn(isReverse bool, lineNodeIdList []string,
 
 func (this_ *Worker) netProxySend(isReverse bool, lineNodeIdList []string, netProxyId string, connId string, bytes []byte) (err error) {
 	send, err := this_.sendToNext(lineNodeIdList, connId, func(listener *MessageListener) (e error) {
-		_, e = this_.Call(listener, methodNetProxySend, &Message{
+		e = this_.callOrPost(listener, methodNetProxySend, &Message{
 			LineNodeIdList: lineNodeIdList,
 			HasBytes:       true,
 			Bytes:          bytes,
@@ -0,0 +1,155 @@
+package process
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Port 监听的端口
+type Port struct {
+	// Protocol tcp、tcp6、udp、udp6
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Port     int    `json:"port"`
+	Inode    string `json:"inode,omitempty"`
+	Uid      int    `json:"uid"`
+	User     string `json:"user,omitempty"`
+	// Pid 没有权限读取进程的文件描述符时为 0
+	Pid  int    `json:"pid,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// portScript 读取 TCP、UDP 套接字和进程打开的套接字文件描述符
+const portScript = `echo @@tcp; cat /proc/net/tcp 2>/dev/null
+echo @@tcp6; cat /proc/net/tcp6 2>/dev/null
+echo @@udp; cat /proc/net/udp 2>/dev/null
+echo @@udp6; cat /proc/net/udp6 2>/dev/null
+echo @@passwd; cat /etc/passwd 2>/dev/null
+echo @@comm; grep -H . /proc/[0-9]*/comm 2>/dev/null
+echo @@fd; ls -l /proc/[0-9]*/fd 2>/dev/null | grep -e '^/proc/' -e 'socket:'
+echo @@end
+`
+
+const (
+	// tcpListen /proc/net/tcp 中 LISTEN 的状态
+	tcpListen = "0A"
+	// udpUnconnected /proc/net/udp 中未连接（绑定监听）的状态
+	udpUnconnected = "07"
+)
+
+// ListPorts 采集监听的端口以及所属进程
+func ListPorts(exec ExecFunc) (list []*Port, err error) {
+	stdout, err := run(exec, portScript)
+	if err != nil {
+		return
+	}
+	list = parsePorts(splitSections(stdout))
+	return
+}
+
+// parseHexAddress 解析 /proc/net/tcp 中的地址，如 0100007F:0016，IP 按 32 位主机字节序（小端）存储
+func parseHexAddress(value string) (address string, port int, ok bool) {
+	index := strings.IndexByte(value, ':')
+	if index < 0 {
+		return
+	}
+	bs, err := hex.DecodeString(value[:index])
+	if err != nil || (len(bs) != 4 && len(bs) != 16) {
+		return
+	}
+	p, err := strconv.ParseUint(value[index+1:], 16, 16)
+	if err != nil {
+		return
+	}
+	ip := make(net.IP, len(bs))
+	for i := 0; i < len(bs); i += 4 {
+		binary.BigEndian.PutUint32(ip[i:], binary.LittleEndian.Uint32(bs[i:]))
+	}
+	address = ip.String()
+	port = int(p)
+	ok = true
+	return
+}
+
+// parseSockets 解析 /proc/net/tcp 等文件中指定状态的套接字
+func parseSockets(protocol string, text string, state string) (list []*Port) {
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 10 || fields[3] != state {
+			continue
+		}
+		address, port, ok := parseHexAddress(fields[1])
+		if !ok {
+			continue
+		}
+		uid, _ := strconv.Atoi(fields[7])
+		list = append(list, &Port{
+			Protocol: protocol,
+			Address:  address,
+			Port:     port,
+			Uid:      uid,
+			Inode:    fields[9],
+		})
+	}
+	return
+}
+
+var (
+	fdDirRegexp  = regexp.MustCompile(`^/proc/(\d+)/fd:$`)
+	socketRegexp = regexp.MustCompile(`socket:\[(\d+)]`)
+	commRegexp   = regexp.MustCompile(`^/proc/(\d+)/comm:(.*)$`)
+)
+
+// parseSocketOwners 解析 ls -l /proc/[pid]/fd 获取套接字 inode 所属的进程
+func parseSocketOwners(text string) (res map[string]int) {
+	res = map[string]int{}
+	var pid int
+	for _, line := range strings.Split(text, "\n") {
+		if match := fdDirRegexp.FindStringSubmatch(line); match != nil {
+			pid, _ = strconv.Atoi(match[1])
+			continue
+		}
+		if match := socketRegexp.FindStringSubmatch(line); match != nil && pid > 0 {
+			if _, ok := res[match[1]]; !ok {
+				res[match[1]] = pid
+			}
+		}
+	}
+	return
+}
+
+func parsePorts(sections map[string]string) (list []*Port) {
+	list = append(list, parseSockets("tcp", sections["tcp"], tcpListen)...)
+	list = append(list, parseSockets("tcp6", sections["tcp6"], tcpListen)...)
+	list = append(list, parseSockets("udp", sections["udp"], udpUnconnected)...)
+	list = append(list, parseSockets("udp6", sections["udp6"], udpUnconnected)...)
+
+	users := parsePasswd(sections["passwd"])
+	owners := parseSocketOwners(sections["fd"])
+	names := map[int]string{}
+	for _, line := range strings.Split(sections["comm"], "\n") {
+		if match := commRegexp.FindStringSubmatch(line); match != nil {
+			pid, _ := strconv.Atoi(match[1])
+			names[pid] = match[2]
+		}
+	}
+	for _, one := range list {
+		one.User = users[one.Uid]
+		if pid, ok := owners[one.Inode]; ok {
+			one.Pid = pid
+			one.Name = names[pid]
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Port != list[j].Port {
+			return list[i].Port < list[j].Port
+		}
+		return list[i].Protocol < list[j].Protocol
+	})
+	return
+}
@@ -0,0 +1,512 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"teamide/pkg/terminal"
+	"time"
+)
+
+// ExecFunc 非交互执行命令
+type ExecFunc func(command string, timeout time.Duration) (res *terminal.ExecResult, err error)
+
+const (
+	// execTimeout 执行采集命令的超时时间
+	execTimeout = 30 * time.Second
+)
+
+// Process 进程信息
+type Process struct {
+	Pid     int     `json:"pid"`
+	PPid    int     `json:"ppid"`
+	Uid     int     `json:"uid"`
+	User    string  `json:"user,omitempty"`
+	Name    string  `json:"name,omitempty"`
+	State   string  `json:"state,omitempty"`
+	Cpu     float64 `json:"cpu"`
+	Mem     float64 `json:"mem"`
+	Rss     uint64  `json:"rss"`
+	Vsz     uint64  `json:"vsz"`
+	Threads int     `json:"threads"`
+	// StartTime 启动时间，毫秒
+	StartTime int64  `json:"startTime,omitempty"`
+	Cmdline   string `json:"cmdline,omitempty"`
+
+	Children []*Process `json:"children,omitempty"`
+
+	cpuTicks uint64
+}
+
+// listScript 采集系统信息，输出以 @@ 开头的行分段，内容不包含进程可以修改的数据
+const listScript = `echo @@pagesize; getconf PAGESIZE 2>/dev/null
+echo @@clktck; getconf CLK_TCK 2>/dev/null
+echo @@meminfo; grep MemTotal /proc/meminfo
+echo @@cpus; grep -c '^cpu[0-9]' /proc/stat
+echo @@btime; grep '^btime' /proc/stat
+echo @@passwd; cat /etc/passwd 2>/dev/null
+echo @@end
+`
+
+// statScript 读取 CPU 总时间、进程所有者和进程状态，进程名可以包含换行，进程状态放在 @@stat 之后的最后部分，不再分段
+const statScript = `head -n1 /proc/stat
+ls -ldn /proc/[0-9]* 2>/dev/null
+echo @@stat; cat /proc/[0-9]*/stat 2>/dev/null
+`
+
+// cmdlineScript 读取进程的命令行，每个进程一行，以进程号开头，命令行最多读取 1024 字节并将 \0、换行替换为空格
+const cmdlineScript = `for p in %s; do printf '%%s ' $p; head -c 1024 /proc/$p/cmdline 2>/dev/null | tr '\000\n\r' '   '; echo; done
+`
+
+const (
+	// statInterval 两次读取进程状态的间隔，用于计算 CPU 使用率
+	statInterval = 500 * time.Millisecond
+	// cmdlinePageSize 每次读取命令行的进程数，避免进程较多时输出超过限制
+	cmdlinePageSize = 500
+)
+
+// run 执行命令，Linux 以外的系统没有 /proc 无法采集
+func run(exec ExecFunc, command string) (stdout string, err error) {
+	res, err := exec(command, execTimeout)
+	if err != nil {
+		return
+	}
+	stdout = res.Stdout
+	if !strings.Contains(stdout, "@@end") {
+		if res.Truncated {
+			err = errors.New("输出内容超过限制，无法解析")
+		} else {
+			err = errors.New("采集失败，只支持 Linux 系统:" + strings.TrimSpace(res.Stderr))
+		}
+		return
+	}
+	return
+}
+
+// splitSections 按 @@ 开头的行分段
+func splitSections(stdout string) (sections map[string]string) {
+	sections = map[string]string{}
+	var name string
+	var builder strings.Builder
+	for _, line := range strings.SplitAfter(stdout, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			if name != "" {
+				sections[name] = builder.String()
+			}
+			name = strings.TrimSpace(line[2:])
+			builder.Reset()
+			continue
+		}
+		builder.WriteString(line)
+	}
+	if name != "" {
+		sections[name] = builder.String()
+	}
+	return
+}
+
+// List 采集进程列表，间隔 statInterval 两次读取进程状态计算 CPU 使用率
+func List(exec ExecFunc) (list []*Process, err error) {
+	before, err := readStats(exec)
+	if err != nil {
+		return
+	}
+	time.Sleep(statInterval)
+	after, err := readStats(exec)
+	if err != nil {
+		return
+	}
+	stdout, err := run(exec, listScript)
+	if err != nil {
+		return
+	}
+	var pidList []int
+	for pid := range after.stats {
+		pidList = append(pidList, pid)
+	}
+	sort.Ints(pidList)
+	cmdlines, err := readCmdlines(exec, pidList)
+	if err != nil {
+		return
+	}
+	list = parseList(splitSections(stdout), before, after, cmdlines)
+	return
+}
+
+// statResult 一次读取的 CPU 总时间、进程所有者和进程状态
+type statResult struct {
+	cpuTotal uint64
+	owners   map[int]int
+	stats    map[int]*Process
+}
+
+func readStats(exec ExecFunc) (res *statResult, err error) {
+	out, err := exec(statScript, execTimeout)
+	if err != nil {
+		return
+	}
+	if out.Truncated {
+		err = errors.New("输出内容超过限制，无法解析")
+		return
+	}
+	res, err = parseStatOutput(out.Stdout)
+	if err != nil {
+		err = errors.New(err.Error() + strings.TrimSpace(out.Stderr))
+		return
+	}
+	return
+}
+
+// parseStatOutput 以第一个 @@stat 行分隔，之后的内容都是进程状态
+func parseStatOutput(stdout string) (res *statResult, err error) {
+	index := strings.Index(stdout, "\n@@stat\n")
+	if index < 0 || !strings.HasPrefix(stdout, "cpu ") {
+		err = errors.New("采集失败，只支持 Linux 系统:")
+		return
+	}
+	head := stdout[:index]
+	cpuLine := head
+	var owners string
+	if i := strings.IndexByte(head, '\n'); i >= 0 {
+		cpuLine = head[:i]
+		owners = head[i+1:]
+	}
+	res = &statResult{
+		cpuTotal: parseCpuTotal(cpuLine),
+		owners:   parseOwners(owners),
+		stats:    parseStats(stdout[index+len("\n@@stat\n"):]),
+	}
+	return
+}
+
+// readCmdlines 按 cmdlinePageSize 分页读取进程的命令行
+func readCmdlines(exec ExecFunc, pidList []int) (res map[int]string, err error) {
+	res = map[int]string{}
+	for start := 0; start < len(pidList); start += cmdlinePageSize {
+		end := start + cmdlinePageSize
+		if end > len(pidList) {
+			end = len(pidList)
+		}
+		var pids []string
+		for _, pid := range pidList[start:end] {
+			pids = append(pids, strconv.Itoa(pid))
+		}
+		var out *terminal.ExecResult
+		out, err = exec(fmt.Sprintf(cmdlineScript, strings.Join(pids, " ")), execTimeout)
+		if err != nil {
+			return
+		}
+		if out.Truncated {
+			err = errors.New("输出内容超过限制，无法解析")
+			return
+		}
+		for pid, cmdline := range parseCmdlines(out.Stdout) {
+			res[pid] = cmdline
+		}
+	}
+	return
+}
+
+func parseUint(s string) uint64 {
+	v, _ := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+	return v
+}
+
+// parseCpuTotal 解析 /proc/stat 第一行的 CPU 总时间
+func parseCpuTotal(line string) (total uint64) {
+	fields := strings.Fields(line)
+	for i := 1; i < len(fields); i++ {
+		total += parseUint(fields[i])
+	}
+	return
+}
+
+// parseStat 解析 /proc/[pid]/stat，进程名可能包含空格和括号，以最后一个右括号分隔
+func parseStat(line string) (one *Process) {
+	start := strings.IndexByte(line, '(')
+	end := strings.LastIndexByte(line, ')')
+	if start < 0 || end < start {
+		return
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(line[:start]))
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 22 {
+		return
+	}
+	one = &Process{
+		Pid:      pid,
+		Name:     line[start+1 : end],
+		State:    fields[0],
+		cpuTicks: parseUint(fields[11]) + parseUint(fields[12]),
+		Vsz:      parseUint(fields[20]),
+		Rss:      parseUint(fields[21]),
+	}
+	one.PPid, _ = strconv.Atoi(fields[1])
+	one.Threads, _ = strconv.Atoi(fields[17])
+	// 启动时间暂存为开机后的时钟周期数
+	one.StartTime = int64(parseUint(fields[19]))
+	return
+}
+
+// parseStats 进程名包含换行时，进程状态被分成多行，解析失败的行之后的一行可能是进程名伪造的，忽略
+func parseStats(text string) (res map[int]*Process) {
+	res = map[int]*Process{}
+	var broken bool
+	for _, line := range strings.Split(text, "\n") {
+		one := parseStat(line)
+		if one == nil {
+			broken = line != ""
+			continue
+		}
+		if !broken {
+			res[one.Pid] = one
+		}
+		broken = false
+	}
+	return
+}
+
+// parseOwners 解析 ls -ldn /proc/[pid] 获取进程的用户 ID
+func parseOwners(text string) (res map[int]int) {
+	res = map[int]int{}
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimPrefix(fields[len(fields)-1], "/proc/"))
+		if err != nil {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		res[pid] = uid
+	}
+	return
+}
+
+// parsePasswd 解析 /etc/passwd 获取用户名
+func parsePasswd(text string) (res map[int]string) {
+	res = map[int]string{}
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		if _, ok := res[uid]; !ok {
+			res[uid] = fields[0]
+		}
+	}
+	return
+}
+
+// parseCmdlines 解析 cmdlineScript 的输出，每行为进程号和命令行
+func parseCmdlines(text string) (res map[int]string) {
+	res = map[int]string{}
+	for _, line := range strings.Split(text, "\n") {
+		index := strings.IndexByte(line, ' ')
+		if index <= 0 {
+			continue
+		}
+		pid, err := strconv.Atoi(line[:index])
+		if err != nil {
+			continue
+		}
+		res[pid] = strings.TrimSpace(line[index+1:])
+	}
+	return
+}
+
+func parseList(sections map[string]string, before *statResult, after *statResult, cmdlines map[int]string) (list []*Process) {
+	pageSize := parseUint(sections["pagesize"])
+	if pageSize == 0 {
+		pageSize = 4096
+	}
+	clkTck := parseUint(sections["clktck"])
+	if clkTck == 0 {
+		clkTck = 100
+	}
+	cpus := parseUint(sections["cpus"])
+	if cpus == 0 {
+		cpus = 1
+	}
+	// MemTotal 单位为 kB
+	var memTotal uint64
+	if fields := strings.Fields(sections["meminfo"]); len(fields) >= 2 {
+		memTotal = parseUint(fields[1]) * 1024
+	}
+	var bootTime int64
+	if fields := strings.Fields(sections["btime"]); len(fields) >= 2 {
+		bootTime = int64(parseUint(fields[1]))
+	}
+	var cpuDelta uint64
+	if after.cpuTotal > before.cpuTotal {
+		cpuDelta = after.cpuTotal - before.cpuTotal
+	}
+	users := parsePasswd(sections["passwd"])
+
+	for pid, one := range after.stats {
+		// CPU 使用率按单核计算，多核时可能超过 100
+		if last, ok := before.stats[pid]; ok && cpuDelta > 0 && one.cpuTicks >= last.cpuTicks {
+			one.Cpu = round(float64(one.cpuTicks-last.cpuTicks) / float64(cpuDelta) * float64(cpus) * 100)
+		}
+		one.Rss *= pageSize
+		if memTotal > 0 {
+			one.Mem = round(float64(one.Rss) / float64(memTotal) * 100)
+		}
+		if bootTime > 0 {
+			one.StartTime = (bootTime*int64(clkTck) + one.StartTime) * 1000 / int64(clkTck)
+		} else {
+			one.StartTime = 0
+		}
+		if uid, ok := after.owners[pid]; ok {
+			one.Uid = uid
+			one.User = users[uid]
+			if one.User == "" {
+				one.User = strconv.Itoa(uid)
+			}
+		}
+		one.Cmdline = cmdlines[pid]
+		list = append(list, one)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Pid < list[j].Pid
+	})
+	return
+}
+
+func round(v float64) float64 {
+	return float64(int64(v*100+0.5)) / 100
+}
+
+// Query 进程查询条件
+type Query struct {
+	// Filter 匹配进程号、名称、用户、命令行
+	Filter string `json:"filter,omitempty"`
+	User   string `json:"user,omitempty"`
+	// Sort 排序字段 pid、cpu、mem、rss、name、user、startTime，默认按 CPU 倒序
+	Sort string `json:"sort,omitempty"`
+	Asc  bool   `json:"asc,omitempty"`
+	// Tree 按父子关系组成树
+	Tree  bool `json:"tree,omitempty"`
+	Limit int  `json:"limit,omitempty"`
+}
+
+func (this_ *Query) match(one *Process) bool {
+	if this_.User != "" && one.User != this_.User {
+		return false
+	}
+	if this_.Filter == "" {
+		return true
+	}
+	filter := strings.ToLower(this_.Filter)
+	return strconv.Itoa(one.Pid) == filter ||
+		strings.Contains(strings.ToLower(one.Name), filter) ||
+		strings.Contains(strings.ToLower(one.User), filter) ||
+		strings.Contains(strings.ToLower(one.Cmdline), filter)
+}
+
+func (this_ *Query) less(a *Process, b *Process) bool {
+	switch this_.Sort {
+	case "pid":
+		return a.Pid < b.Pid
+	case "mem":
+		return a.Mem < b.Mem
+	case "rss":
+		return a.Rss < b.Rss
+	case "name":
+		return a.Name < b.Name
+	case "user":
+		return a.User < b.User
+	case "startTime":
+		return a.StartTime < b.StartTime
+	}
+	return a.Cpu < b.Cpu
+}
+
+// Apply 过滤、排序，树形展示时父进程不在结果中的作为根节点，Limit 只限制根节点数量
+func (this_ *Query) Apply(list []*Process) (res []*Process) {
+	var matched []*Process
+	for _, one := range list {
+		if this_.match(one) {
+			matched = append(matched, one)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if this_.Asc {
+			return this_.less(matched[i], matched[j])
+		}
+		return this_.less(matched[j], matched[i])
+	})
+	if this_.Tree {
+		matched = BuildTree(matched)
+	}
+	if this_.Limit > 0 && len(matched) > this_.Limit {
+		matched = matched[:this_.Limit]
+	}
+	res = matched
+	return
+}
+
+// BuildTree 按父子关系组成树，保持列表中的顺序
+func BuildTree(list []*Process) (roots []*Process) {
+	cache := map[int]*Process{}
+	for _, one := range list {
+		one.Children = nil
+		cache[one.Pid] = one
+	}
+	for _, one := range list {
+		if parent, ok := cache[one.PPid]; ok && one.PPid != one.Pid {
+			parent.Children = append(parent.Children, one)
+		} else {
+			roots = append(roots, one)
+		}
+	}
+	return
+}
+
+// signals 允许发送的信号
+var signals = map[string]bool{
+	"TERM": true, "KILL": true, "HUP": true, "INT": true, "QUIT": true,
+	"USR1": true, "USR2": true, "STOP": true, "CONT": true,
+}
+
+// Signal 向进程发送信号，默认 TERM
+func Signal(exec ExecFunc, pid int, signal string) (err error) {
+	if pid <= 1 {
+		err = errors.New("进程号[" + strconv.Itoa(pid) + "]无效")
+		return
+	}
+	signal = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(signal)), "SIG")
+	if signal == "" {
+		signal = "TERM"
+	}
+	if !signals[signal] {
+		err = errors.New("不支持的信号[" + signal + "]")
+		return
+	}
+	res, err := exec("kill -s "+signal+" "+strconv.Itoa(pid), execTimeout)
+	if err != nil {
+		return
+	}
+	if res.ExitCode != 0 {
+		msg := strings.TrimSpace(res.Stderr)
+		if msg == "" {
+			msg = "退出码:" + strconv.Itoa(res.ExitCode)
+		}
+		err = errors.New("发送信号失败:" + msg)
+		return
+	}
+	return
+}
@@ -0,0 +1,73 @@
+package process
+
+import (
+	"os"
+	"runtime"
+	"teamide/pkg/terminal"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	one := parseStat("42 (my (app) x) S 1 42 42 0 -1 4194560 100 0 0 0 150 50 0 0 20 0 3 0 1000 2000000 256 18446744073709551615")
+	if one == nil || one.Name != "my (app) x" || one.PPid != 1 || one.cpuTicks != 200 || one.Threads != 3 || one.Rss != 256 {
+		t.Fatal(one)
+	}
+
+	address, port, ok := parseHexAddress("0100007F:0016")
+	if !ok || address != "127.0.0.1" || port != 22 {
+		t.Fatal(address, port)
+	}
+	address, port, _ = parseHexAddress("00000000000000000000000001000000:1F90")
+	if address != "::1" || port != 8080 {
+		t.Fatal(address, port)
+	}
+
+	cmdlines := parseCmdlines("1 /sbin/init splash \n22 \n3 nginx: worker @@end \n")
+	if cmdlines[1] != "/sbin/init splash" || cmdlines[22] != "" || cmdlines[3] != "nginx: worker @@end" {
+		t.Fatal(cmdlines)
+	}
+
+	// 进程名包含换行时伪造的进程状态被忽略
+	res, err := parseStatOutput("cpu  1 2 3\ndr-xr-xr-x 9 0 0 0 Jan 1 00:00 /proc/1\n@@stat\n" +
+		"1 (init) S 0 1 1 0 -1 4194560 100 0 0 0 150 50 0 0 20 0 3 0 1000 2000000 256 0\n" +
+		"42 (x\n@@stat\n1 (y) S 0 1 1 0 -1 4194560 100 0 0 0 150 50 0 0 20 0 3 0 1000 2000000 256 0\n")
+	if err != nil || res.cpuTotal != 6 || res.owners[1] != 0 || len(res.stats) != 1 || res.stats[1].Name != "init" {
+		t.Fatal(res, err)
+	}
+
+	list := (&Query{Tree: true, Sort: "pid", Asc: true}).Apply([]*Process{
+		{Pid: 1, PPid: 0}, {Pid: 10, PPid: 1}, {Pid: 11, PPid: 10}, {Pid: 20, PPid: 1},
+	})
+	if len(list) != 1 || len(list[0].Children) != 2 || list[0].Children[0].Children[0].Pid != 11 {
+		t.Fatal(list)
+	}
+
+	if err := Signal(nil, 1, "TERM"); err == nil {
+		t.Fatal("pid 1")
+	}
+	if err := Signal(nil, 100, "SEGV"); err == nil {
+		t.Fatal("signal")
+	}
+}
+
+func TestLocal(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only linux")
+	}
+	list, err := List(terminal.Exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, one := range list {
+		if one.Pid == os.Getpid() {
+			found = one.Rss > 0 && one.Cmdline != "" && one.StartTime > 0
+		}
+	}
+	if !found {
+		t.Fatal("current process not found")
+	}
+	if _, err = ListPorts(terminal.Exec); err != nil {
+		t.Fatal(err)
+	}
+}
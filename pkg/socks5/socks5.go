@@ -0,0 +1,171 @@
+package socks5
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+)
+
+const (
+	version = 5
+
+	methodNoAuth       = 0
+	methodPassword     = 2
+	methodNoAcceptable = 0xff
+
+	// 用户名密码认证子协商版本，见 RFC 1929
+	passwordVersion = 1
+	passwordSuccess = 0
+	passwordFailure = 1
+
+	cmdConnect = 1
+
+	atypIPv4   = 1
+	atypDomain = 3
+	atypIPv6   = 4
+
+	// 应答状态
+	ReplySucceeded           = 0
+	ReplyGeneralFailure      = 1
+	ReplyNotAllowed          = 2
+	ReplyHostUnreachable     = 4
+	ReplyCommandNotSupported = 7
+	ReplyAddressNotSupported = 8
+)
+
+var (
+	ErrVersion = errors.New("socks5 版本不支持")
+	ErrMethod  = errors.New("socks5 认证方式不支持")
+	ErrAuth    = errors.New("socks5 用户名或密码错误")
+)
+
+// Handshake 服务端握手，只支持无认证和 CONNECT 命令，返回客户端请求连接的地址
+// 握手成功后需要调用 Reply 应答连接结果
+func Handshake(conn io.ReadWriter) (address string, err error) {
+	return HandshakeAuth(conn, nil)
+}
+
+// HandshakeAuth 服务端握手，auth 不为空时要求客户端使用用户名密码认证
+func HandshakeAuth(conn io.ReadWriter, auth func(username string, password string) bool) (address string, err error) {
+	head := make([]byte, 2)
+	if _, err = io.ReadFull(conn, head); err != nil {
+		return
+	}
+	if head[0] != version {
+		err = ErrVersion
+		return
+	}
+	methods := make([]byte, head[1])
+	if _, err = io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	var want byte = methodNoAuth
+	if auth != nil {
+		want = methodPassword
+	}
+	var accept bool
+	for _, method := range methods {
+		if method == want {
+			accept = true
+		}
+	}
+	if !accept {
+		_, _ = conn.Write([]byte{version, methodNoAcceptable})
+		err = ErrMethod
+		return
+	}
+	if _, err = conn.Write([]byte{version, want}); err != nil {
+		return
+	}
+	if auth != nil {
+		if err = passwordAuth(conn, auth); err != nil {
+			return
+		}
+	}
+
+	request := make([]byte, 4)
+	if _, err = io.ReadFull(conn, request); err != nil {
+		return
+	}
+	if request[0] != version {
+		err = ErrVersion
+		return
+	}
+	var host string
+	switch request[3] {
+	case atypIPv4, atypIPv6:
+		size := net.IPv4len
+		if request[3] == atypIPv6 {
+			size = net.IPv6len
+		}
+		ip := make([]byte, size)
+		if _, err = io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case atypDomain:
+		size := make([]byte, 1)
+		if _, err = io.ReadFull(conn, size); err != nil {
+			return
+		}
+		domain := make([]byte, size[0])
+		if _, err = io.ReadFull(conn, domain); err != nil {
+			return
+		}
+		host = string(domain)
+	default:
+		_ = Reply(conn, ReplyAddressNotSupported)
+		err = errors.New("socks5 地址类型不支持")
+		return
+	}
+	port := make([]byte, 2)
+	if _, err = io.ReadFull(conn, port); err != nil {
+		return
+	}
+	if request[1] != cmdConnect {
+		_ = Reply(conn, ReplyCommandNotSupported)
+		err = errors.New("socks5 只支持 CONNECT 命令")
+		return
+	}
+	address = net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	return
+}
+
+// Reply 应答连接结果，绑定地址固定为 0.0.0.0:0
+func Reply(conn io.Writer, reply byte) (err error) {
+	_, err = conn.Write([]byte{version, reply, 0, atypIPv4, 0, 0, 0, 0, 0, 0})
+	return
+}
+
+// passwordAuth 用户名密码子协商
+func passwordAuth(conn io.ReadWriter, auth func(username string, password string) bool) (err error) {
+	head := make([]byte, 2)
+	if _, err = io.ReadFull(conn, head); err != nil {
+		return
+	}
+	if head[0] != passwordVersion {
+		err = ErrVersion
+		return
+	}
+	username := make([]byte, head[1])
+	if _, err = io.ReadFull(conn, username); err != nil {
+		return
+	}
+	size := make([]byte, 1)
+	if _, err = io.ReadFull(conn, size); err != nil {
+		return
+	}
+	password := make([]byte, size[0])
+	if _, err = io.ReadFull(conn, password); err != nil {
+		return
+	}
+	if !auth(string(username), string(password)) {
+		_, _ = conn.Write([]byte{passwordVersion, passwordFailure})
+		err = ErrAuth
+		return
+	}
+	_, err = conn.Write([]byte{passwordVersion, passwordSuccess})
+	return
+}
@@ -0,0 +1,96 @@
+package socks5
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestHandshake(t *testing.T) {
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+
+	result := make(chan string, 1)
+	go func() {
+		address, err := Handshake(server)
+		if err != nil {
+			t.Error(err)
+		}
+		_ = Reply(server, ReplySucceeded)
+		result <- address
+	}()
+
+	_, _ = client.Write([]byte{5, 2, 2, 0})
+	buf := make([]byte, 2)
+	_, _ = io.ReadFull(client, buf)
+	if !bytes.Equal(buf, []byte{5, 0}) {
+		t.Fatal(buf)
+	}
+	request := append([]byte{5, 1, 0, 3, 11}, "example.com"...)
+	_, _ = client.Write(append(request, 0x01, 0xbb))
+	buf = make([]byte, 10)
+	_, _ = io.ReadFull(client, buf)
+	if buf[1] != ReplySucceeded {
+		t.Fatal(buf)
+	}
+	if address := <-result; address != "example.com:443" {
+		t.Fatal(address)
+	}
+}
+
+func TestHandshakeIPv4(t *testing.T) {
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+
+	result := make(chan string, 1)
+	go func() {
+		address, _ := Handshake(server)
+		result <- address
+	}()
+	_, _ = client.Write([]byte{5, 1, 0})
+	_, _ = io.ReadFull(client, make([]byte, 2))
+	_, _ = client.Write([]byte{5, 1, 0, 1, 127, 0, 0, 1, 0, 80})
+	if address := <-result; address != "127.0.0.1:80" {
+		t.Fatal(address)
+	}
+}
+
+func TestHandshakeAuth(t *testing.T) {
+	auth := func(username string, password string) bool {
+		return username == "user" && password == "pass"
+	}
+	for _, password := range []string{"pass", "wrong"} {
+		client, server := net.Pipe()
+
+		result := make(chan error, 1)
+		go func() {
+			_, err := HandshakeAuth(server, auth)
+			result <- err
+			_ = server.Close()
+		}()
+		// 只提供无认证时拒绝
+		_, _ = client.Write([]byte{5, 2, 0, 2})
+		buf := make([]byte, 2)
+		_, _ = io.ReadFull(client, buf)
+		if !bytes.Equal(buf, []byte{5, 2}) {
+			t.Fatal(buf)
+		}
+		request := append([]byte{1, 4}, "user"...)
+		request = append(append(request, byte(len(password))), password...)
+		_, _ = client.Write(request)
+		_, _ = io.ReadFull(client, buf)
+		if password == "pass" {
+			if buf[1] != 0 {
+				t.Fatal(buf)
+			}
+			_, _ = client.Write([]byte{5, 1, 0, 1, 127, 0, 0, 1, 0, 22})
+			if err := <-result; err != nil {
+				t.Fatal(err)
+			}
+		} else if buf[1] == 0 || <-result != ErrAuth {
+			t.Fatal("密码错误时应认证失败")
+		}
+		_ = client.Close()
+	}
+}
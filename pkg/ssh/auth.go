@@ -0,0 +1,156 @@
+package ssh
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"net"
+	"os"
+	"strings"
+)
+
+// agentEnabled 是否允许 ssh-agent 认证，服务模式下关闭，避免用户使用服务进程的 agent 密钥
+var agentEnabled = true
+
+// SetAgentEnabled 设置是否允许 ssh-agent 认证，只应在单机模式下开启
+func SetAgentEnabled(enabled bool) {
+	agentEnabled = enabled
+}
+
+// GetAgentSocket ssh-agent 的 socket 地址，未配置时使用环境变量 SSH_AUTH_SOCK，只在允许 ssh-agent 认证时使用
+func (this_ *Config) GetAgentSocket() string {
+	if !agentEnabled {
+		return ""
+	}
+	if this_.AgentSocket != "" {
+		return this_.AgentSocket
+	}
+	return os.Getenv("SSH_AUTH_SOCK")
+}
+
+// GetCertificate OpenSSH 用户证书路径，未配置时查找私钥同目录下的 `<私钥>-cert.pub`
+func (this_ *Config) GetCertificate() string {
+	if this_.Certificate != "" {
+		return this_.Certificate
+	}
+	if this_.PublicKey == "" {
+		return ""
+	}
+	path := this_.PublicKey + "-cert.pub"
+	if exists, _ := util.PathExists(path); exists {
+		return path
+	}
+	return ""
+}
+
+// newAuthMethods 构建认证方式，顺序为：ssh-agent、证书、私钥、密码、键盘交互
+// 使用 ssh-agent 时返回 agent 连接，需在 SSH 连接关闭后关闭
+func newAuthMethods(config *Config) (auth []ssh.AuthMethod, agentConn net.Conn, err error) {
+	if config.UseAgent {
+		if !agentEnabled {
+			err = errors.New("服务模式下不支持 ssh-agent 认证")
+			return
+		}
+		socket := config.GetAgentSocket()
+		if socket == "" {
+			err = errors.New("未配置 ssh-agent socket，且环境变量 SSH_AUTH_SOCK 为空")
+			return
+		}
+		agentConn, err = net.Dial("unix", socket)
+		if err != nil {
+			util.Logger.Error("ssh agent dial error", zap.Any("socket", socket), zap.Error(err))
+			return
+		}
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+	}
+
+	if config.PublicKey != "" {
+		var signer ssh.Signer
+		signer, err = parsePrivateKey(config.PublicKey, config.Password)
+		if err != nil {
+			closeAgentConn(agentConn)
+			return
+		}
+		var signers []ssh.Signer
+		if certificate := config.GetCertificate(); certificate != "" {
+			var certSigner ssh.Signer
+			certSigner, err = newCertSigner(certificate, signer)
+			if err != nil {
+				closeAgentConn(agentConn)
+				return
+			}
+			signers = append(signers, certSigner)
+		}
+		signers = append(signers, signer)
+		auth = append(auth, ssh.PublicKeys(signers...))
+	} else if config.Password != "" {
+		auth = append(auth, ssh.Password(config.Password))
+	}
+
+	if config.KeyboardInteractive != nil || config.KeyboardInteractivePassword {
+		auth = append(auth, ssh.KeyboardInteractive(keyboardInteractiveChallenge(config)))
+	}
+	return
+}
+
+func closeAgentConn(agentConn net.Conn) {
+	if agentConn != nil {
+		_ = agentConn.Close()
+	}
+}
+
+func parsePrivateKey(path string, passphrase string) (signer ssh.Signer, err error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(bs, []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(bs)
+	}
+	return
+}
+
+// newCertSigner 读取 OpenSSH 用户证书，与私钥组合为证书签名
+func newCertSigner(path string, signer ssh.Signer) (certSigner ssh.Signer, err error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(bs)
+	if err != nil {
+		err = errors.New("证书[" + path + "]解析失败:" + err.Error())
+		return
+	}
+	cert, ok := publicKey.(*ssh.Certificate)
+	if !ok {
+		err = errors.New("文件[" + path + "]不是 OpenSSH 证书")
+		return
+	}
+	certSigner, err = ssh.NewCertSigner(cert, signer)
+	return
+}
+
+// keyboardInteractiveChallenge 键盘交互认证
+// 配置了 KeyboardInteractive 时交由其回答（如终端中由用户输入 OTP），否则开启了 KeyboardInteractivePassword 时以密码回答密码类问题
+func keyboardInteractiveChallenge(config *Config) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) (answers []string, err error) {
+		if len(questions) == 0 {
+			return
+		}
+		if config.KeyboardInteractive != nil {
+			return config.KeyboardInteractive(name, instruction, questions, echos)
+		}
+		for _, question := range questions {
+			if !config.KeyboardInteractivePassword || config.Password == "" || !strings.Contains(strings.ToLower(question), "password") {
+				err = errors.New("SSH[" + config.Address + "]需要键盘交互认证[" + strings.TrimSpace(question) + "]，请在终端中连接并输入")
+				return
+			}
+			answers = append(answers, config.Password)
+		}
+		return
+	}
+}
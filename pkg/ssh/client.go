@@ -6,8 +6,8 @@ import (
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"net"
-	"os"
 	"sync"
 	"time"
 )
@@ -17,17 +17,40 @@ var (
 )
 
 type Config struct {
-	Type         string      `json:"type"`
-	Address      string      `json:"address"`
-	Username     string      `json:"username"`
-	Password     string      `json:"password"`
-	PublicKey    string      `json:"publicKey"`
-	Command      string      `json:"command"`
-	Timeout      int         `json:"timeout"`
-	IdleSendOpen bool        `json:"idleSendOpen"`
-	IdleSendTime int         `json:"idleSendTime"`
-	IdleSendChar string      `json:"idleSendChar"`
-	SSHClient    *ssh.Client `json:"-"`
+	Type                        string      `json:"type"`
+	Address                     string      `json:"address"`
+	Username                    string      `json:"username"`
+	Password                    string      `json:"password"`
+	PublicKey                   string      `json:"publicKey"`
+	Command                     string      `json:"command"`
+	Timeout                     int         `json:"timeout"`
+	IdleSendOpen                bool        `json:"idleSendOpen"`
+	IdleSendTime                int         `json:"idleSendTime"`
+	IdleSendChar                string      `json:"idleSendChar"`
+	HostKeyMode                 string      `json:"hostKeyMode"`                 // 主机密钥校验模式 strict、tofu、off，默认 tofu
+	HostKeyFingerprint          string      `json:"hostKeyFingerprint"`          // 固定的主机密钥指纹（SHA256:xxx），配置后只接受该指纹
+	Certificate                 string      `json:"certificate"`                 // OpenSSH 用户证书（-cert.pub），为空时查找私钥同目录下的 `<私钥>-cert.pub`
+	UseAgent                    bool        `json:"useAgent"`                    // 使用 ssh-agent 中的密钥认证
+	AgentSocket                 string      `json:"agentSocket"`                 // ssh-agent socket 地址，默认 SSH_AUTH_SOCK
+	AgentForward                bool        `json:"agentForward"`                // 终端会话开启 agent 转发
+	KeyboardInteractivePassword bool        `json:"keyboardInteractivePassword"` // 键盘交互认证 以保存的密码回答密码类问题 默认关闭
+	SSHClient                   *ssh.Client `json:"-"`
+	JumpConfig                  *Config     `json:"-"` // 上一跳的跳板配置，SSHClient 为空时先连接该跳板，再经由跳板连接
+	// KeyboardInteractive 键盘交互认证（如 OTP 二次验证）的回答方式，为空且未开启 KeyboardInteractivePassword 时不使用键盘交互认证
+	KeyboardInteractive ssh.KeyboardInteractiveChallenge `json:"-"`
+}
+
+// GetChainKey 跳板链标识，从第一跳到当前，用于缓存 key
+func (this_ *Config) GetChainKey() (key string) {
+	for one := this_; one != nil; one = one.JumpConfig {
+		hop := one.Username + "@" + one.Address
+		if key == "" {
+			key = hop
+		} else {
+			key = hop + ">" + key
+		}
+	}
+	return
 }
 
 type Client struct {
@@ -135,33 +158,55 @@ func (this_ *Client) WSWriteData(obj interface{}) {
 }
 
 func NewClient(config Config) (client *ssh.Client, err error) {
+	if config.SSHClient == nil && config.JumpConfig != nil {
+		var jumpClient *ssh.Client
+		jumpClient, err = NewClient(*config.JumpConfig)
+		if err != nil {
+			util.Logger.Error("ssh client jump error", zap.Any("address", config.JumpConfig.Address), zap.Error(err))
+			return
+		}
+		config.SSHClient = jumpClient
+		client, err = NewClient(config)
+		if err != nil {
+			_ = jumpClient.Close()
+			return
+		}
+		// 跳板链作为整体关闭：最后一跳关闭后，关闭其经由的跳板
+		go func() {
+			_ = client.Wait()
+			_ = jumpClient.Close()
+		}()
+		return
+	}
 	var (
 		auth         []ssh.AuthMethod
+		agentConn    net.Conn
 		clientConfig *ssh.ClientConfig
 		sshConfig    ssh.Config
 	)
-	auth = []ssh.AuthMethod{}
-
-	if config.PublicKey != "" {
-		var publicKeyBytes []byte
-		publicKeyBytes, err = os.ReadFile(config.PublicKey)
-		if err != nil {
+	auth, agentConn, err = newAuthMethods(&config)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if agentConn == nil {
 			return
 		}
-		var publicKeySigner ssh.Signer
-		if config.Password != "" {
-			publicKeySigner, err = ssh.ParsePrivateKeyWithPassphrase(publicKeyBytes, []byte(config.Password))
-		} else {
-			publicKeySigner, err = ssh.ParsePrivateKey(publicKeyBytes)
-		}
 		if err != nil {
+			_ = agentConn.Close()
 			return
 		}
-		auth = append(auth, ssh.PublicKeys(publicKeySigner))
-
-	} else if config.Password != "" {
-		auth = append(auth, ssh.Password(config.Password))
-	}
+		if config.AgentForward {
+			// 远程请求 agent 时经由当前连接转发到本地 ssh-agent
+			if e := agent.ForwardToAgent(client, agent.NewClient(agentConn)); e != nil {
+				util.Logger.Error("ssh agent forward error", zap.Error(e))
+			}
+		}
+		go func() {
+			_ = client.Wait()
+			_ = agentConn.Close()
+		}()
+	}()
 
 	sshConfig = ssh.Config{
 		Ciphers: Ciphers,
@@ -170,12 +215,16 @@ func NewClient(config Config) (client *ssh.Client, err error) {
 	if config.Timeout > 0 {
 		timeout = time.Duration(config.Timeout) * time.Second
 	}
+	knownHosts := GetKnownHostsStore()
 	clientConfig = &ssh.ClientConfig{
 		User:            config.Username,
 		Auth:            auth,
 		Timeout:         timeout,
 		Config:          sshConfig,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), //这个可以, 但是不够安全
+		HostKeyCallback: knownHosts.HostKeyCallback(config.HostKeyMode, config.HostKeyFingerprint),
+	}
+	if config.HostKeyMode != HostKeyModeOff && config.HostKeyFingerprint == "" {
+		clientConfig.HostKeyAlgorithms = knownHosts.HostKeyAlgorithms(config.Address)
 	}
 	if config.Type == "" {
 		config.Type = "tcp"
@@ -0,0 +1,70 @@
+package ssh
+
+import (
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"teamide/pkg/terminal"
+	"time"
+)
+
+// Exec 新建 SSH 连接非交互执行命令，获取退出码、标准输出和错误输出，超时后关闭连接
+func Exec(config Config, command string, timeout time.Duration) (res *terminal.ExecResult, err error) {
+	client, err := NewClient(config)
+	if err != nil {
+		return
+	}
+	defer func() { _ = client.Close() }()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return
+	}
+	defer func() { _ = session.Close() }()
+
+	stdout := terminal.NewLimitBuffer(terminal.ExecOutputLimit)
+	stderr := terminal.NewLimitBuffer(terminal.ExecOutputLimit)
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(command)
+	}()
+
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+	var isTimeout bool
+	select {
+	case err = <-done:
+	case <-timeoutC:
+		isTimeout = true
+		_ = session.Signal(ssh.SIGKILL)
+		_ = client.Close()
+		<-done
+	}
+
+	res = &terminal.ExecResult{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Truncated: stdout.Truncated || stderr.Truncated,
+	}
+	if isTimeout {
+		res.ExitCode = -1
+		err = errors.New("命令执行超时")
+		return
+	}
+	if exitErr, ok := err.(*ssh.ExitError); ok {
+		res.ExitCode = exitErr.ExitStatus()
+		err = nil
+	}
+	if err != nil {
+		util.Logger.Error("ssh exec error", zap.Any("address", config.Address), zap.Error(err))
+	}
+	return
+}
@@ -0,0 +1,232 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"teamide/pkg/socks5"
+	"time"
+)
+
+const (
+	// ForwardTypeLocal 本地转发（-L），本地监听，经由 SSH 服务器连接目标地址
+	ForwardTypeLocal = "local"
+	// ForwardTypeRemote 远程转发（-R），SSH 服务器监听，由本地连接目标地址
+	ForwardTypeRemote = "remote"
+	// ForwardTypeDynamic 动态转发（-D），本地监听 SOCKS5，经由 SSH 服务器连接请求的地址
+	ForwardTypeDynamic = "dynamic"
+
+	forwardDialTimeout = 10 * time.Second
+)
+
+// ForwardStats 转发统计，BytesIn 为监听端收到的字节数，BytesOut 为发送给监听端的字节数
+type ForwardStats struct {
+	Connections       int64 `json:"connections"`
+	ActiveConnections int64 `json:"activeConnections"`
+	BytesIn           int64 `json:"bytesIn"`
+	BytesOut          int64 `json:"bytesOut"`
+}
+
+// Snapshot 获取当前统计
+func (this_ *ForwardStats) Snapshot() *ForwardStats {
+	return &ForwardStats{
+		Connections:       atomic.LoadInt64(&this_.Connections),
+		ActiveConnections: atomic.LoadInt64(&this_.ActiveConnections),
+		BytesIn:           atomic.LoadInt64(&this_.BytesIn),
+		BytesOut:          atomic.LoadInt64(&this_.BytesOut),
+	}
+}
+
+// Forward 基于一个 SSH 连接的端口转发，SSH 连接断开或关闭后 Serve 返回
+type Forward struct {
+	Type          string
+	ListenAddress string
+	TargetAddress string
+	Stats         *ForwardStats
+
+	client    *ssh.Client
+	listener  net.Listener
+	conns     map[net.Conn]bool
+	connsLock sync.Mutex
+	closed    bool
+}
+
+// NewForward stats 为空时新建，传入已有的统计可以在重连后继续累计
+func NewForward(client *ssh.Client, forwardType string, listenAddress string, targetAddress string, stats *ForwardStats) *Forward {
+	if stats == nil {
+		stats = &ForwardStats{}
+	}
+	return &Forward{
+		Type:          forwardType,
+		ListenAddress: listenAddress,
+		TargetAddress: targetAddress,
+		Stats:         stats,
+		client:        client,
+		conns:         make(map[net.Conn]bool),
+	}
+}
+
+// Listen 开始监听，远程转发在 SSH 服务器上监听
+func (this_ *Forward) Listen() (err error) {
+	switch this_.Type {
+	case ForwardTypeLocal, ForwardTypeDynamic:
+		this_.listener, err = net.Listen("tcp", this_.ListenAddress)
+	case ForwardTypeRemote:
+		this_.listener, err = this_.client.Listen("tcp", this_.ListenAddress)
+	default:
+		err = errors.New("转发类型[" + this_.Type + "]不支持")
+	}
+	return
+}
+
+// Serve 接收连接直到监听关闭，SSH 连接断开时关闭监听
+func (this_ *Forward) Serve() (err error) {
+	if this_.listener == nil {
+		err = errors.New("转发未监听")
+		return
+	}
+	go func() {
+		_ = this_.client.Wait()
+		this_.Close()
+	}()
+	for {
+		var conn net.Conn
+		conn, err = this_.listener.Accept()
+		if err != nil {
+			this_.connsLock.Lock()
+			closed := this_.closed
+			this_.connsLock.Unlock()
+			if closed {
+				err = nil
+			}
+			return
+		}
+		go this_.handle(conn)
+	}
+}
+
+func (this_ *Forward) addConn(conn net.Conn) bool {
+	this_.connsLock.Lock()
+	defer this_.connsLock.Unlock()
+
+	if this_.closed {
+		return false
+	}
+	this_.conns[conn] = true
+	return true
+}
+
+func (this_ *Forward) removeConn(conn net.Conn) {
+	this_.connsLock.Lock()
+	defer this_.connsLock.Unlock()
+
+	delete(this_.conns, conn)
+}
+
+func (this_ *Forward) handle(conn net.Conn) {
+	defer func() {
+		if e := recover(); e != nil {
+			util.Logger.Error("forward handle error", zap.Any("error", e))
+		}
+		_ = conn.Close()
+	}()
+	if !this_.addConn(conn) {
+		return
+	}
+	defer this_.removeConn(conn)
+	atomic.AddInt64(&this_.Stats.Connections, 1)
+	atomic.AddInt64(&this_.Stats.ActiveConnections, 1)
+	defer atomic.AddInt64(&this_.Stats.ActiveConnections, -1)
+
+	var target net.Conn
+	var err error
+	switch this_.Type {
+	case ForwardTypeLocal:
+		target, err = this_.dial(this_.TargetAddress)
+	case ForwardTypeRemote:
+		target, err = net.DialTimeout("tcp", this_.TargetAddress, forwardDialTimeout)
+	case ForwardTypeDynamic:
+		var address string
+		address, err = socks5.Handshake(conn)
+		if err != nil {
+			return
+		}
+		target, err = this_.dial(address)
+		if err != nil {
+			_ = socks5.Reply(conn, socks5.ReplyHostUnreachable)
+		} else {
+			err = socks5.Reply(conn, socks5.ReplySucceeded)
+		}
+	}
+	if target != nil {
+		defer func() { _ = target.Close() }()
+	}
+	if err != nil {
+		util.Logger.Warn("forward dial error", zap.Any("type", this_.Type), zap.Any("listen", this_.ListenAddress), zap.Error(err))
+		return
+	}
+	if !this_.addConn(target) {
+		return
+	}
+	defer this_.removeConn(target)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		copyCount(target, conn, &this_.Stats.BytesIn)
+		done <- struct{}{}
+	}()
+	go func() {
+		copyCount(conn, target, &this_.Stats.BytesOut)
+		done <- struct{}{}
+	}()
+	// 任意一端结束后关闭两端
+	<-done
+}
+
+// dial 经由 SSH 服务器连接目标地址，目标不可达时 SSH 服务器可能长时间不响应，需要超时
+func (this_ *Forward) dial(address string) (conn net.Conn, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), forwardDialTimeout)
+	defer cancel()
+	conn, err = this_.client.DialContext(ctx, "tcp", address)
+	return
+}
+
+func copyCount(dst io.Writer, src io.Reader, count *int64) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, e := dst.Write(buf[:n]); e != nil {
+				return
+			}
+			atomic.AddInt64(count, int64(n))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Close 关闭监听和所有转发中的连接，不关闭 SSH 连接
+func (this_ *Forward) Close() {
+	this_.connsLock.Lock()
+	defer this_.connsLock.Unlock()
+
+	if this_.closed {
+		return
+	}
+	this_.closed = true
+	if this_.listener != nil {
+		_ = this_.listener.Close()
+	}
+	for conn := range this_.conns {
+		_ = conn.Close()
+	}
+	this_.conns = make(map[net.Conn]bool)
+}
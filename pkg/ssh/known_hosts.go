@@ -0,0 +1,317 @@
+package ssh
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HostKeyModeStrict 严格模式，只接受 known_hosts 中已记录或已固定指纹的主机密钥
+	HostKeyModeStrict = "strict"
+	// HostKeyModeTOFU 首次信任模式，首次连接自动记录主机密钥，之后密钥变更则拒绝连接
+	HostKeyModeTOFU = "tofu"
+	// HostKeyModeOff 关闭主机密钥校验
+	HostKeyModeOff = "off"
+)
+
+var (
+	knownHostsStore     *KnownHostsStore
+	knownHostsStoreLock sync.Mutex
+)
+
+// SetKnownHostsStore 设置全局 known_hosts 存储
+func SetKnownHostsStore(store *KnownHostsStore) {
+	knownHostsStoreLock.Lock()
+	defer knownHostsStoreLock.Unlock()
+	knownHostsStore = store
+}
+
+// GetKnownHostsStore 获取全局 known_hosts 存储，未设置时使用内存存储
+func GetKnownHostsStore() *KnownHostsStore {
+	knownHostsStoreLock.Lock()
+	defer knownHostsStoreLock.Unlock()
+	if knownHostsStore == nil {
+		knownHostsStore = NewKnownHostsStore("")
+	}
+	return knownHostsStore
+}
+
+// KnownHost 已记录的主机密钥
+type KnownHost struct {
+	Address     string `json:"address"`
+	KeyType     string `json:"keyType"`
+	Fingerprint string `json:"fingerprint"`
+	PublicKey   string `json:"publicKey"`
+	// RejectTime 密钥被拒绝的时间，仅待确认的密钥有值
+	RejectTime int64 `json:"rejectTime,omitempty"`
+
+	key ssh.PublicKey
+}
+
+// HostKeyChangedError 主机密钥与已记录或固定的密钥不一致
+type HostKeyChangedError struct {
+	Address           string   `json:"address"`
+	KeyType           string   `json:"keyType"`
+	Fingerprint       string   `json:"fingerprint"`
+	KnownFingerprints []string `json:"knownFingerprints"`
+}
+
+func (this_ *HostKeyChangedError) Error() string {
+	return fmt.Sprintf("主机[%s]密钥已变更，可能存在中间人攻击，当前指纹[%s %s]，已记录指纹[%s]，确认无误后请重新接受该主机密钥",
+		this_.Address, this_.KeyType, this_.Fingerprint, strings.Join(this_.KnownFingerprints, ","))
+}
+
+// HostKeyUnknownError 严格模式下主机密钥未记录
+type HostKeyUnknownError struct {
+	Address     string `json:"address"`
+	KeyType     string `json:"keyType"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+func (this_ *HostKeyUnknownError) Error() string {
+	return fmt.Sprintf("主机[%s]密钥未知，当前指纹[%s %s]，严格模式下需先接受该主机密钥", this_.Address, this_.KeyType, this_.Fingerprint)
+}
+
+// NewKnownHostsStore 创建 known_hosts 存储，path 为空时只保存在内存中
+func NewKnownHostsStore(path string) (res *KnownHostsStore) {
+	res = &KnownHostsStore{
+		path:    path,
+		pending: make(map[string]*KnownHost),
+	}
+	if path != "" {
+		err := res.load()
+		if err != nil {
+			util.Logger.Error("known hosts load error", zap.Any("path", path), zap.Error(err))
+		}
+	}
+	return
+}
+
+// KnownHostsStore 服务端 known_hosts 存储，文件格式与 OpenSSH known_hosts 一致
+type KnownHostsStore struct {
+	path    string
+	hosts   []*KnownHost
+	pending map[string]*KnownHost
+	lock    sync.Mutex
+}
+
+func newKnownHost(address string, key ssh.PublicKey) *KnownHost {
+	return &KnownHost{
+		Address:     address,
+		KeyType:     key.Type(),
+		Fingerprint: ssh.FingerprintSHA256(key),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))),
+		key:         key,
+	}
+}
+
+func (this_ *KnownHostsStore) load() (err error) {
+	bs, err := os.ReadFile(this_.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	var hosts []*KnownHost
+	rest := bs
+	for len(rest) > 0 {
+		var addresses []string
+		var key ssh.PublicKey
+		_, addresses, key, _, rest, err = ssh.ParseKnownHosts(rest)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			break
+		}
+		for _, address := range addresses {
+			hosts = append(hosts, newKnownHost(address, key))
+		}
+	}
+	this_.hosts = hosts
+	return
+}
+
+func (this_ *KnownHostsStore) save() (err error) {
+	if this_.path == "" {
+		return
+	}
+	err = os.MkdirAll(filepath.Dir(this_.path), 0700)
+	if err != nil {
+		return
+	}
+	buf := &bytes.Buffer{}
+	writer := bufio.NewWriter(buf)
+	for _, one := range this_.hosts {
+		_, _ = writer.WriteString(knownhosts.Line([]string{one.Address}, one.key) + "\n")
+	}
+	_ = writer.Flush()
+	err = os.WriteFile(this_.path, buf.Bytes(), 0600)
+	return
+}
+
+func (this_ *KnownHostsStore) find(address string) (res []*KnownHost) {
+	for _, one := range this_.hosts {
+		if one.Address == address {
+			res = append(res, one)
+		}
+	}
+	return
+}
+
+// List 所有已记录的主机密钥
+func (this_ *KnownHostsStore) List() (res []*KnownHost) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	res = append(res, this_.hosts...)
+	return
+}
+
+// Pending 被拒绝、等待确认的主机密钥
+func (this_ *KnownHostsStore) Pending() (res []*KnownHost) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	for _, one := range this_.pending {
+		res = append(res, one)
+	}
+	return
+}
+
+// Accept 接受被拒绝的主机密钥，替换该主机已记录的所有密钥
+func (this_ *KnownHostsStore) Accept(address string, fingerprint string) (res *KnownHost, err error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	address = knownhosts.Normalize(address)
+	find := this_.pending[address]
+	if find == nil || find.Fingerprint != fingerprint {
+		err = errors.New("主机[" + address + "]不存在待确认的密钥[" + fingerprint + "]")
+		return
+	}
+	var hosts []*KnownHost
+	for _, one := range this_.hosts {
+		if one.Address != address {
+			hosts = append(hosts, one)
+		}
+	}
+	res = newKnownHost(address, find.key)
+	this_.hosts = append(hosts, res)
+	delete(this_.pending, address)
+	err = this_.save()
+	return
+}
+
+// Remove 删除主机已记录的所有密钥，下次连接时重新记录
+func (this_ *KnownHostsStore) Remove(address string) (err error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	address = knownhosts.Normalize(address)
+	var hosts []*KnownHost
+	for _, one := range this_.hosts {
+		if one.Address != address {
+			hosts = append(hosts, one)
+		}
+	}
+	this_.hosts = hosts
+	delete(this_.pending, address)
+	err = this_.save()
+	return
+}
+
+// HostKeyAlgorithms 已记录密钥的算法，用于握手时优先协商，避免服务端有多种密钥时误判为变更
+func (this_ *KnownHostsStore) HostKeyAlgorithms(address string) (res []string) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	for _, one := range this_.find(knownhosts.Normalize(address)) {
+		if one.KeyType == ssh.KeyAlgoRSA {
+			res = append(res, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		res = append(res, one.KeyType)
+	}
+	return
+}
+
+// Check 校验主机密钥
+func (this_ *KnownHostsStore) Check(mode string, pinFingerprint string, hostname string, key ssh.PublicKey) (err error) {
+	if mode == HostKeyModeOff {
+		return
+	}
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	address := knownhosts.Normalize(hostname)
+	current := newKnownHost(address, key)
+
+	var knownFingerprints []string
+	if pinFingerprint != "" {
+		knownFingerprints = append(knownFingerprints, pinFingerprint)
+	} else {
+		for _, one := range this_.find(address) {
+			if bytes.Equal(one.key.Marshal(), key.Marshal()) {
+				return
+			}
+			knownFingerprints = append(knownFingerprints, one.Fingerprint)
+		}
+	}
+
+	if pinFingerprint != "" && pinFingerprint == current.Fingerprint {
+		if len(this_.find(address)) == 0 {
+			this_.hosts = append(this_.hosts, current)
+			err = this_.save()
+		}
+		return
+	}
+
+	current.RejectTime = util.GetMilliByTime(time.Now())
+	if len(knownFingerprints) > 0 {
+		this_.pending[address] = current
+		err = &HostKeyChangedError{
+			Address:           address,
+			KeyType:           current.KeyType,
+			Fingerprint:       current.Fingerprint,
+			KnownFingerprints: knownFingerprints,
+		}
+		return
+	}
+	if mode == HostKeyModeStrict {
+		this_.pending[address] = current
+		err = &HostKeyUnknownError{
+			Address:     address,
+			KeyType:     current.KeyType,
+			Fingerprint: current.Fingerprint,
+		}
+		return
+	}
+
+	util.Logger.Info("known hosts add", zap.Any("address", address), zap.Any("fingerprint", current.Fingerprint))
+	current.RejectTime = 0
+	this_.hosts = append(this_.hosts, current)
+	err = this_.save()
+	return
+}
+
+// HostKeyCallback 生成握手时使用的主机密钥校验
+func (this_ *KnownHostsStore) HostKeyCallback(mode string, pinFingerprint string) ssh.HostKeyCallback {
+	if mode == HostKeyModeOff {
+		return ssh.InsecureIgnoreHostKey()
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return this_.Check(mode, pinFingerprint, hostname, key)
+	}
+}
@@ -0,0 +1,66 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"golang.org/x/crypto/ssh"
+	"path/filepath"
+	"testing"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKnownHostsStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	store := NewKnownHostsStore(path)
+	key1 := newTestHostKey(t)
+	key2 := newTestHostKey(t)
+
+	if err := store.Check(HostKeyModeStrict, "", "127.0.0.1:22", key1); err == nil {
+		t.Fatal("strict mode should reject unknown host")
+	}
+	if err := store.Check(HostKeyModeTOFU, "", "127.0.0.1:22", key1); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Check(HostKeyModeStrict, "", "127.0.0.1:22", key1); err != nil {
+		t.Fatal(err)
+	}
+
+	err := store.Check(HostKeyModeTOFU, "", "127.0.0.1:22", key2)
+	var changedErr *HostKeyChangedError
+	if !errors.As(err, &changedErr) {
+		t.Fatalf("want HostKeyChangedError, got %v", err)
+	}
+	if _, err = store.Accept("127.0.0.1:22", changedErr.Fingerprint); err != nil {
+		t.Fatal(err)
+	}
+
+	reload := NewKnownHostsStore(path)
+	if err = reload.Check(HostKeyModeStrict, "", "127.0.0.1", key2); err != nil {
+		t.Fatal(err)
+	}
+	if err = reload.Check(HostKeyModeStrict, "", "127.0.0.1:22", key1); err == nil {
+		t.Fatal("old key should be rejected after accept")
+	}
+
+	if err = reload.Check(HostKeyModeTOFU, ssh.FingerprintSHA256(key1), "10.0.0.1:2222", key2); err == nil {
+		t.Fatal("pinned fingerprint should reject other key")
+	}
+	if err = reload.Check(HostKeyModeStrict, ssh.FingerprintSHA256(key1), "10.0.0.1:2222", key1); err != nil {
+		t.Fatal(err)
+	}
+	if err = reload.Check(HostKeyModeOff, "", "10.0.0.1:2222", key2); err != nil {
+		t.Fatal(err)
+	}
+}
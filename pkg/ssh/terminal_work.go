@@ -10,6 +10,7 @@ import (
 	"github.com/team-ide/go-tool/util"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"io"
 	"os"
 	"regexp"
@@ -251,6 +252,14 @@ func (this_ *terminalService) Start(size *terminal.Size) (err error) {
 	_ = this_.sshSession.Setenv("TERM", "xterm-256color")
 	_ = this_.sshSession.Setenv("COLORTERM", "truecolor")
 
+	if this_.config.UseAgent && this_.config.AgentForward {
+		err = agent.RequestAgentForwarding(this_.sshSession)
+		if err != nil {
+			util.Logger.Error("SSH RequestAgentForwarding Error", zap.Error(err))
+			return
+		}
+	}
+
 	err = NewSSHShell(size, this_.sshSession)
 	if err != nil {
 		util.Logger.Error("Create SSH Shell Error", zap.Error(err))
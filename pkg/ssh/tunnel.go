@@ -0,0 +1,203 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"github.com/team-ide/go-tool/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Tunnel SSH隧道实现，在本地随机端口监听，经由 SSH（含跳板链）转发到远程地址
+// 用于不支持 SSHClient 拨号的客户端，如部分数据库驱动、Kafka、Mongodb
+type Tunnel struct {
+	sshConfig     *Config
+	remoteAddress string
+	LocalPort     int
+	listener      net.Listener
+	sshClient     *ssh.Client
+	closed        bool
+	mu            sync.Mutex
+}
+
+// NewTunnel 创建SSH隧道
+func NewTunnel(sshConfig *Config, remoteAddress string) *Tunnel {
+	return &Tunnel{
+		sshConfig:     sshConfig,
+		remoteAddress: remoteAddress,
+	}
+}
+
+// GetLocalAddress 本地监听地址
+func (t *Tunnel) GetLocalAddress() string {
+	return "127.0.0.1:" + strconv.Itoa(t.LocalPort)
+}
+
+// Start 启动SSH隧道
+func (t *Tunnel) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return errors.New("tunnel is closed")
+	}
+
+	// 创建SSH客户端
+	client, err := NewClient(*t.sshConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create ssh client: %w", err)
+	}
+	t.sshClient = client
+
+	// 创建本地监听器，使用随机端口
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		_ = t.sshClient.Close()
+		return fmt.Errorf("failed to listen on local port: %w", err)
+	}
+	t.listener = listener
+	t.LocalPort = listener.Addr().(*net.TCPAddr).Port
+
+	// 启动转发goroutine
+	go t.forward()
+
+	return nil
+}
+
+// forward 处理端口转发
+func (t *Tunnel) forward() {
+	for {
+		localConn, err := t.listener.Accept()
+		if err != nil {
+			if !t.closed {
+				util.Logger.Error("ssh tunnel accept error", zap.Error(err))
+			}
+			return
+		}
+
+		go t.handleConnection(localConn)
+	}
+}
+
+// handleConnection 处理单个连接
+func (t *Tunnel) handleConnection(localConn net.Conn) {
+	defer func() { _ = localConn.Close() }()
+
+	// 通过SSH连接到远程目标
+	remoteConn, err := t.sshClient.Dial("tcp", t.remoteAddress)
+	if err != nil {
+		util.Logger.Error("ssh tunnel dial remote error",
+			zap.String("remoteAddr", t.remoteAddress),
+			zap.Error(err),
+		)
+		return
+	}
+	defer func() { _ = remoteConn.Close() }()
+
+	// 双向数据转发
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// local -> remote
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(remoteConn, localConn)
+	}()
+
+	// remote -> local
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(localConn, remoteConn)
+	}()
+
+	wg.Wait()
+}
+
+// Close 关闭SSH隧道
+func (t *Tunnel) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return
+	}
+	t.closed = true
+
+	if t.listener != nil {
+		_ = t.listener.Close()
+	}
+
+	if t.sshClient != nil {
+		_ = t.sshClient.Close()
+	}
+}
+
+// StartTunnels 为多个远程地址启动隧道，返回对应的本地地址，任意一个失败则全部关闭
+func StartTunnels(sshConfig *Config, remoteAddresses []string) (tunnels []*Tunnel, localAddresses []string, err error) {
+	for _, remoteAddress := range remoteAddresses {
+		tunnel := NewTunnel(sshConfig, remoteAddress)
+		err = tunnel.Start()
+		if err != nil {
+			CloseTunnels(tunnels)
+			tunnels = nil
+			localAddresses = nil
+			return
+		}
+		tunnels = append(tunnels, tunnel)
+		localAddresses = append(localAddresses, tunnel.GetLocalAddress())
+	}
+	return
+}
+
+// StartAddressTunnels 为逗号或分号分隔的多个地址启动隧道，返回同样格式的本地地址
+// 用于不支持自定义拨号的客户端（go-tool 的 kafka、mongodb），客户端只能连接配置的地址
+func StartAddressTunnels(sshConfig *Config, address string) (tunnels []*Tunnel, localAddress string, err error) {
+	remoteAddresses := strings.FieldsFunc(address, func(r rune) bool { return r == ',' || r == ';' })
+	var localAddresses []string
+	tunnels, localAddresses, err = StartTunnels(sshConfig, remoteAddresses)
+	if err != nil {
+		return
+	}
+	localAddress = strings.Join(localAddresses, ",")
+	return
+}
+
+// CloseTunnels 关闭多个隧道
+func CloseTunnels(tunnels []*Tunnel) {
+	for _, tunnel := range tunnels {
+		tunnel.Close()
+	}
+}
+
+// dialConn 经由 SSH 建立的连接，关闭时一起关闭 SSH 连接
+type dialConn struct {
+	net.Conn
+	sshClient *ssh.Client
+}
+
+func (c *dialConn) Close() (err error) {
+	err = c.Conn.Close()
+	_ = c.sshClient.Close()
+	return
+}
+
+// Dial 新建 SSH 连接（含跳板链），经由其连接到远程地址，返回的连接关闭时关闭 SSH 连接
+func Dial(sshConfig *Config, network string, address string) (conn net.Conn, err error) {
+	sshClient, err := NewClient(*sshConfig)
+	if err != nil {
+		return
+	}
+	remoteConn, err := sshClient.Dial(network, address)
+	if err != nil {
+		_ = sshClient.Close()
+		err = fmt.Errorf("ssh dial [%s] error: %w", address, err)
+		return
+	}
+	conn = &dialConn{Conn: remoteConn, sshClient: sshClient}
+	return
+}
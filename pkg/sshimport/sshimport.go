@@ -0,0 +1,408 @@
+package sshimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	FormatOpenSSH   = "openssh"
+	FormatPutty     = "putty"
+	FormatXshell    = "xshell"
+	FormatMobaXterm = "mobaxterm"
+
+	defaultPort = 22
+)
+
+// Host 导入的 SSH 主机
+type Host struct {
+	Name         string `json:"name"`
+	Host         string `json:"host"`
+	Port         int    `json:"port"`
+	Username     string `json:"username,omitempty"`
+	IdentityFile string `json:"identityFile,omitempty"`
+	// ProxyJump 跳板机列表，按连接顺序，值为导入的主机名称或 [user@]host[:port]
+	ProxyJump []string `json:"proxyJump,omitempty"`
+	Folder    string   `json:"folder,omitempty"`
+}
+
+// Address 返回 host:port
+func (this_ *Host) Address() string {
+	port := this_.Port
+	if port <= 0 {
+		port = defaultPort
+	}
+	return net.JoinHostPort(this_.Host, strconv.Itoa(port))
+}
+
+// Parse 按格式解析会话文件，fileName 用于 Xshell 单会话文件的名称，format 为空时根据内容识别
+func Parse(format string, fileName string, content []byte) (hosts []*Host, err error) {
+	text := decodeText(content)
+	if format == "" {
+		format = Detect(fileName, text)
+	}
+	switch format {
+	case FormatOpenSSH:
+		hosts = ParseOpenSSH(text)
+	case FormatPutty:
+		hosts = ParsePutty(text)
+	case FormatXshell:
+		name := strings.TrimSuffix(filepath.Base(strings.ReplaceAll(fileName, "\\", "/")), filepath.Ext(fileName))
+		if host := ParseXshell(name, text); host != nil {
+			hosts = append(hosts, host)
+		}
+	case FormatMobaXterm:
+		hosts = ParseMobaXterm(text)
+	default:
+		err = errors.New("导入格式[" + format + "]不支持")
+	}
+	return
+}
+
+// Detect 根据文件名和内容识别格式
+func Detect(fileName string, text string) string {
+	lowerName := strings.ToLower(fileName)
+	switch {
+	case strings.HasSuffix(lowerName, ".reg") || strings.Contains(text, `SimonTatham\PuTTY\Sessions`):
+		return FormatPutty
+	case strings.HasSuffix(lowerName, ".xsh") || strings.Contains(text, "[CONNECTION]"):
+		return FormatXshell
+	case strings.HasSuffix(lowerName, ".mxtsessions") || strings.Contains(text, "[Bookmarks"):
+		return FormatMobaXterm
+	}
+	return FormatOpenSSH
+}
+
+// decodeText 处理 UTF-16（注册表导出和 Xshell 文件常见）和 UTF-8 BOM
+func decodeText(content []byte) string {
+	var order binary.ByteOrder
+	if bytes.HasPrefix(content, []byte{0xff, 0xfe}) {
+		order = binary.LittleEndian
+	} else if bytes.HasPrefix(content, []byte{0xfe, 0xff}) {
+		order = binary.BigEndian
+	}
+	if order == nil {
+		return string(bytes.TrimPrefix(content, []byte{0xef, 0xbb, 0xbf}))
+	}
+	content = content[2:]
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		units[i] = order.Uint16(content[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// splitUserHost 拆分 [user@]host[:port]
+func splitUserHost(value string) (user string, host string, port int) {
+	host = value
+	if index := strings.LastIndex(host, "@"); index >= 0 {
+		user = host[:index]
+		host = host[index+1:]
+	}
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host = h
+		port, _ = strconv.Atoi(p)
+	}
+	return
+}
+
+// ParseJump 解析跳板机 [user@]host[:port]，返回用户名和 host:port
+func ParseJump(value string) (username string, address string) {
+	username, host, port := splitUserHost(value)
+	if port <= 0 {
+		port = defaultPort
+	}
+	address = net.JoinHostPort(host, strconv.Itoa(port))
+	return
+}
+
+// ParseOpenSSH 解析 OpenSSH config，忽略带通配符的 Host 和 Match 块，Host * 中的配置作为默认值
+func ParseOpenSSH(text string) (hosts []*Host) {
+	var defaults = &Host{}
+	var current []*Host
+	var inMatch bool
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value := splitKeyValue(line)
+		switch strings.ToLower(key) {
+		case "host":
+			current = nil
+			inMatch = false
+			for _, name := range strings.Fields(value) {
+				if name == "*" {
+					current = append(current, defaults)
+					continue
+				}
+				if strings.ContainsAny(name, "*?!") {
+					continue
+				}
+				host := &Host{Name: name}
+				hosts = append(hosts, host)
+				current = append(current, host)
+			}
+			continue
+		case "match":
+			current = nil
+			inMatch = true
+			continue
+		}
+		if inMatch {
+			continue
+		}
+		for _, host := range current {
+			setOpenSSHOption(host, key, value)
+		}
+	}
+	for _, host := range hosts {
+		if host.Host == "" {
+			host.Host = host.Name
+		}
+		if host.Port == 0 {
+			host.Port = defaults.Port
+		}
+		if host.Username == "" {
+			host.Username = defaults.Username
+		}
+		if host.IdentityFile == "" {
+			host.IdentityFile = defaults.IdentityFile
+		}
+		if host.ProxyJump == nil {
+			host.ProxyJump = defaults.ProxyJump
+		}
+		if host.Port == 0 {
+			host.Port = defaultPort
+		}
+	}
+	return
+}
+
+func splitKeyValue(line string) (key string, value string) {
+	index := strings.IndexAny(line, " \t=")
+	if index < 0 {
+		return line, ""
+	}
+	key = line[:index]
+	value = strings.TrimLeft(line[index:], " \t")
+	value = strings.TrimPrefix(value, "=")
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	return
+}
+
+// setOpenSSHOption 同一配置第一次出现的值生效，与 ssh 的行为一致
+func setOpenSSHOption(host *Host, key string, value string) {
+	switch strings.ToLower(key) {
+	case "hostname":
+		if host.Host == "" {
+			host.Host = value
+		}
+	case "user":
+		if host.Username == "" {
+			host.Username = value
+		}
+	case "port":
+		if host.Port == 0 {
+			host.Port, _ = strconv.Atoi(value)
+		}
+	case "identityfile":
+		if host.IdentityFile == "" {
+			host.IdentityFile = value
+		}
+	case "proxyjump":
+		if host.ProxyJump == nil && !strings.EqualFold(value, "none") {
+			host.ProxyJump = []string{}
+			for _, one := range strings.Split(value, ",") {
+				if one = strings.TrimSpace(one); one != "" {
+					host.ProxyJump = append(host.ProxyJump, one)
+				}
+			}
+		}
+	}
+}
+
+// parseIni 解析 INI，返回按出现顺序的 section 及其键值
+func parseIni(text string) (sections []string, values map[string][][2]string) {
+	values = make(map[string][][2]string)
+	var section string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			sections = append(sections, section)
+			continue
+		}
+		index := strings.Index(line, "=")
+		if index < 0 {
+			continue
+		}
+		values[section] = append(values[section], [2]string{strings.TrimSpace(line[:index]), strings.TrimSpace(line[index+1:])})
+	}
+	return
+}
+
+func iniValue(pairs [][2]string, key string) string {
+	for _, pair := range pairs {
+		if strings.EqualFold(strings.Trim(pair[0], `"`), key) {
+			return pair[1]
+		}
+	}
+	return ""
+}
+
+// ParsePutty 解析 PuTTY 注册表导出，只导入 SSH 会话
+func ParsePutty(text string) (hosts []*Host) {
+	const prefix = `\Software\SimonTatham\PuTTY\Sessions\`
+	sections, values := parseIni(text)
+	for _, section := range sections {
+		index := strings.Index(section, prefix)
+		if index < 0 {
+			continue
+		}
+		name := section[index+len(prefix):]
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+		pairs := values[section]
+		protocol := puttyString(iniValue(pairs, "Protocol"))
+		if protocol != "" && protocol != "ssh" {
+			continue
+		}
+		user, host, port := splitUserHost(puttyString(iniValue(pairs, "HostName")))
+		if host == "" {
+			continue
+		}
+		if username := puttyString(iniValue(pairs, "UserName")); username != "" {
+			user = username
+		}
+		if value := iniValue(pairs, "PortNumber"); strings.HasPrefix(value, "dword:") {
+			p, _ := strconv.ParseInt(strings.TrimPrefix(value, "dword:"), 16, 32)
+			port = int(p)
+		}
+		if port == 0 {
+			port = defaultPort
+		}
+		hosts = append(hosts, &Host{
+			Name:         name,
+			Host:         host,
+			Port:         port,
+			Username:     user,
+			IdentityFile: puttyString(iniValue(pairs, "PublicKeyFile")),
+		})
+	}
+	return
+}
+
+// puttyString 注册表字符串值，去掉引号并还原转义的反斜杠
+func puttyString(value string) string {
+	if !strings.HasPrefix(value, `"`) {
+		return ""
+	}
+	value = strings.TrimSuffix(strings.TrimPrefix(value, `"`), `"`)
+	return strings.ReplaceAll(value, `\\`, `\`)
+}
+
+// ParseXshell 解析单个 Xshell .xsh 会话文件，非 SSH 会话返回 nil
+func ParseXshell(name string, text string) (host *Host) {
+	_, values := parseIni(text)
+	connection := values["CONNECTION"]
+	if protocol := iniValue(connection, "Protocol"); protocol != "" && !strings.EqualFold(protocol, "SSH") {
+		return
+	}
+	address := iniValue(connection, "Host")
+	if address == "" {
+		return
+	}
+	port, _ := strconv.Atoi(iniValue(connection, "Port"))
+	if port == 0 {
+		port = defaultPort
+	}
+	authentication := values["CONNECTION:AUTHENTICATION"]
+	host = &Host{
+		Name:         name,
+		Host:         address,
+		Port:         port,
+		Username:     iniValue(authentication, "UserName"),
+		IdentityFile: iniValue(authentication, "UserKey"),
+	}
+	return
+}
+
+// MobaXterm 会话字段下标，会话值形如 #109#0%host%port%user%...
+const (
+	mobaTypeSSH          = "109"
+	mobaFieldHost        = 1
+	mobaFieldPort        = 2
+	mobaFieldUser        = 3
+	mobaFieldGateway     = 7
+	mobaFieldGatewayPort = 8
+	mobaFieldGatewayUser = 9
+	mobaFieldPrivateKey  = 14
+)
+
+// ParseMobaXterm 解析 MobaXterm .mxtsessions，只导入 SSH 会话，SSH 网关作为跳板机
+func ParseMobaXterm(text string) (hosts []*Host) {
+	sections, values := parseIni(text)
+	for _, section := range sections {
+		if !strings.HasPrefix(section, "Bookmarks") {
+			continue
+		}
+		pairs := values[section]
+		folder := strings.ReplaceAll(iniValue(pairs, "SubRep"), `\`, "/")
+		for _, pair := range pairs {
+			if pair[0] == "SubRep" || pair[0] == "ImgNum" {
+				continue
+			}
+			value := pair[1]
+			if index := strings.Index(value, "#"); index >= 0 {
+				value = value[index:]
+			}
+			fields := strings.Split(value, "%")
+			head := strings.Split(strings.TrimPrefix(fields[0], "#"), "#")
+			if head[0] != mobaTypeSSH || len(fields) <= mobaFieldUser || fields[mobaFieldHost] == "" {
+				continue
+			}
+			port, _ := strconv.Atoi(fields[mobaFieldPort])
+			if port == 0 {
+				port = defaultPort
+			}
+			host := &Host{
+				Name:     pair[0],
+				Host:     fields[mobaFieldHost],
+				Port:     port,
+				Username: fields[mobaFieldUser],
+				Folder:   folder,
+			}
+			if len(fields) > mobaFieldGatewayUser && fields[mobaFieldGateway] != "" {
+				jump := fields[mobaFieldGateway]
+				if fields[mobaFieldGatewayPort] != "" && fields[mobaFieldGatewayPort] != "22" {
+					jump = net.JoinHostPort(jump, fields[mobaFieldGatewayPort])
+				}
+				if fields[mobaFieldGatewayUser] != "" {
+					jump = fields[mobaFieldGatewayUser] + "@" + jump
+				}
+				host.ProxyJump = []string{jump}
+			}
+			if len(fields) > mobaFieldPrivateKey {
+				host.IdentityFile = strings.ReplaceAll(fields[mobaFieldPrivateKey], "_CurrentDrive_", "")
+			}
+			hosts = append(hosts, host)
+		}
+	}
+	return
+}
@@ -0,0 +1,102 @@
+package sshimport
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf16"
+)
+
+func TestParseOpenSSH(t *testing.T) {
+	text := `
+Host *
+    User admin
+    IdentityFile ~/.ssh/id_rsa
+
+Host bastion
+    HostName 10.0.0.1
+    Port 2222
+
+Host app db
+    HostName=192.168.1.10
+    User root
+    ProxyJump bastion,ops@10.0.0.2:22
+
+Host *.internal
+    User nobody
+
+Match host foo
+    User ignored
+`
+	hosts := ParseOpenSSH(text)
+	if len(hosts) != 3 {
+		t.Fatal(len(hosts))
+	}
+	if hosts[0].Address() != "10.0.0.1:2222" || hosts[0].Username != "admin" || hosts[0].IdentityFile != "~/.ssh/id_rsa" {
+		t.Fatal(hosts[0])
+	}
+	if hosts[1].Name != "app" || hosts[2].Name != "db" || hosts[2].Address() != "192.168.1.10:22" || hosts[2].Username != "root" {
+		t.Fatal(hosts[1], hosts[2])
+	}
+	if !reflect.DeepEqual(hosts[1].ProxyJump, []string{"bastion", "ops@10.0.0.2:22"}) {
+		t.Fatal(hosts[1].ProxyJump)
+	}
+}
+
+func TestParsePutty(t *testing.T) {
+	text := "Windows Registry Editor Version 5.00\r\n\r\n" +
+		"[HKEY_CURRENT_USER\\Software\\SimonTatham\\PuTTY\\Sessions\\my%20server]\r\n" +
+		"\"HostName\"=\"root@example.com\"\r\n" +
+		"\"PortNumber\"=dword:00000016\r\n" +
+		"\"Protocol\"=\"ssh\"\r\n" +
+		"\"PublicKeyFile\"=\"C:\\\\keys\\\\id.ppk\"\r\n\r\n" +
+		"[HKEY_CURRENT_USER\\Software\\SimonTatham\\PuTTY\\Sessions\\serial]\r\n" +
+		"\"HostName\"=\"\"\r\n" +
+		"\"Protocol\"=\"serial\"\r\n"
+
+	// 注册表导出为 UTF-16LE
+	units := utf16.Encode([]rune(text))
+	content := []byte{0xff, 0xfe}
+	for _, unit := range units {
+		content = append(content, byte(unit), byte(unit>>8))
+	}
+	hosts, err := Parse("", "sessions.reg", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 {
+		t.Fatal(len(hosts))
+	}
+	host := hosts[0]
+	if host.Name != "my server" || host.Address() != "example.com:22" || host.Username != "root" || host.IdentityFile != `C:\keys\id.ppk` {
+		t.Fatal(host)
+	}
+}
+
+func TestParseXshell(t *testing.T) {
+	text := "[CONNECTION]\nHost=10.1.1.1\nPort=2200\nProtocol=SSH\n[CONNECTION:AUTHENTICATION]\nUserName=deploy\n"
+	hosts, err := Parse("", `D:\sessions\web.xsh`, []byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].Name != "web" || hosts[0].Address() != "10.1.1.1:2200" || hosts[0].Username != "deploy" {
+		t.Fatal(hosts)
+	}
+}
+
+func TestParseMobaXterm(t *testing.T) {
+	text := "[Bookmarks]\nSubRep=\nImgNum=42\n" +
+		"web=#109#0%10.2.2.2%22%root%%-1%-1%jump.example.com%2222%ops%0%0%0%%_CurrentDrive_\\keys\\id_rsa%\n" +
+		"rdp=#91#4%10.2.2.3%3389%admin%\n" +
+		"[Bookmarks_1]\nSubRep=prod\\db\nImgNum=41\n" +
+		"db= #109#0%10.3.3.3%2200%dba%%-1%-1%%%%0%0%0%%%\n"
+	hosts := ParseMobaXterm(text)
+	if len(hosts) != 2 {
+		t.Fatal(len(hosts))
+	}
+	if hosts[0].Address() != "10.2.2.2:22" || !reflect.DeepEqual(hosts[0].ProxyJump, []string{"ops@jump.example.com:2222"}) || hosts[0].IdentityFile != `\keys\id_rsa` {
+		t.Fatal(hosts[0])
+	}
+	if hosts[1].Name != "db" || hosts[1].Folder != "prod/db" || hosts[1].Address() != "10.3.3.3:2200" || hosts[1].ProxyJump != nil {
+		t.Fatal(hosts[1])
+	}
+}
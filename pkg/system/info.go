@@ -189,6 +189,13 @@ func GetInfo() (info *Info) {
 		}
 	}
 
+	info.Disks = GetDiskUsages()
+
+	return
+}
+
+// GetDiskUsages 获取所有分区的使用情况
+func GetDiskUsages() (disks []*DiskUsageStat) {
 	ps, _ := disk.Partitions(true)
 	if ps != nil {
 		for _, p := range ps {
@@ -196,7 +203,7 @@ func GetInfo() (info *Info) {
 			if diskUsageStat == nil {
 				continue
 			}
-			info.Disks = append(info.Disks, &DiskUsageStat{
+			disks = append(disks, &DiskUsageStat{
 				Path:              diskUsageStat.Path,
 				Fstype:            diskUsageStat.Fstype,
 				Total:             diskUsageStat.Total,
@@ -0,0 +1,443 @@
+package telnet
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"regexp"
+	"sync"
+	"teamide/pkg/system"
+	"teamide/pkg/terminal"
+	"time"
+)
+
+const (
+	cmdSE   = 240
+	cmdSB   = 250
+	cmdWILL = 251
+	cmdWONT = 252
+	cmdDO   = 253
+	cmdDONT = 254
+	cmdIAC  = 255
+
+	optBinary = 0
+	optEcho   = 1
+	optSGA    = 3
+	optTTYPE  = 24
+	optNAWS   = 31
+
+	ttypeIS   = 0
+	ttypeSEND = 1
+
+	defaultTerminalType = "xterm"
+	defaultTimeout      = 5
+
+	// autoLoginTimeout 自动登录的最长时间，超时或出现命令提示符后不再自动输入
+	autoLoginTimeout = 60 * time.Second
+)
+
+// 选项协商状态，按 RFC 1143 的 Q 方法（不使用队列）
+const (
+	optionNo = iota
+	optionYes
+	optionWantNo
+	optionWantYes
+)
+
+var (
+	// localOptions 本端同意启用的选项，remoteOptions 同意远程启用的选项
+	localOptions  = map[byte]bool{optNAWS: true, optTTYPE: true, optSGA: true, optBinary: true}
+	remoteOptions = map[byte]bool{optEcho: true, optSGA: true, optBinary: true}
+)
+
+var (
+	// loginPromptRegexp、passwordPromptRegexp 自动登录时匹配的提示
+	loginPromptRegexp    = regexp.MustCompile(`(?i)(login|username|user name)\s*:\s*$`)
+	passwordPromptRegexp = regexp.MustCompile(`(?i)password\s*:\s*$`)
+	// shellPromptRegexp 命令提示符，出现后登录完成
+	shellPromptRegexp = regexp.MustCompile(`[$#>%]\s*$`)
+)
+
+// Config Telnet 配置，配置用户名、密码时匹配到登录提示后自动输入
+type Config struct {
+	Address      string `json:"address"`
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+	TerminalType string `json:"terminalType,omitempty"`
+	Timeout      int    `json:"timeout,omitempty"` // 连接超时时间，秒
+	// NodeId 经由节点连接，连接由节点发起
+	NodeId string `json:"nodeId,omitempty"`
+}
+
+// DialFunc 建立到目标地址的连接，用于经由 SSH 隧道连接
+type DialFunc func(network string, address string, timeout time.Duration) (conn net.Conn, err error)
+
+func NewTerminalService(config *Config, dial DialFunc) (res *terminalService) {
+	if dial == nil {
+		dial = net.DialTimeout
+	}
+	res = &terminalService{
+		config: config,
+		dial:   dial,
+		us:     map[byte]int{},
+		him:    map[byte]int{},
+	}
+	return
+}
+
+type terminalService struct {
+	config    *Config
+	dial      DialFunc
+	conn      net.Conn
+	writeLock sync.Mutex
+	// lock 保护窗口大小和协商状态
+	lock sync.Mutex
+	size *terminal.Size
+	// us 本端选项的协商状态，him 远程选项的协商状态
+	us  map[byte]int
+	him map[byte]int
+	// 跨多次读取的命令解析状态
+	state   int
+	command byte
+	sub     []byte
+	lastCR  bool
+	readBuf []byte
+	// startTime、loginDone 自动登录开始时间，超时或出现命令提示符后登录完成
+	startTime time.Time
+	loginDone bool
+	prompt    []byte
+	userSent  bool
+	passSent  bool
+	isStop    bool
+}
+
+const (
+	stateData = iota
+	stateIAC
+	stateOption
+	stateSub
+	stateSubIAC
+)
+
+func (this_ *terminalService) Start(size *terminal.Size) (err error) {
+	timeout := this_.config.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	this_.size = size
+	this_.startTime = time.Now()
+	conn, err := this_.dial("tcp", this_.config.Address, time.Duration(timeout)*time.Second)
+	if err != nil {
+		return
+	}
+	this_.writeLock.Lock()
+	this_.conn = conn
+	this_.writeLock.Unlock()
+	// 主动协商窗口大小、终端类型和抑制继续
+	this_.lock.Lock()
+	this_.us[optNAWS] = optionWantYes
+	this_.us[optTTYPE] = optionWantYes
+	this_.him[optSGA] = optionWantYes
+	this_.lock.Unlock()
+	err = this_.writeRaw([]byte{
+		cmdIAC, cmdWILL, optNAWS,
+		cmdIAC, cmdWILL, optTTYPE,
+		cmdIAC, cmdDO, optSGA,
+	})
+	return
+}
+
+func (this_ *terminalService) writeRaw(bs []byte) (err error) {
+	this_.writeLock.Lock()
+	defer this_.writeLock.Unlock()
+
+	if this_.conn == nil {
+		err = errors.New("telnet 未连接")
+		return
+	}
+	_, err = this_.conn.Write(bs)
+	return
+}
+
+// Write 用户输入，转义 IAC，非二进制模式下单独的回车按 NVT 规范发送 CR NUL
+func (this_ *terminalService) Write(buf []byte) (n int, err error) {
+	this_.lock.Lock()
+	binary := this_.us[optBinary] == optionYes
+	this_.lock.Unlock()
+	err = this_.writeRaw(escapeInput(buf, binary))
+	if err != nil {
+		return
+	}
+	n = len(buf)
+	return
+}
+
+func escapeInput(buf []byte, binary bool) (out []byte) {
+	out = make([]byte, 0, len(buf)+8)
+	for i, b := range buf {
+		switch {
+		case b == cmdIAC:
+			out = append(out, cmdIAC, cmdIAC)
+		case b == '\r' && !binary && (i+1 >= len(buf) || buf[i+1] != '\n'):
+			out = append(out, '\r', 0)
+		default:
+			out = append(out, b)
+		}
+	}
+	return
+}
+
+// Read 读取远程输出，处理其中的协商命令，只返回数据部分
+func (this_ *terminalService) Read(buf []byte) (n int, err error) {
+	if this_.conn == nil {
+		err = errors.New("telnet 未连接")
+		return
+	}
+	if len(this_.readBuf) < len(buf) {
+		this_.readBuf = make([]byte, len(buf))
+	}
+	for n == 0 {
+		var size int
+		size, err = this_.conn.Read(this_.readBuf[:len(buf)])
+		if size > 0 {
+			var reply []byte
+			n, reply = this_.parse(this_.readBuf[:size], buf)
+			if len(reply) > 0 {
+				if e := this_.writeRaw(reply); e != nil && err == nil {
+					err = e
+				}
+			}
+			this_.autoLogin(buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// parse 解析远程输出，数据写入 out，返回需要回复的协商命令
+func (this_ *terminalService) parse(in []byte, out []byte) (n int, reply []byte) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	for _, b := range in {
+		switch this_.state {
+		case stateData:
+			if b == cmdIAC {
+				this_.state = stateIAC
+				continue
+			}
+			// CR NUL 为单独的回车
+			if this_.lastCR && b == 0 {
+				this_.lastCR = false
+				continue
+			}
+			this_.lastCR = b == '\r'
+			out[n] = b
+			n++
+		case stateIAC:
+			switch b {
+			case cmdIAC:
+				out[n] = b
+				n++
+				this_.state = stateData
+			case cmdWILL, cmdWONT, cmdDO, cmdDONT:
+				this_.command = b
+				this_.state = stateOption
+			case cmdSB:
+				this_.sub = this_.sub[:0]
+				this_.state = stateSub
+			default:
+				// NOP、GA 等命令忽略
+				this_.state = stateData
+			}
+		case stateOption:
+			reply = append(reply, this_.negotiate(this_.command, b)...)
+			this_.state = stateData
+		case stateSub:
+			if b == cmdIAC {
+				this_.state = stateSubIAC
+				continue
+			}
+			this_.sub = append(this_.sub, b)
+		case stateSubIAC:
+			switch b {
+			case cmdSE:
+				reply = append(reply, this_.subNegotiate(this_.sub)...)
+				this_.state = stateData
+			case cmdIAC:
+				this_.sub = append(this_.sub, b)
+				this_.state = stateSub
+			default:
+				this_.state = stateSub
+			}
+		}
+	}
+	return
+}
+
+// negotiate 按 RFC 1143 的 Q 方法应答，只在选项状态变化时回复，避免协商循环
+func (this_ *terminalService) negotiate(command byte, option byte) (reply []byte) {
+	switch command {
+	case cmdDO:
+		switch this_.us[option] {
+		case optionNo:
+			if !localOptions[option] {
+				reply = append(reply, cmdIAC, cmdWONT, option)
+				return
+			}
+			this_.us[option] = optionYes
+			reply = append(reply, cmdIAC, cmdWILL, option)
+		case optionWantYes:
+			// 主动请求的选项收到应答后不再回复
+			this_.us[option] = optionYes
+		case optionWantNo:
+			// 已发送 WONT，对方以 DO 应答为错误应答
+			this_.us[option] = optionNo
+			return
+		default:
+			return
+		}
+		if option == optNAWS {
+			reply = append(reply, this_.nawsBytes()...)
+		}
+	case cmdDONT:
+		switch this_.us[option] {
+		case optionYes:
+			this_.us[option] = optionNo
+			reply = append(reply, cmdIAC, cmdWONT, option)
+		case optionWantYes, optionWantNo:
+			this_.us[option] = optionNo
+		}
+	case cmdWILL:
+		switch this_.him[option] {
+		case optionNo:
+			if !remoteOptions[option] {
+				reply = append(reply, cmdIAC, cmdDONT, option)
+				return
+			}
+			this_.him[option] = optionYes
+			reply = append(reply, cmdIAC, cmdDO, option)
+		case optionWantYes:
+			this_.him[option] = optionYes
+		case optionWantNo:
+			this_.him[option] = optionNo
+		}
+	case cmdWONT:
+		switch this_.him[option] {
+		case optionYes:
+			this_.him[option] = optionNo
+			reply = append(reply, cmdIAC, cmdDONT, option)
+		case optionWantYes, optionWantNo:
+			this_.him[option] = optionNo
+		}
+	}
+	return
+}
+
+func (this_ *terminalService) subNegotiate(sub []byte) (reply []byte) {
+	if len(sub) < 2 || sub[0] != optTTYPE || sub[1] != ttypeSEND {
+		return
+	}
+	terminalType := this_.config.TerminalType
+	if terminalType == "" {
+		terminalType = defaultTerminalType
+	}
+	reply = append(reply, cmdIAC, cmdSB, optTTYPE, ttypeIS)
+	reply = append(reply, terminalType...)
+	reply = append(reply, cmdIAC, cmdSE)
+	return
+}
+
+// nawsBytes 窗口大小子协商，宽高为 2 字节大端，值为 255 时需要转义
+func (this_ *terminalService) nawsBytes() (bs []byte) {
+	if this_.size == nil {
+		return
+	}
+	bs = append(bs, cmdIAC, cmdSB, optNAWS)
+	for _, v := range []int{this_.size.Cols, this_.size.Rows} {
+		for _, b := range []byte{byte(v >> 8), byte(v)} {
+			bs = append(bs, b)
+			if b == cmdIAC {
+				bs = append(bs, cmdIAC)
+			}
+		}
+	}
+	bs = append(bs, cmdIAC, cmdSE)
+	return
+}
+
+// autoLogin 匹配到登录、密码提示后自动输入，各只输入一次
+// 出现命令提示符或超过 autoLoginTimeout 后不再自动输入，避免将密码输入到登录后其它程序的密码提示中
+func (this_ *terminalService) autoLogin(bs []byte) {
+	if this_.loginDone {
+		return
+	}
+	needUser := this_.config.Username != "" && !this_.userSent
+	needPass := this_.config.Password != "" && !this_.passSent
+	if (!needUser && !needPass) || time.Since(this_.startTime) > autoLoginTimeout {
+		this_.loginDone = true
+		this_.prompt = nil
+		return
+	}
+	this_.prompt = append(this_.prompt, bs...)
+	if len(this_.prompt) > 256 {
+		this_.prompt = this_.prompt[len(this_.prompt)-256:]
+	}
+	prompt := bytes.TrimRight(this_.prompt, "\x00")
+	if needUser && loginPromptRegexp.Match(prompt) {
+		this_.userSent = true
+		this_.prompt = nil
+		_, _ = this_.Write([]byte(this_.config.Username + "\r\n"))
+	} else if needPass && passwordPromptRegexp.Match(prompt) {
+		this_.passSent = true
+		this_.prompt = nil
+		_, _ = this_.Write([]byte(this_.config.Password + "\r\n"))
+	} else if shellPromptRegexp.Match(prompt) {
+		this_.loginDone = true
+		this_.prompt = nil
+	}
+}
+
+func (this_ *terminalService) ChangeSize(size *terminal.Size) (err error) {
+	this_.lock.Lock()
+	this_.size = size
+	var bs []byte
+	if this_.us[optNAWS] == optionYes {
+		bs = this_.nawsBytes()
+	}
+	this_.lock.Unlock()
+	if len(bs) == 0 {
+		return
+	}
+	err = this_.writeRaw(bs)
+	return
+}
+
+func (this_ *terminalService) Stop() {
+	this_.writeLock.Lock()
+	defer this_.writeLock.Unlock()
+
+	if this_.isStop {
+		return
+	}
+	this_.isStop = true
+	if this_.conn != nil {
+		_ = this_.conn.Close()
+	}
+}
+
+func (this_ *terminalService) IsWindows() (isWindows bool, err error) {
+	return
+}
+
+func (this_ *terminalService) SystemInfo() (res *system.Info, err error) {
+	err = errors.New("telnet 不支持获取系统信息")
+	return
+}
+
+func (this_ *terminalService) SystemMonitorData() (res *system.MonitorData, err error) {
+	err = errors.New("telnet 不支持获取监控信息")
+	return
+}
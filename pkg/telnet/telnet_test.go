@@ -0,0 +1,123 @@
+package telnet
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"teamide/pkg/terminal"
+	"testing"
+	"time"
+)
+
+func readN(t *testing.T, conn net.Conn, n int) []byte {
+	buf := make([]byte, n)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestTerminalService(t *testing.T) {
+	client, server := net.Pipe()
+	service := NewTerminalService(&Config{
+		Address:  "127.0.0.1:23",
+		Username: "admin",
+		Password: "123456",
+	}, func(network string, address string, timeout time.Duration) (net.Conn, error) {
+		return client, nil
+	})
+
+	go func() {
+		if err := service.Start(&terminal.Size{Cols: 80, Rows: 255}); err != nil {
+			t.Error(err)
+		}
+	}()
+	// 主动协商
+	if bs := readN(t, server, 9); !bytes.Equal(bs, []byte{cmdIAC, cmdWILL, optNAWS, cmdIAC, cmdWILL, optTTYPE, cmdIAC, cmdDO, optSGA}) {
+		t.Fatal(bs)
+	}
+
+	result := make(chan []byte, 10)
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, err := service.Read(buf)
+			if err != nil {
+				close(result)
+				return
+			}
+			result <- append([]byte{}, buf[:n]...)
+		}
+	}()
+
+	// 应答主动请求的选项不再回复 WILL，行高 255 需要转义
+	_, _ = server.Write([]byte{cmdIAC, cmdDO, optNAWS})
+	if bs := readN(t, server, 10); !bytes.Equal(bs, []byte{cmdIAC, cmdSB, optNAWS, 0, 80, 0, 255, 255, cmdIAC, cmdSE}) {
+		t.Fatal(bs)
+	}
+	_, _ = server.Write([]byte{cmdIAC, cmdSB, optTTYPE, ttypeSEND, cmdIAC, cmdSE})
+	if bs := readN(t, server, 11); !bytes.Equal(bs, append(append([]byte{cmdIAC, cmdSB, optTTYPE, ttypeIS}, "xterm"...), cmdIAC, cmdSE)) {
+		t.Fatal(bs)
+	}
+	// 不支持的选项拒绝，远程回显同意
+	_, _ = server.Write([]byte{cmdIAC, cmdDO, 5, cmdIAC, cmdWILL, optEcho})
+	if bs := readN(t, server, 6); !bytes.Equal(bs, []byte{cmdIAC, cmdWONT, 5, cmdIAC, cmdDO, optEcho}) {
+		t.Fatal(bs)
+	}
+
+	// 已启用的选项再次请求、未启用的选项请求停用时不回复
+	_, _ = server.Write([]byte{cmdIAC, cmdWILL, optEcho, cmdIAC, cmdDONT, 5, cmdIAC, cmdWONT, 6, cmdIAC, cmdDO, 6})
+	if bs := readN(t, server, 3); !bytes.Equal(bs, []byte{cmdIAC, cmdWONT, 6}) {
+		t.Fatal(bs)
+	}
+
+	// 数据中的 IAC IAC 和 CR NUL
+	_, _ = server.Write([]byte{'a', cmdIAC, cmdIAC, '\r', 0, 'b', '\r', '\n'})
+	if bs := <-result; !bytes.Equal(bs, []byte{'a', cmdIAC, '\r', 'b', '\r', '\n'}) {
+		t.Fatal(bs)
+	}
+
+	// 自动登录
+	_, _ = server.Write([]byte("login: "))
+	if bs := readN(t, server, 7); string(bs) != "admin\r\n" {
+		t.Fatalf("%q", bs)
+	}
+	<-result
+	_, _ = server.Write([]byte("Password:"))
+	if bs := readN(t, server, 8); string(bs) != "123456\r\n" {
+		t.Fatalf("%q", bs)
+	}
+	<-result
+
+	go func() {
+		_, _ = service.Write([]byte{'\r', cmdIAC})
+	}()
+	if bs := readN(t, server, 4); !bytes.Equal(bs, []byte{'\r', 0, cmdIAC, cmdIAC}) {
+		t.Fatal(bs)
+	}
+
+	service.Stop()
+	if _, ok := <-result; ok {
+		t.Fatal("read should end after stop")
+	}
+}
+
+func TestAutoLoginDone(t *testing.T) {
+	// 出现命令提示符后不再自动输入
+	service := NewTerminalService(&Config{Password: "123456"}, nil)
+	service.startTime = time.Now()
+	service.autoLogin([]byte("$ "))
+	service.autoLogin([]byte("Password:"))
+	if service.passSent || !service.loginDone {
+		t.Fatal("password should not be sent after shell prompt")
+	}
+
+	// 超时后不再自动输入
+	service = NewTerminalService(&Config{Password: "123456"}, nil)
+	service.startTime = time.Now().Add(-autoLoginTimeout * 2)
+	service.autoLogin([]byte("Password:"))
+	if service.passSent {
+		t.Fatal("password should not be sent after timeout")
+	}
+}
@@ -0,0 +1,269 @@
+package terminal
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	escapeNone = iota
+	escapeStart
+	escapeCSI
+	escapeOSC
+	escapeCharset
+)
+
+var (
+	// passwordPromptRegexp 密码输入不回显，当前行为密码提示时输入的内容可能为密码
+	passwordPromptRegexp = regexp.MustCompile(`(?i)(password|passphrase|密码|口令)[^\n]*[:：]\s*$`)
+	// promptEnds 回显行中提示符的结尾，之后为输入的命令
+	promptEnds = []string{"$ ", "# ", "> ", "% "}
+)
+
+// CommandLine 跟踪终端当前输入的命令行
+// 优先使用用户输入的内容，输入中包含方向键、Tab 补全等无法确定结果的按键时，使用终端回显的当前行
+type CommandLine struct {
+	input     []rune
+	uncertain bool
+
+	screen    []rune
+	cursor    int
+	state     int
+	escape    []byte
+	pending   []byte
+	altScreen bool
+
+	lock sync.Mutex
+}
+
+// Input 记录用户输入，不包含回车
+func (this_ *CommandLine) Input(bs []byte) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	for _, r := range string(bs) {
+		switch {
+		case r == 0x7f || r == '\b':
+			if len(this_.input) > 0 {
+				this_.input = this_.input[:len(this_.input)-1]
+			}
+		case r == 0x15:
+			// Ctrl+U
+			this_.input = nil
+		case r == 0x17:
+			// Ctrl+W
+			s := strings.TrimRight(string(this_.input), " ")
+			this_.input = []rune(s[:strings.LastIndex(s, " ")+1])
+		case r == 0x03:
+			// Ctrl+C
+			this_.input = nil
+			this_.uncertain = false
+		case r < 0x20:
+			this_.uncertain = true
+		default:
+			this_.input = append(this_.input, r)
+		}
+	}
+}
+
+// Reset 回车后重置输入
+func (this_ *CommandLine) Reset() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	this_.input = nil
+	this_.uncertain = false
+}
+
+// Output 根据终端输出维护当前行的内容，只处理行编辑常用的控制序列
+func (this_ *CommandLine) Output(bs []byte) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	if len(this_.pending) > 0 {
+		bs = append(this_.pending, bs...)
+		this_.pending = nil
+	}
+	for len(bs) > 0 {
+		if !utf8.FullRune(bs) {
+			this_.pending = append([]byte{}, bs...)
+			return
+		}
+		r, size := utf8.DecodeRune(bs)
+		bs = bs[size:]
+		this_.outputRune(r)
+	}
+}
+
+func (this_ *CommandLine) outputRune(r rune) {
+	switch this_.state {
+	case escapeStart:
+		switch r {
+		case '[':
+			this_.state = escapeCSI
+			this_.escape = this_.escape[:0]
+		case ']':
+			this_.state = escapeOSC
+		case '(', ')':
+			this_.state = escapeCharset
+		default:
+			this_.state = escapeNone
+		}
+		return
+	case escapeCSI:
+		if r >= 0x40 && r <= 0x7e {
+			this_.state = escapeNone
+			this_.onCSI(string(this_.escape), r)
+			return
+		}
+		if len(this_.escape) < 32 {
+			this_.escape = append(this_.escape, byte(r))
+		}
+		return
+	case escapeOSC:
+		if r == '\a' || r == '\\' {
+			this_.state = escapeNone
+		}
+		return
+	case escapeCharset:
+		this_.state = escapeNone
+		return
+	}
+	switch r {
+	case 0x1b:
+		this_.state = escapeStart
+	case '\r':
+		this_.cursor = 0
+	case '\n':
+		this_.screen = nil
+		this_.cursor = 0
+	case '\b':
+		if this_.cursor > 0 {
+			this_.cursor--
+		}
+	case '\t':
+		for {
+			this_.put(' ')
+			if this_.cursor%8 == 0 {
+				break
+			}
+		}
+	default:
+		if r >= 0x20 {
+			this_.put(r)
+		}
+	}
+}
+
+func (this_ *CommandLine) put(r rune) {
+	for len(this_.screen) < this_.cursor {
+		this_.screen = append(this_.screen, ' ')
+	}
+	if this_.cursor < len(this_.screen) {
+		this_.screen[this_.cursor] = r
+	} else {
+		this_.screen = append(this_.screen, r)
+	}
+	this_.cursor++
+}
+
+func (this_ *CommandLine) onCSI(params string, final rune) {
+	if strings.HasPrefix(params, "?") {
+		switch params[1:] {
+		case "1049", "1047", "47":
+			if final == 'h' {
+				this_.altScreen = true
+			} else if final == 'l' {
+				this_.altScreen = false
+			}
+		}
+		return
+	}
+	n, _ := strconv.Atoi(params)
+	count := n
+	if count <= 0 {
+		count = 1
+	}
+	switch final {
+	case 'K':
+		if n == 0 && this_.cursor < len(this_.screen) {
+			this_.screen = this_.screen[:this_.cursor]
+		} else if n == 2 {
+			this_.screen = nil
+		}
+	case 'J':
+		if n == 2 || n == 3 {
+			this_.screen = nil
+			this_.cursor = 0
+		}
+	case 'C':
+		this_.cursor += count
+	case 'D':
+		this_.cursor -= count
+		if this_.cursor < 0 {
+			this_.cursor = 0
+		}
+	case 'G':
+		this_.cursor = count - 1
+	case 'H':
+		// 光标移动到其它行，当前行重新开始
+		this_.screen = nil
+		this_.cursor = 0
+		if index := strings.Index(params, ";"); index >= 0 {
+			if col, _ := strconv.Atoi(params[index+1:]); col > 0 {
+				this_.cursor = col - 1
+			}
+		}
+	case 'P':
+		if this_.cursor < len(this_.screen) {
+			end := this_.cursor + count
+			if end > len(this_.screen) {
+				end = len(this_.screen)
+			}
+			this_.screen = append(this_.screen[:this_.cursor], this_.screen[end:]...)
+		}
+	case '@':
+		if this_.cursor < len(this_.screen) {
+			blank := []rune(strings.Repeat(" ", count))
+			this_.screen = append(this_.screen[:this_.cursor], append(blank, this_.screen[this_.cursor:]...)...)
+		}
+	}
+}
+
+// Line 获取当前命令行，secret 为 true 表示当前行为密码提示，记录时不保存输入的内容
+// 全屏程序、密码提示由远程输出决定，只影响记录方式，不影响命令检查
+func (this_ *CommandLine) Line() (line string, secret bool) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	input := string(this_.input)
+	if this_.altScreen {
+		line = input
+		return
+	}
+	screen := string(this_.screen)
+	// 密码不回显，当前行为密码提示且不包含输入内容
+	if !this_.uncertain && passwordPromptRegexp.MatchString(screen) && (input == "" || !strings.Contains(screen, input)) {
+		line = input
+		secret = true
+		return
+	}
+	if !this_.uncertain {
+		line = input
+		return
+	}
+	line = strings.TrimRight(screen, " ")
+	index := -1
+	for _, end := range promptEnds {
+		if i := strings.Index(line, end); i >= 0 && (index < 0 || i < index) {
+			index = i
+		}
+	}
+	if index >= 0 {
+		line = line[index+2:]
+	}
+	return
+}
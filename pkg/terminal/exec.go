@@ -0,0 +1,91 @@
+package terminal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"time"
+)
+
+const (
+	// ExecOutputLimit 非交互执行时标准输出、错误输出各自保留的最大长度，超出部分丢弃
+	ExecOutputLimit = 1024 * 1024
+)
+
+// ExecResult 非交互执行命令的结果
+type ExecResult struct {
+	ExitCode  int    `json:"exitCode"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// LimitBuffer 只保留前 limit 个字节的缓冲区，超出部分丢弃但不返回错误，避免命令因输出过多而失败
+type LimitBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	Truncated bool
+}
+
+func NewLimitBuffer(limit int) *LimitBuffer {
+	return &LimitBuffer{
+		limit: limit,
+	}
+}
+
+func (this_ *LimitBuffer) Write(p []byte) (n int, err error) {
+	n = len(p)
+	remain := this_.limit - this_.buf.Len()
+	if remain < len(p) {
+		this_.Truncated = true
+		if remain <= 0 {
+			return
+		}
+		p = p[:remain]
+	}
+	this_.buf.Write(p)
+	return
+}
+
+func (this_ *LimitBuffer) String() string {
+	return this_.buf.String()
+}
+
+// Exec 在本机非交互执行命令，超时后结束进程
+func Exec(command string, timeout time.Duration) (res *ExecResult, err error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	if IsWindows() {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	stdout := NewLimitBuffer(ExecOutputLimit)
+	stderr := NewLimitBuffer(ExecOutputLimit)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	res = &ExecResult{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Truncated: stdout.Truncated || stderr.Truncated,
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		res.ExitCode = -1
+		err = errors.New("命令执行超时")
+		return
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		res.ExitCode = exitErr.ExitCode()
+		err = nil
+	}
+	return
+}
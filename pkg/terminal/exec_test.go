@@ -0,0 +1,39 @@
+package terminal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimitBuffer(t *testing.T) {
+	buf := NewLimitBuffer(5)
+	n, err := buf.Write([]byte("abc"))
+	if err != nil || n != 3 {
+		t.Fatal(n, err)
+	}
+	n, err = buf.Write([]byte("defg"))
+	if err != nil || n != 4 {
+		t.Fatal(n, err)
+	}
+	if buf.String() != "abcde" || !buf.Truncated {
+		t.Fatal(buf.String(), buf.Truncated)
+	}
+}
+
+func TestExec(t *testing.T) {
+	if IsWindows() {
+		return
+	}
+	res, err := Exec("echo out; echo err 1>&2; exit 3", time.Second*10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExitCode != 3 || res.Stdout != "out\n" || res.Stderr != "err\n" {
+		t.Fatal(res)
+	}
+
+	_, err = Exec("sleep 5", time.Millisecond*100)
+	if err == nil {
+		t.Fatal("should timeout")
+	}
+}
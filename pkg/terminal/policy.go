@@ -0,0 +1,129 @@
+package terminal
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	// PolicyAllow 允许执行
+	PolicyAllow = 0
+	// PolicyConfirm 需要用户确认后执行
+	PolicyConfirm = 1
+	// PolicyDeny 拒绝执行
+	PolicyDeny = 2
+)
+
+// CommandPolicy 命令策略，规则为正则表达式，匹配命令行中的任意位置，需要整行匹配时使用 ^ $
+type CommandPolicy struct {
+	Name        string
+	DenyList    []string
+	ConfirmList []string
+	// AllowOnly 只允许执行 AllowList 中的命令
+	AllowOnly bool
+	AllowList []string
+}
+
+// PolicyResult 命令检查结果
+type PolicyResult struct {
+	Action int
+	Policy string
+	Rule   string
+	Reason string
+}
+
+type policyRule struct {
+	policy string
+	rule   string
+	re     *regexp.Regexp
+}
+
+// CommandChecker 合并多个策略检查命令：命中拒绝规则优先，其次为只允许模式，最后为确认规则
+type CommandChecker struct {
+	denyList    []*policyRule
+	confirmList []*policyRule
+	allowOnly   bool
+	allowList   []*policyRule
+}
+
+// NewCommandChecker 编译策略规则，任意一个策略为只允许模式时启用只允许模式
+func NewCommandChecker(policies ...*CommandPolicy) (checker *CommandChecker, err error) {
+	checker = &CommandChecker{}
+	compile := func(policy *CommandPolicy, rules []string) (list []*policyRule, err error) {
+		for _, rule := range rules {
+			rule = strings.TrimSpace(rule)
+			if rule == "" {
+				continue
+			}
+			var re *regexp.Regexp
+			re, err = regexp.Compile(rule)
+			if err != nil {
+				err = errors.New("命令策略[" + policy.Name + "]规则[" + rule + "]错误:" + err.Error())
+				return
+			}
+			list = append(list, &policyRule{policy: policy.Name, rule: rule, re: re})
+		}
+		return
+	}
+	for _, policy := range policies {
+		var list []*policyRule
+		if list, err = compile(policy, policy.DenyList); err != nil {
+			return
+		}
+		checker.denyList = append(checker.denyList, list...)
+		if list, err = compile(policy, policy.ConfirmList); err != nil {
+			return
+		}
+		checker.confirmList = append(checker.confirmList, list...)
+		if list, err = compile(policy, policy.AllowList); err != nil {
+			return
+		}
+		checker.allowList = append(checker.allowList, list...)
+		if policy.AllowOnly {
+			checker.allowOnly = true
+		}
+	}
+	return
+}
+
+// IsEmpty 没有任何规则
+func (this_ *CommandChecker) IsEmpty() bool {
+	return len(this_.denyList) == 0 && len(this_.confirmList) == 0 && !this_.allowOnly
+}
+
+// Check 检查命令，空命令总是允许
+// 全屏程序、密码提示等状态来自远程输出，无法信任，所有提交的行都检查全部规则
+func (this_ *CommandChecker) Check(command string) (res *PolicyResult) {
+	res = &PolicyResult{Action: PolicyAllow}
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return
+	}
+	for _, one := range this_.denyList {
+		if one.re.MatchString(command) {
+			res = &PolicyResult{Action: PolicyDeny, Policy: one.policy, Rule: one.rule, Reason: "命中拒绝规则[" + one.rule + "]"}
+			return
+		}
+	}
+	if this_.allowOnly {
+		var allowed bool
+		for _, one := range this_.allowList {
+			if one.re.MatchString(command) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			res = &PolicyResult{Action: PolicyDeny, Reason: "不在允许的命令列表中"}
+			return
+		}
+	}
+	for _, one := range this_.confirmList {
+		if one.re.MatchString(command) {
+			res = &PolicyResult{Action: PolicyConfirm, Policy: one.policy, Rule: one.rule, Reason: "命中确认规则[" + one.rule + "]"}
+			return
+		}
+	}
+	return
+}
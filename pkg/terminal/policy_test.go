@@ -0,0 +1,116 @@
+package terminal
+
+import "testing"
+
+func TestCommandChecker(t *testing.T) {
+	checker, err := NewCommandChecker(&CommandPolicy{
+		Name:        "default",
+		DenyList:    []string{`rm\s+-[a-z]*r[a-z]*f?\s+/\s*$`, `^\s*(shutdown|reboot|halt)\b`},
+		ConfirmList: []string{`^\s*systemctl\s+(stop|restart)\b`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]int{
+		"rm -rf /":                 PolicyDeny,
+		"sudo rm -rf / ":           PolicyDeny,
+		"rm -rf /tmp/a":            PolicyAllow,
+		"shutdown -h now":          PolicyDeny,
+		"systemctl restart nginx":  PolicyConfirm,
+		"systemctl status nginx":   PolicyAllow,
+		"":                         PolicyAllow,
+		"echo shutdown is blocked": PolicyAllow,
+	}
+	for command, action := range cases {
+		if res := checker.Check(command); res.Action != action {
+			t.Fatal(command, res)
+		}
+	}
+
+	checker, err = NewCommandChecker(&CommandPolicy{AllowOnly: true, AllowList: []string{`^(ls|cat|tail)\b`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checker.Check("tail -f app.log").Action != PolicyAllow || checker.Check("vi app.log").Action != PolicyDeny {
+		t.Fatal("allow only")
+	}
+
+	if _, err = NewCommandChecker(&CommandPolicy{DenyList: []string{"("}}); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	line := &CommandLine{}
+	line.Output([]byte("[root@host ~]# "))
+	line.Input([]byte("rm -rf /tmpx"))
+	line.Input([]byte{0x7f, 0x7f, 0x7f, 0x7f})
+	if command, secret := line.Line(); command != "rm -rf /" || secret {
+		t.Fatal(command)
+	}
+	line.Reset()
+
+	// 方向键调出历史命令，使用回显的当前行
+	line.Output([]byte("\r\n[root@host ~]# "))
+	line.Input([]byte("\x1b[A"))
+	line.Output([]byte("ls -l\x1b[K"))
+	line.Output([]byte("\r[root@host ~]# shutdown\x1b[K"))
+	if command, _ := line.Line(); command != "shutdown" {
+		t.Fatal(command)
+	}
+	line.Reset()
+
+	// 密码输入不回显
+	line.Output([]byte("\r\n[sudo] password for root: "))
+	line.Input([]byte("secret"))
+	if command, secret := line.Line(); command != "secret" || !secret {
+		t.Fatal(command, secret)
+	}
+	line.Reset()
+
+	// 全屏程序
+	line.Output([]byte("\x1b[?1049h\x1b[1;1H"))
+	line.Input([]byte(":wq"))
+	if command, secret := line.Line(); command != ":wq" || secret {
+		t.Fatal("alt screen", command)
+	}
+	line.Output([]byte("\x1b[?1049l\r\n[root@host ~]# "))
+	line.Reset()
+	line.Input([]byte("ls"))
+	if command, secret := line.Line(); command != "ls" || secret {
+		t.Fatal(command)
+	}
+}
+
+// 远程输出切换到全屏或输出密码提示，提交的命令仍然检查只允许和确认规则
+func TestCommandLineRemoteState(t *testing.T) {
+	checker, err := NewCommandChecker(&CommandPolicy{
+		AllowOnly:   true,
+		AllowList:   []string{`^(ls|cat|systemctl)\b`},
+		ConfirmList: []string{`^\s*systemctl\s+(stop|restart)\b`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := &CommandLine{}
+	line.Output([]byte("[root@host ~]# echo -e '\\e[?1049h'\r\n\x1b[?1049h"))
+	line.Input([]byte("rm -rf /tmp/a"))
+	command, _ := line.Line()
+	if res := checker.Check(command); res.Action != PolicyDeny {
+		t.Fatal(command, res)
+	}
+	line.Reset()
+	line.Input([]byte("systemctl restart nginx"))
+	command, _ = line.Line()
+	if res := checker.Check(command); res.Action != PolicyConfirm {
+		t.Fatal(command, res)
+	}
+	line.Reset()
+
+	line.Output([]byte("\x1b[?1049l\r\nfake password: "))
+	line.Input([]byte("rm -rf /tmp/a"))
+	command, secret := line.Line()
+	if res := checker.Check(command); !secret || res.Action != PolicyDeny {
+		t.Fatal(command, secret, res)
+	}
+}
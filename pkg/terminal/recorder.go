@@ -0,0 +1,189 @@
+package terminal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// RecordEventOutput 终端输出
+	RecordEventOutput = "o"
+	// RecordEventInput 用户输入
+	RecordEventInput = "i"
+	// RecordEventResize 窗口大小变更，数据格式为 `{cols}x{rows}`
+	RecordEventResize = "r"
+	// RecordEventMarker 标记，如文件上传下载的开始、结束
+	RecordEventMarker = "m"
+)
+
+// RecordHeader asciicast v2 文件头
+type RecordHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// NewRecorder 创建终端录像，格式为 asciicast v2，文件已存在时追加事件
+func NewRecorder(path string, header *RecordHeader) (res *Recorder, err error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return
+	}
+	res = &Recorder{
+		file:      file,
+		writer:    bufio.NewWriter(file),
+		startTime: time.Now(),
+	}
+	if stat.Size() > 0 {
+		// 追加时沿用原文件头的开始时间，保证时间轴连续
+		var find *RecordHeader
+		find, err = ReadRecordHeader(path)
+		if err == nil && find.Timestamp > 0 {
+			res.startTime = time.Unix(find.Timestamp, 0)
+		}
+		err = nil
+		return
+	}
+	header.Version = 2
+	header.Timestamp = res.startTime.Unix()
+	bs, err := json.Marshal(header)
+	if err != nil {
+		_ = file.Close()
+		res = nil
+		return
+	}
+	_, _ = res.writer.Write(bs)
+	_ = res.writer.WriteByte('\n')
+	err = res.writer.Flush()
+	if err != nil {
+		_ = file.Close()
+		res = nil
+	}
+	return
+}
+
+// Recorder 终端录像
+type Recorder struct {
+	file      *os.File
+	writer    *bufio.Writer
+	startTime time.Time
+	// 输出可能在多字节字符中间截断，剩余字节留到下次写入
+	outputRest []byte
+	inputRest  []byte
+	closed     bool
+	lock       sync.Mutex
+}
+
+// Output 记录终端输出
+func (this_ *Recorder) Output(bs []byte) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	this_.outputRest = this_.writeData(RecordEventOutput, this_.outputRest, bs)
+}
+
+// Input 记录用户输入
+func (this_ *Recorder) Input(bs []byte) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	this_.inputRest = this_.writeData(RecordEventInput, this_.inputRest, bs)
+}
+
+// Resize 记录窗口大小变更
+func (this_ *Recorder) Resize(cols int, rows int) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	this_.writeEvent(RecordEventResize, fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// Marker 记录标记
+func (this_ *Recorder) Marker(label string) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	this_.writeEvent(RecordEventMarker, label)
+}
+
+// Close 关闭录像
+func (this_ *Recorder) Close() {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	if this_.closed {
+		return
+	}
+	this_.closed = true
+	_ = this_.file.Close()
+}
+
+func (this_ *Recorder) writeData(eventType string, rest []byte, bs []byte) []byte {
+	data := append(rest, bs...)
+	end := len(data)
+	// 末尾不完整的 UTF-8 字符最多 3 个字节
+	for i := 1; i <= 3 && i <= len(data); i++ {
+		b := data[len(data)-i]
+		if b < 0x80 {
+			break
+		}
+		if utf8.RuneStart(b) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				end = len(data) - i
+			}
+			break
+		}
+	}
+	if end > 0 {
+		this_.writeEvent(eventType, string(data[:end]))
+	}
+	return append([]byte{}, data[end:]...)
+}
+
+func (this_ *Recorder) writeEvent(eventType string, data string) {
+	if this_.closed {
+		return
+	}
+	elapsed := float64(time.Since(this_.startTime).Microseconds()) / 1e6
+	bs, err := json.Marshal([]interface{}{elapsed, eventType, data})
+	if err != nil {
+		return
+	}
+	_, _ = this_.writer.Write(bs)
+	_ = this_.writer.WriteByte('\n')
+	_ = this_.writer.Flush()
+}
+
+// ReadRecordHeader 读取录像文件头
+func ReadRecordHeader(path string) (header *RecordHeader, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = file.Close() }()
+	reader := bufio.NewReader(file)
+	line, err := reader.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return
+	}
+	header = &RecordHeader{}
+	err = json.Unmarshal(line, header)
+	if err != nil {
+		header = nil
+		return
+	}
+	if header.Version != 2 {
+		err = errors.New(fmt.Sprintf("不支持的录像版本[%d]", header.Version))
+		header = nil
+	}
+	return
+}
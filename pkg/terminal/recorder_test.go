@@ -0,0 +1,54 @@
+package terminal
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.cast")
+	recorder, err := NewRecorder(path, &RecordHeader{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := []byte("中文")
+	// 在多字节字符中间截断
+	recorder.Output(text[:2])
+	recorder.Output(text[2:])
+	recorder.Resize(120, 40)
+	recorder.Close()
+
+	header, err := ReadRecordHeader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Width != 80 || header.Height != 24 || header.Timestamp == 0 {
+		t.Fatalf("bad header %+v", header)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	scanner := bufio.NewScanner(file)
+	var events [][]interface{}
+	for scanner.Scan() {
+		var event []interface{}
+		if json.Unmarshal(scanner.Bytes(), &event) == nil {
+			events = append(events, event)
+		}
+	}
+	if len(events) != 2 {
+		t.Fatalf("want 2 events, got %d", len(events))
+	}
+	if events[0][1] != RecordEventOutput || events[0][2] != "中文" {
+		t.Fatalf("bad output event %v", events[0])
+	}
+	if events[1][1] != RecordEventResize || events[1][2] != "120x40" {
+		t.Fatalf("bad resize event %v", events[1])
+	}
+}
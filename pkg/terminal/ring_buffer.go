@@ -0,0 +1,81 @@
+package terminal
+
+import (
+	"sync"
+	"unicode/utf8"
+)
+
+// NewRingBuffer 创建环形缓冲区，只保留最近写入的 size 个字节，用于终端回滚内容
+func NewRingBuffer(size int) *RingBuffer {
+	return &RingBuffer{
+		buf: make([]byte, size),
+	}
+}
+
+// RingBuffer 环形缓冲区
+type RingBuffer struct {
+	buf     []byte
+	start   int
+	length  int
+	wrapped bool
+	lock    sync.Mutex
+}
+
+// Write 写入数据，超出容量时覆盖最早的数据
+func (this_ *RingBuffer) Write(bs []byte) (n int, err error) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	n = len(bs)
+	size := len(this_.buf)
+	if size == 0 {
+		return
+	}
+	if len(bs) >= size {
+		copy(this_.buf, bs[len(bs)-size:])
+		this_.start = 0
+		this_.length = size
+		this_.wrapped = true
+		return
+	}
+	end := (this_.start + this_.length) % size
+	copied := copy(this_.buf[end:], bs)
+	copy(this_.buf, bs[copied:])
+	this_.length += len(bs)
+	if this_.length > size {
+		this_.start = (this_.start + this_.length - size) % size
+		this_.length = size
+		this_.wrapped = true
+	}
+	return
+}
+
+// Bytes 缓冲区中的数据，数据被覆盖过时跳过开头不完整的 UTF-8 字符
+func (this_ *RingBuffer) Bytes() (res []byte) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	res = make([]byte, this_.length)
+	end := this_.start + this_.length
+	if end > len(this_.buf) {
+		end = len(this_.buf)
+	}
+	copied := copy(res, this_.buf[this_.start:end])
+	copy(res[copied:], this_.buf[:this_.length-copied])
+	if this_.wrapped {
+		for i := 0; i < len(res) && i < utf8.UTFMax; i++ {
+			if utf8.RuneStart(res[i]) {
+				res = res[i:]
+				break
+			}
+		}
+	}
+	return
+}
+
+// Len 缓冲区中的数据长度
+func (this_ *RingBuffer) Len() int {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	return this_.length
+}
@@ -0,0 +1,29 @@
+package terminal
+
+import "testing"
+
+func TestRingBuffer(t *testing.T) {
+	buffer := NewRingBuffer(8)
+	_, _ = buffer.Write([]byte("abc"))
+	_, _ = buffer.Write([]byte("defg"))
+	if string(buffer.Bytes()) != "abcdefg" {
+		t.Fatalf("got %q", buffer.Bytes())
+	}
+	_, _ = buffer.Write([]byte("hij"))
+	if string(buffer.Bytes()) != "cdefghij" {
+		t.Fatalf("got %q", buffer.Bytes())
+	}
+	_, _ = buffer.Write([]byte("0123456789"))
+	if string(buffer.Bytes()) != "23456789" {
+		t.Fatalf("got %q", buffer.Bytes())
+	}
+	_, _ = buffer.Write([]byte("中文"))
+	if string(buffer.Bytes()) != "89中文" {
+		t.Fatalf("got %q", buffer.Bytes())
+	}
+	// 覆盖后开头不完整的字符被跳过
+	_, _ = buffer.Write([]byte("abc"))
+	if string(buffer.Bytes()) != "文abc" {
+		t.Fatalf("got %q", buffer.Bytes())
+	}
+}
@@ -0,0 +1,276 @@
+package terminal
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// triggerMaxLine 未换行的内容超过该长度时按一行处理
+	triggerMaxLine = 4 * 1024
+	// TriggerDefaultCooldown 同一个触发器两次动作的默认最小间隔
+	TriggerDefaultCooldown = 5 * time.Second
+)
+
+// triggerColors 高亮颜色对应的 SGR 参数
+var triggerColors = map[string]string{
+	"red":     "1;31",
+	"green":   "1;32",
+	"yellow":  "1;33",
+	"blue":    "1;34",
+	"magenta": "1;35",
+	"cyan":    "1;36",
+	"inverse": "7",
+}
+
+// OutputTrigger 输出触发器，Pattern 为正则表达式，按行匹配去除控制字符后的输出
+type OutputTrigger struct {
+	Name    string
+	Pattern string
+	// Highlight 高亮颜色，为空时不高亮
+	Highlight string
+	// Notify 命中后发送通知
+	Notify bool
+	// SendText 命中后发送到终端的内容，支持 \n、\r、\t 转义
+	SendText string
+	// Cooldown 两次通知、发送内容的最小间隔，小于等于 0 时使用默认值
+	Cooldown time.Duration
+}
+
+// TriggerMatch 命中的触发器，Suppressed 为上次动作后因间隔限制未执行动作的命中次数
+type TriggerMatch struct {
+	Name       string
+	Line       string
+	Text       string
+	Notify     bool
+	SendText   string
+	Suppressed int
+}
+
+type compiledTrigger struct {
+	*OutputTrigger
+	re         *regexp.Regexp
+	color      string
+	sendText   string
+	lastFire   time.Time
+	suppressed int
+}
+
+// TriggerEngine 对终端输出执行触发器
+type TriggerEngine struct {
+	triggers []*compiledTrigger
+	// highlight 需要高亮的触发器合并后的正则
+	highlight *regexp.Regexp
+	colors    []string
+	// groups 每个高亮规则在合并后的正则中的分组序号
+	groups []int
+
+	line      []rune
+	lineFired map[int]bool
+	escape    int
+	lock      sync.Mutex
+}
+
+// UnescapeSendText 处理发送内容中的 \n、\r、\t 转义
+func UnescapeSendText(text string) string {
+	return strings.NewReplacer(`\r`, "\r", `\n`, "\n", `\t`, "\t", `\\`, `\`).Replace(text)
+}
+
+// NewTriggerEngine 编译触发器，没有触发器时返回 nil
+func NewTriggerEngine(triggers ...*OutputTrigger) (engine *TriggerEngine, err error) {
+	if len(triggers) == 0 {
+		return
+	}
+	engine = &TriggerEngine{
+		lineFired: map[int]bool{},
+	}
+	var highlights []string
+	group := 1
+	for _, trigger := range triggers {
+		one := &compiledTrigger{
+			OutputTrigger: trigger,
+			sendText:      UnescapeSendText(trigger.SendText),
+		}
+		if strings.TrimSpace(trigger.Pattern) == "" {
+			err = errors.New("触发器[" + trigger.Name + "]规则不能为空")
+			return
+		}
+		if one.re, err = regexp.Compile(trigger.Pattern); err != nil {
+			err = errors.New("触发器[" + trigger.Name + "]规则[" + trigger.Pattern + "]错误:" + err.Error())
+			return
+		}
+		if trigger.Highlight != "" {
+			var ok bool
+			if one.color, ok = triggerColors[trigger.Highlight]; !ok {
+				err = errors.New("触发器[" + trigger.Name + "]高亮颜色[" + trigger.Highlight + "]不支持")
+				return
+			}
+			highlights = append(highlights, "("+trigger.Pattern+")")
+			engine.colors = append(engine.colors, one.color)
+			engine.groups = append(engine.groups, group)
+			group += 1 + one.re.NumSubexp()
+		}
+		if one.Cooldown <= 0 {
+			one.Cooldown = TriggerDefaultCooldown
+		}
+		engine.triggers = append(engine.triggers, one)
+	}
+	if len(highlights) > 0 {
+		engine.highlight, err = regexp.Compile(strings.Join(highlights, "|"))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Feed 处理一段输出，返回高亮后的输出和需要执行动作的触发器
+// 完整的行和当前未结束的行都会匹配，同一行中每个触发器只执行一次
+func (this_ *TriggerEngine) Feed(bs []byte, now time.Time) (out []byte, matches []*TriggerMatch) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	// 高亮时从本段输出开始时的控制序列状态解析，上一段输出中未结束的控制序列不会被匹配
+	escape := this_.escape
+	for _, r := range string(bs) {
+		if !plain(&this_.escape, r) {
+			continue
+		}
+		if r == '\n' || r == '\r' || len(this_.line) >= triggerMaxLine {
+			if len(this_.line) > 0 {
+				matches = append(matches, this_.matchLine(now)...)
+			}
+			this_.line = this_.line[:0]
+			this_.lineFired = map[int]bool{}
+			if r == '\n' || r == '\r' {
+				continue
+			}
+		}
+		this_.line = append(this_.line, r)
+	}
+	if len(this_.line) > 0 {
+		matches = append(matches, this_.matchLine(now)...)
+	}
+	out = this_.highlightOutput(bs, escape)
+	return
+}
+
+// plain 跳过控制序列，escape 为控制序列解析状态，返回是否为可见字符或换行
+func plain(escape *int, r rune) bool {
+	switch *escape {
+	case escapeStart:
+		switch r {
+		case '[':
+			*escape = escapeCSI
+		case ']':
+			*escape = escapeOSC
+		case '(', ')':
+			*escape = escapeCharset
+		default:
+			*escape = escapeNone
+		}
+		return false
+	case escapeCSI:
+		if r >= 0x40 && r <= 0x7e {
+			*escape = escapeNone
+		}
+		return false
+	case escapeOSC:
+		if r == '\a' || r == '\\' {
+			*escape = escapeNone
+		}
+		return false
+	case escapeCharset:
+		*escape = escapeNone
+		return false
+	}
+	if r == 0x1b {
+		*escape = escapeStart
+		return false
+	}
+	return r == '\n' || r == '\r' || r == '\t' || r >= 0x20 && r != 0x7f
+}
+
+func (this_ *TriggerEngine) matchLine(now time.Time) (matches []*TriggerMatch) {
+	line := string(this_.line)
+	for i, trigger := range this_.triggers {
+		if this_.lineFired[i] || (!trigger.Notify && trigger.sendText == "") {
+			continue
+		}
+		text := trigger.re.FindString(line)
+		if text == "" && !trigger.re.MatchString(line) {
+			continue
+		}
+		this_.lineFired[i] = true
+		if now.Sub(trigger.lastFire) < trigger.Cooldown {
+			trigger.suppressed++
+			continue
+		}
+		matches = append(matches, &TriggerMatch{
+			Name:       trigger.Name,
+			Line:       line,
+			Text:       text,
+			Notify:     trigger.Notify,
+			SendText:   trigger.sendText,
+			Suppressed: trigger.suppressed,
+		})
+		trigger.lastFire = now
+		trigger.suppressed = 0
+	}
+	return
+}
+
+// highlightOutput 为命中高亮规则的内容添加颜色，escape 为本段输出开始时的控制序列状态
+// 只在控制序列之间的可见内容中匹配，被控制序列分隔的内容不会命中
+func (this_ *TriggerEngine) highlightOutput(bs []byte, escape int) (out []byte) {
+	if this_.highlight == nil {
+		return bs
+	}
+	var last int
+	start := -1
+	for i, r := range string(bs) {
+		if plain(&escape, r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			out, last = this_.highlightSpan(out, bs, last, start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		out, last = this_.highlightSpan(out, bs, last, start, len(bs))
+	}
+	if last == 0 {
+		return bs
+	}
+	out = append(out, bs[last:]...)
+	return
+}
+
+// highlightSpan 匹配 bs[start:end] 中的可见内容，last 为已写入 out 的原始输出位置
+func (this_ *TriggerEngine) highlightSpan(out []byte, bs []byte, last int, start int, end int) ([]byte, int) {
+	for _, index := range this_.highlight.FindAllSubmatchIndex(bs[start:end], -1) {
+		if index[0] == index[1] {
+			continue
+		}
+		color := ""
+		for i, group := range this_.groups {
+			if index[group*2] >= 0 {
+				color = this_.colors[i]
+				break
+			}
+		}
+		out = append(out, bs[last:start+index[0]]...)
+		out = append(out, "\x1b["+color+"m"...)
+		out = append(out, bs[start+index[0]:start+index[1]]...)
+		out = append(out, "\x1b[0m"...)
+		last = start + index[1]
+	}
+	return out, last
+}
@@ -0,0 +1,59 @@
+package terminal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTriggerEngine(t *testing.T) {
+	engine, err := NewTriggerEngine(
+		&OutputTrigger{Name: "error", Pattern: `(E)RROR`, Highlight: "red", Notify: true},
+		&OutputTrigger{Name: "warn", Pattern: `WARN`, Highlight: "yellow"},
+		&OutputTrigger{Name: "done", Pattern: `BUILD (SUCCESS|FAILURE)`, Notify: true, SendText: `exit\n`},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	out, matches := engine.Feed([]byte("WARN a\r\nERROR b"), now)
+	if string(out) != "\x1b[1;33mWARN\x1b[0m a\r\n\x1b[1;31mERROR\x1b[0m b" {
+		t.Fatalf("%q", out)
+	}
+	if len(matches) != 1 || matches[0].Name != "error" || matches[0].Line != "ERROR b" {
+		t.Fatal(matches)
+	}
+	// 同一行继续输出不重复触发，间隔内的其它行只记录次数
+	_, matches = engine.Feed([]byte(" c\nERROR d\n"), now.Add(time.Second))
+	if len(matches) != 0 {
+		t.Fatal(matches)
+	}
+	_, matches = engine.Feed([]byte("ERROR e\n"), now.Add(10*time.Second))
+	if len(matches) != 1 || matches[0].Suppressed != 1 {
+		t.Fatal(matches)
+	}
+
+	// 控制序列分隔的输出按去除控制序列后的内容匹配
+	_, matches = engine.Feed([]byte("BUILD \x1b[32mSUCC"), now)
+	if len(matches) != 0 {
+		t.Fatal(matches)
+	}
+	_, matches = engine.Feed([]byte("ESS\x1b[0m\n"), now)
+	if len(matches) != 1 || matches[0].Text != "BUILD SUCCESS" || matches[0].SendText != "exit\n" {
+		t.Fatal(matches)
+	}
+
+	// 控制序列中的内容不高亮，包括上一段输出中未结束的控制序列
+	out, _ = engine.Feed([]byte("\x1b]0;WARN\aWARN \x1b]0;"), now)
+	if string(out) != "\x1b]0;WARN\a\x1b[1;33mWARN\x1b[0m \x1b]0;" {
+		t.Fatalf("%q", out)
+	}
+	out, _ = engine.Feed([]byte("WARN\aWARN\n"), now)
+	if string(out) != "WARN\a\x1b[1;33mWARN\x1b[0m\n" {
+		t.Fatalf("%q", out)
+	}
+
+	if _, err = NewTriggerEngine(&OutputTrigger{Name: "bad", Pattern: "("}); err == nil {
+		t.Fatal("should error")
+	}
+}
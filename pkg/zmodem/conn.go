@@ -0,0 +1,172 @@
+package zmodem
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrClosed    = errors.New("传输已关闭")
+	ErrTimeout   = errors.New("传输等待超时")
+	ErrCancelled = errors.New("传输已取消")
+)
+
+// Conn 终端上的文件传输会话，Feed 写入远程的输出，Write 写入远程的输入
+type Conn struct {
+	in        chan []byte
+	buf       []byte
+	write     func(bs []byte) (err error)
+	timeout   time.Duration
+	done      chan struct{}
+	closeOnce sync.Once
+	lock      sync.Mutex
+}
+
+// NewConn timeout 为等待远程输出的超时时间
+func NewConn(write func(bs []byte) (err error), timeout time.Duration) *Conn {
+	return &Conn{
+		in:      make(chan []byte, 64),
+		write:   write,
+		timeout: timeout,
+		done:    make(chan struct{}),
+	}
+}
+
+// Feed 远程的输出交给传输会话处理，会话已关闭时返回 false，由调用方按普通输出处理
+func (this_ *Conn) Feed(bs []byte) bool {
+	if len(bs) == 0 {
+		return true
+	}
+	data := make([]byte, len(bs))
+	copy(data, bs)
+	select {
+	case <-this_.done:
+		return false
+	default:
+	}
+	select {
+	case this_.in <- data:
+		return true
+	case <-this_.done:
+		return false
+	}
+}
+
+func (this_ *Conn) Write(bs []byte) (err error) {
+	select {
+	case <-this_.done:
+		return ErrClosed
+	default:
+	}
+	return this_.write(bs)
+}
+
+func (this_ *Conn) Close() {
+	this_.closeOnce.Do(func() {
+		close(this_.done)
+	})
+}
+
+func (this_ *Conn) IsClosed() bool {
+	select {
+	case <-this_.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Remaining 会话结束后未处理的远程输出，取出后清空
+func (this_ *Conn) Remaining() (bs []byte) {
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+
+	bs = this_.buf
+	this_.buf = nil
+	for {
+		select {
+		case data := <-this_.in:
+			bs = append(bs, data...)
+		default:
+			return
+		}
+	}
+}
+
+// Drain 丢弃已收到的远程输出
+func (this_ *Conn) Drain() {
+	_ = this_.Remaining()
+}
+
+func (this_ *Conn) fill(timeout time.Duration) (err error) {
+	this_.lock.Lock()
+	has := len(this_.buf) > 0
+	this_.lock.Unlock()
+	if has {
+		return
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case data := <-this_.in:
+		this_.lock.Lock()
+		this_.buf = append(this_.buf, data...)
+		this_.lock.Unlock()
+	case <-this_.done:
+		err = ErrClosed
+	case <-timer.C:
+		err = ErrTimeout
+	}
+	return
+}
+
+func (this_ *Conn) readByteTimeout(timeout time.Duration) (b byte, err error) {
+	for {
+		err = this_.fill(timeout)
+		if err != nil {
+			return
+		}
+		this_.lock.Lock()
+		if len(this_.buf) > 0 {
+			b = this_.buf[0]
+			this_.buf = this_.buf[1:]
+			this_.lock.Unlock()
+			return
+		}
+		this_.lock.Unlock()
+	}
+}
+
+// skipByte 在 timeout 内收到的下一个字节为 b 时丢弃，用于处理可选的结尾字符
+func (this_ *Conn) skipByte(b byte, timeout time.Duration) (skipped bool) {
+	if this_.fill(timeout) != nil {
+		return
+	}
+	this_.lock.Lock()
+	defer this_.lock.Unlock()
+	if len(this_.buf) > 0 && this_.buf[0] == b {
+		this_.buf = this_.buf[1:]
+		skipped = true
+	}
+	return
+}
+
+func (this_ *Conn) ReadByte() (b byte, err error) {
+	return this_.readByteTimeout(this_.timeout)
+}
+
+// ReadLine 读取一行，不包含换行符
+func (this_ *Conn) ReadLine() (line []byte, err error) {
+	var b byte
+	for {
+		b, err = this_.ReadByte()
+		if err != nil {
+			return
+		}
+		if b == '\n' {
+			return
+		}
+		line = append(line, b)
+	}
+}
@@ -0,0 +1,366 @@
+package zmodem
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+const (
+	zPad    = '*'
+	zDle    = 0x18
+	zBin    = 'A'
+	zHex    = 'B'
+	zBin32  = 'C'
+	xOn     = 0x11
+	xOff    = 0x13
+	canChar = 0x18
+
+	// 帧类型
+	ZRQINIT = 0
+	ZRINIT  = 1
+	ZSINIT  = 2
+	ZACK    = 3
+	ZFILE   = 4
+	ZSKIP   = 5
+	ZNAK    = 6
+	ZABORT  = 7
+	ZFIN    = 8
+	ZRPOS   = 9
+	ZDATA   = 10
+	ZEOF    = 11
+	ZFERR   = 12
+	ZCRC    = 13
+	ZCAN    = 16
+
+	// 数据子包结束类型
+	zCrcE = 'h'
+	zCrcG = 'i'
+	zCrcQ = 'j'
+	zCrcW = 'k'
+	zRub0 = 'l'
+	zRub1 = 'm'
+
+	// ZRINIT 标识
+	canFdx  = 0x01
+	canOvio = 0x02
+	canFc32 = 0x20
+	escCtl  = 0x40
+)
+
+var (
+	errBadCrc    = errors.New("CRC校验失败")
+	errBadEscape = errors.New("转义字符错误")
+
+	// abortSequence 取消传输，8 个 CAN 后跟 10 个退格
+	abortSequence = []byte{canChar, canChar, canChar, canChar, canChar, canChar, canChar, canChar, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8}
+)
+
+// Header 帧头，Data 为 ZP0~ZP3，位置按小端存储，ZF0 为 Data[3]
+type Header struct {
+	Type   byte
+	Data   [4]byte
+	format byte
+}
+
+func newPosHeader(frameType byte, pos uint32) *Header {
+	res := &Header{Type: frameType}
+	binary.LittleEndian.PutUint32(res.Data[:], pos)
+	return res
+}
+
+func (this_ *Header) Position() uint32 {
+	return binary.LittleEndian.Uint32(this_.Data[:])
+}
+
+func (this_ *Header) String() string {
+	return fmt.Sprintf("header[type:%d data:%v]", this_.Type, this_.Data)
+}
+
+func crc16(bs []byte) (crc uint16) {
+	for _, b := range bs {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return
+}
+
+// encoder ZDLE 转义，ctl 为 true 时转义所有控制字符
+type encoder struct {
+	ctl  bool
+	last byte
+	out  []byte
+}
+
+func (this_ *encoder) writeByte(b byte) {
+	switch {
+	case b == zDle || b == 0x10 || b == 0x90 || b == xOn || b == 0x91 || b == xOff || b == 0x93,
+		this_.ctl && b&0x60 == 0,
+		(b == 0x0d || b == 0x8d) && this_.last&0x7f == '@':
+		this_.out = append(this_.out, zDle, b^0x40)
+	default:
+		this_.out = append(this_.out, b)
+	}
+	this_.last = b
+}
+
+func (this_ *encoder) write(bs []byte) {
+	for _, b := range bs {
+		this_.writeByte(b)
+	}
+}
+
+// encodeHexHeader 十六进制帧头，除 ZFIN、ZACK 外以 XON 结尾
+func encodeHexHeader(header *Header) (bs []byte) {
+	data := append([]byte{header.Type}, header.Data[:]...)
+	crc := crc16(data)
+	data = append(data, byte(crc>>8), byte(crc))
+	bs = append(bs, zPad, zPad, zDle, zHex)
+	bs = append(bs, hex.EncodeToString(data)...)
+	bs = append(bs, '\r', 0x8a)
+	if header.Type != ZFIN && header.Type != ZACK {
+		bs = append(bs, xOn)
+	}
+	return
+}
+
+// encodeBinHeader 二进制帧头，使用 CRC16
+func encodeBinHeader(header *Header, ctl bool) (bs []byte) {
+	data := append([]byte{header.Type}, header.Data[:]...)
+	crc := crc16(data)
+	e := &encoder{ctl: ctl}
+	e.out = append(e.out, zPad, zDle, zBin)
+	e.write(data)
+	e.write([]byte{byte(crc >> 8), byte(crc)})
+	return e.out
+}
+
+// encodeSubpacket 数据子包，使用 CRC16，ZCRCW 结尾时追加 XON
+func encodeSubpacket(data []byte, end byte, ctl bool) (bs []byte) {
+	crc := crc16(append(append([]byte{}, data...), end))
+	e := &encoder{ctl: ctl}
+	e.write(data)
+	e.out = append(e.out, zDle, end)
+	e.write([]byte{byte(crc >> 8), byte(crc)})
+	if end == zCrcW {
+		e.out = append(e.out, xOn)
+	}
+	return e.out
+}
+
+// readEscaped 读取一个转义后的字节，isEnd 为 true 时 b 为子包结束类型
+func readEscaped(conn *Conn) (b byte, isEnd bool, err error) {
+	for {
+		b, err = conn.ReadByte()
+		if err != nil {
+			return
+		}
+		if b == zDle {
+			break
+		}
+		// 未转义的流控字符忽略
+		if b&0x7f == xOn || b&0x7f == xOff {
+			continue
+		}
+		return
+	}
+	for cans := 1; ; cans++ {
+		b, err = conn.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case zDle:
+			if cans >= 4 {
+				err = ErrCancelled
+				return
+			}
+			continue
+		case zCrcE, zCrcG, zCrcQ, zCrcW:
+			isEnd = true
+			return
+		case zRub0:
+			b = 0x7f
+			return
+		case zRub1:
+			b = 0xff
+			return
+		case xOn, xOff, xOn | 0x80, xOff | 0x80:
+			continue
+		}
+		if b&0x60 == 0x40 {
+			b ^= 0x40
+			return
+		}
+		err = errBadEscape
+		return
+	}
+}
+
+func readEscapedBytes(conn *Conn, n int) (bs []byte, err error) {
+	for i := 0; i < n; i++ {
+		var b byte
+		var isEnd bool
+		b, isEnd, err = readEscaped(conn)
+		if err != nil {
+			return
+		}
+		if isEnd {
+			err = errBadEscape
+			return
+		}
+		bs = append(bs, b)
+	}
+	return
+}
+
+// readHeader 跳过无关的输出，读取下一个帧头，连续 5 个 CAN 视为取消
+func readHeader(conn *Conn) (header *Header, err error) {
+	var cans int
+	var b byte
+	for {
+		b, err = conn.ReadByte()
+		if err != nil {
+			return
+		}
+		if b == canChar {
+			cans++
+			if cans >= 5 {
+				err = ErrCancelled
+				return
+			}
+			continue
+		}
+		cans = 0
+		if b != zPad {
+			continue
+		}
+		for b == zPad {
+			b, err = conn.ReadByte()
+			if err != nil {
+				return
+			}
+		}
+		if b != zDle {
+			continue
+		}
+		b, err = conn.ReadByte()
+		if err != nil {
+			return
+		}
+		switch b {
+		case zHex:
+			return readHexHeader(conn)
+		case zBin:
+			return readBinHeader(conn)
+		case zBin32:
+			return readBin32Header(conn)
+		}
+	}
+}
+
+func readHexHeader(conn *Conn) (header *Header, err error) {
+	var text []byte
+	for len(text) < 14 {
+		var b byte
+		b, err = conn.ReadByte()
+		if err != nil {
+			return
+		}
+		text = append(text, b)
+	}
+	data, err := hex.DecodeString(string(text))
+	if err != nil {
+		return
+	}
+	if crc16(data[:5]) != uint16(data[5])<<8|uint16(data[6]) {
+		err = errBadCrc
+		return
+	}
+	header = &Header{Type: data[0], format: zHex}
+	copy(header.Data[:], data[1:5])
+
+	// 结尾的 CR LF，除 ZFIN、ZACK 外还有 XON
+	for i := 0; i < 2; i++ {
+		if _, err = conn.ReadByte(); err != nil {
+			return
+		}
+	}
+	if header.Type != ZFIN && header.Type != ZACK {
+		conn.skipByte(xOn, readOptionalTimeout)
+	}
+	return
+}
+
+func readBinHeader(conn *Conn) (header *Header, err error) {
+	data, err := readEscapedBytes(conn, 7)
+	if err != nil {
+		return
+	}
+	if crc16(data[:5]) != uint16(data[5])<<8|uint16(data[6]) {
+		err = errBadCrc
+		return
+	}
+	header = &Header{Type: data[0], format: zBin}
+	copy(header.Data[:], data[1:5])
+	return
+}
+
+func readBin32Header(conn *Conn) (header *Header, err error) {
+	data, err := readEscapedBytes(conn, 9)
+	if err != nil {
+		return
+	}
+	if crc32.ChecksumIEEE(data[:5]) != binary.LittleEndian.Uint32(data[5:]) {
+		err = errBadCrc
+		return
+	}
+	header = &Header{Type: data[0], format: zBin32}
+	copy(header.Data[:], data[1:5])
+	return
+}
+
+// readSubpacket 读取数据子包，CRC 类型和前一个帧头一致
+func readSubpacket(conn *Conn, use32 bool) (data []byte, end byte, err error) {
+	for {
+		var b byte
+		var isEnd bool
+		b, isEnd, err = readEscaped(conn)
+		if err != nil {
+			return
+		}
+		if !isEnd {
+			data = append(data, b)
+			continue
+		}
+		end = b
+		break
+	}
+	if use32 {
+		var crc []byte
+		crc, err = readEscapedBytes(conn, 4)
+		if err != nil {
+			return
+		}
+		if crc32.ChecksumIEEE(append(append([]byte{}, data...), end)) != binary.LittleEndian.Uint32(crc) {
+			err = errBadCrc
+		}
+		return
+	}
+	crc, err := readEscapedBytes(conn, 2)
+	if err != nil {
+		return
+	}
+	if crc16(append(append([]byte{}, data...), end)) != uint16(crc[0])<<8|uint16(crc[1]) {
+		err = errBadCrc
+	}
+	return
+}
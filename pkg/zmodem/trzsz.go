@@ -0,0 +1,356 @@
+package zmodem
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	trzszVersion = "1.1.5"
+	// trzszBufSize 未配置时每次发送的数据大小
+	trzszBufSize = 10 * 1024
+)
+
+// trzszMagicRegexp 远程执行 trz、tsz 时输出的标识，R 为上传到远程，S 为从远程下载，D 为上传目录
+var trzszMagicRegexp = regexp.MustCompile(`::TRZSZ:TRANSFER:([RSD]):(\d+\.\d+\.\d+)(:\d+)?`)
+
+var trzszMagicPrefix = []byte("::TRZSZ:TRANSFER:")
+
+// DetectTrzsz 在终端输出中查找 trzsz 的标识，上传目录不支持，按上传处理后由 TrzszSend 拒绝
+func DetectTrzsz(bs []byte) (index int, mode Mode, isDir bool) {
+	if !bytes.Contains(bs, trzszMagicPrefix) {
+		return -1, ModeNone, false
+	}
+	match := trzszMagicRegexp.FindSubmatchIndex(bs)
+	if match == nil {
+		return -1, ModeNone, false
+	}
+	index = match[0]
+	switch bs[match[2]] {
+	case 'S':
+		mode = ModeDownload
+	case 'D':
+		mode = ModeUpload
+		isDir = true
+	default:
+		mode = ModeUpload
+	}
+	return
+}
+
+type trzsz struct {
+	conn    *Conn
+	bufSize int
+}
+
+func encodeTrzszBytes(bs []byte) string {
+	buf := &bytes.Buffer{}
+	z := zlib.NewWriter(buf)
+	_, _ = z.Write(bs)
+	_ = z.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func decodeTrzszBytes(str string) (bs []byte, err error) {
+	data, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return
+	}
+	z, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	defer func() { _ = z.Close() }()
+	return io.ReadAll(z)
+}
+
+func (this_ *trzsz) sendLine(typ string, value string) (err error) {
+	return this_.conn.Write([]byte("#" + typ + ":" + value + "\n"))
+}
+
+func (this_ *trzsz) sendInteger(typ string, value int64) (err error) {
+	return this_.sendLine(typ, strconv.FormatInt(value, 10))
+}
+
+func (this_ *trzsz) sendBytes(typ string, value []byte) (err error) {
+	return this_.sendLine(typ, encodeTrzszBytes(value))
+}
+
+// recvLine 读取远程发送的 #typ:value 行，远程失败时返回远程的错误信息
+func (this_ *trzsz) recvLine(typ string) (value string, err error) {
+	for {
+		var line []byte
+		line, err = this_.conn.ReadLine()
+		if err != nil {
+			return
+		}
+		str := strings.TrimRight(string(line), "\r")
+		if index := strings.LastIndex(str, "#"+typ+":"); index >= 0 {
+			value = str[index+len(typ)+2:]
+			return
+		}
+		for _, failType := range []string{"FAIL", "fail"} {
+			if index := strings.LastIndex(str, "#"+failType+":"); index >= 0 {
+				msg, e := decodeTrzszBytes(str[index+len(failType)+2:])
+				if e != nil {
+					msg = []byte(str[index+len(failType)+2:])
+				}
+				err = errors.New(string(msg))
+				return
+			}
+		}
+	}
+}
+
+func (this_ *trzsz) recvInteger(typ string) (value int64, err error) {
+	str, err := this_.recvLine(typ)
+	if err != nil {
+		return
+	}
+	return strconv.ParseInt(str, 10, 64)
+}
+
+func (this_ *trzsz) recvBytes(typ string) (value []byte, err error) {
+	str, err := this_.recvLine(typ)
+	if err != nil {
+		return
+	}
+	return decodeTrzszBytes(str)
+}
+
+func (this_ *trzsz) checkInteger(expect int64) (err error) {
+	value, err := this_.recvInteger("SUCC")
+	if err != nil {
+		return
+	}
+	if value != expect {
+		err = fmt.Errorf("校验失败，期望[%d]实际[%d]", expect, value)
+	}
+	return
+}
+
+func (this_ *trzsz) checkBytes(expect []byte) (err error) {
+	value, err := this_.recvBytes("SUCC")
+	if err != nil {
+		return
+	}
+	if !bytes.Equal(value, expect) {
+		err = errors.New("校验失败")
+	}
+	return
+}
+
+// start 跳过标识行，发送确认信息，不支持二进制和目录模式，读取远程的配置
+func (this_ *trzsz) start(confirm bool) (err error) {
+	if _, err = this_.conn.ReadLine(); err != nil {
+		return
+	}
+	action, _ := json.Marshal(map[string]interface{}{
+		"lang":        "go",
+		"confirm":     confirm,
+		"version":     trzszVersion,
+		"support_dir": false,
+		"binary":      false,
+	})
+	err = this_.sendBytes("ACT", action)
+	if err != nil || !confirm {
+		return
+	}
+	cfg, err := this_.recvBytes("CFG")
+	if err != nil {
+		return
+	}
+	config := map[string]interface{}{}
+	_ = json.Unmarshal(cfg, &config)
+	this_.bufSize = trzszBufSize
+	if bufSize, ok := config["bufsize"].(float64); ok && bufSize > 0 {
+		this_.bufSize = int(bufSize)
+	}
+	return
+}
+
+// exit 结束传输，远程输出 msg
+func (this_ *trzsz) exit(err error, msg string) {
+	if err != nil {
+		if err != ErrClosed && err != ErrCancelled {
+			_ = this_.sendBytes("FAIL", []byte(err.Error()))
+		}
+		return
+	}
+	_ = this_.sendBytes("EXIT", []byte(msg))
+}
+
+// TrzszCancel 拒绝远程的 trz、tsz 请求
+func TrzszCancel(conn *Conn) (err error) {
+	t := &trzsz{conn: conn}
+	return t.start(false)
+}
+
+// TrzszAbort 传输过程中通知远程取消，会话关闭后也会发送
+func TrzszAbort(conn *Conn) {
+	_ = conn.write([]byte("#FAIL:" + encodeTrzszBytes([]byte(ErrCancelled.Error())) + "\n"))
+}
+
+// TrzszSend 向远程的 trz 发送文件
+func TrzszSend(conn *Conn, files []*SendFile, onProgress func(progress *Progress)) (err error) {
+	t := &trzsz{conn: conn}
+	defer func() { t.exit(err, fmt.Sprintf("Received %d file(s)", len(files))) }()
+
+	if err = t.start(true); err != nil {
+		return
+	}
+	if err = t.sendInteger("NUM", int64(len(files))); err != nil {
+		return
+	}
+	if err = t.checkInteger(int64(len(files))); err != nil {
+		return
+	}
+	buf := make([]byte, t.bufSize)
+	for _, file := range files {
+		if err = t.sendBytes("NAME", []byte(file.Name)); err != nil {
+			return
+		}
+		if _, err = t.recvBytes("SUCC"); err != nil {
+			return
+		}
+		if err = t.sendInteger("SIZE", file.Size); err != nil {
+			return
+		}
+		if err = t.checkInteger(file.Size); err != nil {
+			return
+		}
+		hash := md5.New()
+		var pos int64
+		for pos < file.Size {
+			var n int
+			n, err = file.Reader.ReadAt(buf, pos)
+			if err == io.EOF && n > 0 {
+				err = nil
+			}
+			if err != nil {
+				return
+			}
+			hash.Write(buf[:n])
+			if err = t.sendBytes("DATA", buf[:n]); err != nil {
+				return
+			}
+			if err = t.checkInteger(int64(n)); err != nil {
+				return
+			}
+			pos += int64(n)
+			progress(onProgress, file.Name, file.Size, pos, false)
+		}
+		digest := hash.Sum(nil)
+		if err = t.sendBytes("MD5", digest); err != nil {
+			return
+		}
+		if err = t.checkBytes(digest); err != nil {
+			return
+		}
+		progress(onProgress, file.Name, file.Size, pos, true)
+	}
+	return
+}
+
+// TrzszReceive 接收远程 tsz 发送的文件，onFile 返回 nil 时结束传输
+func TrzszReceive(conn *Conn, onFile func(info *FileInfo) (writer io.WriteCloser, err error), onProgress func(progress *Progress)) (err error) {
+	t := &trzsz{conn: conn}
+	var count int
+	defer func() { t.exit(err, fmt.Sprintf("Sent %d file(s)", count)) }()
+
+	if err = t.start(true); err != nil {
+		return
+	}
+	num, err := t.recvInteger("NUM")
+	if err != nil {
+		return
+	}
+	if err = t.sendInteger("SUCC", num); err != nil {
+		return
+	}
+	for ; int64(count) < num; count++ {
+		err = t.receiveFile(onFile, onProgress)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (this_ *trzsz) receiveFile(onFile func(info *FileInfo) (writer io.WriteCloser, err error), onProgress func(progress *Progress)) (err error) {
+	name, err := this_.recvBytes("NAME")
+	if err != nil {
+		return
+	}
+	info := &FileInfo{Name: string(name)}
+	writer, err := onFile(info)
+	if err != nil {
+		return
+	}
+	if writer == nil {
+		err = errors.New("文件[" + info.Name + "]无法保存")
+		return
+	}
+	defer func() { _ = writer.Close() }()
+	if err = this_.sendBytes("SUCC", name); err != nil {
+		return
+	}
+	if info.Size, err = this_.recvInteger("SIZE"); err != nil {
+		return
+	}
+	if err = this_.sendInteger("SUCC", info.Size); err != nil {
+		return
+	}
+	hash := md5.New()
+	var pos int64
+	for pos < info.Size {
+		var data []byte
+		data, err = this_.recvBytes("DATA")
+		if err != nil {
+			return
+		}
+		if _, err = writer.Write(data); err != nil {
+			return
+		}
+		hash.Write(data)
+		pos += int64(len(data))
+		if err = this_.sendInteger("SUCC", int64(len(data))); err != nil {
+			return
+		}
+		progress(onProgress, info.Name, info.Size, pos, false)
+	}
+	digest, err := this_.recvBytes("MD5")
+	if err != nil {
+		return
+	}
+	if !bytes.Equal(digest, hash.Sum(nil)) {
+		err = errors.New("文件[" + info.Name + "]MD5校验失败")
+		return
+	}
+	if err = this_.sendBytes("SUCC", digest); err != nil {
+		return
+	}
+	progress(onProgress, info.Name, info.Size, pos, true)
+	return
+}
+
+func progress(onProgress func(progress *Progress), name string, size int64, transferred int64, done bool) {
+	if onProgress == nil {
+		return
+	}
+	onProgress(&Progress{
+		Name:        name,
+		Size:        size,
+		Transferred: transferred,
+		Done:        done,
+	})
+}
@@ -0,0 +1,513 @@
+package zmodem
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// subpacketSize 发送时每个数据子包的大小
+	subpacketSize = 1024
+	// windowSize 发送多少数据后等待接收方确认
+	windowSize = 32 * 1024
+	// maxRetry 同一位置重试的最大次数
+	maxRetry = 10
+)
+
+var (
+	// readOptionalTimeout 读取可选结尾字符的等待时间
+	readOptionalTimeout = time.Millisecond * 200
+
+	// 远程 sz 发出的 ZRQINIT，远程 rz 发出的 ZRINIT，**\x18B00、**\x18B01
+	zrqinitPrefix = []byte{zPad, zPad, zDle, zHex, '0', '0'}
+	zrinitPrefix  = []byte{zPad, zPad, zDle, zHex, '0', '1'}
+)
+
+type Mode int
+
+const (
+	ModeNone Mode = iota
+	// ModeUpload 远程执行 rz，从服务端上传文件到远程
+	ModeUpload
+	// ModeDownload 远程执行 sz，从远程下载文件到服务端
+	ModeDownload
+)
+
+// Detect 在终端输出中查找 ZMODEM 的开始帧，返回开始帧的位置
+func Detect(bs []byte) (index int, mode Mode) {
+	if index = bytes.Index(bs, zrqinitPrefix); index >= 0 {
+		return index, ModeDownload
+	}
+	if index = bytes.Index(bs, zrinitPrefix); index >= 0 {
+		return index, ModeUpload
+	}
+	return -1, ModeNone
+}
+
+// Abort 向远程发送取消传输的序列
+func Abort(conn *Conn) {
+	_ = conn.write(abortSequence)
+}
+
+// FileInfo 传输的文件信息
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// SendFile 上传的文件，Reader 需要支持按位置读取，用于断点重传
+type SendFile struct {
+	FileInfo
+	Reader io.ReaderAt
+}
+
+// Progress 传输进度
+type Progress struct {
+	Name        string
+	Size        int64
+	Transferred int64
+	// Done 当前文件传输结束
+	Done bool
+}
+
+type sender struct {
+	conn       *Conn
+	ctl        bool
+	onProgress func(progress *Progress)
+}
+
+// Send 向远程的 rz 发送文件
+func Send(conn *Conn, files []*SendFile, onProgress func(progress *Progress)) (err error) {
+	s := &sender{
+		conn:       conn,
+		onProgress: onProgress,
+	}
+	defer func() {
+		if err != nil && err != ErrCancelled && err != ErrClosed {
+			Abort(conn)
+		}
+	}()
+
+	// 等待选择文件期间 rz 会重复发送 ZRINIT，丢弃后重新请求
+	conn.Drain()
+	err = s.writeHeader(encodeHexHeader(&Header{Type: ZRQINIT}))
+	if err != nil {
+		return
+	}
+	header, err := s.waitHeader(ZRINIT)
+	if err != nil {
+		return
+	}
+	s.ctl = header.Data[3]&escCtl != 0
+
+	for i, file := range files {
+		err = s.sendFile(file, len(files)-i)
+		if err != nil {
+			return
+		}
+	}
+	return s.finish()
+}
+
+func (this_ *sender) writeHeader(bs []byte) (err error) {
+	return this_.conn.Write(bs)
+}
+
+// waitHeader 等待指定类型的帧头，忽略其它帧头
+func (this_ *sender) waitHeader(frameType byte) (header *Header, err error) {
+	for i := 0; i < maxRetry; i++ {
+		header, err = readHeader(this_.conn)
+		if err == errBadCrc || err == errBadEscape {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if header.Type == ZCAN || header.Type == ZABORT {
+			err = ErrCancelled
+			return
+		}
+		if header.Type == frameType {
+			return
+		}
+	}
+	err = fmt.Errorf("等待帧[%d]失败", frameType)
+	return
+}
+
+func (this_ *sender) sendFile(file *SendFile, filesLeft int) (err error) {
+	info := fmt.Sprintf("%d %o %o 0 %d %d", file.Size, file.ModTime.Unix(), 0644, filesLeft, file.Size)
+	data := append([]byte(file.Name), 0)
+	data = append(data, info...)
+	data = append(data, 0)
+
+	var pos uint32
+	var header *Header
+	for retry := 0; ; retry++ {
+		if retry >= maxRetry {
+			err = errors.New("发送文件[" + file.Name + "]信息失败")
+			return
+		}
+		err = this_.writeHeader(append(encodeBinHeader(&Header{Type: ZFILE}, this_.ctl), encodeSubpacket(data, zCrcW, this_.ctl)...))
+		if err != nil {
+			return
+		}
+		header, err = readHeader(this_.conn)
+		if err == errBadCrc || err == errBadEscape {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		switch header.Type {
+		case ZRPOS:
+			pos = header.Position()
+		case ZSKIP:
+			return
+		case ZCAN, ZABORT, ZFIN:
+			err = ErrCancelled
+			return
+		default:
+			// ZRINIT、ZNAK 等重新发送文件信息
+			continue
+		}
+		break
+	}
+	return this_.sendData(file, pos)
+}
+
+// sendData 从 pos 开始发送文件内容，每个窗口以 ZCRCW 结尾并等待确认，收到 ZRPOS 时从指定位置重传
+func (this_ *sender) sendData(file *SendFile, pos uint32) (err error) {
+	buf := make([]byte, subpacketSize)
+	var retry int
+	for {
+		if retry >= maxRetry {
+			err = errors.New("发送文件[" + file.Name + "]内容失败")
+			return
+		}
+		out := encodeBinHeader(newPosHeader(ZDATA, pos), this_.ctl)
+		var sent int
+		var eof bool
+		var n int
+		for {
+			n, err = file.Reader.ReadAt(buf, int64(pos)+int64(sent))
+			if err == io.EOF {
+				eof = true
+				err = nil
+			}
+			if err != nil {
+				return
+			}
+			sent += n
+			end := byte(zCrcG)
+			if eof || int64(pos)+int64(sent) >= file.Size {
+				eof = true
+				end = zCrcE
+			} else if sent >= windowSize {
+				end = zCrcW
+			}
+			out = append(out, encodeSubpacket(buf[:n], end, this_.ctl)...)
+			if end != zCrcG {
+				break
+			}
+		}
+		err = this_.conn.Write(out)
+		if err != nil {
+			return
+		}
+
+		var header *Header
+		if eof {
+			err = this_.writeHeader(encodeBinHeader(newPosHeader(ZEOF, pos+uint32(sent)), this_.ctl))
+			if err != nil {
+				return
+			}
+		}
+		header, err = readHeader(this_.conn)
+		if err == errBadCrc || err == errBadEscape {
+			retry++
+			continue
+		}
+		if err != nil {
+			return
+		}
+		switch header.Type {
+		case ZACK:
+			pos += uint32(sent)
+			retry = 0
+			this_.progress(file, int64(pos), false)
+		case ZRINIT:
+			if !eof {
+				retry++
+				continue
+			}
+			this_.progress(file, int64(pos)+int64(sent), true)
+			return
+		case ZRPOS:
+			pos = header.Position()
+			retry++
+		case ZSKIP:
+			return
+		case ZCAN, ZABORT, ZFIN:
+			err = ErrCancelled
+			return
+		default:
+			retry++
+		}
+	}
+}
+
+func (this_ *sender) progress(file *SendFile, transferred int64, done bool) {
+	if this_.onProgress == nil {
+		return
+	}
+	this_.onProgress(&Progress{
+		Name:        file.Name,
+		Size:        file.Size,
+		Transferred: transferred,
+		Done:        done,
+	})
+}
+
+// finish 发送 ZFIN，收到 ZFIN 后发送 OO 结束会话
+func (this_ *sender) finish() (err error) {
+	for i := 0; i < maxRetry; i++ {
+		err = this_.writeHeader(encodeHexHeader(&Header{Type: ZFIN}))
+		if err != nil {
+			return
+		}
+		var header *Header
+		header, err = readHeader(this_.conn)
+		if err == errBadCrc || err == errBadEscape {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if header.Type == ZFIN {
+			return this_.conn.Write([]byte("OO"))
+		}
+	}
+	err = errors.New("结束传输失败")
+	return
+}
+
+type receiver struct {
+	conn       *Conn
+	onFile     func(info *FileInfo) (writer io.WriteCloser, err error)
+	onProgress func(progress *Progress)
+	info       *FileInfo
+	writer     io.WriteCloser
+	pos        uint32
+}
+
+// Receive 接收远程 sz 发送的文件，onFile 返回 nil 时跳过该文件
+func Receive(conn *Conn, onFile func(info *FileInfo) (writer io.WriteCloser, err error), onProgress func(progress *Progress)) (err error) {
+	r := &receiver{
+		conn:       conn,
+		onFile:     onFile,
+		onProgress: onProgress,
+	}
+	defer func() {
+		r.closeFile()
+		if err != nil && err != ErrCancelled && err != ErrClosed {
+			Abort(conn)
+		}
+	}()
+
+	err = r.sendRInit()
+	if err != nil {
+		return
+	}
+	var errCount int
+	for {
+		if errCount >= maxRetry {
+			err = errors.New("接收文件失败")
+			return
+		}
+		var header *Header
+		header, err = readHeader(conn)
+		if err == errBadCrc || err == errBadEscape {
+			errCount++
+			err = r.sendPos()
+			if err != nil {
+				return
+			}
+			continue
+		}
+		if err != nil {
+			return
+		}
+		var finished bool
+		finished, err = r.onHeader(header)
+		if err == errBadCrc || err == errBadEscape {
+			errCount++
+			err = r.sendPos()
+			if err != nil {
+				return
+			}
+			continue
+		}
+		if err != nil || finished {
+			return
+		}
+		errCount = 0
+	}
+}
+
+func (this_ *receiver) sendRInit() (err error) {
+	header := &Header{Type: ZRINIT}
+	header.Data[3] = canFdx | canOvio | canFc32
+	return this_.conn.Write(encodeHexHeader(header))
+}
+
+func (this_ *receiver) sendPos() (err error) {
+	return this_.conn.Write(encodeHexHeader(newPosHeader(ZRPOS, this_.pos)))
+}
+
+func (this_ *receiver) closeFile() {
+	if this_.writer != nil {
+		_ = this_.writer.Close()
+		this_.writer = nil
+	}
+	this_.info = nil
+}
+
+func (this_ *receiver) onHeader(header *Header) (finished bool, err error) {
+	use32 := header.format == zBin32
+	switch header.Type {
+	case ZRQINIT:
+		err = this_.sendRInit()
+	case ZSINIT:
+		_, _, err = readSubpacket(this_.conn, use32)
+		if err != nil {
+			return
+		}
+		err = this_.conn.Write(encodeHexHeader(&Header{Type: ZACK}))
+	case ZFILE:
+		var data []byte
+		data, _, err = readSubpacket(this_.conn, use32)
+		if err != nil {
+			return
+		}
+		err = this_.startFile(data)
+	case ZDATA:
+		if this_.writer == nil {
+			err = this_.conn.Write(encodeHexHeader(&Header{Type: ZSKIP}))
+			return
+		}
+		if header.Position() != this_.pos {
+			err = this_.sendPos()
+			return
+		}
+		err = this_.readData(use32)
+	case ZEOF:
+		// 位置不一致的 ZEOF 忽略
+		if this_.writer == nil || header.Position() != this_.pos {
+			return
+		}
+		err = this_.writer.Close()
+		this_.writer = nil
+		if err != nil {
+			return
+		}
+		this_.progress(true)
+		this_.info = nil
+		err = this_.sendRInit()
+	case ZFIN:
+		err = this_.conn.Write(encodeHexHeader(&Header{Type: ZFIN}))
+		if err != nil {
+			return
+		}
+		// 发送方最后的 OO
+		if this_.conn.skipByte('O', readOptionalTimeout) {
+			this_.conn.skipByte('O', readOptionalTimeout)
+		}
+		finished = true
+	case ZCAN, ZABORT:
+		err = ErrCancelled
+	}
+	return
+}
+
+// startFile 解析文件信息：文件名\0大小 修改时间(八进制) 权限 ...\0
+func (this_ *receiver) startFile(data []byte) (err error) {
+	this_.closeFile()
+	parts := bytes.SplitN(data, []byte{0}, 3)
+	info := &FileInfo{
+		Name: string(parts[0]),
+	}
+	if len(parts) > 1 {
+		fields := strings.Fields(string(parts[1]))
+		if len(fields) > 0 {
+			info.Size, _ = strconv.ParseInt(fields[0], 10, 64)
+		}
+		if len(fields) > 1 {
+			if mtime, e := strconv.ParseInt(fields[1], 8, 64); e == nil && mtime > 0 {
+				info.ModTime = time.Unix(mtime, 0)
+			}
+		}
+	}
+	writer, err := this_.onFile(info)
+	if err != nil {
+		return
+	}
+	if writer == nil {
+		return this_.conn.Write(encodeHexHeader(&Header{Type: ZSKIP}))
+	}
+	this_.info = info
+	this_.writer = writer
+	this_.pos = 0
+	this_.progress(false)
+	return this_.sendPos()
+}
+
+// readData 读取 ZDATA 后的数据子包，直到帧结束
+func (this_ *receiver) readData(use32 bool) (err error) {
+	for {
+		var data []byte
+		var end byte
+		data, end, err = readSubpacket(this_.conn, use32)
+		if err != nil {
+			return
+		}
+		_, err = this_.writer.Write(data)
+		if err != nil {
+			return
+		}
+		this_.pos += uint32(len(data))
+		this_.progress(false)
+		switch end {
+		case zCrcG:
+			continue
+		case zCrcQ:
+			err = this_.conn.Write(encodeHexHeader(newPosHeader(ZACK, this_.pos)))
+			if err != nil {
+				return
+			}
+			continue
+		case zCrcW:
+			return this_.conn.Write(encodeHexHeader(newPosHeader(ZACK, this_.pos)))
+		default:
+			return
+		}
+	}
+}
+
+func (this_ *receiver) progress(done bool) {
+	if this_.onProgress == nil || this_.info == nil {
+		return
+	}
+	this_.onProgress(&Progress{
+		Name:        this_.info.Name,
+		Size:        this_.info.Size,
+		Transferred: int64(this_.pos),
+		Done:        done,
+	})
+}
@@ -0,0 +1,91 @@
+package zmodem
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestHexHeader(t *testing.T) {
+	header := &Header{Type: ZRINIT}
+	header.Data[3] = 0x23
+	if string(encodeHexHeader(header)) != "**\x18B0100000023be50\r\x8a\x11" {
+		t.Fatalf("%q", encodeHexHeader(header))
+	}
+	if string(encodeHexHeader(&Header{Type: ZFIN})) != "**\x18B0800000000022d\r\x8a" {
+		t.Fatalf("%q", encodeHexHeader(&Header{Type: ZFIN}))
+	}
+
+	index, mode := Detect([]byte("rz waiting to receive.**\x18B0100000023be50\r\x8a\x11"))
+	if index != 22 || mode != ModeUpload {
+		t.Fatal(index, mode)
+	}
+	index, mode = Detect([]byte("rz\r**\x18B00000000000000\r\x8a\x11"))
+	if index != 3 || mode != ModeDownload {
+		t.Fatal(index, mode)
+	}
+}
+
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (this_ *bufferCloser) Close() error {
+	return nil
+}
+
+func TestSendReceive(t *testing.T) {
+	var a, b *Conn
+	a = NewConn(func(bs []byte) (err error) {
+		b.Feed(bs)
+		return
+	}, time.Second*5)
+	b = NewConn(func(bs []byte) (err error) {
+		a.Feed(bs)
+		return
+	}, time.Second*5)
+
+	// 包含需要转义的字节，大小超过一个窗口
+	data := make([]byte, windowSize*2+100)
+	rand.New(rand.NewSource(1)).Read(data)
+	data[10] = zDle
+	data[11] = xOn
+
+	var received []*bufferCloser
+	receiveErr := make(chan error, 1)
+	go func() {
+		receiveErr <- Receive(b, func(info *FileInfo) (writer io.WriteCloser, err error) {
+			if info.Name != "test.bin" || info.Size != int64(len(data)) {
+				t.Error("file info error", info)
+			}
+			one := &bufferCloser{}
+			received = append(received, one)
+			return one, nil
+		}, nil)
+	}()
+	time.Sleep(time.Millisecond * 100)
+
+	var done bool
+	err := Send(a, []*SendFile{
+		{
+			FileInfo: FileInfo{Name: "test.bin", Size: int64(len(data)), ModTime: time.Now()},
+			Reader:   bytes.NewReader(data),
+		},
+	}, func(progress *Progress) {
+		done = progress.Done
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = <-receiveErr; err != nil {
+		t.Fatal(err)
+	}
+	if !done || len(received) != 1 || !bytes.Equal(received[0].Bytes(), data) {
+		t.Fatal("received data error")
+	}
+	if remaining := b.Remaining(); len(remaining) != 0 {
+		t.Fatalf("remaining %q", remaining)
+	}
+}